@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/internal/config"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/clipboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/cursor"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/dispatch"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/gamepad"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/pen"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/throttle"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/touch"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/benu-cloud/benu-webrtc/pkg/files"
+)
+
+// keys held by a peer that stopped sending input are released after it
+const inputTimeout = 10 * time.Second
+
+// how often the host clipboard is checked for changes
+const clipboardInterval = 500 * time.Millisecond
+
+// how often input dropped by the rate limit is logged
+const inputStatsInterval = time.Minute
+
+// the stream settings a restart changes, listed to admins changing the others
+var restartSettings = config.RestartSettings("video", "capture", "audio")
+
+// the stream and the controls of this host, see startHost
+type host struct {
+	// errors of the running pipeline
+	errs           <-chan error
+	permissions    *permissions.Permissions
+	updateSettings func(settings *config.StreamSettings) error
+	inputStats     *inputStats
+	stop           func() error
+}
+
+// pipeline is what the host needs from the stream, the stream package on windows, see host_windows.go
+type pipeline interface {
+	setup(settings *config.StreamSettings) (<-chan error, error)
+	start() error
+	stop() error
+	updateSettings(settings *config.StreamSettings) error
+	applySettingsUpdate(update types.StreamSettingsUpdate) error
+	setPeersHandler(handler peersHandler) error
+	setControlsHandler(handler controlsHandler) error
+	setFilesHandler(handler filesHandler) error
+	setCursorHandler(handler cursorHandler) error
+	sendControlsMessage(peerId string, message string) error
+	sendCursorMessage(peerId string, message string) error
+	filesChannel() files.Channel
+	// the settings after the updates
+	settings() (config.StreamSettings, error)
+}
+
+// as stream.PeersHandler
+type peersHandler interface {
+	OnPeerAdded(peerId string)
+}
+
+// as stream.ControlsHandler
+type controlsHandler interface {
+	OnControlsOpened(peerId string)
+	OnControlsMessage(peerId string, message string)
+	OnControlsClosed(peerId string)
+}
+
+// as stream.FilesHandler
+type filesHandler interface {
+	OnFilesOpened(peerId string)
+	OnFilesMessage(peerId string, message string)
+	OnFilesData(peerId string, data []byte)
+	OnFilesBufferedAmountLow(peerId string)
+	OnFilesClosed(peerId string)
+}
+
+// as stream.CursorHandler
+type cursorHandler interface {
+	OnCursorOpened(peerId string)
+	OnCursorClosed(peerId string)
+}
+
+// the devices of the host that peers control
+type devices struct {
+	keyboard keyboard.Keyboard
+	mouse    mouse.Mouse
+	// nil if the clipboard isn't synced
+	clipboard clipboard.Clipboard
+	// nil if the cursor isn't sent to peers, with -vclientcursor peers draw it
+	cursor cursor.Cursor
+	// creates the gamepad of an index, nil without gamepads
+	gamepad func(index int) (gamepad.Gamepad, error)
+	// nil if peers can't use them
+	touch touch.Touch
+	pen   pen.Pen
+}
+
+// implemented by touchscreens and pens scaling from the stream resolution, which changes with the settings
+type resizable interface {
+	SetResolution(width int, height int)
+}
+
+// peers registers the peers joining the stream with the dispatcher, which forgets them
+// when their controls datachannel closes
+type peers struct {
+	dispatcher *dispatch.Dispatcher
+	// the role peers join with
+	role permissions.Role
+	// nil if input isn't recorded
+	recordings *recordings
+}
+
+func (p peers) OnPeerAdded(peerId string) {
+	p.dispatcher.AddPeer(peerId, p.role)
+	if p.recordings != nil {
+		p.recordings.start(peerId)
+	}
+}
+
+// inputStats reports the input dropped by the rate limit and lost by the coalescer since the last report
+type inputStats struct {
+	// nil without a rate limit
+	limiter *throttle.Limiter
+	// nil without coalescing
+	coalescer *throttle.Coalescer
+	dropped   uint64
+	failed    uint64
+}
+
+// report is empty if nothing was dropped or lost since the last one
+func (s *inputStats) report() string {
+	var dropped, failed uint64
+	if s.limiter != nil {
+		dropped = s.limiter.Dropped() - s.dropped
+		s.dropped += dropped
+	}
+	if s.coalescer != nil {
+		failed = s.coalescer.Stats().Errors - s.failed
+		s.failed += failed
+	}
+	if dropped == 0 && failed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("controls: dropped %d input events over -controlsrate, %d merged mouse events failed", dropped, failed)
+}
+
+// log the reports every interval until stop is closed
+func (s *inputStats) log(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if r := s.report(); r != "" {
+				log.Print(r)
+			}
+		}
+	}
+}
+
+// captureArea is the part of the screen the capture target shows, in the coordinates of the cursor.
+// It is empty, the whole screen, for windows, which move
+func captureArea(c cursor.Cursor, target config.CaptureTarget) cursor.Area {
+	if target.Window != "" || target.Process != "" {
+		return cursor.Area{}
+	}
+	var area cursor.Area
+	if monitors, ok := c.(cursor.Monitors); ok {
+		monitor, err := monitors.MonitorArea(target.Monitor)
+		if err != nil {
+			log.Printf("cursor: %v", err)
+		} else {
+			area = monitor
+		}
+	}
+	if r := target.Region; r.Width > 0 && r.Height > 0 {
+		area = cursor.Area{X: area.X + r.X, Y: area.Y + r.Y, Width: r.Width, Height: r.Height}
+	}
+	return area
+}
+
+// startHost sets up the pipeline with the peers' input going to the devices, and starts it.
+// When this fails the pipeline is stopped again
+func startHost(cfg *config.Config, p pipeline, dev devices) (*host, error) {
+	sandbox, err := files.NewSandbox(cfg.Files.SandboxDir, int64(cfg.Files.Quota))
+	if err != nil {
+		return nil, err
+	}
+	errs, err := p.setup(&cfg.Stream)
+	if err != nil {
+		return nil, err
+	}
+	d := dispatch.NewDispatcher(dev.keyboard, dev.mouse, p.sendControlsMessage, inputTimeout,
+		dispatch.WithBlockedShortcuts(cfg.Controls.BlockedShortcuts),
+		dispatch.WithCoalescing(time.Duration(cfg.Controls.InputCoalesce)*time.Millisecond))
+	stats := &inputStats{coalescer: d.Coalescer()}
+	if cfg.Controls.InputRate > 0 {
+		stats.limiter = throttle.NewLimiter(float64(cfg.Controls.InputRate), int(cfg.Controls.InputBurst))
+		d.SetRateLimit(stats.limiter)
+	}
+	d.Permissions().SetClipboardRoles(cfg.Controls.ClipboardReadRoles...)
+	var watcher *cursor.Watcher
+	if cfg.Stream.VideoClientCursor && dev.cursor != nil {
+		res := cfg.Stream.VideoResolution
+		watcher = cursor.NewWatcher(dev.cursor, p.sendCursorMessage, res.Width, res.Height, cursor.DefaultInterval)
+		watcher.SetGeometry(res.Width, res.Height, captureArea(dev.cursor, cfg.Stream.Capture))
+	}
+	var resized []resizable
+	for _, device := range []interface{}{dev.touch, dev.pen} {
+		if r, ok := device.(resizable); ok {
+			resized = append(resized, r)
+		}
+	}
+	// the cursor, the touchscreen and the pen follow the resolution and the capture target to the updated settings
+	followSettings := func() {
+		if watcher == nil && len(resized) == 0 {
+			return
+		}
+		settings, err := p.settings()
+		if err != nil {
+			log.Printf("controls: %v", err)
+			return
+		}
+		res := settings.VideoResolution
+		if watcher != nil {
+			watcher.SetGeometry(res.Width, res.Height, captureArea(dev.cursor, settings.Capture))
+		}
+		for _, r := range resized {
+			r.SetResolution(res.Width, res.Height)
+		}
+	}
+	updateSettings := func(settings *config.StreamSettings) error {
+		defer followSettings()
+		return p.updateSettings(settings)
+	}
+	d.SetSettingsUpdater(func(update types.StreamSettingsUpdate) error {
+		defer followSettings()
+		return p.applySettingsUpdate(update)
+	}, restartSettings...)
+	if dev.touch != nil {
+		d.SetTouch(dev.touch)
+	}
+	if dev.pen != nil {
+		d.SetPen(dev.pen)
+	}
+	var gamepads *gamepad.Pool
+	if cfg.Controls.Gamepads > 0 && dev.gamepad != nil {
+		gamepads = gamepad.NewPool(int(cfg.Controls.Gamepads), dev.gamepad)
+		d.SetGamepads(gamepads)
+	}
+	var clipboardSync *clipboard.Sync
+	if dev.clipboard != nil {
+		clipboardSync = clipboard.NewSync(dev.clipboard, int(cfg.Controls.ClipboardMaxSize), clipboardInterval)
+		d.SetClipboard(clipboardSync)
+	}
+	fail := func(err error) (*host, error) {
+		if clipboardSync != nil {
+			clipboardSync.Close()
+		}
+		if watcher != nil {
+			watcher.Close()
+		}
+		closeTouchPen(dev)
+		if stopErr := p.stop(); stopErr != nil {
+			return nil, errors.Join(err, stopErr)
+		}
+		return nil, err
+	}
+	var controls controlsHandler = d
+	var inputRecordings *recordings
+	if cfg.Controls.RecordDir != "" {
+		if inputRecordings, err = newRecordings(cfg.Controls.RecordDir, d); err != nil {
+			return fail(err)
+		}
+		controls = recordingControls{Dispatcher: d, recordings: inputRecordings}
+	}
+	if err := p.setPeersHandler(peers{dispatcher: d, role: cfg.Controls.PeerRole, recordings: inputRecordings}); err != nil {
+		return fail(err)
+	}
+	if err := p.setControlsHandler(controls); err != nil {
+		return fail(err)
+	}
+	fileTransfers := files.NewManager(sandbox, p.filesChannel())
+	fileTransfers.SetPermissions(d.Permissions())
+	if err := p.setFilesHandler(fileTransfers); err != nil {
+		return fail(err)
+	}
+	if watcher != nil {
+		if err := p.setCursorHandler(watcher); err != nil {
+			return fail(err)
+		}
+		watcher.Start()
+	}
+	if err := p.start(); err != nil {
+		return fail(err)
+	}
+	stopStats := make(chan struct{})
+	go stats.log(inputStatsInterval, stopStats)
+	return &host{
+		errs:           errs,
+		permissions:    d.Permissions(),
+		updateSettings: updateSettings,
+		inputStats:     stats,
+		stop: func() error {
+			close(stopStats)
+			d.ReleaseAll()
+			if gamepads != nil {
+				gamepads.Close()
+			}
+			closeTouchPen(dev)
+			if clipboardSync != nil {
+				clipboardSync.Close()
+			}
+			if watcher != nil {
+				watcher.Close()
+			}
+			var saveErr error
+			if inputRecordings != nil {
+				saveErr = inputRecordings.saveAll()
+			}
+			return errors.Join(saveErr, p.stop())
+		},
+	}, nil
+}
+
+// lifts the contacts and the pen and removes them
+func closeTouchPen(dev devices) {
+	if dev.touch != nil {
+		dev.touch.Close()
+	}
+	if dev.pen != nil {
+		dev.pen.Close()
+	}
+}
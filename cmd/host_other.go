@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"runtime"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/internal/config"
+)
+
+func newHost(cfg *config.Config) (*host, error) {
+	return nil, pkgerrors.NewNotImplementedError("newHost", "the stream on "+runtime.GOOS)
+}
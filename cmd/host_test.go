@@ -0,0 +1,492 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/internal/config"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/cursor"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/gamepad"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/macro"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/benu-cloud/benu-webrtc/pkg/files"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePipeline keeps the handlers and the messages sent to peers, and fails the calls in fail
+type fakePipeline struct {
+	fail     map[string]error
+	calls    []string
+	peers    peersHandler
+	controls controlsHandler
+	files    filesHandler
+	cursor   cursorHandler
+	sent     map[string][]string
+	// the cursor is sent from the watcher's goroutine
+	cursorSent chan string
+	updates    []types.StreamSettingsUpdate
+	current    config.StreamSettings
+}
+
+func newFakePipeline() *fakePipeline {
+	return &fakePipeline{fail: make(map[string]error), sent: make(map[string][]string), cursorSent: make(chan string, 100)}
+}
+
+func (p *fakePipeline) call(name string) error {
+	p.calls = append(p.calls, name)
+	return p.fail[name]
+}
+
+func (p *fakePipeline) setup(settings *config.StreamSettings) (<-chan error, error) {
+	p.current = *settings
+	return make(chan error), p.call("setup")
+}
+
+func (p *fakePipeline) start() error {
+	return p.call("start")
+}
+
+func (p *fakePipeline) stop() error {
+	return p.call("stop")
+}
+
+func (p *fakePipeline) updateSettings(settings *config.StreamSettings) error {
+	p.current = *settings
+	return p.call("updateSettings")
+}
+
+func (p *fakePipeline) applySettingsUpdate(update types.StreamSettingsUpdate) error {
+	p.updates = append(p.updates, update)
+	p.current = p.current.Update(update)
+	return p.call("applySettingsUpdate")
+}
+
+func (p *fakePipeline) settings() (config.StreamSettings, error) {
+	return p.current, nil
+}
+
+func (p *fakePipeline) setPeersHandler(handler peersHandler) error {
+	p.peers = handler
+	return p.call("setPeersHandler")
+}
+
+func (p *fakePipeline) setControlsHandler(handler controlsHandler) error {
+	p.controls = handler
+	return p.call("setControlsHandler")
+}
+
+func (p *fakePipeline) setFilesHandler(handler filesHandler) error {
+	p.files = handler
+	return p.call("setFilesHandler")
+}
+
+func (p *fakePipeline) setCursorHandler(handler cursorHandler) error {
+	p.cursor = handler
+	return p.call("setCursorHandler")
+}
+
+func (p *fakePipeline) sendControlsMessage(peerId string, message string) error {
+	p.sent[peerId] = append(p.sent[peerId], message)
+	return nil
+}
+
+func (p *fakePipeline) sendCursorMessage(peerId string, message string) error {
+	p.cursorSent <- message
+	return nil
+}
+
+// waits for a cursor message containing substr
+func (p *fakePipeline) cursorMessage(t *testing.T, substr string) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case m := <-p.cursorSent:
+			if strings.Contains(m, substr) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no cursor message with %s", substr)
+		}
+	}
+}
+
+func (p *fakePipeline) filesChannel() files.Channel {
+	return fakeFilesChannel{p}
+}
+
+// sends the files messages to the peers like controls messages
+type fakeFilesChannel struct {
+	p *fakePipeline
+}
+
+func (c fakeFilesChannel) SendMessage(peerId string, message string) error {
+	return c.p.sendControlsMessage(peerId, message)
+}
+
+func (c fakeFilesChannel) SendData(peerId string, data []byte) error {
+	return nil
+}
+
+func (c fakeFilesChannel) BufferedAmount(peerId string) (uint64, error) {
+	return 0, nil
+}
+
+// a peer joining like the stream adds it
+func (p *fakePipeline) join(peerId string) {
+	p.peers.OnPeerAdded(peerId)
+	p.controls.OnControlsOpened(peerId)
+	p.files.OnFilesOpened(peerId)
+}
+
+// a cursor on the second of two 1000x500 monitors side by side
+type fakeCursor struct {
+	mutex sync.Mutex
+	state cursor.State
+}
+
+func (c *fakeCursor) State() (cursor.State, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state, nil
+}
+
+func (c *fakeCursor) Image() (cursor.Image, error) {
+	return cursor.Image{Pixels: image.NewNRGBA(image.Rect(0, 0, 1, 1))}, nil
+}
+
+func (c *fakeCursor) Close() error {
+	return nil
+}
+
+func (c *fakeCursor) MonitorArea(monitor string) (cursor.Area, error) {
+	if monitor == "1" {
+		return cursor.Area{X: 1000, Width: 1000, Height: 500}, nil
+	}
+	return cursor.Area{Width: 1000, Height: 500}, nil
+}
+
+func (c *fakeCursor) move(x, y int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.state.X, c.state.Y = x, y
+}
+
+func loadConfig(t *testing.T, args ...string) config.Config {
+	args = append(args, "-filesdir", t.TempDir(), "-vresolution", "1920x1080", "-rmqusername", "user", "-rmqpassword", "password")
+	cfg, err := config.Load(args, nil)
+	assert.NoError(t, err)
+	return cfg
+}
+
+func TestStartHost(t *testing.T) {
+	cfg := loadConfig(t, "-peerrole", "admin", "-controlscoalesce", "0")
+	p := newFakePipeline()
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	h, err := startHost(&cfg, p, devices{keyboard: k, mouse: m})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"setup", "setPeersHandler", "setControlsHandler", "setFilesHandler", "start"}, p.calls)
+
+	p.join("a")
+	role, ok := h.permissions.Role("a")
+	assert.True(t, ok)
+	assert.Equal(t, permissions.Admin, role)
+	p.controls.OnControlsMessage("a", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("a", `{"type":"keychar","payload":{"key":"a","down":true}}`)
+	p.controls.OnControlsMessage("a", `{"type":"mousemove","payload":{"dx":1,"dy":2}}`)
+	assert.Equal(t, []string{"char a true"}, k.Calls())
+	assert.Equal(t, []string{"move 1 2"}, m.Calls())
+
+	// the settings command reaches the pipeline and lists what it can't change
+	p.controls.OnControlsMessage("a", `{"type":"streamsettings","payload":{"videoBitrate":8000}}`)
+	if assert.Len(t, p.updates, 1) {
+		assert.Equal(t, uint(8000), *p.updates[0].VideoBitrate)
+	}
+	assert.Contains(t, p.sent["a"][len(p.sent["a"])-1], `"fixed":["video.encoder",`)
+
+	// held keys are released when stopping
+	assert.NoError(t, h.stop())
+	assert.Equal(t, []string{"char a true", "char a false"}, k.Calls())
+	assert.Equal(t, "stop", p.calls[len(p.calls)-1])
+}
+
+func TestStartHostViewer(t *testing.T) {
+	cfg := loadConfig(t, "-peerrole", "viewer")
+	p := newFakePipeline()
+	k := &fake.Keyboard{}
+	_, err := startHost(&cfg, p, devices{keyboard: k, mouse: &fake.Mouse{}})
+	assert.NoError(t, err)
+	p.join("v")
+	p.controls.OnControlsMessage("v", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("v", `{"type":"keychar","payload":{"key":"a","down":true}}`)
+	assert.Empty(t, k.Calls())
+}
+
+func TestStartHostClipboard(t *testing.T) {
+	cfg := loadConfig(t, "-clipboardmaxsize", "5")
+	p := newFakePipeline()
+	c := &fake.Clipboard{}
+	h, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}, clipboard: c})
+	assert.NoError(t, err)
+	defer h.stop()
+	p.join("c")
+	p.controls.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("c", `{"type":"clipboard","payload":{"text":"short"}}`)
+	p.controls.OnControlsMessage("c", `{"type":"clipboard","payload":{"text":"too long"}}`)
+	content, err := c.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "short", content.Text)
+	assert.Contains(t, p.sent["c"][len(p.sent["c"])-1], "ClipboardTooLargeError")
+}
+
+func TestStartHostFiles(t *testing.T) {
+	cfg := loadConfig(t, "-peerrole", "viewer", "-filesquota", "10")
+	p := newFakePipeline()
+	h, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}})
+	assert.NoError(t, err)
+	defer h.stop()
+	upload := func(id int, content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return fmt.Sprintf(`{"type":"upload","payload":{"id":%d,"name":"%s.txt","size":%d,"sha256":"%s"}}`, id, content, len(content), hex.EncodeToString(sum[:]))
+	}
+	p.join("v")
+	p.files.OnFilesMessage("v", upload(1, "content"))
+	assert.Contains(t, p.sent["v"][len(p.sent["v"])-1], "PermissionError")
+
+	p.join("c")
+	h.permissions.SetRole("c", permissions.Controller)
+	p.files.OnFilesMessage("c", upload(1, "content"))
+	assert.Equal(t, `{"type":"uploadready","payload":{"id":1,"offset":0}}`, p.sent["c"][len(p.sent["c"])-1])
+	// over -filesquota with the first one
+	p.files.OnFilesMessage("c", upload(2, "more"))
+	assert.Contains(t, p.sent["c"][len(p.sent["c"])-1], "QuotaExceededError")
+}
+
+func TestStartHostStopsPipeline(t *testing.T) {
+	for _, failing := range []string{"setPeersHandler", "setControlsHandler", "setFilesHandler", "start"} {
+		cfg := loadConfig(t)
+		p := newFakePipeline()
+		p.fail[failing] = errors.New("failed")
+		_, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}})
+		assert.Error(t, err, failing)
+		assert.Equal(t, "stop", p.calls[len(p.calls)-1], failing)
+	}
+
+	// nothing to stop
+	cfg := loadConfig(t)
+	p := newFakePipeline()
+	p.fail["setup"] = errors.New("failed")
+	_, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"setup"}, p.calls)
+}
+
+func TestStartHostCursor(t *testing.T) {
+	cfg := loadConfig(t, "-vclientcursor", "-capturemonitor", "1")
+	p := newFakePipeline()
+	c := &fakeCursor{state: cursor.State{X: 1500, Y: 250, Visible: true, ScreenWidth: 2000, ScreenHeight: 500}}
+	h, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}, cursor: c})
+	assert.NoError(t, err)
+	defer h.stop()
+	assert.Contains(t, p.calls, "setCursorHandler")
+	p.join("a")
+	p.cursor.OnCursorOpened("a")
+	// the middle of the captured monitor
+	p.cursorMessage(t, `"x":960,"y":540`)
+
+	// follows the resolution
+	h.permissions.SetRole("a", permissions.Admin)
+	p.controls.OnControlsMessage("a", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("a", `{"type":"streamsettings","payload":{"videoWidth":200,"videoHeight":100}}`)
+	c.move(1500, 200)
+	p.cursorMessage(t, `"x":100,"y":40`)
+
+	// and the capture target
+	settings := cfg.Stream
+	settings.VideoResolution = config.Resolution{Width: 200, Height: 100}
+	settings.Capture = config.CaptureTarget{Monitor: "0"}
+	assert.NoError(t, h.updateSettings(&settings))
+	c.move(500, 250)
+	p.cursorMessage(t, `"x":100,"y":50`)
+}
+
+func TestStartHostInputLimits(t *testing.T) {
+	cfg := loadConfig(t, "-controlsrate", "1", "-controlsburst", "2", "-controlscoalesce", "1")
+	p := newFakePipeline()
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	h, err := startHost(&cfg, p, devices{keyboard: k, mouse: m})
+	assert.NoError(t, err)
+	defer h.stop()
+	p.join("c")
+	p.controls.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("c", `{"type":"mousemove","payload":{"dx":1,"dy":2}}`)
+	p.controls.OnControlsMessage("c", `{"type":"mousemove","payload":{"dx":3,"dy":4}}`)
+	p.controls.OnControlsMessage("c", `{"type":"mousemove","payload":{"dx":5,"dy":6}}`)
+	// merged, without the one over the burst
+	assert.Eventually(t, func() bool { return len(m.Calls()) > 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"move 4 6"}, m.Calls())
+	assert.Equal(t, "controls: dropped 1 input events over -controlsrate, 0 merged mouse events failed", h.inputStats.report())
+	assert.Empty(t, h.inputStats.report())
+}
+
+func TestStartHostRecord(t *testing.T) {
+	dir := t.TempDir()
+	cfg := loadConfig(t, "-recorddir", dir, "-controlscoalesce", "0")
+	p := newFakePipeline()
+	h, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}})
+	assert.NoError(t, err)
+	p.join("c/1")
+	p.join("v")
+	p.controls.OnControlsMessage("c/1", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("c/1", `{"type":"keychar","payload":{"key":"a","down":true}}`)
+	p.controls.OnControlsMessage("c/1", `{"type":"keychar","payload":{"key":"a","down":false}}`)
+	// saved when the peer leaves, without the peer that sent no input
+	p.controls.OnControlsClosed("c/1")
+	p.controls.OnControlsClosed("v")
+	recorded, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	assert.NoError(t, err)
+	if assert.Len(t, recorded, 1) {
+		assert.True(t, strings.HasPrefix(filepath.Base(recorded[0]), "c_1-"))
+		file, err := os.Open(recorded[0])
+		assert.NoError(t, err)
+		events, err := macro.Read(file)
+		file.Close()
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+	}
+
+	// and the ones still there when stopping
+	p.join("c2")
+	h.permissions.SetRole("c2", permissions.Controller)
+	p.controls.OnControlsMessage("c2", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("c2", `{"type":"mousemove","payload":{"dx":1,"dy":2}}`)
+	assert.NoError(t, h.stop())
+	recorded, err = filepath.Glob(filepath.Join(dir, "c2-*.jsonl"))
+	assert.NoError(t, err)
+	assert.Len(t, recorded, 1)
+}
+
+// a gamepad counting its presses, closed when its peer leaves
+type fakeGamepad struct {
+	presses int
+	rumble  chan types.GamepadRumble
+}
+
+func (g *fakeGamepad) SendInputButton(button types.GamepadButton, down bool) error {
+	g.presses++
+	return nil
+}
+
+func (g *fakeGamepad) SendInputStick(stick types.GamepadStick, x float64, y float64) error {
+	return nil
+}
+
+func (g *fakeGamepad) SendInputTrigger(trigger types.GamepadTrigger, value float64) error {
+	return nil
+}
+
+func (g *fakeGamepad) Rumble() <-chan types.GamepadRumble {
+	return g.rumble
+}
+
+func (g *fakeGamepad) Close() error {
+	close(g.rumble)
+	return nil
+}
+
+func TestStartHostGamepads(t *testing.T) {
+	cfg := loadConfig(t, "-gamepads", "1")
+	p := newFakePipeline()
+	var pads []*fakeGamepad
+	newGamepad := func(index int) (gamepad.Gamepad, error) {
+		pad := &fakeGamepad{rumble: make(chan types.GamepadRumble)}
+		pads = append(pads, pad)
+		return pad, nil
+	}
+	h, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}, gamepad: newGamepad})
+	assert.NoError(t, err)
+	p.join("a")
+	p.join("b")
+	p.controls.OnControlsMessage("a", `{"type":"gamepadbutton","payload":{"button":"A","down":true}}`)
+	// one gamepad for -gamepads 1
+	p.controls.OnControlsMessage("b", `{"type":"gamepadbutton","payload":{"button":"A","down":true}}`)
+	assert.Contains(t, p.sent["b"][len(p.sent["b"])-1], "PoolFullError")
+	if assert.Len(t, pads, 1) {
+		assert.Equal(t, 1, pads[0].presses)
+	}
+	assert.NoError(t, h.stop())
+	_, open := <-pads[0].rumble
+	assert.False(t, open)
+
+	// disabled
+	cfg = loadConfig(t, "-gamepads", "0")
+	p = newFakePipeline()
+	_, err = startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}, gamepad: newGamepad})
+	assert.NoError(t, err)
+	p.join("a")
+	p.controls.OnControlsMessage("a", `{"type":"gamepadbutton","payload":{"button":"A","down":true}}`)
+	assert.Len(t, pads, 1)
+}
+
+// a touchscreen and pen keeping the resolution they scale from
+type fakeTouchPen struct {
+	contacts []types.TouchContact
+	pen      []types.PenState
+	width    int
+	height   int
+	closed   int
+}
+
+func (f *fakeTouchPen) SendInputTouch(contacts []types.TouchContact) error {
+	f.contacts = append(f.contacts, contacts...)
+	return nil
+}
+
+func (f *fakeTouchPen) Cancel() error {
+	return nil
+}
+
+func (f *fakeTouchPen) SendInputPen(state types.PenState) error {
+	f.pen = append(f.pen, state)
+	return nil
+}
+
+func (f *fakeTouchPen) SetResolution(width int, height int) {
+	f.width, f.height = width, height
+}
+
+func (f *fakeTouchPen) Close() error {
+	f.closed++
+	return nil
+}
+
+func TestStartHostTouchPen(t *testing.T) {
+	cfg := loadConfig(t, "-peerrole", "admin")
+	p := newFakePipeline()
+	touchPen := &fakeTouchPen{}
+	h, err := startHost(&cfg, p, devices{keyboard: &fake.Keyboard{}, mouse: &fake.Mouse{}, touch: touchPen, pen: touchPen})
+	assert.NoError(t, err)
+	p.join("a")
+	p.controls.OnControlsMessage("a", `{"type":"controlrequest"}`)
+	p.controls.OnControlsMessage("a", `{"type":"touch","payload":{"contacts":[{"id":1,"phase":"down","x":5,"y":6}]}}`)
+	p.controls.OnControlsMessage("a", `{"type":"pen","payload":{"x":1,"y":2,"inRange":true}}`)
+	assert.Len(t, touchPen.contacts, 1)
+	assert.Len(t, touchPen.pen, 1)
+
+	// follow the resolution
+	p.controls.OnControlsMessage("a", `{"type":"streamsettings","payload":{"videoWidth":1280,"videoHeight":720}}`)
+	assert.Equal(t, []int{1280, 720}, []int{touchPen.width, touchPen.height})
+	assert.NoError(t, h.stop())
+	assert.Equal(t, 2, touchPen.closed)
+}
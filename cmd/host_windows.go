@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/benu-cloud/benu-webrtc/internal/config"
+	"github.com/benu-cloud/benu-webrtc/internal/stream"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/benu-cloud/benu-webrtc/pkg/files"
+)
+
+// streamPipeline is the pipeline of the stream package
+type streamPipeline struct{}
+
+func (streamPipeline) setup(settings *config.StreamSettings) (<-chan error, error) {
+	return stream.SetupPipeline(settings)
+}
+
+func (streamPipeline) start() error {
+	return stream.StartPipeline()
+}
+
+func (streamPipeline) stop() error {
+	return stream.StopPipeline()
+}
+
+func (streamPipeline) updateSettings(settings *config.StreamSettings) error {
+	return stream.UpdateSettings(settings)
+}
+
+func (streamPipeline) applySettingsUpdate(update types.StreamSettingsUpdate) error {
+	return stream.ApplySettingsUpdate(update)
+}
+
+func (streamPipeline) setPeersHandler(handler peersHandler) error {
+	return stream.SetPeersHandler(handler)
+}
+
+func (streamPipeline) setControlsHandler(handler controlsHandler) error {
+	return stream.SetControlsHandler(handler)
+}
+
+func (streamPipeline) setFilesHandler(handler filesHandler) error {
+	return stream.SetFilesHandler(handler)
+}
+
+func (streamPipeline) setCursorHandler(handler cursorHandler) error {
+	return stream.SetCursorHandler(handler)
+}
+
+func (streamPipeline) sendControlsMessage(peerId string, message string) error {
+	return stream.SendControlsMessage(peerId, message)
+}
+
+func (streamPipeline) sendCursorMessage(peerId string, message string) error {
+	return stream.SendCursorMessage(peerId, message)
+}
+
+func (streamPipeline) filesChannel() files.Channel {
+	return stream.FilesChannel{}
+}
+
+func (streamPipeline) settings() (config.StreamSettings, error) {
+	return stream.Settings()
+}
+
+// newHost runs the stream of this host with its keyboard, mouse, clipboard, gamepads, touchscreen, pen
+// and, with -vclientcursor, cursor
+func newHost(cfg *config.Config) (*host, error) {
+	k, m, err := newInput()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClipboard()
+	if err != nil {
+		return nil, err
+	}
+	dev := devices{keyboard: k, mouse: m, clipboard: c, gamepad: newGamepad}
+	if cfg.Stream.VideoClientCursor {
+		if dev.cursor, err = newCursor(); err != nil {
+			return nil, err
+		}
+	}
+	res := cfg.Stream.VideoResolution
+	if cfg.Controls.Touch {
+		if dev.touch, err = newTouch(res.Width, res.Height); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Controls.Pen {
+		if dev.pen, err = newPen(res.Width, res.Height); err != nil {
+			if dev.touch != nil {
+				dev.touch.Close()
+			}
+			return nil, err
+		}
+	}
+	return startHost(cfg, streamPipeline{}, dev)
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package main
+
+import (
+	"runtime"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	pkgclipboard "github.com/benu-cloud/benu-webrtc/pkg/controls/clipboard"
+	pkgcursor "github.com/benu-cloud/benu-webrtc/pkg/controls/cursor"
+	pkggamepad "github.com/benu-cloud/benu-webrtc/pkg/controls/gamepad"
+	pkgkeyboard "github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	pkgmouse "github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	pkgpen "github.com/benu-cloud/benu-webrtc/pkg/controls/pen"
+	pkgtouch "github.com/benu-cloud/benu-webrtc/pkg/controls/touch"
+)
+
+func newInput() (pkgkeyboard.Keyboard, pkgmouse.Mouse, error) {
+	return nil, nil, pkgerrors.NewNotImplementedError("newInput", "keyboard and mouse on "+runtime.GOOS)
+}
+
+func newClipboard() (pkgclipboard.Clipboard, error) {
+	return nil, pkgerrors.NewNotImplementedError("newClipboard", "the clipboard on "+runtime.GOOS)
+}
+
+func newCursor() (pkgcursor.Cursor, error) {
+	return nil, pkgerrors.NewNotImplementedError("newCursor", "the cursor on "+runtime.GOOS)
+}
+
+func newGamepad(index int) (pkggamepad.Gamepad, error) {
+	return nil, pkgerrors.NewNotImplementedError("newGamepad", "gamepads on "+runtime.GOOS)
+}
+
+func newTouch(width int, height int) (pkgtouch.Touch, error) {
+	return nil, pkgerrors.NewNotImplementedError("newTouch", "touch on "+runtime.GOOS)
+}
+
+func newPen(width int, height int) (pkgpen.Pen, error) {
+	return nil, pkgerrors.NewNotImplementedError("newPen", "the pen on "+runtime.GOOS)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/benu-cloud/benu-webrtc/internal/controls/clipboard"
+	"github.com/benu-cloud/benu-webrtc/internal/controls/cursor"
+	"github.com/benu-cloud/benu-webrtc/internal/controls/gamepad"
+	"github.com/benu-cloud/benu-webrtc/internal/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/internal/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/internal/controls/pen"
+	"github.com/benu-cloud/benu-webrtc/internal/controls/touch"
+	pkgclipboard "github.com/benu-cloud/benu-webrtc/pkg/controls/clipboard"
+	pkgcursor "github.com/benu-cloud/benu-webrtc/pkg/controls/cursor"
+	pkggamepad "github.com/benu-cloud/benu-webrtc/pkg/controls/gamepad"
+	pkgkeyboard "github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	pkgmouse "github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	pkgpen "github.com/benu-cloud/benu-webrtc/pkg/controls/pen"
+	pkgtouch "github.com/benu-cloud/benu-webrtc/pkg/controls/touch"
+)
+
+func newInput() (pkgkeyboard.Keyboard, pkgmouse.Mouse, error) {
+	return &keyboard.Keyboard_c{}, &mouse.Mouse_c{}, nil
+}
+
+func newClipboard() (pkgclipboard.Clipboard, error) {
+	return clipboard.NewClipboard_c()
+}
+
+func newCursor() (pkgcursor.Cursor, error) {
+	return cursor.NewCursor_c()
+}
+
+func newGamepad(index int) (pkggamepad.Gamepad, error) {
+	return gamepad.NewGamepad_vigem(index)
+}
+
+func newTouch(width int, height int) (pkgtouch.Touch, error) {
+	return touch.NewTouch_c(width, height)
+}
+
+func newPen(width int, height int) (pkgpen.Pen, error) {
+	return pen.NewPen_c(width, height)
+}
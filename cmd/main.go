@@ -2,15 +2,31 @@ package main
 
 import (
 	"fmt"
-	"time"
-
-	"github.com/benu-cloud/benu-livestreaming-gst/internal/controls/keyboard"
+	"os"
 )
 
+// subcommands by name, each gets the arguments after its name and returns the exit code
+var commands map[string]func(args []string) int = map[string]func(args []string) int{
+	"replay": replay,
+	"serve":  serve,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [arguments]\n\ncommands:\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "  replay    replay recorded keyboard and mouse input")
+	fmt.Fprintln(os.Stderr, "  serve     run the stream, reloading the config on SIGHUP and when its file changes")
+}
+
 func main() {
-	k := &keyboard.Keyboard_c{}
-	time.Sleep(time.Second)
-	fmt.Println("sending a")
-	k.SendInputKeyChar('a', true)
-	k.SendInputKeyChar('a', false)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown command '%s'.\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	os.Exit(command(os.Args[2:]))
 }
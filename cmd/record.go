@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/dispatch"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/macro"
+)
+
+// recordings records the keyboard and mouse input of every peer, saved to a macro file in dir
+// when the peer leaves, see the replay command
+type recordings struct {
+	dir        string
+	dispatcher *dispatch.Dispatcher
+	mutex      sync.Mutex
+	recorders  map[string]*macro.Recorder
+	// replaced in tests
+	now func() time.Time
+}
+
+func newRecordings(dir string, d *dispatch.Dispatcher) (*recordings, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &recordings{dir: dir, dispatcher: d, recorders: make(map[string]*macro.Recorder), now: time.Now}, nil
+}
+
+// start records a peer that joined
+func (r *recordings) start(peerId string) {
+	recorder := macro.NewRecorder()
+	r.mutex.Lock()
+	r.recorders[peerId] = recorder
+	r.mutex.Unlock()
+	r.dispatcher.Record(peerId, recorder)
+}
+
+// save writes the recording of a peer and stops it, nothing is written without input
+func (r *recordings) save(peerId string) error {
+	r.mutex.Lock()
+	recorder, ok := r.recorders[peerId]
+	delete(r.recorders, peerId)
+	r.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	r.dispatcher.Record(peerId, nil)
+	events := recorder.Events()
+	if len(events) == 0 {
+		return nil
+	}
+	file, err := os.Create(filepath.Join(r.dir, r.fileName(peerId)))
+	if err != nil {
+		return err
+	}
+	if err := macro.Write(file, events); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// saveAll saves the recordings of every peer, when stopping
+func (r *recordings) saveAll() error {
+	r.mutex.Lock()
+	peerIds := make([]string, 0, len(r.recorders))
+	for peerId := range r.recorders {
+		peerIds = append(peerIds, peerId)
+	}
+	r.mutex.Unlock()
+	var errs []error
+	for _, peerId := range peerIds {
+		errs = append(errs, r.save(peerId))
+	}
+	return errors.Join(errs...)
+}
+
+// the peer and the time it left, with anything but letters, digits, - and _ of the peer replaced
+func (r *recordings) fileName(peerId string) string {
+	safe := strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' {
+			return c
+		}
+		return '_'
+	}, peerId)
+	return fmt.Sprintf("%s-%s.jsonl", safe, r.now().Format("20060102-150405"))
+}
+
+// recordingControls saves the recording of a peer before the dispatcher forgets it
+type recordingControls struct {
+	*dispatch.Dispatcher
+	recordings *recordings
+}
+
+func (c recordingControls) OnControlsClosed(peerId string) {
+	if err := c.recordings.save(peerId); err != nil {
+		log.Printf("controls: saving the input of %s: %v", peerId, err)
+	}
+	c.Dispatcher.OnControlsClosed(peerId)
+}
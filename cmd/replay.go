@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/macro"
+)
+
+// replay a macro file against the local keyboard and mouse
+func replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	var speed float64
+	var delay time.Duration
+	flags.Float64Var(&speed, "speed", 1, "Replay speed, 2 is twice as fast. 0 replays without waiting.")
+	flags.DurationVar(&delay, "delay", 3*time.Second, "Time to wait before replaying, to focus the target window.")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s replay [flags] FILE\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: a macro file is required.")
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	events, err := macro.Read(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	k, m, err := newInput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// stop on ctrl+c, releasing everything held down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	select {
+	case <-ctx.Done():
+		return 1
+	case <-time.After(delay):
+	}
+	fmt.Printf("replaying %d events\n", len(events))
+	if err := macro.Replay(ctx, events, k, m, speed); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/benu-cloud/benu-webrtc/internal/broker"
+	"github.com/benu-cloud/benu-webrtc/internal/config"
+)
+
+// run the stream with the config, which is reloaded on SIGHUP and when the config file changes.
+// The environment, with the .env file, is read again on every reload
+func serve(args []string) int {
+	cfg, err := config.Load(args, config.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return 0
+	}
+	if cfg.PrintConfig {
+		// printed even when invalid, to see what went wrong
+		if printErr := cfg.Print(os.Stdout); printErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", printErr)
+			return 1
+		}
+		if err == nil {
+			return 0
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	b, err := broker.Connect(&cfg.Broker)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer b.Close()
+	h, err := newHost(&cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer h.stop()
+	r := config.NewReloader(args, config.Environ, cfg, applyConfig(h, b))
+	r.Watch(cfg.ConfigPoll)
+	defer r.Close()
+
+	// SIGTERM is how services are stopped, never sent on windows
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case <-ctx.Done():
+		return 0
+	case err := <-h.errs:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+}
+
+// applyConfig applies the live changes of a reload to the host and the broker,
+// each part is applied even when another fails
+func applyConfig(h *host, b *broker.Broker) config.ApplyFunc {
+	return func(cfg config.Config, changes []config.Change) ([]config.Change, error) {
+		var streamChanges, rolesChanges, brokerChanges []config.Change
+		for _, c := range changes {
+			switch {
+			case strings.HasPrefix(c.Setting, "rabbitmq."):
+				brokerChanges = append(brokerChanges, c)
+			case c.Setting == "controls.clipboardroles":
+				rolesChanges = append(rolesChanges, c)
+			default:
+				streamChanges = append(streamChanges, c)
+			}
+		}
+		applied := make([]config.Change, 0, len(changes))
+		var errs []error
+		if len(streamChanges) > 0 {
+			if err := h.updateSettings(&cfg.Stream); err != nil {
+				errs = append(errs, err)
+			} else {
+				applied = append(applied, streamChanges...)
+			}
+		}
+		if len(rolesChanges) > 0 {
+			h.permissions.SetClipboardRoles(cfg.Controls.ClipboardReadRoles...)
+			applied = append(applied, rolesChanges...)
+		}
+		if len(brokerChanges) > 0 {
+			if err := b.Reconnect(&cfg.Broker); err != nil {
+				errs = append(errs, err)
+			} else {
+				applied = append(applied, brokerChanges...)
+			}
+		}
+		return applied, errors.Join(errs...)
+	}
+}
@@ -6,13 +6,12 @@ require (
 	github.com/benu-cloud/benu-errors v0.0.0-20230409132418-7793765bb34e
 	github.com/benu-cloud/benu-message v0.0.0-20230409144420-1c725c331bb2
 	github.com/joho/godotenv v1.5.1
-	github.com/namsral/flag v1.7.4-pre
+	github.com/rabbitmq/amqp091-go v1.8.0
 	github.com/stretchr/testify v1.8.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/rabbitmq/amqp091-go v1.8.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,133 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/benu-cloud/benu-message/message"
+	"github.com/benu-cloud/benu-message/rabbitmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// the methods of rabbitmq.RabbitMQConnection the broker uses, faked in tests
+type connection interface {
+	NewConsumer(queue string) (<-chan amqp.Delivery, error)
+	Publish(queue string, payload message.GenericPayload) error
+	CloseConnection() error
+}
+
+type dialFunc func(settings *rabbitmq.MessageBrokerSettings) (connection, error)
+
+func dialRabbitMQ(settings *rabbitmq.MessageBrokerSettings) (connection, error) {
+	conn, err := rabbitmq.NewConnection(settings)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// a queue consumed through every connection of the broker
+type consumer struct {
+	queue string
+	out   chan amqp.Delivery
+	// the deliveries of the next connection, closed by Close
+	next chan (<-chan amqp.Delivery)
+}
+
+// forward sends the deliveries of a connection until it is closed, then those of the next one
+func (c *consumer) forward(deliveries <-chan amqp.Delivery) {
+	defer close(c.out)
+	for {
+		for d := range deliveries {
+			c.out <- d
+		}
+		var ok bool
+		if deliveries, ok = <-c.next; !ok {
+			return
+		}
+	}
+}
+
+// Broker is the connection to the message broker, which Reconnect replaces while the service runs,
+// like when its credentials change. The stream and its peers don't depend on it, so they stay connected
+type Broker struct {
+	mutex     sync.RWMutex
+	dial      dialFunc
+	conn      connection
+	consumers []*consumer
+}
+
+// Connect dials the broker with the settings
+func Connect(settings *rabbitmq.MessageBrokerSettings) (*Broker, error) {
+	return connect(settings, dialRabbitMQ)
+}
+
+func connect(settings *rabbitmq.MessageBrokerSettings, dial dialFunc) (*Broker, error) {
+	conn, err := dial(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &Broker{dial: dial, conn: conn}, nil
+}
+
+// Consume returns the deliveries of the queue, which keep coming after a reconnect.
+// They must be read, a reconnect waits for the deliveries sent before it
+func (b *Broker) Consume(queue string) (<-chan amqp.Delivery, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	deliveries, err := b.conn.NewConsumer(queue)
+	if err != nil {
+		return nil, err
+	}
+	c := &consumer{
+		queue: queue,
+		out:   make(chan amqp.Delivery),
+		next:  make(chan (<-chan amqp.Delivery), 1),
+	}
+	b.consumers = append(b.consumers, c)
+	go c.forward(deliveries)
+	return c.out, nil
+}
+
+// Publish sends the payload to the queue through the current connection
+func (b *Broker) Publish(queue string, payload message.GenericPayload) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.conn.Publish(queue, payload)
+}
+
+// Reconnect dials the broker with the settings and replaces the connection once the queues are
+// consumed through the new one. When it fails the old connection is kept
+func (b *Broker) Reconnect(settings *rabbitmq.MessageBrokerSettings) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	conn, err := b.dial(settings)
+	if err != nil {
+		return err
+	}
+	next := make([]<-chan amqp.Delivery, len(b.consumers))
+	for i, c := range b.consumers {
+		if next[i], err = conn.NewConsumer(c.queue); err != nil {
+			conn.CloseConnection()
+			return err
+		}
+	}
+	old := b.conn
+	b.conn = conn
+	for i, c := range b.consumers {
+		c.next <- next[i]
+	}
+	// ends the deliveries of the old connection, the consumers move on to the new one
+	return old.CloseConnection()
+}
+
+// Close closes the connection and the deliveries of the consumed queues
+func (b *Broker) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	err := b.conn.CloseConnection()
+	for _, c := range b.consumers {
+		close(c.next)
+	}
+	b.consumers = nil
+	return err
+}
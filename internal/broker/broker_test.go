@@ -0,0 +1,103 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-message/message"
+	"github.com/benu-cloud/benu-message/rabbitmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConnection struct {
+	username  string
+	queues    map[string]chan amqp.Delivery
+	published []string
+	closed    bool
+}
+
+func (c *fakeConnection) NewConsumer(queue string) (<-chan amqp.Delivery, error) {
+	if c.username == "guest" {
+		return nil, errors.New("access refused to queue")
+	}
+	deliveries := make(chan amqp.Delivery, 1)
+	c.queues[queue] = deliveries
+	return deliveries, nil
+}
+
+func (c *fakeConnection) Publish(queue string, payload message.GenericPayload) error {
+	c.published = append(c.published, queue)
+	return nil
+}
+
+func (c *fakeConnection) CloseConnection() error {
+	c.closed = true
+	for _, deliveries := range c.queues {
+		close(deliveries)
+	}
+	return nil
+}
+
+type fakeDialer struct {
+	conns []*fakeConnection
+}
+
+func (d *fakeDialer) dial(settings *rabbitmq.MessageBrokerSettings) (connection, error) {
+	if settings.Password != "password" {
+		return nil, errors.New("access refused")
+	}
+	c := &fakeConnection{username: settings.Username, queues: make(map[string]chan amqp.Delivery)}
+	d.conns = append(d.conns, c)
+	return c, nil
+}
+
+func receive(t *testing.T, deliveries <-chan amqp.Delivery) string {
+	select {
+	case d := <-deliveries:
+		return string(d.Body)
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+		return ""
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	d := &fakeDialer{}
+	_, err := connect(&rabbitmq.MessageBrokerSettings{Username: "user", Password: "wrong"}, d.dial)
+	assert.Error(t, err)
+	b, err := connect(&rabbitmq.MessageBrokerSettings{Username: "user", Password: "password"}, d.dial)
+	assert.NoError(t, err)
+	deliveries, err := b.Consume("offers")
+	assert.NoError(t, err)
+	d.conns[0].queues["offers"] <- amqp.Delivery{Body: []byte("first")}
+	assert.Equal(t, "first", receive(t, deliveries))
+
+	// refused credentials keep the old connection
+	assert.Error(t, b.Reconnect(&rabbitmq.MessageBrokerSettings{Username: "rotated", Password: "wrong"}))
+	assert.Len(t, d.conns, 1)
+	assert.False(t, d.conns[0].closed)
+
+	// so does a user that can't consume the queues, and the new connection is closed
+	assert.Error(t, b.Reconnect(&rabbitmq.MessageBrokerSettings{Username: "guest", Password: "password"}))
+	assert.Len(t, d.conns, 2)
+	assert.True(t, d.conns[1].closed)
+	assert.False(t, d.conns[0].closed)
+
+	assert.NoError(t, b.Reconnect(&rabbitmq.MessageBrokerSettings{Username: "rotated", Password: "password"}))
+	assert.Len(t, d.conns, 3)
+	assert.True(t, d.conns[0].closed)
+	assert.Equal(t, "rotated", d.conns[2].username)
+
+	// the consumer carries on with the new connection
+	d.conns[2].queues["offers"] <- amqp.Delivery{Body: []byte("second")}
+	assert.Equal(t, "second", receive(t, deliveries))
+	assert.NoError(t, b.Publish("answers", "answer"))
+	assert.Equal(t, []string{"answers"}, d.conns[2].published)
+
+	assert.NoError(t, b.Close())
+	assert.True(t, d.conns[2].closed)
+	_, ok := <-deliveries
+	assert.False(t, ok)
+}
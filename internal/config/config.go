@@ -0,0 +1,173 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// Ranges of the settings
+const (
+	MaxFramerate = 240
+	// in kbit/sec
+	MaxVideoBitrate = 500000
+	// opus bitrates in bps
+	MinAudioBitrate  = 6000
+	MaxAudioBitrate  = 510000
+	MaxPacketLossPct = 100
+	// in ms
+	MaxMicrophoneLatency = 1000
+	// besides the screen and the composite, each is encoded on its own
+	MaxVideoSources = 8
+)
+
+// checker collects the settings that are out of range
+type checker struct {
+	errs ValidationErrors
+}
+
+func (c *checker) check(ok bool, flag string, got interface{}, expected string) {
+	if !ok {
+		c.errs = append(c.errs, pkgerrors.NewBadCommanlineArgument(flag, fmt.Sprint(got), expected))
+	}
+}
+
+func (c *checker) checkRange(flag string, got uint, min uint, max uint) {
+	c.check(got >= min && got <= max, flag, got, fmt.Sprintf("%d-%d", min, max))
+}
+
+// checkResolution requires even sides, which the I420 frames fed to the encoders need
+func (c *checker) checkResolution(flag string, r Resolution) {
+	c.check(r.Width > 0 && r.Height > 0 && r.Width%2 == 0 && r.Height%2 == 0,
+		flag, r.String(), "[WIDTH]x[HEIGHT], both even and not zero")
+}
+
+func (c *checker) checkStream(s *StreamSettings) {
+	c.checkResolution("vresolution", s.VideoResolution)
+	t := &s.Capture
+	c.check(t.Window == "" || t.Process == "", "captureprocess", t.Process, "not set along with capturewindow")
+	c.check(t.Window == "" && t.Process == "" || t.Monitor == "", "capturemonitor", t.Monitor, "not set along with a window to capture")
+	c.check(s.VideoTemplateFile == "" || *t == (CaptureTarget{}), "vtemplate", s.VideoTemplateFile, "no capture target, the template captures by itself")
+	if s.VideoEncoder == Auto {
+		c.check(len(s.VideoEncoderOrder) > 0, "vencoderorder", s.VideoEncoderOrder.String(), "at least one encoder")
+		// the template has its own encoder, which the peers must be told
+		c.check(s.VideoTemplateFile == "", "vencoder", s.VideoEncoder.String(), "the encoder of -vtemplate, not auto")
+	}
+	c.checkSources(s)
+	c.checkRange("vframerate", s.VideoBaseFramerate, 1, MaxFramerate)
+	c.checkRange("vbitrate", s.VideoBaseBitrate, 1, MaxVideoBitrate)
+	c.checkRange("abitrate", s.AudioBaseBitrate, MinAudioBitrate, MaxAudioBitrate)
+	c.checkRange("apacketlosspct", s.AudioBasePacketLossPct, 0, MaxPacketLossPct)
+	if s.MicrophoneEnabled {
+		c.checkRange("miclatency", s.MicrophoneLatency, 0, MaxMicrophoneLatency)
+	}
+	if s.CameraEnabled {
+		c.checkResolution("cameraresolution", s.CameraResolution)
+		c.checkRange("cameraframerate", s.CameraFramerate, 1, MaxFramerate)
+		c.check(s.CameraSink != "", "camerasink", s.CameraSink, "a GStreamer sink")
+	}
+}
+
+func (c *checker) checkSources(s *StreamSettings) {
+	c.check(len(s.VideoSources) <= MaxVideoSources, "vsources", s.VideoSources.String(), fmt.Sprintf("at most %d sources", MaxVideoSources))
+	names := map[string]bool{ScreenSource: true, CompositeSource: true}
+	for _, v := range s.VideoSources {
+		c.check(sourceNamePattern.MatchString(v.Name) && !names[v.Name], "vsources", v.Name,
+			"unique names of lowercase letters and digits, not screen or composite")
+		names[v.Name] = true
+		c.check(v.Target != "" || v.Kind == MonitorSource || v.Kind == WebcamSource, "vsources", v.Name, "a window title or executable to capture")
+		if v.Resolution != (Resolution{}) {
+			c.checkResolution("vsources", v.Resolution)
+		}
+	}
+	if len(s.Composite.Sources) == 0 {
+		return
+	}
+	drawn := make(map[string]bool)
+	for _, name := range s.Composite.Sources {
+		c.check(names[name] && name != CompositeSource && !drawn[name], "vcomposite", name, "screen or a source of -vsources, each once")
+		drawn[name] = true
+	}
+	c.check(len(s.Composite.Sources) >= 2, "vcomposite", s.Composite.String(), "at least two sources")
+	// the composite captures the screen with the built-in elements
+	c.check(s.VideoTemplateFile == "" || !drawn[ScreenSource], "vcomposite", s.Composite.String(), "not the screen of -vtemplate")
+	if s.Composite.Resolution != (Resolution{}) {
+		c.checkResolution("vcomposite", s.Composite.Resolution)
+	}
+}
+
+// Validate reports every stream setting out of range in ValidationErrors
+func (s *StreamSettings) Validate() error {
+	var c checker
+	c.checkStream(s)
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+// Update returns the settings with the fields set in the update changed
+func (s StreamSettings) Update(u types.StreamSettingsUpdate) StreamSettings {
+	if u.VideoBitrate != nil {
+		s.VideoBaseBitrate = *u.VideoBitrate
+	}
+	if u.VideoFramerate != nil {
+		s.VideoBaseFramerate = *u.VideoFramerate
+	}
+	if u.VideoWidth != nil {
+		s.VideoResolution.Width = *u.VideoWidth
+	}
+	if u.VideoHeight != nil {
+		s.VideoResolution.Height = *u.VideoHeight
+	}
+	if u.VideoCursor != nil {
+		s.VideoShowCursor = *u.VideoCursor
+	}
+	if u.AudioBitrate != nil {
+		s.AudioBaseBitrate = *u.AudioBitrate
+	}
+	if u.AudioPacketLossPct != nil {
+		s.AudioBasePacketLossPct = *u.AudioPacketLossPct
+	}
+	return s
+}
+
+// validate reports every setting out of range or missing
+func (cfg *Config) validate() ValidationErrors {
+	var c checker
+	m := &cfg.Broker
+	c.checkStream(&cfg.Stream)
+	c.check(cfg.Controls.InputRate == 0 || cfg.Controls.InputBurst > 0, "controlsburst", cfg.Controls.InputBurst, "not zero with -controlsrate")
+	c.check(m.Host != "", "rmqhost", m.Host, "a host name")
+	c.check(m.Username != "", "rmqusername", m.Username, "a user name (required)")
+	c.check(m.Password != "", "rmqpassword", "", "a password (required)")
+	c.check(m.PublishTimeout > 0, "rmqtimeout", (*Seconds)(&m.PublishTimeout).String(), "seconds, not zero")
+	return c.errs
+}
+
+// flagsOf returns flags reading and writing a copy of cfg
+func flagsOf(cfg Config) (*flag.FlagSet, *Config) {
+	copied := &Config{}
+	fs := newFlagSet(copied)
+	*copied = cfg
+	return fs, copied
+}
+
+// Print writes the settings in the config file format, secrets redacted
+func (cfg *Config) Print(w io.Writer) error {
+	fs, _ := flagsOf(*cfg)
+	return printConfig(fs, w)
+}
+
+// String is the printed config, so secrets don't end up in logs
+func (cfg Config) String() string {
+	var b strings.Builder
+	if err := cfg.Print(&b); err != nil {
+		return err.Error()
+	}
+	return b.String()
+}
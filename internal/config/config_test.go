@@ -0,0 +1,214 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var required = []string{"-vresolution", "1920x1080", "-rmqusername", "user", "-rmqpassword", "password"}
+
+type loadTest struct {
+	args []string
+	env  []string
+	// flags of the invalid settings, in order
+	invalid []string
+}
+
+func TestLoadValidation(t *testing.T) {
+	loadTests := []loadTest{
+		{required, nil, nil},
+		{nil, nil, []string{"vresolution", "rmqusername", "rmqpassword"}},
+		{nil, []string{"VRESOLUTION=1280x720", "RMQUSERNAME=user", "RMQPASSWORD=password"}, nil},
+		{append([]string{"-vresolution", "1279x720"}, required[2:]...), nil, []string{"vresolution"}},
+		{append([]string{"-vframerate", "0", "-vbitrate", "0", "-abitrate", "100", "-apacketlosspct", "101"}, required...), nil,
+			[]string{"vframerate", "vbitrate", "abitrate", "apacketlosspct"}},
+		{append([]string{"-apacketlosspct", "100"}, required...), nil, nil},
+		// only checked when used
+		{append([]string{"-cameraresolution", "641x480"}, required...), nil, nil},
+		{append([]string{"-camera", "-cameraresolution", "641x480", "-cameraframerate", "0", "-camerasink", ""}, required...), nil,
+			[]string{"cameraresolution", "cameraframerate", "camerasink"}},
+		{append([]string{"-rmqtimeout", "0"}, required...), nil, []string{"rmqtimeout"}},
+		{append([]string{"-capturemonitor", "1", "-captureregion", "1280x720+0+0"}, required...), nil, nil},
+		{append([]string{"-capturewindow", "Notepad", "-captureprocess", "notepad.exe", "-capturemonitor", "1"}, required...), nil,
+			[]string{"captureprocess", "capturemonitor"}},
+		{append([]string{"-vsources", "cam=webcam:,docs=window:Notes", "-vcomposite", "grid:screen+cam+docs"}, required...), nil, nil},
+		{append([]string{"-vsources", "Cam=webcam:,screen=monitor:1,docs=process:,tv=monitor:@641x480"}, required...), nil,
+			[]string{"vsources", "vsources", "vsources", "vsources"}},
+		{append([]string{"-vsources", "cam=webcam:,cam=webcam:1", "-vcomposite", "pip:cam+cam+tv@1x1"}, required...), nil,
+			[]string{"vsources", "vcomposite", "vcomposite", "vcomposite"}},
+		{append([]string{"-vcomposite", "pip:screen"}, required...), nil, []string{"vcomposite"}},
+		// every bad environment variable is reported
+		{required, []string{"VFRAMERATE=fast", "MIC=maybe"}, []string{"VFRAMERATE", "MIC"}},
+	}
+	for _, test := range loadTests {
+		_, err := Load(test.args, test.env)
+		if test.invalid == nil {
+			assert.NoError(t, err, test.args)
+			continue
+		}
+		var errs ValidationErrors
+		if !assert.ErrorAs(t, err, &errs, test.args) {
+			continue
+		}
+		invalid := make([]string, len(errs))
+		for i, err := range errs {
+			var bad *pkgerrors.BadCommanlineArgument
+			if assert.ErrorAs(t, err, &bad) {
+				invalid[i] = bad.For
+			}
+		}
+		assert.ElementsMatch(t, test.invalid, invalid, test.args)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(append([]string{"-rmqport", "1234", "-rmqtimeout", "10"}, required...), []string{"MIC=", "VFRAMERATE=30"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1234), cfg.Broker.Port)
+	assert.Equal(t, 10*time.Second, cfg.Broker.PublishTimeout)
+	assert.True(t, cfg.Stream.MicrophoneEnabled)
+	assert.Equal(t, uint(30), cfg.Stream.VideoBaseFramerate)
+	assert.Equal(t, H264, cfg.Stream.VideoEncoder)
+
+	cfg, err = Load(append([]string{"-vencoder", "auto", "-vencoderorder", "H264, VP9"}, required...), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Auto, cfg.Stream.VideoEncoder)
+	assert.Equal(t, EncoderList{H264, VP9}, cfg.Stream.VideoEncoderOrder)
+	_, err = Load(append([]string{"-vencoderorder", "H264,auto"}, required...), nil)
+	assert.Error(t, err)
+	_, err = Load(append([]string{"-vencoder", "auto", "-vencoderorder", ""}, required...), nil)
+	assert.Error(t, err)
+	// only needed for auto
+	_, err = Load(append([]string{"-vencoderorder", ""}, required...), nil)
+	assert.NoError(t, err)
+
+	_, err = Load([]string{"-rmqport", "70000"}, nil)
+	assert.Error(t, err)
+	_, err = Load(append([]string{"extra"}, required...), nil)
+	assert.Error(t, err)
+	_, err = Load(append(required, "extra"), nil)
+	var unexpected *UnexpectedArgumentError
+	assert.ErrorAs(t, err, &unexpected)
+	_, err = Load([]string{"-h"}, nil)
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("video:\n  framerate: 24\n  bitrate: 1000\n  encoder: VP9\n"), 0o600))
+	cfg, err := Load(append([]string{"-configfile", file, "-vframerate", "50"}, required...), []string{"VBITRATE=2000"})
+	assert.NoError(t, err)
+	// flags over the environment over the file over defaults
+	assert.Equal(t, uint(50), cfg.Stream.VideoBaseFramerate)
+	assert.Equal(t, uint(2000), cfg.Stream.VideoBaseBitrate)
+	assert.Equal(t, VP9, cfg.Stream.VideoEncoder)
+	assert.Equal(t, uint(64000), cfg.Stream.AudioBaseBitrate)
+
+	// the file can be named in the environment too
+	cfg, err = Load(required, []string{"CONFIGFILE=" + file})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(24), cfg.Stream.VideoBaseFramerate)
+}
+
+func TestEnviron(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+	t.Setenv("VBITRATE", "2000")
+
+	assert.Contains(t, Environ(), "VBITRATE=2000")
+	assert.NoError(t, os.WriteFile(".env", []byte("VBITRATE=1000\nVFRAMERATE=24\n"), 0o600))
+	// the process environment wins
+	env := Environ()
+	assert.Contains(t, env, "VBITRATE=2000")
+	assert.NotContains(t, env, "VBITRATE=1000")
+	assert.Contains(t, env, "VFRAMERATE=24")
+	// read again
+	assert.NoError(t, os.WriteFile(".env", []byte("VFRAMERATE=30\n"), 0o600))
+	assert.Contains(t, Environ(), "VFRAMERATE=30")
+}
+
+func TestPeerRole(t *testing.T) {
+	cfg, err := Load(required, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, permissions.Controller, cfg.Controls.PeerRole)
+	cfg, err = Load(append([]string{"-peerrole", "viewer"}, required...), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, permissions.Viewer, cfg.Controls.PeerRole)
+	_, err = Load(append([]string{"-peerrole", "owner"}, required...), nil)
+	assert.Error(t, err)
+}
+
+func TestInputLimits(t *testing.T) {
+	cfg, err := Load(append([]string{"-controlsrate", "500", "-controlsburst", "50", "-controlscoalesce", "16"}, required...), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(500), cfg.Controls.InputRate)
+	assert.Equal(t, uint(50), cfg.Controls.InputBurst)
+	assert.Equal(t, uint(16), cfg.Controls.InputCoalesce)
+	// a limit needs a burst
+	_, err = Load(append([]string{"-controlsrate", "500", "-controlsburst", "0"}, required...), nil)
+	assert.Error(t, err)
+	_, err = Load(append([]string{"-controlsrate", "0", "-controlsburst", "0"}, required...), nil)
+	assert.NoError(t, err)
+}
+
+type regionTest struct {
+	region   string
+	expected Region
+	err      bool
+}
+
+func TestRegion(t *testing.T) {
+	regionTests := []regionTest{
+		{"", Region{}, false},
+		{"1280x720+100+50", Region{X: 100, Y: 50, Width: 1280, Height: 720}, false},
+		{"1280x720", Region{}, true},
+		{"1280x720+100", Region{}, true},
+		{"1280x720+-100+50", Region{}, true},
+		{"0x720+0+0", Region{}, true},
+		{"1280x720+0+0 ", Region{}, true},
+		{"1280x720+0+0+0", Region{}, true},
+	}
+	for _, test := range regionTests {
+		var r Region
+		err := r.Set(test.region)
+		if test.err {
+			assert.Error(t, err, test.region)
+			continue
+		}
+		assert.NoError(t, err, test.region)
+		assert.Equal(t, test.expected, r)
+		assert.Equal(t, test.region, r.String())
+	}
+}
+
+func TestStreamSettingsUpdate(t *testing.T) {
+	cfg, err := Load(required, nil)
+	assert.NoError(t, err)
+	bitrate, width, cursor := uint(8000), 1280, false
+	updated := cfg.Stream.Update(types.StreamSettingsUpdate{VideoBitrate: &bitrate, VideoWidth: &width, VideoCursor: &cursor})
+	assert.Equal(t, uint(8000), updated.VideoBaseBitrate)
+	assert.Equal(t, Resolution{Width: 1280, Height: 1080}, updated.VideoResolution)
+	assert.False(t, updated.VideoShowCursor)
+	assert.Equal(t, cfg.Stream.VideoBaseFramerate, updated.VideoBaseFramerate)
+	// the original is left alone
+	assert.Equal(t, uint(52000), cfg.Stream.VideoBaseBitrate)
+	assert.NoError(t, updated.Validate())
+
+	width = 1281
+	updated = updated.Update(types.StreamSettingsUpdate{VideoWidth: &width})
+	var errs ValidationErrors
+	if assert.ErrorAs(t, updated.Validate(), &errs) {
+		assert.Len(t, errs, 1)
+	}
+}
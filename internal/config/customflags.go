@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
 )
 
 func (r *Resolution) String() string {
@@ -35,6 +38,27 @@ badFormat:
 	return pkgerrors.NewBadCommanlineArgument("Resolution", s, "[WIDTH]x[HEIGHT]")
 }
 
+func (r *Region) String() string {
+	if *r == (Region{}) {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d+%d+%d", r.Width, r.Height, r.X, r.Y)
+}
+
+// Set takes the X11 geometry format [WIDTH]x[HEIGHT]+[X]+[Y], empty for no region
+func (r *Region) Set(s string) error {
+	var region Region
+	if s != "" {
+		_, err := fmt.Sscanf(s, "%dx%d+%d+%d", &region.Width, &region.Height, &region.X, &region.Y)
+		// reading it back rejects signs, spaces and anything after it
+		if err != nil || region.Width <= 0 || region.Height <= 0 || region.X < 0 || region.Y < 0 || region.String() != s {
+			return pkgerrors.NewBadCommanlineArgument("Region", s, "[WIDTH]x[HEIGHT]+[X]+[Y]")
+		}
+	}
+	*r = region
+	return nil
+}
+
 func (e *VideoEncoder) String() string {
 	switch *e {
 	case VP9:
@@ -43,6 +67,8 @@ func (e *VideoEncoder) String() string {
 		return "H264"
 	case NVH264:
 		return "NVH264"
+	case Auto:
+		return "auto"
 	}
 	return ""
 }
@@ -55,12 +81,260 @@ func (e *VideoEncoder) Set(s string) error {
 		*e = H264
 	case "NVH264":
 		*e = NVH264
+	case "auto":
+		*e = Auto
+	default:
+		return pkgerrors.NewBadCommanlineArgument("VideoEncoder", s, "(VP9 / H264 / NVH264 / auto)")
+	}
+	return nil
+}
+
+func (l *EncoderList) String() string {
+	encoders := make([]string, len(*l))
+	for i := range *l {
+		encoders[i] = (*l)[i].String()
+	}
+	return strings.Join(encoders, ",")
+}
+
+func (l *EncoderList) Set(s string) error {
+	encoders := make(EncoderList, 0)
+	for _, part := range strings.Split(s, ",") {
+		var encoder VideoEncoder
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if err := encoder.Set(part); err != nil || encoder == Auto {
+			return pkgerrors.NewBadCommanlineArgument("EncoderList", s, "comma separated encoders (VP9 / H264 / NVH264)")
+		}
+		encoders = append(encoders, encoder)
+	}
+	*l = encoders
+	return nil
+}
+
+func (p *EncoderProfile) String() string {
+	return string(*p)
+}
+
+func (p *EncoderProfile) Set(s string) error {
+	if _, ok := profileProperties[EncoderProfile(s)]; !ok {
+		return pkgerrors.NewBadCommanlineArgument("EncoderProfile", s, "(ultra-low-latency / balanced / text-sharp / motion)")
+	}
+	*p = EncoderProfile(s)
+	return nil
+}
+
+func (l *EncoderOverrides) String() string {
+	overrides := make([]string, len(*l))
+	for i, o := range *l {
+		overrides[i] = o.Name + "=" + o.Value
+		if o.Encoder != nil {
+			overrides[i] = o.Encoder.String() + ":" + overrides[i]
+		}
+	}
+	return strings.Join(overrides, ",")
+}
+
+func (l *EncoderOverrides) Set(s string) error {
+	overrides := make(EncoderOverrides, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var o EncoderOverride
+		if prefix, property, ok := strings.Cut(part, ":"); ok {
+			o.Encoder = new(VideoEncoder)
+			if err := o.Encoder.Set(prefix); err != nil || *o.Encoder == Auto {
+				goto badFormat
+			}
+			part = property
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || name == "" || value == "" {
+			goto badFormat
+		}
+		o.Name, o.Value = name, value
+		overrides = append(overrides, o)
+	}
+	*l = overrides
+	return nil
+badFormat:
+	return pkgerrors.NewBadCommanlineArgument("EncoderOverrides", s, "comma separated [ENCODER:]property=value like H264:ref=3")
+}
+
+func (k *SourceKind) String() string {
+	switch *k {
+	case MonitorSource:
+		return "monitor"
+	case WindowSource:
+		return "window"
+	case ProcessSource:
+		return "process"
+	case WebcamSource:
+		return "webcam"
+	}
+	return ""
+}
+
+func (k *SourceKind) Set(s string) error {
+	switch s {
+	case "monitor":
+		*k = MonitorSource
+	case "window":
+		*k = WindowSource
+	case "process":
+		*k = ProcessSource
+	case "webcam":
+		*k = WebcamSource
 	default:
-		return pkgerrors.NewBadCommanlineArgument("VideoEncoder", s, "(VP9 / H264 / NVH264)")
+		return pkgerrors.NewBadCommanlineArgument("SourceKind", s, "(monitor / window / process / webcam)")
 	}
 	return nil
 }
 
+// cutResolution splits an optional @[WIDTH]x[HEIGHT] off the end of s
+func cutResolution(s string) (string, Resolution, bool) {
+	var r Resolution
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return s, r, true
+	}
+	if err := r.Set(s[i+1:]); err != nil {
+		return s, r, false
+	}
+	return s[:i], r, true
+}
+
+// withResolution appends @[WIDTH]x[HEIGHT] unless r is empty
+func withResolution(s string, r Resolution) string {
+	if r == (Resolution{}) {
+		return s
+	}
+	return s + "@" + r.String()
+}
+
+func (l *VideoSources) String() string {
+	sources := make([]string, len(*l))
+	for i, v := range *l {
+		sources[i] = withResolution(v.Name+"="+v.Kind.String()+":"+v.Target, v.Resolution)
+	}
+	return strings.Join(sources, ",")
+}
+
+// Set takes comma separated NAME=KIND:TARGET[@[WIDTH]x[HEIGHT]], like cam=webcam:0@1280x720
+func (l *VideoSources) Set(s string) error {
+	sources := make(VideoSources, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var v VideoSource
+		var ok bool
+		if part, v.Resolution, ok = cutResolution(part); !ok {
+			goto badFormat
+		}
+		name, target, ok := strings.Cut(part, "=")
+		if !ok || name == "" {
+			goto badFormat
+		}
+		kind, target, ok := strings.Cut(target, ":")
+		if !ok || v.Kind.Set(kind) != nil {
+			goto badFormat
+		}
+		v.Name, v.Target = name, target
+		sources = append(sources, v)
+	}
+	*l = sources
+	return nil
+badFormat:
+	return pkgerrors.NewBadCommanlineArgument("VideoSources", s, "comma separated NAME=KIND:TARGET[@[WIDTH]x[HEIGHT]] like cam=webcam:0")
+}
+
+func (c *Composite) String() string {
+	if len(c.Sources) == 0 {
+		return ""
+	}
+	return withResolution(string(c.Layout)+":"+strings.Join(c.Sources, "+"), c.Resolution)
+}
+
+// Set takes LAYOUT:SOURCE+SOURCE[@[WIDTH]x[HEIGHT]] like pip:screen+cam, empty for no composite
+func (c *Composite) Set(s string) error {
+	var composite Composite
+	if s != "" {
+		rest, resolution, ok := cutResolution(s)
+		layout, sources, found := strings.Cut(rest, ":")
+		switch CompositeLayout(layout) {
+		case SideBySide, Stacked, Grid, PictureInPicture:
+		default:
+			ok = false
+		}
+		if !ok || !found || sources == "" {
+			return pkgerrors.NewBadCommanlineArgument("Composite", s, "(sidebyside / stacked / grid / pip):SOURCE+SOURCE[@[WIDTH]x[HEIGHT]]")
+		}
+		composite = Composite{CompositeLayout(layout), strings.Split(sources, "+"), resolution}
+	}
+	*c = composite
+	return nil
+}
+
+func (l *ShortcutList) String() string {
+	shortcuts := make([]string, len(*l))
+	for i, chord := range *l {
+		shortcuts[i] = keyboard.ChordString(chord)
+	}
+	return strings.Join(shortcuts, ",")
+}
+
+func (l *ShortcutList) Set(s string) error {
+	blocklist, err := keyboard.ParseBlocklist(s)
+	if err != nil {
+		return pkgerrors.NewBadCommanlineArgument("ShortcutList", s, "comma separated shortcuts like META+L,ALT+F4")
+	}
+	*l = blocklist
+	return nil
+}
+
+func (l *RoleList) String() string {
+	roles := make([]string, len(*l))
+	for i, role := range *l {
+		roles[i] = string(role)
+	}
+	return strings.Join(roles, ",")
+}
+
+func (l *RoleList) Set(s string) error {
+	roles := make(RoleList, 0)
+	for _, part := range strings.Split(s, ",") {
+		role := permissions.Role(strings.TrimSpace(part))
+		switch role {
+		case "":
+		case permissions.Viewer, permissions.Controller, permissions.Admin:
+			roles = append(roles, role)
+		default:
+			return pkgerrors.NewBadCommanlineArgument("RoleList", s, "comma separated roles (viewer / controller / admin)")
+		}
+	}
+	*l = roles
+	return nil
+}
+
+func (r *Role) String() string {
+	return string(*r)
+}
+
+func (r *Role) Set(s string) error {
+	switch role := permissions.Role(strings.TrimSpace(s)); role {
+	case permissions.Viewer, permissions.Controller, permissions.Admin:
+		*r = Role(role)
+		return nil
+	}
+	return pkgerrors.NewBadCommanlineArgument("Role", s, "viewer / controller / admin")
+}
+
 func (p *PortNumber) String() string {
 	return fmt.Sprintf("%d", uint(*p))
 }
@@ -73,7 +347,21 @@ func (p *PortNumber) Set(s string) error {
 	if portnum < 0 || portnum > 65535 {
 		goto badFormat
 	}
+	*p = PortNumber(portnum)
 	return nil
 badFormat:
 	return pkgerrors.NewBadCommanlineArgument("Port", s, "0-65535")
 }
+
+func (d *Seconds) String() string {
+	return fmt.Sprintf("%d", time.Duration(*d)/time.Second)
+}
+
+func (d *Seconds) Set(s string) error {
+	seconds, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return pkgerrors.NewBadCommanlineArgument("Seconds", s, "non-negative integer")
+	}
+	*d = Seconds(time.Duration(seconds) * time.Second)
+	return nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+)
+
+// ValidationErrors holds every invalid setting found while loading
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("ValidationErrors: %s", strings.Join(messages, "; "))
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
+
+// ConfigFileError indicates a config file that can't be read or has unknown keys
+type ConfigFileError struct {
+	File   string
+	Reason string
+}
+
+func (e *ConfigFileError) Error() string {
+	return fmt.Sprintf("ConfigFileError: %s: %s", e.File, e.Reason)
+}
+
+func NewConfigFileError(file string, reason string) error {
+	return &ConfigFileError{
+		File:   file,
+		Reason: reason,
+	}
+}
+
+// SecretError indicates a secret that can't be read from its file or credential provider
+type SecretError struct {
+	Name   string
+	Reason string
+}
+
+func (e *SecretError) Error() string {
+	return fmt.Sprintf("SecretError: %s: %s", e.Name, e.Reason)
+}
+
+func NewSecretError(name string, reason string) error {
+	return &SecretError{
+		Name:   name,
+		Reason: reason,
+	}
+}
+
+// TemplateError indicates a video template that can't be read or expanded
+type TemplateError struct {
+	File   string
+	Reason string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("TemplateError: %s: %s", e.File, e.Reason)
+}
+
+func NewTemplateError(file string, reason string) error {
+	return &TemplateError{
+		File:   file,
+		Reason: reason,
+	}
+}
+
+// UnexpectedArgumentError indicates a command line argument that isn't a flag
+type UnexpectedArgumentError struct {
+	Arg string
+}
+
+func (e *UnexpectedArgumentError) Error() string {
+	return fmt.Sprintf("UnexpectedArgumentError: '%s' is not a flag", e.Arg)
+}
+
+func NewUnexpectedArgumentError(arg string) error {
+	return &UnexpectedArgumentError{
+		Arg: arg,
+	}
+}
+
+// badValue turns the error of setting flag f into a BadCommanlineArgument for where it was set
+func badValue(where string, f *flag.Flag, got string, err error) error {
+	var bad *pkgerrors.BadCommanlineArgument
+	if errors.As(err, &bad) {
+		return pkgerrors.NewBadCommanlineArgument(where, got, bad.ExpectedFormat)
+	}
+	// the flags without their own type are booleans and numbers
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return pkgerrors.NewBadCommanlineArgument(where, got, "true / false")
+	}
+	return pkgerrors.NewBadCommanlineArgument(where, got, "non-negative integer")
+}
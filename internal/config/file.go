@@ -0,0 +1,189 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// a setting of the config file and the flag it stands for
+type fileKey struct {
+	section string
+	key     string
+	flag    string
+	// never printed, it can be read from a file named by the flag with a -file suffix
+	secret bool
+	// the running service applies it on reload, see Reloader
+	live bool
+}
+
+// Config file layout, printed in this order
+// ! Every flag except the config file ones must be listed
+var fileKeys = []fileKey{
+	{"video", "resolution", "vresolution", false, true},
+	{"video", "encoder", "vencoder", false, false},
+	{"video", "encoderorder", "vencoderorder", false, false},
+	{"video", "profile", "vprofile", false, false},
+	{"video", "encoderprops", "vencoderprops", false, false},
+	{"video", "template", "vtemplate", false, false},
+	{"video", "sources", "vsources", false, false},
+	{"video", "composite", "vcomposite", false, false},
+	{"video", "framerate", "vframerate", false, true},
+	{"video", "bitrate", "vbitrate", false, true},
+	{"video", "cursor", "vcursor", false, true},
+	{"video", "clientcursor", "vclientcursor", false, false},
+	{"capture", "monitor", "capturemonitor", false, true},
+	{"capture", "window", "capturewindow", false, true},
+	{"capture", "process", "captureprocess", false, true},
+	{"capture", "region", "captureregion", false, true},
+	{"audio", "bitrate", "abitrate", false, true},
+	{"audio", "packetlosspct", "apacketlosspct", false, true},
+	{"microphone", "enabled", "mic", false, false},
+	{"microphone", "device", "micdevice", false, false},
+	{"microphone", "latency", "miclatency", false, false},
+	{"microphone", "muted", "micmuted", false, false},
+	{"camera", "enabled", "camera", false, false},
+	{"camera", "sink", "camerasink", false, false},
+	{"camera", "resolution", "cameraresolution", false, false},
+	{"camera", "framerate", "cameraframerate", false, false},
+	{"controls", "blockedshortcuts", "blockedshortcuts", false, false},
+	{"controls", "clipboardmaxsize", "clipboardmaxsize", false, false},
+	{"controls", "clipboardroles", "clipboardroles", false, true},
+	{"controls", "peerrole", "peerrole", false, false},
+	{"controls", "inputrate", "controlsrate", false, false},
+	{"controls", "inputburst", "controlsburst", false, false},
+	{"controls", "inputcoalesce", "controlscoalesce", false, false},
+	{"controls", "recorddir", "recorddir", false, false},
+	{"controls", "gamepads", "gamepads", false, false},
+	{"controls", "touch", "touch", false, false},
+	{"controls", "pen", "pen", false, false},
+	{"files", "dir", "filesdir", false, false},
+	{"files", "quota", "filesquota", false, false},
+	{"rabbitmq", "host", "rmqhost", false, true},
+	{"rabbitmq", "port", "rmqport", false, true},
+	{"rabbitmq", "vhost", "rmqvhost", false, true},
+	{"rabbitmq", "username", "rmqusername", false, true},
+	{"rabbitmq", "password", "rmqpassword", true, true},
+	{"rabbitmq", "passwordfile", "rmqpassword-file", false, true},
+	{"rabbitmq", "timeout", "rmqtimeout", false, true},
+}
+
+const redacted = "REDACTED"
+
+func findFileKey(section string, key string) (fileKey, bool) {
+	for _, k := range fileKeys {
+		if k.section == section && k.key == key {
+			return k, true
+		}
+	}
+	return fileKey{}, false
+}
+
+// fileValue turns a scalar, or a list of scalars joined by commas, into a flag value
+func fileValue(node *yaml.Node) (string, bool) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, true
+	case yaml.SequenceNode:
+		values := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", false
+			}
+			values[i] = item.Value
+		}
+		return strings.Join(values, ","), true
+	}
+	return "", false
+}
+
+// applyConfigFile sets the flags the file has a value for, unless the command line or
+// the environment set them already. Unknown keys are errors
+func applyConfigFile(fs *flag.FlagSet, name string, r io.Reader) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var root yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&root); err != nil {
+		if err == io.EOF {
+			// empty file
+			return nil
+		}
+		return NewConfigFileError(name, err.Error())
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		return NewConfigFileError(name, "expected a mapping of sections")
+	}
+	for i := 0; i < len(document.Content); i += 2 {
+		section, settings := document.Content[i], document.Content[i+1]
+		if settings.Kind != yaml.MappingNode {
+			return NewConfigFileError(name, fmt.Sprintf("line %d: section %s should be a mapping", section.Line, section.Value))
+		}
+		for j := 0; j < len(settings.Content); j += 2 {
+			key, value := settings.Content[j], settings.Content[j+1]
+			k, ok := findFileKey(section.Value, key.Value)
+			if !ok {
+				return NewConfigFileError(name, fmt.Sprintf("line %d: unknown key %s.%s", key.Line, section.Value, key.Value))
+			}
+			v, ok := fileValue(value)
+			if !ok {
+				return NewConfigFileError(name, fmt.Sprintf("line %d: %s.%s should be a value or a list of values", value.Line, k.section, k.key))
+			}
+			if set[k.flag] {
+				continue
+			}
+			if err := fs.Set(k.flag, v); err != nil {
+				return NewConfigFileError(name, fmt.Sprintf("line %d: %s.%s: %s", value.Line, k.section, k.key, err.Error()))
+			}
+		}
+	}
+	return nil
+}
+
+// loadConfigFile applies a config file, see applyConfigFile
+func loadConfigFile(fs *flag.FlagSet, name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return NewConfigFileError(name, err.Error())
+	}
+	defer file.Close()
+	return applyConfigFile(fs, name, file)
+}
+
+// printConfig writes the effective settings in the config file format, secrets redacted
+func printConfig(fs *flag.FlagSet, w io.Writer) error {
+	document := &yaml.Node{Kind: yaml.MappingNode}
+	var settings *yaml.Node
+	for _, k := range fileKeys {
+		if settings == nil || document.Content[len(document.Content)-2].Value != k.section {
+			settings = &yaml.Node{Kind: yaml.MappingNode}
+			document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k.section}, settings)
+		}
+		value := fs.Lookup(k.flag).Value.String()
+		if k.secret && value != "" {
+			value = redacted
+		}
+		valueNode := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if value == "" {
+			// would read back as null otherwise
+			valueNode.Style = yaml.DoubleQuotedStyle
+		}
+		settings.Content = append(settings.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k.key}, valueNode)
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
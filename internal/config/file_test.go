@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"flag"
+	"github.com/stretchr/testify/assert"
+)
+
+type configFileTest struct {
+	file     string
+	args     []string
+	expected string
+	err      bool
+}
+
+func newTestFlagSet() (*flag.FlagSet, *Resolution, *string, *RoleList) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	resolution := &Resolution{}
+	password := new(string)
+	roles := &RoleList{}
+	fs.Var(resolution, "vresolution", "")
+	fs.StringVar(password, "rmqpassword", "", "")
+	fs.Var(roles, "clipboardroles", "")
+	return fs, resolution, password, roles
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	configFileTests := []configFileTest{
+		{"", nil, "0x0", false},
+		{"video:\n  resolution: 1280x720\n", nil, "1280x720", false},
+		// flags win over the file
+		{"video:\n  resolution: 1280x720\n", []string{"-vresolution", "800x600"}, "800x600", false},
+		{"video:\n  resolution: bad\n", nil, "", true},
+		{"video:\n  resolutoin: 1280x720\n", nil, "", true},
+		{"vidoe:\n  resolution: 1280x720\n", nil, "", true},
+		{"video: 1280x720\n", nil, "", true},
+		{"video:\n  resolution: [1280x720, {a: b}]\n", nil, "", true},
+		{"- video\n", nil, "", true},
+	}
+	for _, test := range configFileTests {
+		fs, resolution, _, _ := newTestFlagSet()
+		assert.NoError(t, fs.Parse(test.args))
+		err := applyConfigFile(fs, "test.yaml", strings.NewReader(test.file))
+		if test.err {
+			assert.IsType(t, &ConfigFileError{}, err, test.file)
+			continue
+		}
+		assert.NoError(t, err, test.file)
+		assert.Equal(t, test.expected, resolution.String(), test.file)
+	}
+}
+
+func TestConfigFileLists(t *testing.T) {
+	fs, _, _, roles := newTestFlagSet()
+	assert.NoError(t, fs.Parse(nil))
+	assert.NoError(t, applyConfigFile(fs, "test.yaml", strings.NewReader("controls:\n  clipboardroles: [admin, viewer]\n")))
+	assert.Equal(t, "admin,viewer", roles.String())
+}
+
+func TestPrintConfig(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, k := range fileKeys {
+		fs.String(k.flag, "", "")
+	}
+	assert.NoError(t, fs.Parse([]string{"-rmqpassword", "hunter2", "-vframerate", "30"}))
+
+	var out bytes.Buffer
+	assert.NoError(t, printConfig(fs, &out))
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.Contains(t, out.String(), "password: "+redacted)
+	assert.Contains(t, out.String(), "video:\n  resolution: \"\"\n")
+
+	// what is printed reads back the same
+	printed := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, k := range fileKeys {
+		printed.String(k.flag, "", "")
+	}
+	assert.NoError(t, printed.Parse(nil))
+	assert.NoError(t, applyConfigFile(printed, "printed.yaml", &out))
+	assert.Equal(t, "30", printed.Lookup("vframerate").Value.String())
+}
@@ -1,88 +1,217 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/benu-cloud/benu-message/rabbitmq"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
 	"github.com/joho/godotenv"
-	"github.com/namsral/flag"
 )
 
-func ParseArgs() (s StreamSettings, m rabbitmq.MessageBrokerSettings) {
+// newFlagSet defines every setting as a flag writing to cfg, and sets cfg to the defaults
+func newFlagSet(cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("benu-webrtc", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	s, c, f, m := &cfg.Stream, &cfg.Controls, &cfg.Files, &cfg.Broker
+	s.VideoEncoder = H264
+	s.CameraResolution = Resolution{Width: 1280, Height: 720}
+	s.VideoEncoderOrder = EncoderList{NVH264, H264, VP9}
+	s.VideoProfile = UltraLowLatency
+	c.BlockedShortcuts.Set(keyboard.DefaultBlocklist)
+	c.ClipboardReadRoles = RoleList{permissions.Admin, permissions.Controller}
+	c.PeerRole = permissions.Controller
+	m.Port = 5672
+	m.PublishTimeout = 5 * time.Second
+
+	fs.StringVar(&cfg.ConfigFile, "configfile", "", "YAML config file, see config.example.yaml. Environment variables and flags override it.")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "Print the effective config with secrets redacted and exit.")
+	fs.Var((*Seconds)(&cfg.ConfigPoll), "configpoll", "Reload the config file when it changes, checking every this many seconds. 0 only reloads on SIGHUP.")
+
+	fs.Var(&s.VideoResolution, "vresolution", "The resolution to use (required). Should be in the format [WIDTH]x[HEIGHT].")
+	fs.Var(&s.VideoEncoder, "vencoder", "The video encoder to use. auto uses the first of -vencoderorder that works on this host.")
+	fs.Var(&s.VideoEncoderOrder, "vencoderorder", "Comma separated video encoders tried in order by -vencoder auto.")
+	fs.Var(&s.VideoProfile, "vprofile", "Video encoder tuning (ultra-low-latency / balanced / text-sharp / motion).")
+	fs.Var(&s.VideoEncoderOverrides, "vencoderprops", "Comma separated encoder properties set over -vprofile, like speed-preset=fast or H264:ref=3 for one encoder only.")
+	fs.StringVar(&s.Capture.Monitor, "capturemonitor", "", "Monitor to capture, by index like 1 or device name like \\\\.\\DISPLAY2. Empty captures the primary monitor.")
+	fs.StringVar(&s.Capture.Window, "capturewindow", "", "Capture the first application window whose title contains this instead of a monitor.")
+	fs.StringVar(&s.Capture.Process, "captureprocess", "", "Capture the first application window of this executable, like notepad.exe, instead of a monitor.")
+	fs.Var(&s.Capture.Region, "captureregion", "Part of the monitor or window to capture, in the format [WIDTH]x[HEIGHT]+[X]+[Y]. Empty captures all of it.")
+	fs.StringVar(&s.VideoTemplateFile, "vtemplate", "", "File with the capture, convert and encode part of the pipeline, replacing the built-in one. See video-template.example.")
+	fs.Var(&s.VideoSources, "vsources", "Comma separated videos clients can subscribe to next to the screen, as NAME=KIND:TARGET[@[WIDTH]x[HEIGHT]] with KIND monitor, window, process or webcam, like cam=webcam:0.")
+	fs.Var(&s.Composite, "vcomposite", "Draw sources into one video clients can subscribe to as composite, as LAYOUT:SOURCE+SOURCE[@[WIDTH]x[HEIGHT]] with LAYOUT sidebyside, stacked, grid or pip, like pip:screen+cam.")
+	fs.UintVar(&s.VideoBaseFramerate, "vframerate", 60, "Video base framerate.")
+	fs.UintVar(&s.VideoBaseBitrate, "vbitrate", 52000, "Video base bitrate in kbit/sec.")
+	fs.BoolVar(&s.VideoShowCursor, "vcursor", true, "Whether to show cursor in recorded screen.")
+	fs.BoolVar(&s.VideoClientCursor, "vclientcursor", false, "Send the cursor shape and position to clients to draw instead of capturing it. Overrides -vcursor.")
+	fs.UintVar(&s.AudioBaseBitrate, "abitrate", 64000, "Audio base bitrate in bps.")
+	fs.UintVar(&s.AudioBasePacketLossPct, "apacketlosspct", 5, "Audio base packet loss percentage. Should be in range 0-100.")
+	fs.BoolVar(&s.MicrophoneEnabled, "mic", false, "Receive the microphone of each client and play it on the host.")
+	fs.StringVar(&s.MicrophoneDevice, "micdevice", "", "Output device id the microphones are played on, like a virtual audio cable. Empty uses the default device.")
+	fs.UintVar(&s.MicrophoneLatency, "miclatency", 60, "Microphone jitter buffer in ms.")
+	fs.BoolVar(&s.MicrophoneStartMuted, "micmuted", true, "Whether clients start muted until they are unmuted.")
+	fs.BoolVar(&s.CameraEnabled, "camera", false, "Receive the webcam of clients and write the enabled one to a virtual camera on the host.")
+	fs.StringVar(&s.CameraSink, "camerasink", "autovideosink sync=false", "GStreamer sink writing raw video to the virtual camera, like the element of a virtual camera driver with its properties. GStreamer has no virtual camera of its own on Windows, the default shows the webcam in a window on the host.")
+	fs.Var(&s.CameraResolution, "cameraresolution", "Virtual camera resolution. Should be in the format [WIDTH]x[HEIGHT].")
+	fs.UintVar(&s.CameraFramerate, "cameraframerate", 30, "Virtual camera framerate.")
+
+	fs.Var(&c.BlockedShortcuts, "blockedshortcuts", "Comma separated host shortcuts clients can't press, like META+L,ALT+F4. Empty allows all.")
+	fs.UintVar(&c.ClipboardMaxSize, "clipboardmaxsize", 262144, "Largest clipboard content synced with clients in bytes. 0 means no limit.")
+	fs.Var(&c.ClipboardReadRoles, "clipboardroles", "Comma separated roles that receive the host clipboard (viewer / controller / admin).")
+	fs.Var((*Role)(&c.PeerRole), "peerrole", "Role of clients when they join (viewer / controller / admin).")
+	fs.UintVar(&c.InputRate, "controlsrate", 1000, "Input events per second a client may send, the ones above are dropped. 0 means no limit.")
+	fs.UintVar(&c.InputBurst, "controlsburst", 200, "Input events a client may send at once above -controlsrate.")
+	fs.UintVar(&c.InputCoalesce, "controlscoalesce", 8, "Mouse moves and scrolls of clients arriving within this many ms are merged into one. 0 sends each one.")
+	fs.StringVar(&c.RecordDir, "recorddir", "", "Directory the keyboard and mouse input of each client is saved to as a macro when it leaves, see the replay command. Empty records nothing.")
+	fs.UintVar(&c.Gamepads, "gamepads", 4, "Virtual gamepads clients play with, one each, created when a client first uses one. 0 disables them.")
+	fs.BoolVar(&c.Touch, "touch", true, "Let the controlling client use a touchscreen, with multiple contacts.")
+	fs.BoolVar(&c.Pen, "pen", true, "Let the controlling client use a pen, with pressure and tilt.")
+
+	fs.StringVar(&f.SandboxDir, "filesdir", "files", "Directory clients upload files to and download files from.")
+	fs.Uint64Var(&f.Quota, "filesquota", 1073741824, "Most bytes the files directory may take up, uploads in progress included. 0 means no quota.")
+
+	fs.StringVar(&m.Host, "rmqhost", "localhost", "RabbitMQ message broker host.")
+	fs.Var((*PortNumber)(&m.Port), "rmqport", "RabbitMQ message broker port. Should be in the range 0-65535.")
+	fs.StringVar(&m.VHost, "rmqvhost", "", "RabbitMQ virtual host.")
+	fs.StringVar(&m.Username, "rmqusername", "", "RabbitMQ username (required).")
+	fs.StringVar(&m.Password, "rmqpassword", "", "RabbitMQ password (required). Prefer -rmqpassword-file, flags show up in process listings.")
+	fs.StringVar(&cfg.BrokerPasswordFile, "rmqpassword-file", "", "File the RabbitMQ password is read from, like a docker secret.")
+	fs.Var((*Seconds)(&m.PublishTimeout), "rmqtimeout", "RabbitMQ publish timeout in seconds")
+	return fs
+}
+
+// Environ returns the environment variables of the process, and those of the .env file in the working directory
+// the process doesn't set. The file is read on every call, so a reload picks up its changes
+func Environ() []string {
+	env := os.Environ()
+	dotenv, err := godotenv.Read()
+	if err != nil {
+		// like when there is no file
+		return env
+	}
+	set := make(map[string]bool, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		set[key] = true
+	}
+	for key, value := range dotenv {
+		if !set[key] {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// envKey is the environment variable a flag is read from
+func envKey(name string) string {
+	return strings.ReplaceAll(strings.ToUpper(name), "-", "_")
+}
+
+// applyEnv sets the flags the command line didn't set from environment variables, in the form KEY=value
+func applyEnv(fs *flag.FlagSet, env []string) ValidationErrors {
+	values := make(map[string]string)
+	for _, kv := range env {
+		if key, value, ok := strings.Cut(kv, "="); ok && key != "" {
+			values[key] = value
+		}
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var errs ValidationErrors
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := values[envKey(f.Name)]
+		if !ok || set[f.Name] {
+			return
+		}
+		// a bool flag without value is true, like on the command line
+		if b, isBool := f.Value.(interface{ IsBoolFlag() bool }); isBool && b.IsBoolFlag() && value == "" {
+			value = "true"
+		}
+		previous := f.Value.String()
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, badValue(envKey(f.Name), f, value, err))
+			// numbers are zeroed on errors, which would be reported again
+			f.Value.Set(previous)
+		}
+	})
+	return errs
+}
+
+// Load reads the settings from, by increasing precedence, defaults, the config file,
+// the environment in the form KEY=value and the command line arguments without the program name.
+// Secrets are then read from their files, or asked from the credential provider when not set.
+// Every invalid setting is reported in the returned ValidationErrors
+func Load(args []string, env []string) (Config, error) {
+	var cfg Config
+	fs := newFlagSet(&cfg)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return cfg, err
+		}
+		return cfg, ValidationErrors{err}
+	}
+	if fs.NArg() > 0 {
+		return cfg, ValidationErrors{NewUnexpectedArgumentError(fs.Arg(0))}
+	}
+	errs := applyEnv(fs, env)
+	if cfg.ConfigFile != "" {
+		if err := loadConfigFile(fs, cfg.ConfigFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, resolveSecrets(fs)...)
+	if err := loadVideoTemplate(&cfg.Stream); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return cfg, errs
+	}
+	return cfg, nil
+}
+
+// Usage writes the flags, their defaults and their descriptions
+func Usage(w io.Writer) {
+	fs := newFlagSet(&Config{})
+	fs.SetOutput(w)
+	fmt.Fprintln(w, "Usage of benu-webrtc:")
+	fs.PrintDefaults()
+}
+
+// ParseArgs loads the settings from the command line, the environment and .env, see Load.
+// It exits on invalid settings and prints the config when asked to
+func ParseArgs() (s StreamSettings, c ControlsSettings, f FilesSettings, m rabbitmq.MessageBrokerSettings) {
 	// try to load env variables if they exist
 	godotenv.Load()
 
-	var videoResolution Resolution
-	var videoEncoder VideoEncoder = H264
-	var videoBaseFramerate uint
-	var videoBaseBitrate uint
-	var videoShowCursor bool
-	var audioBaseBitrate uint
-	var audioBasePacketLossPct uint
-
-	var rmqhost string
-	var rmqport PortNumber = PortNumber(5672)
-	var rmqvHost string
-	var rmqusername string
-	var rmqpassword string
-	var rmqpublishTimeoutSeconds uint
-
-	flag.Var(&videoResolution, "vresolution", "The resolution to use (required). Should be in the format [WIDTH]x[HEIGHT].")
-	flag.Var(&videoEncoder, "vencoder", "The video encoder to use.")
-	flag.UintVar(&videoBaseFramerate, "vframerate", 60, "Video base framerate.")
-	flag.UintVar(&videoBaseBitrate, "vbitrate", 52000, "Video base bitrate in kbit/sec.")
-	flag.BoolVar(&videoShowCursor, "vcursor", true, "Whether to show cursor in recorded screen.")
-	flag.UintVar(&audioBaseBitrate, "abitrate", 64000, "Audio base bitrate in bps.")
-	flag.UintVar(&audioBasePacketLossPct, "apacketlosspct", 5, "Audio base packet loss percentage. Should be in range 0-100.")
-
-	flag.StringVar(&rmqhost, "rmqhost", "localhost", "RabbitMQ message broker host.")
-	flag.Var(&rmqport, "rmqport", "RabbitMQ message broker port. Should be in the range 0-65535.")
-	flag.StringVar(&rmqvHost, "rmqvhost", "", "RabbitMQ virtual host.")
-	flag.StringVar(&rmqusername, "rmqusername", "", "RabbitMQ username (required).")
-	flag.StringVar(&rmqpassword, "rmqpassword", "", "RabbitMQ password (required).")
-	flag.UintVar(&rmqpublishTimeoutSeconds, "rmqtimeout", 5, "RabbitMQ publish timeout in seconds")
-
-	flag.Parse()
-
-	// check required fields
-	if videoResolution.Width == 0 || videoResolution.Height == 0 {
-		fmt.Println("Error: the flag -vresolution is required.")
-		flag.Usage()
-		os.Exit(1)
+	cfg, err := Load(os.Args[1:], os.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		Usage(os.Stdout)
+		os.Exit(0)
 	}
-	if audioBasePacketLossPct >= 100 {
-		fmt.Println("Error: the flag -apacketlosspct is less than 100")
-		flag.Usage()
-		os.Exit(1)
-	}
-	if rmqusername == "" {
-		fmt.Println("Error: the flag -rmqusername is required.")
-		flag.Usage()
-		os.Exit(1)
+	if cfg.PrintConfig {
+		// printed even when invalid, to see what went wrong
+		if printErr := cfg.Print(os.Stdout); printErr != nil {
+			fmt.Println("Error:", printErr)
+			os.Exit(1)
+		}
+		if err == nil {
+			os.Exit(0)
+		}
 	}
-	if rmqpassword == "" {
-		fmt.Println("Error: the flag -rmqpassword is required.")
-		flag.Usage()
+	if err != nil {
+		fmt.Println("Error:", err)
+		Usage(os.Stdout)
 		os.Exit(1)
 	}
-
-	s.AudioBaseBitrate = audioBaseBitrate
-	s.AudioBasePacketLossPct = audioBasePacketLossPct
-	s.VideoBaseBitrate = videoBaseBitrate
-	s.VideoBaseFramerate = videoBaseFramerate
-	s.VideoEncoder = videoEncoder
-	s.VideoResolution = videoResolution
-	s.VideoShowCursor = videoShowCursor
-	s.VideoResolution = videoResolution
-
-	m.Host = rmqhost
-	m.Password = rmqpassword
-	m.Port = uint(rmqport)
-	m.Username = rmqusername
-	m.VHost = rmqvHost
-	m.PublishTimeout = time.Second * time.Duration(rmqpublishTimeoutSeconds)
-
-	return
+	return cfg.Stream, cfg.Controls, cfg.Files, cfg.Broker
 }
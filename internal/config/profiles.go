@@ -0,0 +1,107 @@
+package config
+
+import (
+	"sort"
+)
+
+// Encoder profiles
+const (
+	// the lowest delay, what the pipeline always used
+	UltraLowLatency EncoderProfile = "ultra-low-latency"
+	// more time per frame and larger buffers for quality
+	Balanced EncoderProfile = "balanced"
+	// desktops, where text should stay readable and most of the screen is still
+	TextSharp EncoderProfile = "text-sharp"
+	// games and video, where much of the screen changes every frame
+	Motion EncoderProfile = "motion"
+)
+
+// profileProperties is the tuning of each encoder per profile.
+// The bitrate, keyframes and threads are set by the pipeline
+var profileProperties = map[EncoderProfile]map[VideoEncoder]map[string]string{
+	UltraLowLatency: {
+		VP9: {
+			"buffer-initial-size": "500", "buffer-optimal-size": "600", "buffer-size": "1500",
+			"deadline": "1", "cpu-used": "8", "max-intra-bitrate": "250", "static-threshold": "1",
+			"error-resilient": "default", "row-mt": "true",
+		},
+		H264: {
+			"vbv-buf-capacity": "750", "speed-preset": "veryfast", "tune": "zerolatency",
+			"sliced-threads": "true", "b-adapt": "false", "ref": "1", "psy-tune": "ssim",
+		},
+		NVH264: {
+			"vbv-buffer-size": "1300", "b-adapt": "false", "rc-lookahead": "0", "zerolatency": "true",
+			"preset": "low-latency-hq", "rc-mode": "cbr",
+		},
+	},
+	Balanced: {
+		VP9: {
+			"buffer-initial-size": "1000", "buffer-optimal-size": "1200", "buffer-size": "3000",
+			"deadline": "1", "cpu-used": "6", "max-intra-bitrate": "400", "static-threshold": "1",
+			"error-resilient": "default", "row-mt": "true",
+		},
+		H264: {
+			"vbv-buf-capacity": "1000", "speed-preset": "faster", "tune": "zerolatency",
+			"sliced-threads": "true", "b-adapt": "false", "ref": "2", "psy-tune": "ssim",
+		},
+		NVH264: {
+			"vbv-buffer-size": "2000", "b-adapt": "false", "rc-lookahead": "0", "zerolatency": "true",
+			"preset": "low-latency-hq", "rc-mode": "cbr-hq",
+		},
+	},
+	TextSharp: {
+		VP9: {
+			"buffer-initial-size": "1000", "buffer-optimal-size": "1200", "buffer-size": "3000",
+			"deadline": "1", "cpu-used": "6", "max-intra-bitrate": "600", "static-threshold": "100",
+			"error-resilient": "default", "row-mt": "true", "tune-content": "screen",
+		},
+		H264: {
+			"vbv-buf-capacity": "1000", "speed-preset": "faster", "tune": "zerolatency+stillimage",
+			"sliced-threads": "true", "b-adapt": "false", "ref": "2", "psy-tune": "none",
+		},
+		NVH264: {
+			"vbv-buffer-size": "2000", "b-adapt": "false", "rc-lookahead": "0", "zerolatency": "true",
+			"preset": "low-latency-hq", "rc-mode": "cbr-hq", "spatial-aq": "true",
+		},
+	},
+	Motion: {
+		VP9: {
+			"buffer-initial-size": "500", "buffer-optimal-size": "800", "buffer-size": "2000",
+			"deadline": "1", "cpu-used": "8", "max-intra-bitrate": "300", "static-threshold": "0",
+			"error-resilient": "default", "row-mt": "true",
+		},
+		H264: {
+			"vbv-buf-capacity": "1000", "speed-preset": "superfast", "tune": "zerolatency",
+			"sliced-threads": "true", "b-adapt": "false", "ref": "1", "psy-tune": "film",
+		},
+		NVH264: {
+			"vbv-buffer-size": "2600", "b-adapt": "false", "rc-lookahead": "0", "zerolatency": "true",
+			"preset": "low-latency-hp", "rc-mode": "cbr-ld-hq",
+		},
+	},
+}
+
+// EncoderProperties returns the name=value properties the encoder is tuned with, sorted by name.
+// Those of the profile are overridden by VideoEncoderOverrides, the ones for this encoder last
+func (s *StreamSettings) EncoderProperties(encoder VideoEncoder) []string {
+	properties := make(map[string]string)
+	for name, value := range profileProperties[s.VideoProfile][encoder] {
+		properties[name] = value
+	}
+	for _, o := range s.VideoEncoderOverrides {
+		if o.Encoder == nil {
+			properties[o.Name] = o.Value
+		}
+	}
+	for _, o := range s.VideoEncoderOverrides {
+		if o.Encoder != nil && *o.Encoder == encoder {
+			properties[o.Name] = o.Value
+		}
+	}
+	lines := make([]string, 0, len(properties))
+	for name, value := range properties {
+		lines = append(lines, name+"="+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
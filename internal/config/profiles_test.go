@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfilesCoverEncoders(t *testing.T) {
+	for profile, encoders := range profileProperties {
+		for _, encoder := range []VideoEncoder{VP9, H264, NVH264} {
+			assert.NotEmpty(t, encoders[encoder], "%s %d", profile, encoder)
+		}
+	}
+}
+
+func TestEncoderProperties(t *testing.T) {
+	cfg, err := Load(append([]string{"-vprofile", "text-sharp", "-vencoderprops", "ref=3, H264:speed-preset=fast,VP9:ref=4,H264:ref=5"}, required...), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, TextSharp, cfg.Stream.VideoProfile)
+	assert.Equal(t, "ref=3,H264:speed-preset=fast,VP9:ref=4,H264:ref=5", cfg.Stream.VideoEncoderOverrides.String())
+
+	properties := cfg.Stream.EncoderProperties(H264)
+	// sorted, the encoder's own overrides last
+	assert.Equal(t, []string{
+		"b-adapt=false", "psy-tune=none", "ref=5", "sliced-threads=true",
+		"speed-preset=fast", "tune=zerolatency+stillimage", "vbv-buf-capacity=1000",
+	}, properties)
+	assert.Contains(t, cfg.Stream.EncoderProperties(NVH264), "ref=3")
+	assert.Contains(t, cfg.Stream.EncoderProperties(VP9), "ref=4")
+
+	for _, bad := range []string{"ref", "=3", "ref=", "auto:ref=3", "X264:ref=3"} {
+		_, err = Load(append([]string{"-vencoderprops", bad}, required...), nil)
+		assert.Error(t, err, bad)
+	}
+	_, err = Load(append([]string{"-vprofile", "fast"}, required...), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Change is a setting that differs between two configs
+type Change struct {
+	// as in the config file, like video.bitrate
+	Setting string
+	// redacted for secrets
+	Old string
+	New string
+	// whether the running service applies it, otherwise it needs a restart
+	Live bool
+	flag string
+}
+
+func (c Change) String() string {
+	if c.Live {
+		return fmt.Sprintf("%s changed from '%s' to '%s'", c.Setting, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s changed from '%s' to '%s', restart to apply", c.Setting, c.Old, c.New)
+}
+
+// Diff lists the settings of the config file that differ between two configs
+func Diff(old Config, new Config) []Change {
+	oldFlags, _ := flagsOf(old)
+	newFlags, _ := flagsOf(new)
+	changes := make([]Change, 0)
+	for _, k := range fileKeys {
+		o, n := oldFlags.Lookup(k.flag).Value.String(), newFlags.Lookup(k.flag).Value.String()
+		if o == n {
+			continue
+		}
+		if k.secret {
+			o, n = redacted, redacted
+		}
+		changes = append(changes, Change{
+			Setting: k.section + "." + k.key,
+			Old:     o,
+			New:     n,
+			Live:    k.live,
+			flag:    k.flag,
+		})
+	}
+	return changes
+}
+
+// RestartSettings lists the settings of the sections that the running service doesn't apply,
+// like video.encoder, as in the config file
+func RestartSettings(sections ...string) []string {
+	settings := make([]string, 0)
+	for _, k := range fileKeys {
+		if k.live {
+			continue
+		}
+		for _, section := range sections {
+			if k.section == section {
+				settings = append(settings, k.section+"."+k.key)
+			}
+		}
+	}
+	return settings
+}
+
+// ApplyFunc applies the live changes to the running service, cfg is the config with them.
+// The stream settings go to stream.UpdateSettings, the clipboard roles to Permissions.SetClipboardRoles
+// and the rabbitmq settings to broker.Reconnect. It returns the changes it applied, also when it fails partway
+type ApplyFunc func(cfg Config, changes []Change) ([]Change, error)
+
+// Reloader loads the config again with the arguments it started with and the environment env returns,
+// which picks up changes of the config file and of a .env file, see Environ
+type Reloader struct {
+	mutex   sync.Mutex
+	args    []string
+	env     func() []string
+	current Config
+	apply   ApplyFunc
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewReloader creates a reloader, env is called on every reload and may be nil for no environment
+func NewReloader(args []string, env func() []string, current Config, apply ApplyFunc) *Reloader {
+	return &Reloader{
+		args:    args,
+		env:     env,
+		current: current,
+		apply:   apply,
+		done:    make(chan struct{}),
+	}
+}
+
+// Config returns the running config
+func (r *Reloader) Config() Config {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.current
+}
+
+// Reload loads the config and applies the live changes, the others are logged as needing a restart
+// and the running config keeps their old value. An invalid config is rejected, keeping the running one,
+// and when applying fails the running config only takes the changes that were applied
+func (r *Reloader) Reload() ([]Change, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var env []string
+	if r.env != nil {
+		env = r.env()
+	}
+	loaded, err := Load(r.args, env)
+	if err != nil {
+		log.Println("config reload rejected:", err)
+		return nil, err
+	}
+	changes := Diff(r.current, loaded)
+	if len(changes) == 0 {
+		log.Println("config reload: nothing changed")
+	}
+	live := make([]Change, 0)
+	for _, c := range changes {
+		log.Println("config reload:", c)
+		if c.Live {
+			live = append(live, c)
+		}
+	}
+	next := withChanges(r.current, loaded, live)
+	if len(live) > 0 && r.apply != nil {
+		applied, err := r.apply(next, live)
+		if err != nil {
+			log.Println("config reload failed:", err)
+			r.current = withChanges(r.current, loaded, applied)
+			return changes, err
+		}
+	}
+	r.current = next
+	return changes, nil
+}
+
+// withChanges returns cfg with the changed settings taken from loaded
+func withChanges(cfg Config, loaded Config, changes []Change) Config {
+	loadedFlags, _ := flagsOf(loaded)
+	nextFlags, next := flagsOf(cfg)
+	for _, c := range changes {
+		// the values were valid when loaded
+		nextFlags.Set(c.flag, loadedFlags.Lookup(c.flag).Value.String())
+	}
+	return *next
+}
+
+// Watch reloads on SIGHUP, and when the config file is modified if poll isn't zero, until Close
+func (r *Reloader) Watch(poll time.Duration) {
+	signals := make(chan os.Signal, 1)
+	// never sent on windows, where the file is polled instead
+	signal.Notify(signals, syscall.SIGHUP)
+	file := r.Config().ConfigFile
+	modified := modTime(file)
+	go func() {
+		defer signal.Stop(signals)
+		var ticks <-chan time.Time
+		if poll > 0 && file != "" {
+			ticker := time.NewTicker(poll)
+			defer ticker.Stop()
+			ticks = ticker.C
+		}
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-signals:
+				// the file read by this reload isn't reloaded again by the next tick
+				modified = modTime(file)
+				r.Reload()
+			case <-ticks:
+				if m := modTime(file); !m.Equal(modified) {
+					modified = m
+					r.Reload()
+				}
+			}
+		}
+	}()
+}
+
+// Close stops watching
+func (r *Reloader) Close() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+// zero if the file can't be read, so it is reloaded once it can
+func modTime(name string) time.Time {
+	info, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, file string, content string) {
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+}
+
+func TestDiff(t *testing.T) {
+	old, err := Load(required, nil)
+	assert.NoError(t, err)
+	new := old
+	new.Stream.VideoBaseBitrate = 1000
+	new.Broker.Password = "rotated"
+	new.Broker.Host = "broker"
+	assert.Equal(t, []Change{
+		{Setting: "video.bitrate", Old: "52000", New: "1000", Live: true, flag: "vbitrate"},
+		{Setting: "rabbitmq.host", Old: "localhost", New: "broker", Live: true, flag: "rmqhost"},
+		{Setting: "rabbitmq.password", Old: redacted, New: redacted, Live: true, flag: "rmqpassword"},
+	}, Diff(old, new))
+	assert.Empty(t, Diff(old, old))
+}
+
+func TestRestartSettings(t *testing.T) {
+	assert.Equal(t, []string{"microphone.enabled", "microphone.device", "microphone.latency", "microphone.muted"}, RestartSettings("microphone"))
+	assert.Equal(t, []string{"camera.enabled", "camera.sink", "camera.resolution", "camera.framerate", "files.dir", "files.quota"}, RestartSettings("camera", "files"))
+	assert.Empty(t, RestartSettings("capture", "audio"))
+}
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "video:\n  bitrate: 1000\n")
+	args := append([]string{"-configfile", file}, required...)
+	cfg, err := Load(args, nil)
+	assert.NoError(t, err)
+
+	applied := make([]Config, 0)
+	r := NewReloader(args, nil, cfg, func(cfg Config, changes []Change) ([]Change, error) {
+		applied = append(applied, cfg)
+		return changes, nil
+	})
+	writeConfig(t, file, "video:\n  bitrate: 2000\nfiles:\n  quota: 1000\n")
+	changes, err := r.Reload()
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, uint(2000), applied[0].Stream.VideoBaseBitrate)
+		// needs a restart, so it isn't applied
+		assert.Equal(t, uint64(1073741824), applied[0].Files.Quota)
+	}
+	assert.Equal(t, applied[0], r.Config())
+
+	// a bad config is rejected and the running one kept
+	writeConfig(t, file, "video:\n  bitrate: fast\n")
+	_, err = r.Reload()
+	assert.Error(t, err)
+	writeConfig(t, file, "video:\n  bitrat: 3000\n")
+	_, err = r.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, uint(2000), r.Config().Stream.VideoBaseBitrate)
+	assert.Len(t, applied, 1)
+
+	// only the restart is left, nothing to apply
+	writeConfig(t, file, "video:\n  bitrate: 2000\nfiles:\n  quota: 1000\n")
+	changes, err = r.Reload()
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Len(t, applied, 1)
+}
+
+func TestReloadEnv(t *testing.T) {
+	env := []string{"VBITRATE=1000"}
+	cfg, err := Load(required, env)
+	assert.NoError(t, err)
+	r := NewReloader(required, func() []string { return env }, cfg, func(cfg Config, changes []Change) ([]Change, error) {
+		return changes, nil
+	})
+	// like a changed .env file
+	env = []string{"VBITRATE=2000"}
+	changes, err := r.Reload()
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, uint(2000), r.Config().Stream.VideoBaseBitrate)
+}
+
+func TestReloadPartiallyApplied(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "video:\n  bitrate: 1000\naudio:\n  bitrate: 96000\n")
+	args := append([]string{"-configfile", file}, required...)
+	cfg, err := Load(args, nil)
+	assert.NoError(t, err)
+
+	fail := true
+	r := NewReloader(args, nil, cfg, func(cfg Config, changes []Change) ([]Change, error) {
+		if fail {
+			return changes[:1], errors.New("audio bitrate not applied")
+		}
+		return changes, nil
+	})
+	writeConfig(t, file, "video:\n  bitrate: 2000\naudio:\n  bitrate: 128000\n")
+	_, err = r.Reload()
+	assert.Error(t, err)
+	// the video bitrate was applied before failing
+	assert.Equal(t, uint(2000), r.Config().Stream.VideoBaseBitrate)
+	assert.Equal(t, uint(96000), r.Config().Stream.AudioBaseBitrate)
+
+	// the next reload applies what is left
+	fail = false
+	changes, err := r.Reload()
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, uint(128000), r.Config().Stream.AudioBaseBitrate)
+}
+
+func TestWatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, file, "video:\n  bitrate: 1000\n")
+	args := append([]string{"-configfile", file}, required...)
+	cfg, err := Load(args, nil)
+	assert.NoError(t, err)
+
+	var mutex sync.Mutex
+	bitrates := make([]uint, 0)
+	r := NewReloader(args, nil, cfg, func(cfg Config, changes []Change) ([]Change, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		bitrates = append(bitrates, cfg.Stream.VideoBaseBitrate)
+		return changes, nil
+	})
+	r.Watch(time.Millisecond)
+	defer r.Close()
+	writeConfig(t, file, "video:\n  bitrate: 2000\n")
+	// the modification time may not change within its resolution
+	assert.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Hour)))
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(bitrates) == 1 && bitrates[0] == 2000
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+)
+
+// CredentialProvider looks up the secrets the settings don't set, like from a vault.
+// name is the flag of the secret, like rmqpassword
+type CredentialProvider interface {
+	Credential(name string) (value string, ok bool, err error)
+}
+
+// DirProvider reads each secret from the file named after its flag in Dir,
+// like /run/secrets/rmqpassword. Missing files are not an error
+type DirProvider struct {
+	Dir string
+}
+
+func (p DirProvider) Credential(name string) (string, bool, error) {
+	content, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return trimSecret(content), true, nil
+}
+
+var (
+	providerMutex      sync.Mutex
+	credentialProvider CredentialProvider
+)
+
+// SetCredentialProvider sets the provider Load asks for the secrets that aren't set, nil removes it
+func SetCredentialProvider(p CredentialProvider) {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+	credentialProvider = p
+}
+
+func getCredentialProvider() CredentialProvider {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+	return credentialProvider
+}
+
+// files end with a newline more often than not
+func trimSecret(content []byte) string {
+	return strings.TrimRight(string(content), "\r\n")
+}
+
+// resolveSecrets sets each secret from its file, or from the credential provider when it isn't set.
+// Setting both a secret and its file is an error, as it's unclear which one is meant
+func resolveSecrets(fs *flag.FlagSet) ValidationErrors {
+	provider := getCredentialProvider()
+	var errs ValidationErrors
+	for _, k := range fileKeys {
+		if !k.secret {
+			continue
+		}
+		value := fs.Lookup(k.flag).Value.String()
+		file := fs.Lookup(k.flag + "-file").Value.String()
+		switch {
+		case file != "" && value != "":
+			errs = append(errs, pkgerrors.NewBadCommanlineArgument(k.flag+"-file", file, "not set along with "+k.flag))
+		case file != "":
+			content, err := os.ReadFile(file)
+			if err != nil {
+				errs = append(errs, NewSecretError(k.flag, err.Error()))
+				continue
+			}
+			fs.Set(k.flag, trimSecret(content))
+		case value == "" && provider != nil:
+			secret, ok, err := provider.Credential(k.flag)
+			if err != nil {
+				errs = append(errs, NewSecretError(k.flag, err.Error()))
+				continue
+			}
+			if ok {
+				fs.Set(k.flag, secret)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type mapProvider map[string]string
+
+func (p mapProvider) Credential(name string) (string, bool, error) {
+	value, ok := p[name]
+	return value, ok, nil
+}
+
+type failingProvider struct{}
+
+func (failingProvider) Credential(name string) (string, bool, error) {
+	return "", false, errors.New("unreachable")
+}
+
+var withoutPassword = required[:4]
+
+func TestSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "rmqpassword")
+	assert.NoError(t, os.WriteFile(file, []byte("from file\n"), 0o600))
+
+	cfg, err := Load(append([]string{"-rmqpassword-file", file}, withoutPassword...), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "from file", cfg.Broker.Password)
+	cfg, err = Load(withoutPassword, []string{"RMQPASSWORD_FILE=" + file})
+	assert.NoError(t, err)
+	assert.Equal(t, "from file", cfg.Broker.Password)
+
+	// both set
+	_, err = Load(append([]string{"-rmqpassword-file", file}, required...), nil)
+	var bad *pkgerrors.BadCommanlineArgument
+	if assert.ErrorAs(t, err, &bad) {
+		assert.Equal(t, "rmqpassword-file", bad.For)
+	}
+	_, err = Load(append([]string{"-rmqpassword-file", filepath.Join(dir, "missing")}, withoutPassword...), nil)
+	var secretErr *SecretError
+	assert.ErrorAs(t, err, &secretErr)
+}
+
+func TestCredentialProvider(t *testing.T) {
+	defer SetCredentialProvider(nil)
+	SetCredentialProvider(mapProvider{"rmqpassword": "from provider"})
+	cfg, err := Load(withoutPassword, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "from provider", cfg.Broker.Password)
+	// only asked when not set
+	cfg, err = Load(required, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", cfg.Broker.Password)
+
+	SetCredentialProvider(mapProvider{})
+	_, err = Load(withoutPassword, nil)
+	assert.Error(t, err)
+
+	SetCredentialProvider(failingProvider{})
+	_, err = Load(withoutPassword, nil)
+	var secretErr *SecretError
+	assert.ErrorAs(t, err, &secretErr)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "rmqpassword"), []byte("from dir\r\n"), 0o600))
+	SetCredentialProvider(DirProvider{Dir: dir})
+	cfg, err = Load(withoutPassword, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "from dir", cfg.Broker.Password)
+}
+
+func TestConfigString(t *testing.T) {
+	cfg, err := Load(required, nil)
+	assert.NoError(t, err)
+	for _, s := range []string{cfg.String(), fmt.Sprint(cfg), fmt.Sprintf("%v", &cfg)} {
+		assert.Contains(t, s, "  password: "+redacted+"\n")
+		assert.NotContains(t, s, ": password\n")
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import "regexp"
+
+// names of video sources, which end up in element names and stream ids
+var sourceNamePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// VideoSource returns the source of VideoSources with the name
+func (s *StreamSettings) VideoSource(name string) (VideoSource, bool) {
+	for _, v := range s.VideoSources {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return VideoSource{}, false
+}
+
+// SourceResolution is the resolution a source is encoded at
+func (s *StreamSettings) SourceResolution(v VideoSource) Resolution {
+	if v.Resolution == (Resolution{}) {
+		return s.VideoResolution
+	}
+	return v.Resolution
+}
+
+// CompositeResolution is the resolution the composite is drawn and encoded at
+func (s *StreamSettings) CompositeResolution() Resolution {
+	if s.Composite.Resolution == (Resolution{}) {
+		return s.VideoResolution
+	}
+	return s.Composite.Resolution
+}
+
+// inComposite reports whether the composite draws the source
+func (s *StreamSettings) inComposite(name string) bool {
+	for _, source := range s.Composite.Sources {
+		if source == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribableSources lists the videos peers can subscribe to: the screen, the sources and the composite.
+// A webcam can only be opened once, so one the composite draws is only sent as part of it
+func (s *StreamSettings) SubscribableSources() []string {
+	names := []string{ScreenSource}
+	for _, v := range s.VideoSources {
+		if v.Kind != WebcamSource || !s.inComposite(v.Name) {
+			names = append(names, v.Name)
+		}
+	}
+	if len(s.Composite.Sources) > 0 {
+		names = append(names, CompositeSource)
+	}
+	return names
+}
+
+// CompositeRegions returns where the composite draws each of its sources, in their order,
+// in a video of CompositeResolution. Sources are scaled to their region
+func (s *StreamSettings) CompositeRegions() []Region {
+	n := len(s.Composite.Sources)
+	size := s.CompositeResolution()
+	regions := make([]Region, n)
+	switch s.Composite.Layout {
+	case SideBySide:
+		for i := range regions {
+			regions[i] = Region{X: i * (size.Width / n), Width: size.Width / n, Height: size.Height}
+		}
+	case Stacked:
+		for i := range regions {
+			regions[i] = Region{Y: i * (size.Height / n), Width: size.Width, Height: size.Height / n}
+		}
+	case Grid:
+		columns := 1
+		for columns*columns < n {
+			columns++
+		}
+		rows := (n + columns - 1) / columns
+		width, height := size.Width/columns, size.Height/rows
+		for i := range regions {
+			regions[i] = Region{X: i % columns * width, Y: i / columns * height, Width: width, Height: height}
+		}
+	case PictureInPicture:
+		// the others are a quarter of the size, three to a row from the bottom right corner up
+		width, height, margin := size.Width/4, size.Height/4, size.Height/32
+		for i := range regions {
+			if i == 0 {
+				regions[i] = Region{Width: size.Width, Height: size.Height}
+				continue
+			}
+			column, row := (i-1)%3, (i-1)/3
+			regions[i] = Region{
+				X:      size.Width - (column+1)*(width+margin),
+				Y:      size.Height - (row+1)*(height+margin),
+				Width:  width,
+				Height: height,
+			}
+		}
+	}
+	return regions
+}
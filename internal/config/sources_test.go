@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVideoSources(t *testing.T) {
+	cfg, err := Load(append([]string{
+		"-vsources", "cam=webcam:0@1280x720, docs=window:Notes: draft,tv=monitor:",
+		"-vcomposite", "pip:screen+cam@1280x720",
+	}, required...), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, VideoSources{
+		{Name: "cam", Kind: WebcamSource, Target: "0", Resolution: Resolution{Width: 1280, Height: 720}},
+		{Name: "docs", Kind: WindowSource, Target: "Notes: draft"},
+		{Name: "tv", Kind: MonitorSource},
+	}, cfg.Stream.VideoSources)
+	assert.Equal(t, "cam=webcam:0@1280x720,docs=window:Notes: draft,tv=monitor:", cfg.Stream.VideoSources.String())
+	assert.Equal(t, "pip:screen+cam@1280x720", cfg.Stream.Composite.String())
+	// the webcam is only sent in the composite
+	assert.Equal(t, []string{ScreenSource, "docs", "tv", CompositeSource}, cfg.Stream.SubscribableSources())
+	docs, ok := cfg.Stream.VideoSource("docs")
+	assert.True(t, ok)
+	assert.Equal(t, Resolution{Width: 1920, Height: 1080}, cfg.Stream.SourceResolution(docs))
+
+	for _, bad := range [][]string{
+		{"-vsources", "cam"},
+		{"-vsources", "cam=webcam"},
+		{"-vsources", "cam=camera:0"},
+		{"-vsources", "cam=webcam:0@720p"},
+		{"-vcomposite", "screen+cam"},
+		{"-vcomposite", "mosaic:screen+cam"},
+		{"-vcomposite", "pip:"},
+	} {
+		_, err = Load(append(bad, required...), nil)
+		assert.Error(t, err, bad)
+	}
+}
+
+type compositeTest struct {
+	layout   CompositeLayout
+	sources  []string
+	expected []Region
+}
+
+func TestCompositeRegions(t *testing.T) {
+	compositeTests := []compositeTest{
+		{SideBySide, []string{"a", "b"}, []Region{{0, 0, 960, 1080}, {960, 0, 960, 1080}}},
+		{Stacked, []string{"a", "b", "c"}, []Region{{0, 0, 1920, 360}, {0, 360, 1920, 360}, {0, 720, 1920, 360}}},
+		{Grid, []string{"a", "b", "c"}, []Region{{0, 0, 960, 540}, {960, 0, 960, 540}, {0, 540, 960, 540}}},
+		{PictureInPicture, []string{"a", "b", "c"}, []Region{{0, 0, 1920, 1080}, {1407, 777, 480, 270}, {894, 777, 480, 270}}},
+	}
+	for _, test := range compositeTests {
+		s := StreamSettings{
+			VideoResolution: Resolution{Width: 1920, Height: 1080},
+			Composite:       Composite{Layout: test.layout, Sources: test.sources},
+		}
+		assert.Equal(t, test.expected, s.CompositeRegions(), test.layout)
+	}
+}
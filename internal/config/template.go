@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"text/template"
+)
+
+// TemplateValues are the settings a video template can use, like {{.Width}}
+type TemplateValues struct {
+	Width  int
+	Height int
+	// frames per second
+	Framerate uint
+	// in kbit/sec, and in bps for encoders like vp9enc
+	Bitrate    uint
+	BitrateBps uint
+	// whether to capture the cursor
+	Cursor bool
+}
+
+// CaptureCursor is whether the screen is captured with the cursor,
+// clients drawing it themselves mustn't see it twice
+func (s *StreamSettings) CaptureCursor() bool {
+	return s.VideoShowCursor && !s.VideoClientCursor
+}
+
+// ExpandVideoTemplate returns the video template with the settings filled in, empty without a template
+func (s *StreamSettings) ExpandVideoTemplate() (string, error) {
+	if s.VideoTemplate == "" {
+		return "", nil
+	}
+	t, err := template.New(s.VideoTemplateFile).Option("missingkey=error").Parse(s.VideoTemplate)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = t.Execute(&b, TemplateValues{
+		Width:      s.VideoResolution.Width,
+		Height:     s.VideoResolution.Height,
+		Framerate:  s.VideoBaseFramerate,
+		Bitrate:    s.VideoBaseBitrate,
+		BitrateBps: s.VideoBaseBitrate * 1000,
+		Cursor:     s.CaptureCursor(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// loadVideoTemplate reads the video template file and checks it expands
+func loadVideoTemplate(s *StreamSettings) error {
+	if s.VideoTemplateFile == "" {
+		return nil
+	}
+	content, err := os.ReadFile(s.VideoTemplateFile)
+	if err != nil {
+		return NewTemplateError(s.VideoTemplateFile, err.Error())
+	}
+	s.VideoTemplate = string(content)
+	if strings.TrimSpace(s.VideoTemplate) == "" {
+		return NewTemplateError(s.VideoTemplateFile, "empty template")
+	}
+	if _, err := s.ExpandVideoTemplate(); err != nil {
+		return NewTemplateError(s.VideoTemplateFile, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type templateTest struct {
+	template string
+	expanded string
+	err      bool
+}
+
+func TestVideoTemplate(t *testing.T) {
+	templateTests := []templateTest{
+		{"videotestsrc ! video/x-raw,width={{.Width}},height={{.Height}},framerate={{.Framerate}}/1 ! x264enc bitrate={{.Bitrate}}",
+			"videotestsrc ! video/x-raw,width=1920,height=1080,framerate=60/1 ! x264enc bitrate=52000", false},
+		{"{{/* a comment */}}\nvideotestsrc ! vp9enc target-bitrate={{.BitrateBps}}\n",
+			"videotestsrc ! vp9enc target-bitrate=52000000", false},
+		// caps lists aren't placeholders
+		{"d3d11screencapturesrc show-cursor={{.Cursor}} ! video/x-raw,format={ I420, NV12 } ! x264enc",
+			"d3d11screencapturesrc show-cursor=true ! video/x-raw,format={ I420, NV12 } ! x264enc", false},
+		{"videotestsrc ! x264enc bitrate={{.Bitrat}}", "", true},
+		{"videotestsrc ! x264enc bitrate={{.Bitrate}", "", true},
+		{"  \n", "", true},
+	}
+	for _, test := range templateTests {
+		file := filepath.Join(t.TempDir(), "template")
+		assert.NoError(t, os.WriteFile(file, []byte(test.template), 0o600))
+		cfg, err := Load(append([]string{"-vtemplate", file}, required...), nil)
+		if test.err {
+			var templateErr *TemplateError
+			assert.ErrorAs(t, err, &templateErr, test.template)
+			continue
+		}
+		if !assert.NoError(t, err, test.template) {
+			continue
+		}
+		expanded, err := cfg.Stream.ExpandVideoTemplate()
+		assert.NoError(t, err)
+		assert.Equal(t, test.expanded, expanded)
+	}
+}
+
+func TestVideoTemplateSettings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "template")
+	assert.NoError(t, os.WriteFile(file, []byte("videotestsrc ! x264enc bitrate={{.Bitrate}}"), 0o600))
+	cfg, err := Load(append([]string{"-vtemplate", file}, required...), nil)
+	assert.NoError(t, err)
+	// expanded with the live settings
+	bitrate := uint(1000)
+	updated := cfg.Stream.Update(types.StreamSettingsUpdate{VideoBitrate: &bitrate})
+	expanded, err := updated.ExpandVideoTemplate()
+	assert.NoError(t, err)
+	assert.Equal(t, "videotestsrc ! x264enc bitrate=1000", expanded)
+
+	// the peers must know the encoder
+	_, err = Load(append([]string{"-vtemplate", file, "-vencoder", "auto"}, required...), nil)
+	assert.Error(t, err)
+	// it captures by itself
+	_, err = Load(append([]string{"-vtemplate", file, "-capturemonitor", "1"}, required...), nil)
+	assert.Error(t, err)
+	_, err = Load(append([]string{"-vtemplate", filepath.Join(t.TempDir(), "missing")}, required...), nil)
+	var templateErr *TemplateError
+	assert.ErrorAs(t, err, &templateErr)
+
+	// the example is valid
+	_, err = Load(append([]string{"-vtemplate", "../../video-template.example"}, required...), nil)
+	assert.NoError(t, err)
+}
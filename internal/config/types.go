@@ -1,10 +1,38 @@
 package config
 
+import (
+	"time"
+
+	"github.com/benu-cloud/benu-message/rabbitmq"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
 type (
 	// video encoder
 	VideoEncoder int
+	// video encoders by preference
+	EncoderList []VideoEncoder
+	// named encoder tuning
+	EncoderProfile string
+	// encoder properties set over the profile
+	EncoderOverrides []EncoderOverride
+	// what a video source captures
+	SourceKind int
+	// videos peers can subscribe to next to the screen
+	VideoSources []VideoSource
+	// how a composite arranges its sources
+	CompositeLayout string
 	// port number
 	PortNumber uint
+	// shortcuts clients can't press
+	ShortcutList []types.Chord
+	// peer roles
+	RoleList []permissions.Role
+	// a peer role
+	Role permissions.Role
+	// whole seconds
+	Seconds time.Duration
 )
 
 // Supported video encoders
@@ -13,23 +41,182 @@ const (
 	VP9    VideoEncoder = 0
 	H264   VideoEncoder = 1
 	NVH264 VideoEncoder = 2
+	// not in C, the stream picks the first encoder of StreamSettings.VideoEncoderOrder that works on the host
+	Auto VideoEncoder = 3
+)
+
+// EncoderOverride sets a property of the encoder, like ref=3
+type EncoderOverride struct {
+	// only this encoder, nil for any
+	Encoder *VideoEncoder
+	Name    string
+	// in gst-launch syntax
+	Value string
+}
+
+// Region is a rectangle of the screen
+type Region struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// CaptureTarget is what the screen capture records, the primary monitor when empty
+type CaptureTarget struct {
+	// index like 1 or device name like \\.\DISPLAY2
+	Monitor string
+	// the first application window whose title contains this, instead of a monitor
+	Window string
+	// the first application window of this executable, like notepad.exe, instead of a monitor
+	Process string
+	// part of the monitor or window, all of it when empty
+	Region Region
+}
+
+// Kinds of video sources
+const (
+	MonitorSource SourceKind = iota
+	WindowSource
+	ProcessSource
+	WebcamSource
+)
+
+// Names of the video sources that aren't in StreamSettings.VideoSources
+const (
+	// the capture of StreamSettings.Capture, or the template
+	ScreenSource = "screen"
+	// the sources of StreamSettings.Composite drawn into one video
+	CompositeSource = "composite"
+)
+
+// VideoSource is a video peers can subscribe to next to the screen,
+// encoded like the screen and at its framerate and bitrate
+type VideoSource struct {
+	// lowercase letters and digits, the stream id of its track
+	Name string
+	Kind SourceKind
+	// monitor index or device name, window title, executable, or webcam index or name.
+	// Empty is the primary monitor or the default webcam
+	Target string
+	// the video resolution when empty
+	Resolution Resolution
+}
+
+// Composite layouts
+const (
+	// the sources in a row
+	SideBySide CompositeLayout = "sidebyside"
+	// the sources in a column
+	Stacked CompositeLayout = "stacked"
+	// the sources in rows of equal cells
+	Grid CompositeLayout = "grid"
+	// the first source fills the video, the others are small in its bottom right corner
+	PictureInPicture CompositeLayout = "pip"
 )
 
+// Composite draws several sources into one video on the host, which peers can subscribe to as CompositeSource
+type Composite struct {
+	Layout CompositeLayout
+	// names of ScreenSource or StreamSettings.VideoSources, none for no composite
+	Sources []string
+	// the video resolution when empty
+	Resolution Resolution
+}
+
 // Resolution
 type Resolution struct {
 	Height int
 	Width  int
 }
 
+// controls settings
+type ControlsSettings struct {
+	BlockedShortcuts ShortcutList
+	// in bytes, 0 means no limit
+	ClipboardMaxSize   uint
+	ClipboardReadRoles RoleList
+	// the role of peers when they join
+	PeerRole permissions.Role
+	// input events per second a peer may send, 0 means no limit
+	InputRate uint
+	// input events a peer may send at once above InputRate
+	InputBurst uint
+	// in ms, mouse moves and scrolls arriving within it are merged, 0 sends each one
+	InputCoalesce uint
+	// the keyboard and mouse input of each peer is saved here as a macro, empty records nothing
+	RecordDir string
+	// virtual gamepads peers play with, one each, 0 disables them
+	Gamepads uint
+	// whether the controller can use a touchscreen and a pen
+	Touch bool
+	Pen   bool
+}
+
+// file transfer settings
+type FilesSettings struct {
+	// uploads land here and downloads are served from here
+	SandboxDir string
+	// in bytes, 0 means no quota
+	Quota uint64
+}
+
 // stream settings
 type StreamSettings struct {
 	// video
-	VideoResolution    Resolution
-	VideoEncoder       VideoEncoder
+	VideoResolution Resolution
+	VideoEncoder    VideoEncoder
+	// tried in order for Auto
+	VideoEncoderOrder  EncoderList
 	VideoBaseFramerate uint
 	VideoBaseBitrate   uint
 	VideoShowCursor    bool
+	// clients draw the cursor from the cursor datachannel, it isn't captured
+	VideoClientCursor bool
+	// encoder tuning, see StreamSettings.EncoderProperties
+	VideoProfile          EncoderProfile
+	VideoEncoderOverrides EncoderOverrides
+	// can be switched while streaming
+	Capture CaptureTarget
+	// replaces the built-in capture, convert and encode elements, see ExpandVideoTemplate
+	VideoTemplateFile string
+	// the content of the file
+	VideoTemplate string
+	// more videos, see VideoSource
+	VideoSources VideoSources
+	// see Composite
+	Composite Composite
 	// audio
 	AudioBaseBitrate       uint
 	AudioBasePacketLossPct uint
+	// microphone uplink, clients' microphones are played on the host
+	MicrophoneEnabled bool
+	// output device id, empty for the default device
+	MicrophoneDevice string
+	// jitter buffer in ms
+	MicrophoneLatency    uint
+	MicrophoneStartMuted bool
+	// webcam passthrough, a peer's webcam is written to a virtual camera on the host
+	CameraEnabled bool
+	// GStreamer sink of the virtual camera driver, as in a pipeline description
+	CameraSink string
+	// what the virtual camera shows, whatever clients send is scaled to it
+	CameraResolution Resolution
+	CameraFramerate  uint
+}
+
+// all settings
+type Config struct {
+	Stream   StreamSettings
+	Controls ControlsSettings
+	Files    FilesSettings
+	Broker   rabbitmq.MessageBrokerSettings
+	// the YAML file settings were read from, if any
+	ConfigFile string
+	// only print the effective settings
+	PrintConfig bool
+	// how often the config file is checked for changes, 0 disables it
+	ConfigPoll time.Duration
+	// file Broker.Password is read from, like a docker secret
+	BrokerPasswordFile string
 }
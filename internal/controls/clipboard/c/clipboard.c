@@ -0,0 +1,86 @@
+#include "clipboard.h"
+
+#include <stdlib.h>
+#include <string.h>
+
+// other applications hold the clipboard open for short moments
+#define OPEN_ATTEMPTS 10
+#define OPEN_RETRY_MS 10
+
+static int openClipboard(HWND owner) {
+  int i;
+  for (i = 0; i < OPEN_ATTEMPTS; i++) {
+    if (OpenClipboard(owner)) return ERROR_SUCCESS;
+    Sleep(OPEN_RETRY_MS);
+  }
+  return GetLastError();
+}
+
+// the format browsers and image editors use for png images
+UINT pngClipboardFormat() { return RegisterClipboardFormatW(L"PNG"); }
+
+DWORD clipboardSequence() { return GetClipboardSequenceNumber(); }
+
+// copy a format off the clipboard, *data is NULL if the format isn't there
+// and must otherwise be freed by the caller
+int readClipboard(const UINT format, void **data, size_t *size) {
+  *data = NULL;
+  *size = 0;
+  int result = openClipboard(NULL);
+  if (result != ERROR_SUCCESS) return result;
+  HANDLE handle = GetClipboardData(format);
+  if (handle == NULL) {
+    CloseClipboard();
+    return ERROR_SUCCESS;
+  }
+  void *locked = GlobalLock(handle);
+  if (locked == NULL) {
+    result = GetLastError();
+    CloseClipboard();
+    return result;
+  }
+  *size = GlobalSize(handle);
+  *data = malloc(*size);
+  if (*data == NULL) {
+    result = ERROR_NOT_ENOUGH_MEMORY;
+    *size = 0;
+  } else {
+    memcpy(*data, locked, *size);
+  }
+  GlobalUnlock(handle);
+  CloseClipboard();
+  return result;
+}
+
+// replace the clipboard with the given formats
+int writeClipboard(const ClipboardData *formats, const int count) {
+  // setting data needs an owner window, a message-only one is enough
+  HWND owner = CreateWindowExW(0, L"STATIC", NULL, 0, 0, 0, 0, 0, HWND_MESSAGE,
+                               NULL, NULL, NULL);
+  if (owner == NULL) return GetLastError();
+  int result = openClipboard(owner);
+  if (result != ERROR_SUCCESS) {
+    DestroyWindow(owner);
+    return result;
+  }
+  if (!EmptyClipboard()) result = GetLastError();
+  int i;
+  for (i = 0; i < count && result == ERROR_SUCCESS; i++) {
+    HGLOBAL handle = GlobalAlloc(GMEM_MOVEABLE, formats[i].size);
+    if (handle == NULL) {
+      result = ERROR_NOT_ENOUGH_MEMORY;
+      break;
+    }
+    void *locked = GlobalLock(handle);
+    memcpy(locked, formats[i].data, formats[i].size);
+    GlobalUnlock(handle);
+    // the clipboard owns the memory once it is set
+    if (SetClipboardData(formats[i].format, handle) == NULL) {
+      result = GetLastError();
+      GlobalFree(handle);
+    }
+  }
+  CloseClipboard();
+  DestroyWindow(owner);
+  return result;
+}
\ No newline at end of file
@@ -0,0 +1,17 @@
+#ifndef CLIPBOARD_H
+#define CLIPBOARD_H
+#include <stddef.h>
+#include <windows.h>
+
+// one format of what is written to the clipboard
+typedef struct {
+  UINT format;
+  const void *data;
+  size_t size;
+} ClipboardData;
+
+UINT pngClipboardFormat();
+DWORD clipboardSequence();
+int readClipboard(const UINT format, void **data, size_t *size);
+int writeClipboard(const ClipboardData *formats, const int count);
+#endif
\ No newline at end of file
@@ -0,0 +1,148 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+)
+
+// windows keeps images on the clipboard as device independent bitmaps,
+// a BITMAPINFOHEADER (or a larger version of it) followed by the pixels
+
+const (
+	bitmapInfoHeaderSize = 40
+	biRGB                = 0
+	biBitfields          = 3
+)
+
+// dibToPNG converts a 24 or 32 bit bitmap
+func dibToPNG(dib []byte) ([]byte, error) {
+	if len(dib) < bitmapInfoHeaderSize {
+		return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("bitmap of %d bytes has no header", len(dib)))
+	}
+	le := binary.LittleEndian
+	headerSize := int(le.Uint32(dib[0:4]))
+	width := int(int32(le.Uint32(dib[4:8])))
+	height := int(int32(le.Uint32(dib[8:12])))
+	bitCount := int(le.Uint16(dib[14:16]))
+	compression := le.Uint32(dib[16:20])
+	colorsUsed := int(le.Uint32(dib[32:36]))
+	if headerSize < bitmapInfoHeaderSize || headerSize > len(dib) || width <= 0 || height == 0 {
+		return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("bad bitmap header"))
+	}
+	if bitCount != 24 && bitCount != 32 {
+		return nil, pkgerrors.NewNotImplementedError("dibToPNG", fmt.Sprintf("%d bit bitmaps", bitCount))
+	}
+	// rows are stored bottom up unless the height is negative
+	bottomUp := height > 0
+	if !bottomUp {
+		height = -height
+	}
+	offset := headerSize + colorsUsed*4
+	masks := [4]uint32{0x00ff0000, 0x0000ff00, 0x000000ff, 0xff000000}
+	switch compression {
+	case biRGB:
+	case biBitfields:
+		// after a plain header, inside the larger ones
+		at := headerSize
+		if headerSize == bitmapInfoHeaderSize {
+			offset += 12
+		} else {
+			at = bitmapInfoHeaderSize
+		}
+		if at+12 > len(dib) {
+			return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("bitmap masks missing"))
+		}
+		for i := 0; i < 3; i++ {
+			masks[i] = le.Uint32(dib[at+i*4:])
+		}
+		masks[3] = 0
+		if headerSize > bitmapInfoHeaderSize && at+16 <= len(dib) {
+			masks[3] = le.Uint32(dib[at+12:])
+		}
+	default:
+		return nil, pkgerrors.NewNotImplementedError("dibToPNG", fmt.Sprintf("bitmap compression %d", compression))
+	}
+	stride := (width*bitCount + 31) / 32 * 4
+	if offset+stride*height > len(dib) {
+		return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("bitmap of %d bytes is too short for %dx%d", len(dib), width, height))
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	// most programs leave alpha at zero, which means opaque
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		row := y
+		if bottomUp {
+			row = height - 1 - y
+		}
+		src := dib[offset+row*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			if bitCount == 24 {
+				c = color.NRGBA{R: src[x*3+2], G: src[x*3+1], B: src[x*3], A: 0xff}
+			} else {
+				v := le.Uint32(src[x*4:])
+				c = color.NRGBA{R: channel(v, masks[0]), G: channel(v, masks[1]), B: channel(v, masks[2]), A: channel(v, masks[3])}
+				hasAlpha = hasAlpha || c.A != 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	if bitCount == 32 && !hasAlpha {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, pkgerrors.NewMarshalError(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pngToDIB converts to a 32 bit bottom up bitmap
+func pngToDIB(data []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, pkgerrors.NewUnmarshalError(err)
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dib := make([]byte, bitmapInfoHeaderSize+width*height*4)
+	le := binary.LittleEndian
+	le.PutUint32(dib[0:4], bitmapInfoHeaderSize)
+	le.PutUint32(dib[4:8], uint32(width))
+	le.PutUint32(dib[8:12], uint32(height))
+	le.PutUint16(dib[12:14], 1)
+	le.PutUint16(dib[14:16], 32)
+	le.PutUint32(dib[16:20], biRGB)
+	le.PutUint32(dib[20:24], uint32(width*height*4))
+	pixels := dib[bitmapInfoHeaderSize:]
+	for y := 0; y < height; y++ {
+		row := pixels[(height-1-y)*width*4:]
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			copy(row[x*4:], []byte{c.B, c.G, c.R, c.A})
+		}
+	}
+	return dib, nil
+}
+
+// a color channel scaled to 8 bits
+func channel(v uint32, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	value := (v & mask) >> bits.TrailingZeros32(mask)
+	size := bits.OnesCount32(mask)
+	if size >= 8 {
+		return uint8(value >> (size - 8))
+	}
+	return uint8(value * 0xff / (1<<size - 1))
+}
@@ -0,0 +1,85 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a 2x2 image with a different color in every corner
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{G: 0xff, A: 0xff})
+	img.SetNRGBA(0, 1, color.NRGBA{B: 0xff, A: 0xff})
+	img.SetNRGBA(1, 1, color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80})
+	return img
+}
+
+func decodePNG(t *testing.T, data []byte) image.Image {
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	return img
+}
+
+// a bitmap header followed by rows
+func dibHeader(width int32, height int32, bitCount uint16, compression uint32) []byte {
+	header := make([]byte, bitmapInfoHeaderSize)
+	le := binary.LittleEndian
+	le.PutUint32(header[0:4], bitmapInfoHeaderSize)
+	le.PutUint32(header[4:8], uint32(width))
+	le.PutUint32(header[8:12], uint32(height))
+	le.PutUint16(header[12:14], 1)
+	le.PutUint16(header[14:16], bitCount)
+	le.PutUint32(header[16:20], compression)
+	return header
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, testImage()))
+	dib, err := pngToDIB(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Len(t, dib, bitmapInfoHeaderSize+2*2*4)
+	data, err := dibToPNG(dib)
+	assert.NoError(t, err)
+	img := decodePNG(t, data)
+	for _, p := range []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		assert.Equal(t, testImage().NRGBAAt(p.X, p.Y), color.NRGBAModel.Convert(img.At(p.X, p.Y)), p)
+	}
+}
+
+type dibTest struct {
+	name     string
+	dib      []byte
+	expected color.NRGBA
+	err      bool
+}
+
+func TestDIBToPNG(t *testing.T) {
+	dibTests := []dibTest{
+		// rows padded to 4 bytes, the bottom row comes first
+		{"24 bit", append(dibHeader(1, 2, 24, biRGB), 0, 0, 0, 0, 0x30, 0x20, 0x10, 0), color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}, false},
+		{"top down", append(dibHeader(1, -2, 24, biRGB), 0x30, 0x20, 0x10, 0, 0, 0, 0, 0), color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}, false},
+		// zero alpha everywhere is opaque
+		{"32 bit", append(dibHeader(1, 1, 32, biRGB), 0x30, 0x20, 0x10, 0), color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}, false},
+		{"bitfields", append(append(dibHeader(1, 1, 32, biBitfields), 0xff, 0, 0, 0, 0, 0xff, 0, 0, 0, 0, 0xff, 0), 0x10, 0x20, 0x30, 0), color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}, false},
+		{"16 bit", append(dibHeader(1, 1, 16, biRGB), 0, 0, 0, 0), color.NRGBA{}, true},
+		{"too short", dibHeader(4, 4, 32, biRGB), color.NRGBA{}, true},
+		{"no header", []byte{1, 2, 3}, color.NRGBA{}, true},
+	}
+	for _, test := range dibTests {
+		data, err := dibToPNG(test.dib)
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, color.NRGBAModel.Convert(decodePNG(t, data).At(0, 0)), test.name)
+	}
+}
@@ -0,0 +1,19 @@
+package clipboard
+
+import "fmt"
+
+// ClipboardError indicates that reading or writing the clipboard of the OS failed
+type ClipboardError struct {
+	// windows error code, exit code of the clipboard tool on linux
+	Code int
+}
+
+func (e *ClipboardError) Error() string {
+	return fmt.Sprintf("ClipboardError: Code %d", e.Code)
+}
+
+func NewClipboardError(code int) error {
+	return &ClipboardError{
+		Code: code,
+	}
+}
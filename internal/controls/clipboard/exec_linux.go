@@ -0,0 +1,132 @@
+//go:build linux
+
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// text formats, in order of preference
+var textTypes []string = []string{"text/plain;charset=utf-8", "UTF8_STRING", "text/plain"}
+
+const imageType = "image/png"
+
+// exec implementation, with the wl-clipboard tools on wayland and xclip on X11.
+// They hold a single format, so an image is kept rather than its text
+type Clipboard_exec struct {
+	mutex   sync.Mutex
+	wayland bool
+}
+
+// NewClipboard_exec picks the tools for the session, they must be installed
+func NewClipboard_exec() (*Clipboard_exec, error) {
+	wayland := os.Getenv("WAYLAND_DISPLAY") != ""
+	tools := []string{"xclip"}
+	if wayland {
+		tools = []string{"wl-copy", "wl-paste"}
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, err
+		}
+	}
+	return &Clipboard_exec{wayland: wayland}, nil
+}
+
+func (c *Clipboard_exec) Read() (types.ClipboardContent, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var content types.ClipboardContent
+	// the tools fail when nothing is copied
+	available, err := c.types()
+	if err != nil {
+		return content, nil
+	}
+	for _, textType := range textTypes {
+		if available[textType] {
+			text, err := c.read(textType)
+			if err != nil {
+				return content, err
+			}
+			content.Text = string(text)
+			break
+		}
+	}
+	if available[imageType] {
+		content.Image, err = c.read(imageType)
+	}
+	return content, err
+}
+
+func (c *Clipboard_exec) Write(content types.ClipboardContent) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+	case len(content.Image) > 0:
+		return c.write(imageType, content.Image)
+	case content.Text == "" && c.wayland:
+		return run(exec.Command("wl-copy", "--clear"))
+	default:
+		return c.write(textTypes[0], []byte(content.Text))
+	}
+}
+
+// the formats on the clipboard
+func (c *Clipboard_exec) types() (map[string]bool, error) {
+	cmd := exec.Command("xclip", "-selection", "clipboard", "-o", "-t", "TARGETS")
+	if c.wayland {
+		cmd = exec.Command("wl-paste", "--list-types")
+	}
+	out, err := output(cmd)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		available[strings.TrimSpace(line)] = true
+	}
+	return available, nil
+}
+
+func (c *Clipboard_exec) read(mimeType string) ([]byte, error) {
+	cmd := exec.Command("xclip", "-selection", "clipboard", "-o", "-t", mimeType)
+	if c.wayland {
+		cmd = exec.Command("wl-paste", "--no-newline", "--type", mimeType)
+	}
+	return output(cmd)
+}
+
+func (c *Clipboard_exec) write(mimeType string, data []byte) error {
+	cmd := exec.Command("xclip", "-selection", "clipboard", "-i", "-t", mimeType)
+	if c.wayland {
+		cmd = exec.Command("wl-copy", "--type", mimeType)
+	}
+	cmd.Stdin = bytes.NewReader(data)
+	return run(cmd)
+}
+
+func output(cmd *exec.Cmd) ([]byte, error) {
+	out, err := cmd.Output()
+	return out, exitError(err)
+}
+
+// the tools stay in the background to serve the clipboard,
+// so their output isn't waited for
+func run(cmd *exec.Cmd) error {
+	return exitError(cmd.Run())
+}
+
+func exitError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return NewClipboardError(exitErr.ExitCode())
+	}
+	return err
+}
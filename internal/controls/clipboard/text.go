@@ -0,0 +1,29 @@
+package clipboard
+
+import "unicode/utf16"
+
+// windows keeps text on the clipboard as utf-16
+
+// null terminated utf-16
+func encodeText(text string) []byte {
+	units := append(utf16.Encode([]rune(text)), 0)
+	bytes := make([]byte, len(units)*2)
+	for i, unit := range units {
+		bytes[i*2] = byte(unit)
+		bytes[i*2+1] = byte(unit >> 8)
+	}
+	return bytes
+}
+
+// up to the first null, the memory can be larger than the text
+func decodeText(bytes []byte) string {
+	units := make([]uint16, 0, len(bytes)/2)
+	for i := 0; i+1 < len(bytes); i += 2 {
+		unit := uint16(bytes[i]) | uint16(bytes[i+1])<<8
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units))
+}
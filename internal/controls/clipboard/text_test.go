@@ -0,0 +1,16 @@
+package clipboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestText(t *testing.T) {
+	for _, text := range []string{"", "hello", "Zoë Ångström", "名前 😀"} {
+		encoded := encodeText(text)
+		assert.Equal(t, []byte{0, 0}, encoded[len(encoded)-2:])
+		// windows rounds the memory up, anything after the null is ignored
+		assert.Equal(t, text, decodeText(append(encoded, 'x', 0, 0)))
+	}
+}
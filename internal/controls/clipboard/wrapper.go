@@ -0,0 +1,107 @@
+//go:build windows
+
+package clipboard
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -lclipboard
+#include <stdlib.h>
+#include "clipboard.h"
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// c implementation, text is kept as utf-16 and images both as png and as a bitmap
+type Clipboard_c struct {
+	mutex sync.Mutex
+	// registered at runtime
+	pngFormat C.UINT
+}
+
+func NewClipboard_c() (*Clipboard_c, error) {
+	format := C.pngClipboardFormat()
+	if format == 0 {
+		return nil, NewClipboardError(int(C.GetLastError()))
+	}
+	return &Clipboard_c{pngFormat: format}, nil
+}
+
+func (c *Clipboard_c) Read() (types.ClipboardContent, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var content types.ClipboardContent
+	text, err := read(C.CF_UNICODETEXT)
+	if err != nil {
+		return content, err
+	}
+	content.Text = decodeText(text)
+	if content.Image, err = read(c.pngFormat); err != nil {
+		return content, err
+	}
+	if content.Image != nil {
+		return content, nil
+	}
+	// screenshots and most programs only put a bitmap
+	dib, err := read(C.CF_DIB)
+	if err != nil || dib == nil {
+		return content, err
+	}
+	content.Image, err = dibToPNG(dib)
+	return content, err
+}
+
+func (c *Clipboard_c) Write(content types.ClipboardContent) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	formats := make(map[C.UINT][]byte)
+	if content.Text != "" {
+		formats[C.CF_UNICODETEXT] = encodeText(content.Text)
+	}
+	if len(content.Image) > 0 {
+		dib, err := pngToDIB(content.Image)
+		if err != nil {
+			return err
+		}
+		formats[c.pngFormat] = content.Image
+		formats[C.CF_DIB] = dib
+	}
+	// the data is copied to C memory, go pointers can't be kept in C memory
+	data := make([]C.ClipboardData, 0, len(formats))
+	for format, bytes := range formats {
+		cbytes := C.CBytes(bytes)
+		defer C.free(cbytes)
+		data = append(data, C.ClipboardData{format: format, data: cbytes, size: C.size_t(len(bytes))})
+	}
+	var first *C.ClipboardData
+	if len(data) > 0 {
+		first = &data[0]
+	}
+	if code := C.writeClipboard(first, C.int(len(data))); code != C.ERROR_SUCCESS {
+		return NewClipboardError(int(code))
+	}
+	return nil
+}
+
+func (c *Clipboard_c) Sequence() (uint64, error) {
+	return uint64(C.clipboardSequence()), nil
+}
+
+// a format from the clipboard, nil if it isn't there
+func read(format C.UINT) ([]byte, error) {
+	var data unsafe.Pointer
+	var size C.size_t
+	if code := C.readClipboard(format, &data, &size); code != C.ERROR_SUCCESS {
+		return nil, NewClipboardError(int(code))
+	}
+	if data == nil {
+		return nil, nil
+	}
+	defer C.free(data)
+	return C.GoBytes(data, C.int(size)), nil
+}
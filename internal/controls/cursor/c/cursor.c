@@ -0,0 +1,175 @@
+#include "cursor.h"
+
+#include <stdlib.h>
+#include <string.h>
+
+int getCursorState(CursorState *state) {
+  CURSORINFO info = {0};
+  info.cbSize = sizeof(info);
+  if (!GetCursorInfo(&info)) return GetLastError();
+  state->x = info.ptScreenPos.x;
+  state->y = info.ptScreenPos.y;
+  state->visible = (info.flags & CURSOR_SHOWING) != 0;
+  state->shape = (unsigned long long)(ULONG_PTR)info.hCursor;
+  state->screenWidth = GetSystemMetrics(SM_CXSCREEN);
+  state->screenHeight = GetSystemMetrics(SM_CYSCREEN);
+  return ERROR_SUCCESS;
+}
+
+typedef struct {
+  const char *name;
+  // -1 to find the monitor by name
+  int index;
+  int count;
+  HMONITOR monitor;
+} MonitorSearch;
+
+static BOOL CALLBACK onMonitor(HMONITOR monitor, HDC hdc, LPRECT rect,
+                               LPARAM data) {
+  (void)hdc;
+  (void)rect;
+  MonitorSearch *search = (MonitorSearch *)data;
+  bool found;
+  if (search->index >= 0) {
+    found = search->count == search->index;
+  } else {
+    MONITORINFOEXA info = {0};
+    info.cbSize = sizeof(info);
+    found = GetMonitorInfoA(monitor, (MONITORINFO *)&info) &&
+            lstrcmpiA(info.szDevice, search->name) == 0;
+  }
+  search->count++;
+  if (found) search->monitor = monitor;
+  return !found;
+}
+
+// find the area of a monitor named like the capture monitor of the stream:
+// empty for the primary one, an index counted from 0 in the order windows
+// lists the monitors, or a device name like \\.\DISPLAY2
+int getMonitorArea(const char *monitor, CursorArea *area) {
+  MonitorSearch search = {monitor, -1, 0, NULL};
+  if (monitor[0] == '\0') {
+    const POINT origin = {0, 0};
+    search.monitor = MonitorFromPoint(origin, MONITOR_DEFAULTTOPRIMARY);
+  } else {
+    char *end;
+    const long index = strtol(monitor, &end, 10);
+    if (*end == '\0' && index >= 0) search.index = (int)index;
+    EnumDisplayMonitors(NULL, NULL, onMonitor, (LPARAM)&search);
+  }
+  if (search.monitor == NULL) return ERROR_INVALID_PARAMETER;
+  MONITORINFO info = {0};
+  info.cbSize = sizeof(info);
+  if (!GetMonitorInfoA(search.monitor, &info)) return ERROR_INVALID_PARAMETER;
+  area->x = info.rcMonitor.left;
+  area->y = info.rcMonitor.top;
+  area->width = info.rcMonitor.right - info.rcMonitor.left;
+  area->height = info.rcMonitor.bottom - info.rcMonitor.top;
+  return ERROR_SUCCESS;
+}
+
+// read a bitmap as 32 bit bgra, top row first. *bits must be freed by the
+// caller
+static int readBitmap(HBITMAP bitmap, int *width, int *height,
+                      unsigned char **bits) {
+  BITMAP info;
+  if (GetObjectW(bitmap, sizeof(info), &info) == 0)
+    return ERROR_INVALID_HANDLE;
+  *width = info.bmWidth;
+  *height = info.bmHeight;
+  BITMAPINFO header = {0};
+  header.bmiHeader.biSize = sizeof(BITMAPINFOHEADER);
+  header.bmiHeader.biWidth = info.bmWidth;
+  // negative for top-down rows
+  header.bmiHeader.biHeight = -info.bmHeight;
+  header.bmiHeader.biPlanes = 1;
+  header.bmiHeader.biBitCount = 32;
+  header.bmiHeader.biCompression = BI_RGB;
+  *bits = malloc((size_t)info.bmWidth * info.bmHeight * 4);
+  if (*bits == NULL) return ERROR_NOT_ENOUGH_MEMORY;
+  HDC dc = GetDC(NULL);
+  int lines = GetDIBits(dc, bitmap, 0, info.bmHeight, *bits, &header,
+                        DIB_RGB_COLORS);
+  ReleaseDC(NULL, dc);
+  if (lines == 0) {
+    free(*bits);
+    *bits = NULL;
+    return ERROR_INVALID_HANDLE;
+  }
+  return ERROR_SUCCESS;
+}
+
+// read the current cursor shape, image->pixels must be freed with
+// freeCursorImage
+int getCursorImage(CursorImage *image) {
+  memset(image, 0, sizeof(*image));
+  CURSORINFO cursor = {0};
+  cursor.cbSize = sizeof(cursor);
+  if (!GetCursorInfo(&cursor)) return GetLastError();
+  if (cursor.hCursor == NULL) return ERROR_INVALID_CURSOR_HANDLE;
+  ICONINFO icon;
+  if (!GetIconInfo(cursor.hCursor, &icon)) return GetLastError();
+
+  int width = 0, height = 0, maskWidth = 0, maskHeight = 0;
+  unsigned char *color = NULL, *mask = NULL;
+  int result = readBitmap(icon.hbmMask, &maskWidth, &maskHeight, &mask);
+  if (result == ERROR_SUCCESS && icon.hbmColor != NULL)
+    result = readBitmap(icon.hbmColor, &width, &height, &color);
+  if (result != ERROR_SUCCESS) goto done;
+  // a monochrome cursor has its and mask above its xor mask
+  if (color == NULL) {
+    width = maskWidth;
+    height = maskHeight / 2;
+  }
+  const size_t count = (size_t)width * height;
+  image->pixels = malloc(count * 4);
+  if (image->pixels == NULL) {
+    result = ERROR_NOT_ENOUGH_MEMORY;
+    goto done;
+  }
+  // older color cursors leave alpha empty and use the mask for transparency
+  bool hasAlpha = false;
+  for (size_t i = 0; color != NULL && i < count && !hasAlpha; i++)
+    hasAlpha = color[i * 4 + 3] != 0;
+  for (size_t i = 0; i < count; i++) {
+    unsigned char *out = image->pixels + i * 4;
+    // mask bits come out of GetDIBits as black or white pixels
+    const bool transparent = mask[i * 4] != 0;
+    if (color != NULL) {
+      const unsigned char *in = color + i * 4;
+      out[0] = in[2];
+      out[1] = in[1];
+      out[2] = in[0];
+      out[3] = hasAlpha ? in[3] : (transparent ? 0 : 255);
+      continue;
+    }
+    const bool inverted = mask[(i + count) * 4] != 0;
+    if (transparent && !inverted) {
+      memset(out, 0, 4);
+      continue;
+    }
+    // clients can't invert what is under the cursor, black shows on most
+    // backgrounds
+    const unsigned char value = !transparent && inverted ? 255 : 0;
+    out[0] = value;
+    out[1] = value;
+    out[2] = value;
+    out[3] = 255;
+  }
+  image->width = width;
+  image->height = height;
+  image->hotspotX = icon.xHotspot;
+  image->hotspotY = icon.yHotspot;
+
+done:
+  free(color);
+  free(mask);
+  DeleteObject(icon.hbmMask);
+  if (icon.hbmColor != NULL) DeleteObject(icon.hbmColor);
+  return result;
+}
+
+void freeCursorImage(CursorImage *image) {
+  free(image->pixels);
+  image->pixels = NULL;
+}
\ No newline at end of file
@@ -0,0 +1,38 @@
+#ifndef CURSOR_H
+#define CURSOR_H
+#include <stdbool.h>
+#include <windows.h>
+
+typedef struct {
+  // on the primary screen
+  int x;
+  int y;
+  bool visible;
+  // the cursor handle, which stays the same while the shape does
+  unsigned long long shape;
+  int screenWidth;
+  int screenHeight;
+} CursorState;
+
+// rgba pixels with straight alpha, top row first
+typedef struct {
+  int width;
+  int height;
+  int hotspotX;
+  int hotspotY;
+  unsigned char *pixels;
+} CursorImage;
+
+// a rectangle of the screen, in the coordinates of CursorState
+typedef struct {
+  int x;
+  int y;
+  int width;
+  int height;
+} CursorArea;
+
+int getCursorState(CursorState *state);
+int getMonitorArea(const char *monitor, CursorArea *area);
+int getCursorImage(CursorImage *image);
+void freeCursorImage(CursorImage *image);
+#endif
\ No newline at end of file
@@ -0,0 +1,99 @@
+/*x11 xfixes*/
+#include "cursor_linux.h"
+
+#include <X11/extensions/Xfixes.h>
+#include <errno.h>
+#include <stdlib.h>
+#include <string.h>
+
+// connect to the display in DISPLAY and follow its cursor changes
+int createCursor(CursorDevice **device) {
+  Display *display = XOpenDisplay(NULL);
+  if (display == NULL) return ENXIO;
+  int eventBase, errorBase;
+  if (!XFixesQueryExtension(display, &eventBase, &errorBase)) {
+    XCloseDisplay(display);
+    return ENOTSUP;
+  }
+  *device = calloc(1, sizeof(CursorDevice));
+  if (*device == NULL) {
+    XCloseDisplay(display);
+    return ENOMEM;
+  }
+  (*device)->display = display;
+  (*device)->root = DefaultRootWindow(display);
+  (*device)->eventBase = eventBase;
+  XFixesSelectCursorInput(display, (*device)->root,
+                          XFixesDisplayCursorNotifyMask);
+  XFixesCursorImage *image = XFixesGetCursorImage(display);
+  if (image != NULL) {
+    (*device)->serial = image->cursor_serial;
+    XFree(image);
+  }
+  return 0;
+}
+int destroyCursor(CursorDevice *device) {
+  XCloseDisplay(device->display);
+  free(device);
+  return 0;
+}
+// shape changes come as events, the position is queried
+int getCursorState(CursorDevice *device, CursorState *state) {
+  Display *display = device->display;
+  while (XPending(display) > 0) {
+    XEvent event;
+    XNextEvent(display, &event);
+    if (event.type == device->eventBase + XFixesCursorNotify)
+      device->serial = ((XFixesCursorNotifyEvent *)&event)->cursor_serial;
+  }
+  Window root, child;
+  int rootX, rootY, windowX, windowY;
+  unsigned int buttons;
+  if (!XQueryPointer(display, device->root, &root, &child, &rootX, &rootY,
+                     &windowX, &windowY, &buttons))
+    return ENODEV;
+  const int screen = DefaultScreen(display);
+  state->x = rootX;
+  state->y = rootY;
+  state->visible = true;
+  state->shape = device->serial;
+  state->screenWidth = DisplayWidth(display, screen);
+  state->screenHeight = DisplayHeight(display, screen);
+  return 0;
+}
+// read the current cursor shape, image->pixels must be freed with
+// freeCursorImage
+int getCursorImage(CursorDevice *device, CursorImage *image) {
+  memset(image, 0, sizeof(*image));
+  XFixesCursorImage *cursor = XFixesGetCursorImage(device->display);
+  if (cursor == NULL) return ENODEV;
+  const size_t count = (size_t)cursor->width * cursor->height;
+  image->pixels = malloc(count * 4);
+  if (image->pixels == NULL) {
+    XFree(cursor);
+    return ENOMEM;
+  }
+  // pixels are premultiplied argb, one in each unsigned long
+  for (size_t i = 0; i < count; i++) {
+    const unsigned long pixel = cursor->pixels[i];
+    const unsigned alpha = (pixel >> 24) & 0xff;
+    unsigned char *out = image->pixels + i * 4;
+    for (int c = 0; c < 3; c++) {
+      unsigned value = (pixel >> (16 - c * 8)) & 0xff;
+      if (value > alpha) value = alpha;
+      out[c] = alpha == 0 ? 0 : (value * 255 + alpha / 2) / alpha;
+    }
+    out[3] = alpha;
+  }
+  image->width = cursor->width;
+  image->height = cursor->height;
+  image->hotspotX = cursor->xhot;
+  image->hotspotY = cursor->yhot;
+  device->serial = cursor->cursor_serial;
+  XFree(cursor);
+  return 0;
+}
+void freeCursorImage(CursorImage *image) {
+  free(image->pixels);
+  image->pixels = NULL;
+}
\ No newline at end of file
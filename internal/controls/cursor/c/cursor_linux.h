@@ -0,0 +1,41 @@
+#ifndef CURSOR_LINUX_H
+#define CURSOR_LINUX_H
+#include <X11/Xlib.h>
+#include <stdbool.h>
+
+typedef struct {
+  Display *display;
+  Window root;
+  // cursor change events are numbered from here
+  int eventBase;
+  // serial of the current cursor shape
+  unsigned long serial;
+} CursorDevice;
+
+typedef struct {
+  // on the root window
+  int x;
+  int y;
+  // X11 doesn't tell whether the cursor is hidden
+  bool visible;
+  // the xfixes cursor serial, which stays the same while the shape does
+  unsigned long long shape;
+  int screenWidth;
+  int screenHeight;
+} CursorState;
+
+// rgba pixels with straight alpha, top row first
+typedef struct {
+  int width;
+  int height;
+  int hotspotX;
+  int hotspotY;
+  unsigned char *pixels;
+} CursorImage;
+
+int createCursor(CursorDevice **device);
+int destroyCursor(CursorDevice *device);
+int getCursorState(CursorDevice *device, CursorState *state);
+int getCursorImage(CursorDevice *device, CursorImage *image);
+void freeCursorImage(CursorImage *image);
+#endif
\ No newline at end of file
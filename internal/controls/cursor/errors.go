@@ -0,0 +1,19 @@
+package cursor
+
+import "fmt"
+
+// CursorError indicates that reading the cursor of the OS failed
+type CursorError struct {
+	// errno value on linux, windows error code on windows
+	Code int
+}
+
+func (e *CursorError) Error() string {
+	return fmt.Sprintf("CursorError: Code %d", e.Code)
+}
+
+func NewCursorError(code int) error {
+	return &CursorError{
+		Code: code,
+	}
+}
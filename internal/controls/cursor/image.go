@@ -0,0 +1,20 @@
+package cursor
+
+import (
+	"image"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/cursor"
+)
+
+// newImage wraps rgba pixels with straight alpha, as the backends read them
+func newImage(pixels []byte, width int, height int, hotspotX int, hotspotY int) cursor.Image {
+	return cursor.Image{
+		Pixels: &image.NRGBA{
+			Pix:    pixels,
+			Stride: width * 4,
+			Rect:   image.Rect(0, 0, width, height),
+		},
+		HotspotX: hotspotX,
+		HotspotY: hotspotY,
+	}
+}
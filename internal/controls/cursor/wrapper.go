@@ -0,0 +1,69 @@
+//go:build windows
+
+package cursor
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -lcursor
+#include <stdlib.h>
+#include "cursor.h"
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/cursor"
+)
+
+// c implementation, follows the cursor on the primary screen
+type Cursor_c struct{}
+
+func NewCursor_c() (*Cursor_c, error) {
+	return &Cursor_c{}, nil
+}
+
+func (c *Cursor_c) State() (cursor.State, error) {
+	var state C.CursorState
+	if code := C.getCursorState(&state); code != C.ERROR_SUCCESS {
+		return cursor.State{}, NewCursorError(int(code))
+	}
+	return cursor.State{
+		X:            int(state.x),
+		Y:            int(state.y),
+		Visible:      bool(state.visible),
+		Shape:        uint64(state.shape),
+		ScreenWidth:  int(state.screenWidth),
+		ScreenHeight: int(state.screenHeight),
+	}, nil
+}
+
+func (c *Cursor_c) Image() (cursor.Image, error) {
+	var image C.CursorImage
+	if code := C.getCursorImage(&image); code != C.ERROR_SUCCESS {
+		return cursor.Image{}, NewCursorError(int(code))
+	}
+	defer C.freeCursorImage(&image)
+	pixels := C.GoBytes(unsafe.Pointer(image.pixels), image.width*image.height*4)
+	return newImage(pixels, int(image.width), int(image.height), int(image.hotspotX), int(image.hotspotY)), nil
+}
+
+// MonitorArea finds a monitor like the capture monitor of the stream names it, see cursor.Monitors
+func (c *Cursor_c) MonitorArea(monitor string) (cursor.Area, error) {
+	name := C.CString(monitor)
+	defer C.free(unsafe.Pointer(name))
+	var area C.CursorArea
+	if code := C.getMonitorArea(name, &area); code != C.ERROR_SUCCESS {
+		return cursor.Area{}, NewCursorError(int(code))
+	}
+	return cursor.Area{
+		X:      int(area.x),
+		Y:      int(area.y),
+		Width:  int(area.width),
+		Height: int(area.height),
+	}, nil
+}
+
+func (c *Cursor_c) Close() error {
+	return nil
+}
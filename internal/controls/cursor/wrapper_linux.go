@@ -0,0 +1,81 @@
+//go:build linux
+
+package cursor
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -lcursor_linux -lXfixes -lX11
+#include <errno.h>
+#include "cursor_linux.h"
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/cursor"
+)
+
+// xfixes implementation, follows the cursor of the X11 display in DISPLAY
+type Cursor_xfixes struct {
+	mutex  sync.Mutex
+	device *C.CursorDevice
+}
+
+func NewCursor_xfixes() (*Cursor_xfixes, error) {
+	var device *C.CursorDevice
+	if code := C.createCursor(&device); code != 0 {
+		return nil, NewCursorError(int(code))
+	}
+	return &Cursor_xfixes{device: device}, nil
+}
+
+func (c *Cursor_xfixes) State() (cursor.State, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.device == nil {
+		return cursor.State{}, NewCursorError(int(C.ENODEV))
+	}
+	var state C.CursorState
+	if code := C.getCursorState(c.device, &state); code != 0 {
+		return cursor.State{}, NewCursorError(int(code))
+	}
+	return cursor.State{
+		X:            int(state.x),
+		Y:            int(state.y),
+		Visible:      bool(state.visible),
+		Shape:        uint64(state.shape),
+		ScreenWidth:  int(state.screenWidth),
+		ScreenHeight: int(state.screenHeight),
+	}, nil
+}
+
+func (c *Cursor_xfixes) Image() (cursor.Image, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.device == nil {
+		return cursor.Image{}, NewCursorError(int(C.ENODEV))
+	}
+	var image C.CursorImage
+	if code := C.getCursorImage(c.device, &image); code != 0 {
+		return cursor.Image{}, NewCursorError(int(code))
+	}
+	defer C.freeCursorImage(&image)
+	pixels := C.GoBytes(unsafe.Pointer(image.pixels), image.width*image.height*4)
+	return newImage(pixels, int(image.width), int(image.height), int(image.hotspotX), int(image.hotspotY)), nil
+}
+
+func (c *Cursor_xfixes) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.device == nil {
+		return nil
+	}
+	code := C.destroyCursor(c.device)
+	c.device = nil
+	if code != 0 {
+		return NewCursorError(int(code))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+#include "gamepad.h"
+
+#include <stdlib.h>
+
+// games set both motors at once, and keep them running until they set them
+// again
+static VOID CALLBACK onRumble(PVIGEM_CLIENT client, PVIGEM_TARGET target,
+                              UCHAR largeMotor, UCHAR smallMotor,
+                              UCHAR ledNumber, LPVOID userData) {
+  (void)client;
+  (void)target;
+  (void)ledNumber;
+  GamepadDevice *device = userData;
+  EnterCriticalSection(&device->lock);
+  // 0-255 to 0-65535
+  device->rumble.strong = largeMotor * 257;
+  device->rumble.weak = smallMotor * 257;
+  device->rumble.duration = 0;
+  LeaveCriticalSection(&device->lock);
+  SetEvent(device->rumbleEvent);
+}
+
+int createGamepad(GamepadDevice **device) {
+  GamepadDevice *d = calloc(1, sizeof(GamepadDevice));
+  if (d == NULL) return ERROR_NOT_ENOUGH_MEMORY;
+  InitializeCriticalSection(&d->lock);
+  int code = ERROR_SUCCESS;
+  // auto reset, so every rumble is read once
+  d->rumbleEvent = CreateEvent(NULL, FALSE, FALSE, NULL);
+  if (d->rumbleEvent == NULL) {
+    code = GetLastError();
+    goto error;
+  }
+  d->client = vigem_alloc();
+  if (d->client == NULL) {
+    code = ERROR_NOT_ENOUGH_MEMORY;
+    goto error;
+  }
+  VIGEM_ERROR err = vigem_connect(d->client);
+  if (!VIGEM_SUCCESS(err)) {
+    code = err;
+    goto error;
+  }
+  // an Xbox 360 controller, which games map out of the box
+  d->target = vigem_target_x360_alloc();
+  if (d->target == NULL) {
+    code = ERROR_NOT_ENOUGH_MEMORY;
+    goto error;
+  }
+  err = vigem_target_add(d->client, d->target);
+  if (!VIGEM_SUCCESS(err)) {
+    code = err;
+    goto error;
+  }
+  err = vigem_target_x360_register_notification(
+      d->client, d->target, (PFN_VIGEM_X360_NOTIFICATION)onRumble, d);
+  if (!VIGEM_SUCCESS(err)) {
+    vigem_target_remove(d->client, d->target);
+    code = err;
+    goto error;
+  }
+  *device = d;
+  return ERROR_SUCCESS;
+error:
+  if (d->target != NULL) vigem_target_free(d->target);
+  if (d->client != NULL) {
+    vigem_disconnect(d->client);
+    vigem_free(d->client);
+  }
+  if (d->rumbleEvent != NULL) CloseHandle(d->rumbleEvent);
+  DeleteCriticalSection(&d->lock);
+  free(d);
+  return code;
+}
+
+int destroyGamepad(GamepadDevice *device) {
+  vigem_target_x360_unregister_notification(device->target);
+  VIGEM_ERROR err = vigem_target_remove(device->client, device->target);
+  vigem_target_free(device->target);
+  vigem_disconnect(device->client);
+  vigem_free(device->client);
+  CloseHandle(device->rumbleEvent);
+  DeleteCriticalSection(&device->lock);
+  free(device);
+  if (!VIGEM_SUCCESS(err)) return err;
+  return ERROR_SUCCESS;
+}
+
+int sendGamepadReport(GamepadDevice *device, const XUSB_REPORT *report) {
+  VIGEM_ERROR err =
+      vigem_target_x360_update(device->client, device->target, *report);
+  if (!VIGEM_SUCCESS(err)) return err;
+  return ERROR_SUCCESS;
+}
+
+int readGamepadRumble(GamepadDevice *device, GamepadRumble *rumble,
+                      const int timeoutMs) {
+  DWORD result = WaitForSingleObject(device->rumbleEvent, timeoutMs);
+  if (result == WAIT_TIMEOUT) return WAIT_TIMEOUT;
+  if (result != WAIT_OBJECT_0) return GetLastError();
+  EnterCriticalSection(&device->lock);
+  *rumble = device->rumble;
+  LeaveCriticalSection(&device->lock);
+  return ERROR_SUCCESS;
+}
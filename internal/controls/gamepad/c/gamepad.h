@@ -0,0 +1,32 @@
+#ifndef GAMEPAD_H
+#define GAMEPAD_H
+#include <stdbool.h>
+#include <windows.h>
+// needs the ViGEmBus driver and its client library
+#include <ViGEm/Client.h>
+
+typedef struct {
+  // motor magnitudes in range 0-65535
+  unsigned short strong;
+  unsigned short weak;
+  // in milliseconds, 0 means until stopped
+  unsigned short duration;
+} GamepadRumble;
+
+typedef struct {
+  PVIGEM_CLIENT client;
+  PVIGEM_TARGET target;
+  // the last rumble from the host, set when rumbleEvent is signaled
+  CRITICAL_SECTION lock;
+  HANDLE rumbleEvent;
+  GamepadRumble rumble;
+} GamepadDevice;
+
+int createGamepad(GamepadDevice **device);
+int destroyGamepad(GamepadDevice *device);
+// send the whole state of the gamepad
+int sendGamepadReport(GamepadDevice *device, const XUSB_REPORT *report);
+// WAIT_TIMEOUT when nothing came within timeoutMs
+int readGamepadRumble(GamepadDevice *device, GamepadRumble *rumble,
+                      const int timeoutMs);
+#endif
@@ -0,0 +1,165 @@
+#include "gamepad_linux.h"
+
+#include <errno.h>
+#include <fcntl.h>
+#include <poll.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <unistd.h>
+
+// identify as a wired Xbox 360 controller, which games map out of the box
+#define GAMEPAD_VENDOR 0x045e
+#define GAMEPAD_PRODUCT 0x028e
+#define GAMEPAD_VERSION 0x0110
+
+static const int buttons[] = {BTN_SOUTH, BTN_EAST,   BTN_NORTH, BTN_WEST,
+                              BTN_TL,    BTN_TR,     BTN_SELECT, BTN_START,
+                              BTN_MODE,  BTN_THUMBL, BTN_THUMBR};
+
+static int setupAxis(const int fd, const int code, const int min,
+                     const int max, const int fuzz, const int flat) {
+  struct uinput_abs_setup abs = {0};
+  abs.code = code;
+  abs.absinfo.minimum = min;
+  abs.absinfo.maximum = max;
+  abs.absinfo.fuzz = fuzz;
+  abs.absinfo.flat = flat;
+  if (ioctl(fd, UI_SET_ABSBIT, code) < 0) return errno;
+  if (ioctl(fd, UI_ABS_SETUP, &abs) < 0) return errno;
+  return 0;
+}
+static int emit(const int fd, const int type, const int code,
+                const int value) {
+  struct input_event event = {0};
+  event.type = type;
+  event.code = code;
+  event.value = value;
+  if (write(fd, &event, sizeof(event)) != sizeof(event)) return errno;
+  return 0;
+}
+static int report(const int fd) { return emit(fd, EV_SYN, SYN_REPORT, 0); }
+
+int createGamepad(GamepadDevice **device, const int index) {
+  int fd = open("/dev/uinput", O_RDWR | O_NONBLOCK);
+  if (fd < 0) return errno;
+  int code = 0;
+  size_t i;
+  // buttons
+  if (ioctl(fd, UI_SET_EVBIT, EV_KEY) < 0) goto error;
+  for (i = 0; i < sizeof(buttons) / sizeof(buttons[0]); i++) {
+    if (ioctl(fd, UI_SET_KEYBIT, buttons[i]) < 0) goto error;
+  }
+  // sticks, triggers and the d-pad as a hat
+  if (ioctl(fd, UI_SET_EVBIT, EV_ABS) < 0) goto error;
+  if ((code = setupAxis(fd, ABS_X, -32768, 32767, 16, 128)) ||
+      (code = setupAxis(fd, ABS_Y, -32768, 32767, 16, 128)) ||
+      (code = setupAxis(fd, ABS_RX, -32768, 32767, 16, 128)) ||
+      (code = setupAxis(fd, ABS_RY, -32768, 32767, 16, 128)) ||
+      (code = setupAxis(fd, ABS_Z, 0, 255, 0, 0)) ||
+      (code = setupAxis(fd, ABS_RZ, 0, 255, 0, 0)) ||
+      (code = setupAxis(fd, ABS_HAT0X, -1, 1, 0, 0)) ||
+      (code = setupAxis(fd, ABS_HAT0Y, -1, 1, 0, 0))) {
+    close(fd);
+    return code;
+  }
+  // rumble
+  if (ioctl(fd, UI_SET_EVBIT, EV_FF) < 0) goto error;
+  if (ioctl(fd, UI_SET_FFBIT, FF_RUMBLE) < 0) goto error;
+
+  struct uinput_setup setup = {0};
+  snprintf(setup.name, UINPUT_MAX_NAME_SIZE, "Benu Virtual Gamepad %d",
+           index + 1);
+  setup.id.bustype = BUS_USB;
+  setup.id.vendor = GAMEPAD_VENDOR;
+  setup.id.product = GAMEPAD_PRODUCT;
+  setup.id.version = GAMEPAD_VERSION;
+  setup.ff_effects_max = GAMEPAD_MAX_EFFECTS;
+  if (ioctl(fd, UI_DEV_SETUP, &setup) < 0) goto error;
+  if (ioctl(fd, UI_DEV_CREATE) < 0) goto error;
+
+  *device = calloc(1, sizeof(GamepadDevice));
+  if (*device == NULL) {
+    ioctl(fd, UI_DEV_DESTROY);
+    close(fd);
+    return ENOMEM;
+  }
+  (*device)->fd = fd;
+  return 0;
+error:
+  code = errno;
+  close(fd);
+  return code;
+}
+int destroyGamepad(GamepadDevice *device) {
+  int code = 0;
+  if (ioctl(device->fd, UI_DEV_DESTROY) < 0) code = errno;
+  close(device->fd);
+  free(device);
+  return code;
+}
+int sendGamepadButton(GamepadDevice *device, const int button, bool down) {
+  int code = emit(device->fd, EV_KEY, button, down ? 1 : 0);
+  if (code) return code;
+  return report(device->fd);
+}
+int sendGamepadAxes(GamepadDevice *device, const int axisX, const int x,
+                    const int axisY, const int y) {
+  int code = emit(device->fd, EV_ABS, axisX, x);
+  if (code) return code;
+  code = emit(device->fd, EV_ABS, axisY, y);
+  if (code) return code;
+  return report(device->fd);
+}
+int sendGamepadAxis(GamepadDevice *device, const int axis, const int value) {
+  int code = emit(device->fd, EV_ABS, axis, value);
+  if (code) return code;
+  return report(device->fd);
+}
+// wait for the next rumble, returns EAGAIN if nothing was played before the
+// timeout. force feedback uploads from games are handled while waiting
+int readGamepadRumble(GamepadDevice *device, GamepadRumble *rumble,
+                      const int timeoutMs) {
+  struct pollfd pfd = {.fd = device->fd, .events = POLLIN};
+  int ready = poll(&pfd, 1, timeoutMs);
+  if (ready < 0) return errno;
+  if (ready == 0) return EAGAIN;
+  struct input_event event;
+  if (read(device->fd, &event, sizeof(event)) != sizeof(event)) return errno;
+
+  if (event.type == EV_UINPUT && event.code == UI_FF_UPLOAD) {
+    struct uinput_ff_upload upload = {0};
+    upload.request_id = event.value;
+    if (ioctl(device->fd, UI_BEGIN_FF_UPLOAD, &upload) < 0) return errno;
+    if (upload.effect.type == FF_RUMBLE && upload.effect.id >= 0 &&
+        upload.effect.id < GAMEPAD_MAX_EFFECTS) {
+      device->effects[upload.effect.id] = upload.effect;
+      upload.retval = 0;
+    } else {
+      upload.retval = -EINVAL;
+    }
+    if (ioctl(device->fd, UI_END_FF_UPLOAD, &upload) < 0) return errno;
+    return EAGAIN;
+  }
+  if (event.type == EV_UINPUT && event.code == UI_FF_ERASE) {
+    struct uinput_ff_erase erase = {0};
+    erase.request_id = event.value;
+    if (ioctl(device->fd, UI_BEGIN_FF_ERASE, &erase) < 0) return errno;
+    if (erase.effect_id < GAMEPAD_MAX_EFFECTS) {
+      memset(&device->effects[erase.effect_id], 0, sizeof(struct ff_effect));
+    }
+    erase.retval = 0;
+    if (ioctl(device->fd, UI_END_FF_ERASE, &erase) < 0) return errno;
+    return EAGAIN;
+  }
+  // playing an effect, the code is the effect id and the value is 0 to stop
+  if (event.type == EV_FF && event.code < GAMEPAD_MAX_EFFECTS) {
+    struct ff_effect *effect = &device->effects[event.code];
+    rumble->strong = event.value ? effect->u.rumble.strong_magnitude : 0;
+    rumble->weak = event.value ? effect->u.rumble.weak_magnitude : 0;
+    rumble->duration = event.value ? effect->replay.length : 0;
+    return 0;
+  }
+  return EAGAIN;
+}
\ No newline at end of file
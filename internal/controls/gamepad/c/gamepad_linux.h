@@ -0,0 +1,31 @@
+#ifndef GAMEPAD_LINUX_H
+#define GAMEPAD_LINUX_H
+#include <linux/input.h>
+#include <linux/uinput.h>
+#include <stdbool.h>
+
+#define GAMEPAD_MAX_EFFECTS 16
+
+typedef struct {
+  int fd;
+  // uploaded force feedback effects, indexed by effect id
+  struct ff_effect effects[GAMEPAD_MAX_EFFECTS];
+} GamepadDevice;
+
+typedef struct {
+  // motor magnitudes in range 0-65535
+  unsigned short strong;
+  unsigned short weak;
+  // in milliseconds, 0 means until stopped
+  unsigned short duration;
+} GamepadRumble;
+
+int createGamepad(GamepadDevice **device, const int index);
+int destroyGamepad(GamepadDevice *device);
+int sendGamepadButton(GamepadDevice *device, const int button, bool down);
+int sendGamepadAxes(GamepadDevice *device, const int axisX, const int x,
+                    const int axisY, const int y);
+int sendGamepadAxis(GamepadDevice *device, const int axis, const int value);
+int readGamepadRumble(GamepadDevice *device, GamepadRumble *rumble,
+                      const int timeoutMs);
+#endif
\ No newline at end of file
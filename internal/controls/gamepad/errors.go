@@ -0,0 +1,19 @@
+package gamepad
+
+import "fmt"
+
+// GamepadInputError indicates that sending gamepad input to the OS failed
+type GamepadInputError struct {
+	// errno value on linux, VIGEM_ERROR or win32 error code on windows
+	Code int
+}
+
+func (e *GamepadInputError) Error() string {
+	return fmt.Sprintf("GamepadInputError: Code %d", e.Code)
+}
+
+func NewGamepadInputError(code int) error {
+	return &GamepadInputError{
+		Code: code,
+	}
+}
@@ -0,0 +1,11 @@
+package gamepad
+
+// map a value in range -1 to 1 onto min to max
+func scale(value float64, min int, max int) int {
+	if value < -1 {
+		value = -1
+	} else if value > 1 {
+		value = 1
+	}
+	return min + int((value+1)/2*float64(max-min)+0.5)
+}
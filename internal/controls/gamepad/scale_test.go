@@ -0,0 +1,28 @@
+package gamepad
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		value    float64
+		min      int
+		max      int
+		expected int
+	}{
+		{-1, -32768, 32767, -32768},
+		{0, -32768, 32767, 0},
+		{1, -32768, 32767, 32767},
+		// out of range input is clamped
+		{2, -32768, 32767, 32767},
+		{-1.5, 0, 255, 0},
+		{0, 0, 255, 128},
+		{1, 0, 255, 255},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, scale(tt.value, tt.min, tt.max), "%v", tt.value)
+	}
+}
@@ -0,0 +1,206 @@
+//go:build linux
+
+package gamepad
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -lgamepad_linux
+#include <errno.h>
+#include "gamepad_linux.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+var gamepadButton map[types.GamepadButton]C.int = map[types.GamepadButton]C.int{
+	types.GamepadA:     C.BTN_SOUTH,
+	types.GamepadB:     C.BTN_EAST,
+	types.GamepadX:     C.BTN_NORTH,
+	types.GamepadY:     C.BTN_WEST,
+	types.GamepadLB:    C.BTN_TL,
+	types.GamepadRB:    C.BTN_TR,
+	types.GamepadBack:  C.BTN_SELECT,
+	types.GamepadStart: C.BTN_START,
+	types.GamepadGuide: C.BTN_MODE,
+	types.GamepadLS:    C.BTN_THUMBL,
+	types.GamepadRS:    C.BTN_THUMBR,
+}
+
+// the d-pad is a hat, so each button moves one of its axes
+type dpadDirection struct {
+	axis  C.int
+	value int
+}
+
+var gamepadDpad map[types.GamepadButton]dpadDirection = map[types.GamepadButton]dpadDirection{
+	types.GamepadDpadUp:    {C.ABS_HAT0Y, -1},
+	types.GamepadDpadDown:  {C.ABS_HAT0Y, 1},
+	types.GamepadDpadLeft:  {C.ABS_HAT0X, -1},
+	types.GamepadDpadRight: {C.ABS_HAT0X, 1},
+}
+
+var gamepadStick map[types.GamepadStick][2]C.int = map[types.GamepadStick][2]C.int{
+	types.LeftStick:  {C.ABS_X, C.ABS_Y},
+	types.RightStick: {C.ABS_RX, C.ABS_RY},
+}
+
+var gamepadTrigger map[types.GamepadTrigger]C.int = map[types.GamepadTrigger]C.int{
+	types.LeftTrigger:  C.ABS_Z,
+	types.RightTrigger: C.ABS_RZ,
+}
+
+// how long reading rumble waits before checking if the gamepad was closed
+const rumblePollMs = 100
+
+// uinput implementation, shows up as an Xbox 360 controller
+type Gamepad_uinput struct {
+	mutex  sync.Mutex
+	device *C.GamepadDevice
+	// d-pad buttons currently held down
+	dpad   map[types.GamepadButton]bool
+	rumble chan types.GamepadRumble
+	// set by the first Close, under the mutex
+	closing bool
+	closed  chan struct{}
+	done    chan struct{}
+}
+
+// NewGamepad_uinput creates a virtual gamepad, index is used to tell gamepads apart by name
+func NewGamepad_uinput(index int) (*Gamepad_uinput, error) {
+	var device *C.GamepadDevice
+	if code := C.createGamepad(&device, C.int(index)); code != 0 {
+		return nil, NewGamepadInputError(int(code))
+	}
+	g := &Gamepad_uinput{
+		device: device,
+		dpad:   make(map[types.GamepadButton]bool),
+		rumble: make(chan types.GamepadRumble, 16),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go g.readRumble()
+	return g, nil
+}
+
+func (g *Gamepad_uinput) SendInputButton(button types.GamepadButton, down bool) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.device == nil {
+		return NewGamepadInputError(int(C.ENODEV))
+	}
+	if dpad, ok := gamepadDpad[button]; ok {
+		g.dpad[button] = down
+		// opposite directions cancel out
+		value := 0
+		for b, d := range gamepadDpad {
+			if d.axis == dpad.axis && g.dpad[b] {
+				value += d.value
+			}
+		}
+		if code := C.sendGamepadAxis(g.device, dpad.axis, C.int(value)); code != 0 {
+			return NewGamepadInputError(int(code))
+		}
+		return nil
+	}
+	cbutton, ok := gamepadButton[button]
+	if !ok {
+		return pkgerrors.NewNotImplementedError("SendInputButton", string(button))
+	}
+	if code := C.sendGamepadButton(g.device, cbutton, C.bool(down)); code != 0 {
+		return NewGamepadInputError(int(code))
+	}
+	return nil
+}
+
+func (g *Gamepad_uinput) SendInputStick(stick types.GamepadStick, x float64, y float64) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.device == nil {
+		return NewGamepadInputError(int(C.ENODEV))
+	}
+	axes, ok := gamepadStick[stick]
+	if !ok {
+		return pkgerrors.NewNotImplementedError("SendInputStick", string(stick))
+	}
+	if code := C.sendGamepadAxes(g.device, axes[0], C.int(scale(x, -32768, 32767)), axes[1], C.int(scale(y, -32768, 32767))); code != 0 {
+		return NewGamepadInputError(int(code))
+	}
+	return nil
+}
+
+func (g *Gamepad_uinput) SendInputTrigger(trigger types.GamepadTrigger, value float64) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.device == nil {
+		return NewGamepadInputError(int(C.ENODEV))
+	}
+	axis, ok := gamepadTrigger[trigger]
+	if !ok {
+		return pkgerrors.NewNotImplementedError("SendInputTrigger", fmt.Sprintf("trigger %s", trigger))
+	}
+	if code := C.sendGamepadAxis(g.device, axis, C.int(scale(value*2-1, 0, 255))); code != 0 {
+		return NewGamepadInputError(int(code))
+	}
+	return nil
+}
+
+func (g *Gamepad_uinput) Rumble() <-chan types.GamepadRumble {
+	return g.rumble
+}
+
+func (g *Gamepad_uinput) Close() error {
+	g.mutex.Lock()
+	if g.closing {
+		g.mutex.Unlock()
+		return nil
+	}
+	g.closing = true
+	close(g.closed)
+	g.mutex.Unlock()
+	// the device can only be destroyed once nothing reads from it
+	<-g.done
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	code := C.destroyGamepad(g.device)
+	g.device = nil
+	if code != 0 {
+		return NewGamepadInputError(int(code))
+	}
+	return nil
+}
+
+// pass rumble from the host on until the gamepad is closed
+func (g *Gamepad_uinput) readRumble() {
+	defer close(g.done)
+	defer close(g.rumble)
+	var rumble C.GamepadRumble
+	for {
+		select {
+		case <-g.closed:
+			return
+		default:
+		}
+		if code := C.readGamepadRumble(g.device, &rumble, rumblePollMs); code != 0 {
+			if code != C.EAGAIN {
+				time.Sleep(rumblePollMs * time.Millisecond)
+			}
+			continue
+		}
+		// drop rumble nobody is reading rather than stall the game
+		select {
+		case g.rumble <- types.GamepadRumble{
+			Strong:   float64(rumble.strong) / 65535,
+			Weak:     float64(rumble.weak) / 65535,
+			Duration: time.Duration(rumble.duration) * time.Millisecond,
+		}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,182 @@
+//go:build windows
+
+package gamepad
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -lgamepad -lViGEmClient -lsetupapi
+#include "gamepad.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// on xinput the d-pad is made of buttons too
+var gamepadButton map[types.GamepadButton]uint16 = map[types.GamepadButton]uint16{
+	types.GamepadA:         C.XUSB_GAMEPAD_A,
+	types.GamepadB:         C.XUSB_GAMEPAD_B,
+	types.GamepadX:         C.XUSB_GAMEPAD_X,
+	types.GamepadY:         C.XUSB_GAMEPAD_Y,
+	types.GamepadLB:        C.XUSB_GAMEPAD_LEFT_SHOULDER,
+	types.GamepadRB:        C.XUSB_GAMEPAD_RIGHT_SHOULDER,
+	types.GamepadBack:      C.XUSB_GAMEPAD_BACK,
+	types.GamepadStart:     C.XUSB_GAMEPAD_START,
+	types.GamepadGuide:     C.XUSB_GAMEPAD_GUIDE,
+	types.GamepadLS:        C.XUSB_GAMEPAD_LEFT_THUMB,
+	types.GamepadRS:        C.XUSB_GAMEPAD_RIGHT_THUMB,
+	types.GamepadDpadUp:    C.XUSB_GAMEPAD_DPAD_UP,
+	types.GamepadDpadDown:  C.XUSB_GAMEPAD_DPAD_DOWN,
+	types.GamepadDpadLeft:  C.XUSB_GAMEPAD_DPAD_LEFT,
+	types.GamepadDpadRight: C.XUSB_GAMEPAD_DPAD_RIGHT,
+}
+
+// how long reading rumble waits before checking if the gamepad was closed
+const rumblePollMs = 100
+
+// ViGEm implementation, shows up as an Xbox 360 controller.
+// Needs the ViGEmBus driver installed
+type Gamepad_vigem struct {
+	mutex  sync.Mutex
+	device *C.GamepadDevice
+	// the whole state is sent with every change
+	report C.XUSB_REPORT
+	rumble chan types.GamepadRumble
+	// set by the first Close, under the mutex
+	closing bool
+	closed  chan struct{}
+	done    chan struct{}
+}
+
+// NewGamepad_vigem creates a virtual gamepad, the driver picks its player index
+func NewGamepad_vigem(index int) (*Gamepad_vigem, error) {
+	var device *C.GamepadDevice
+	if code := C.createGamepad(&device); code != C.ERROR_SUCCESS {
+		return nil, NewGamepadInputError(int(code))
+	}
+	g := &Gamepad_vigem{
+		device: device,
+		rumble: make(chan types.GamepadRumble, 16),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go g.readRumble()
+	return g, nil
+}
+
+func (g *Gamepad_vigem) SendInputButton(button types.GamepadButton, down bool) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	flag, ok := gamepadButton[button]
+	if !ok {
+		return pkgerrors.NewNotImplementedError("SendInputButton", string(button))
+	}
+	if down {
+		g.report.wButtons |= C.USHORT(flag)
+	} else {
+		g.report.wButtons &^= C.USHORT(flag)
+	}
+	return g.send()
+}
+
+func (g *Gamepad_vigem) SendInputStick(stick types.GamepadStick, x float64, y float64) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	// positive y is up on xinput
+	sx, sy := C.SHORT(scale(x, -32768, 32767)), C.SHORT(scale(-y, -32768, 32767))
+	switch stick {
+	case types.LeftStick:
+		g.report.sThumbLX, g.report.sThumbLY = sx, sy
+	case types.RightStick:
+		g.report.sThumbRX, g.report.sThumbRY = sx, sy
+	default:
+		return pkgerrors.NewNotImplementedError("SendInputStick", string(stick))
+	}
+	return g.send()
+}
+
+func (g *Gamepad_vigem) SendInputTrigger(trigger types.GamepadTrigger, value float64) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	v := C.BYTE(scale(value*2-1, 0, 255))
+	switch trigger {
+	case types.LeftTrigger:
+		g.report.bLeftTrigger = v
+	case types.RightTrigger:
+		g.report.bRightTrigger = v
+	default:
+		return pkgerrors.NewNotImplementedError("SendInputTrigger", fmt.Sprintf("trigger %s", trigger))
+	}
+	return g.send()
+}
+
+func (g *Gamepad_vigem) Rumble() <-chan types.GamepadRumble {
+	return g.rumble
+}
+
+func (g *Gamepad_vigem) Close() error {
+	g.mutex.Lock()
+	if g.closing {
+		g.mutex.Unlock()
+		return nil
+	}
+	g.closing = true
+	close(g.closed)
+	g.mutex.Unlock()
+	// the device can only be destroyed once nothing reads from it
+	<-g.done
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	code := C.destroyGamepad(g.device)
+	g.device = nil
+	if code != C.ERROR_SUCCESS {
+		return NewGamepadInputError(int(code))
+	}
+	return nil
+}
+
+// needs the mutex
+func (g *Gamepad_vigem) send() error {
+	if g.device == nil {
+		return NewGamepadInputError(int(C.ERROR_DEVICE_NOT_CONNECTED))
+	}
+	if code := C.sendGamepadReport(g.device, &g.report); code != C.ERROR_SUCCESS {
+		return NewGamepadInputError(int(code))
+	}
+	return nil
+}
+
+// pass rumble from the host on until the gamepad is closed
+func (g *Gamepad_vigem) readRumble() {
+	defer close(g.done)
+	defer close(g.rumble)
+	var rumble C.GamepadRumble
+	for {
+		select {
+		case <-g.closed:
+			return
+		default:
+		}
+		if code := C.readGamepadRumble(g.device, &rumble, rumblePollMs); code != C.ERROR_SUCCESS {
+			if code != C.WAIT_TIMEOUT {
+				time.Sleep(rumblePollMs * time.Millisecond)
+			}
+			continue
+		}
+		// drop rumble nobody is reading rather than stall the game
+		select {
+		case g.rumble <- types.GamepadRumble{
+			Strong:   float64(rumble.strong) / 65535,
+			Weak:     float64(rumble.weak) / 65535,
+			Duration: time.Duration(rumble.duration) * time.Millisecond,
+		}:
+		default:
+		}
+	}
+}
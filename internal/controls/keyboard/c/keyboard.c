@@ -1,5 +1,42 @@
 #include "keyboard.h"
 
+#include <stdlib.h>
+
+// fill a unicode keyboard input for a single utf-16 code unit
+static void fillInputUnicode(INPUT *input, const WCHAR unit, bool keyDown) {
+  input->type = INPUT_KEYBOARD;
+  input->ki.wScan = unit;
+  input->ki.dwFlags = KEYEVENTF_UNICODE;
+  if (!keyDown) input->ki.dwFlags |= KEYEVENTF_KEYUP;
+}
+// fill a virtual key keyboard input, returns false if the unit has no key
+static bool fillInputControlChar(INPUT *input, const WCHAR unit,
+                                 bool keyDown) {
+  // KEYEVENTF_UNICODE is not understood as a key press for these
+  switch (unit) {
+    case L'\r':
+    case L'\n':
+      input->ki.wVk = VK_RETURN;
+      break;
+    case L'\t':
+      input->ki.wVk = VK_TAB;
+      break;
+    case L'\b':
+      input->ki.wVk = VK_BACK;
+      break;
+    default:
+      return false;
+  }
+  input->type = INPUT_KEYBOARD;
+  if (!keyDown) input->ki.dwFlags = KEYEVENTF_KEYUP;
+  return true;
+}
+static int sendInputs(const UINT count, INPUT *inputs) {
+  UINT sent = SendInput(count, inputs, sizeof(INPUT));
+  if (sent != count) return HRESULT_FROM_WIN32(GetLastError());
+  return ERROR_SUCCESS;
+}
+
 int sendInputKeyCode(const int key, bool keyDown) {
   INPUT input = {0};
   input.type = INPUT_KEYBOARD;
@@ -9,11 +46,102 @@ int sendInputKeyCode(const int key, bool keyDown) {
   if (!sent) return HRESULT_FROM_WIN32(GetLastError());
   return ERROR_SUCCESS;
 }
-int sendInputKeyChar(const char key, bool keyDown) {
-  int code;
-  code = VkKeyScan(key);
-  if (code == 0xFFFF) {
+// press a key by position, an 0xE0 prefix in the high byte marks extended keys
+int sendInputScancode(const unsigned int scancode, bool keyDown) {
+  if (scancode > 0xFFFF) return ERROR_BAD_ARGUMENTS;
+  INPUT input = {0};
+  input.type = INPUT_KEYBOARD;
+  input.ki.wScan = scancode & 0xFF;
+  input.ki.dwFlags = KEYEVENTF_SCANCODE;
+  if ((scancode >> 8) == 0xE0) input.ki.dwFlags |= KEYEVENTF_EXTENDEDKEY;
+  if (!keyDown) input.ki.dwFlags |= KEYEVENTF_KEYUP;
+  return sendInputs(1, &input);
+}
+int sendInputKeyChar(const unsigned int codepoint, bool keyDown) {
+  // reject surrogates and anything outside of unicode
+  if (codepoint > 0x10FFFF || (codepoint >= 0xD800 && codepoint <= 0xDFFF)) {
     return ERROR_BAD_ARGUMENTS;
   }
-  return sendInputKeyCode(code, keyDown);
+  INPUT inputs[2] = {0};
+  if (codepoint <= 0xFFFF) {
+    if (fillInputControlChar(&inputs[0], (WCHAR)codepoint, keyDown)) {
+      return sendInputs(1, inputs);
+    }
+    // prefer a real key press if the active layout has one without modifiers,
+    // since some applications only read virtual key codes
+    SHORT code = VkKeyScanW((WCHAR)codepoint);
+    if (code != -1 && HIBYTE(code) == 0) {
+      return sendInputKeyCode(LOBYTE(code), keyDown);
+    }
+    fillInputUnicode(&inputs[0], (WCHAR)codepoint, keyDown);
+    return sendInputs(1, inputs);
+  }
+  // characters outside the BMP are sent as a surrogate pair
+  unsigned int offset = codepoint - 0x10000;
+  fillInputUnicode(&inputs[0], (WCHAR)(0xD800 + (offset >> 10)), keyDown);
+  fillInputUnicode(&inputs[1], (WCHAR)(0xDC00 + (offset & 0x3FF)), keyDown);
+  return sendInputs(2, inputs);
+}
+// the virtual key typing a character in the active layout, ignoring the
+// modifiers it needs, or -1
+int charVirtualKey(const unsigned int codepoint) {
+  if (codepoint > 0xFFFF) return -1;
+  SHORT code = VkKeyScanW((WCHAR)codepoint);
+  if (code == -1) return -1;
+  return LOBYTE(code);
+}
+// press modifiers in order, then the key, and release them in reverse, all in
+// a single SendInput call so no other input can get in between
+int sendInputChord(const int *modifiers, const int count, const int key) {
+  if (count < 0) return ERROR_BAD_ARGUMENTS;
+  const int total = (count + 1) * 2;
+  INPUT *inputs = calloc(total, sizeof(INPUT));
+  if (inputs == NULL) return ERROR_NOT_ENOUGH_MEMORY;
+  int i;
+  for (i = 0; i < count; i++) {
+    inputs[i].type = INPUT_KEYBOARD;
+    inputs[i].ki.wVk = modifiers[i];
+    inputs[total - 1 - i].type = INPUT_KEYBOARD;
+    inputs[total - 1 - i].ki.wVk = modifiers[i];
+    inputs[total - 1 - i].ki.dwFlags = KEYEVENTF_KEYUP;
+  }
+  inputs[count].type = INPUT_KEYBOARD;
+  inputs[count].ki.wVk = key;
+  inputs[count + 1].type = INPUT_KEYBOARD;
+  inputs[count + 1].ki.wVk = key;
+  inputs[count + 1].ki.dwFlags = KEYEVENTF_KEYUP;
+  int result = sendInputs(total, inputs);
+  free(inputs);
+  return result;
+}
+int sendInputText(const WCHAR *text, const int length) {
+  if (length <= 0) return ERROR_SUCCESS;
+  // each utf-16 code unit gets a key down and a key up
+  INPUT *inputs = calloc(length * 2, sizeof(INPUT));
+  if (inputs == NULL) return ERROR_NOT_ENOUGH_MEMORY;
+  int i, count = 0;
+  for (i = 0; i < length; i++) {
+    // windows line breaks are one enter
+    if (text[i] == L'\n' && i > 0 && text[i - 1] == L'\r') continue;
+    if (fillInputControlChar(&inputs[count], text[i], true)) {
+      fillInputControlChar(&inputs[count + 1], text[i], false);
+      count += 2;
+      continue;
+    }
+    // a surrogate pair has to be sent as two consecutive key downs
+    if (IS_HIGH_SURROGATE(text[i]) && i + 1 < length &&
+        IS_LOW_SURROGATE(text[i + 1])) {
+      fillInputUnicode(&inputs[count++], text[i], true);
+      fillInputUnicode(&inputs[count++], text[i + 1], true);
+      fillInputUnicode(&inputs[count++], text[i], false);
+      fillInputUnicode(&inputs[count++], text[i + 1], false);
+      i++;
+      continue;
+    }
+    fillInputUnicode(&inputs[count++], text[i], true);
+    fillInputUnicode(&inputs[count++], text[i], false);
+  }
+  int result = sendInputs(count, inputs);
+  free(inputs);
+  return result;
 }
\ No newline at end of file
@@ -4,6 +4,10 @@
 #include <windows.h>
 
 int sendInputKeyCode(const int key, bool keyDown);
-int sendInputKeyChar(const char key, bool keyDown);
+int sendInputScancode(const unsigned int scancode, bool keyDown);
+int sendInputKeyChar(const unsigned int codepoint, bool keyDown);
+int charVirtualKey(const unsigned int codepoint);
+int sendInputChord(const int *modifiers, const int count, const int key);
+int sendInputText(const WCHAR *text, const int length);
 
 #endif
\ No newline at end of file
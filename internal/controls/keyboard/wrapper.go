@@ -1,3 +1,5 @@
+//go:build windows
+
 package keyboard
 
 /*
@@ -8,6 +10,10 @@ package keyboard
 import "C"
 
 import (
+	"fmt"
+	"unicode/utf16"
+	"unsafe"
+
 	pkgerrors "github.com/benu-cloud/benu-errors"
 	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
 )
@@ -104,7 +110,19 @@ var specialKeyboardKey map[types.SpecialKeyboardKey]C.int = map[types.SpecialKey
 type Keyboard_c struct{}
 
 func (c *Keyboard_c) SendInputKeyChar(key rune, down bool) error {
-	if code := C.sendInputKeyChar((C.char)(key), (C.bool)(down)); code != C.ERROR_SUCCESS {
+	if code := C.sendInputKeyChar((C.uint)(key), (C.bool)(down)); code != C.ERROR_SUCCESS {
+		return pkgerrors.NewKeyboardInputError(int(code))
+	}
+	return nil
+}
+
+func (c *Keyboard_c) TypeText(text string) error {
+	// windows expects utf-16, characters outside the BMP become surrogate pairs
+	units := utf16.Encode([]rune(text))
+	if len(units) == 0 {
+		return nil
+	}
+	if code := C.sendInputText((*C.WCHAR)(unsafe.Pointer(&units[0])), C.int(len(units))); code != C.ERROR_SUCCESS {
 		return pkgerrors.NewKeyboardInputError(int(code))
 	}
 	return nil
@@ -120,3 +138,40 @@ func (c *Keyboard_c) SendInputKeySpecialKey(k types.SpecialKeyboardKey, down boo
 	}
 	return nil
 }
+
+func (c *Keyboard_c) SendInputPhysicalKey(k types.PhysicalKey, down bool) error {
+	scancode, ok := physicalKeyScancode[k]
+	if !ok {
+		return pkgerrors.NewNotImplementedError("SendInputPhysicalKey", string(k))
+	}
+	if code := C.sendInputScancode(C.uint(scancode), (C.bool)(down)); code != C.ERROR_SUCCESS {
+		return pkgerrors.NewKeyboardInputError(int(code))
+	}
+	return nil
+}
+
+func (c *Keyboard_c) SendInputChord(chord types.Chord) error {
+	modifiers := make([]C.int, len(chord.Modifiers), len(chord.Modifiers)+1)
+	for i, modifier := range chord.Modifiers {
+		ckey, ok := specialKeyboardKey[modifier]
+		if !ok {
+			return pkgerrors.NewNotImplementedError("SendInputChord", string(modifier))
+		}
+		modifiers[i] = ckey
+	}
+	var ckey C.int
+	if chord.Key != "" {
+		var ok bool
+		if ckey, ok = specialKeyboardKey[chord.Key]; !ok {
+			return pkgerrors.NewNotImplementedError("SendInputChord", string(chord.Key))
+		}
+	} else if ckey = C.charVirtualKey(C.uint(chord.Char)); ckey < 0 {
+		return pkgerrors.NewNotImplementedError("SendInputChord", fmt.Sprintf("character %c", chord.Char))
+	}
+	// the modifiers may be empty, so always pass a valid pointer
+	modifiers = append(modifiers, 0)
+	if code := C.sendInputChord(&modifiers[0], C.int(len(chord.Modifiers)), ckey); code != C.ERROR_SUCCESS {
+		return pkgerrors.NewKeyboardInputError(int(code))
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 #include "mouse.h"
 
+#include <stdlib.h>
+
 int sendInputMove(const int dx, const int dy) {
   INPUT input = {0};
   input.type = INPUT_MOUSE;
@@ -26,4 +28,24 @@ int sendInputScroll(const int scrollDir, const int size) {
   UINT sent = SendInput(1, &input, sizeof(INPUT));
   if (!sent) return HRESULT_FROM_WIN32(GetLastError());
   return ERROR_SUCCESS;
+}
+// send several events with a single SendInput call, so they can't be
+// interleaved with other input
+int sendInputBatch(const MouseEvent *events, const int count) {
+  if (count <= 0) return ERROR_SUCCESS;
+  INPUT *inputs = calloc(count, sizeof(INPUT));
+  if (inputs == NULL) return ERROR_NOT_ENOUGH_MEMORY;
+  int i;
+  for (i = 0; i < count; i++) {
+    inputs[i].type = INPUT_MOUSE;
+    inputs[i].mi.dwFlags = events[i].flags;
+    inputs[i].mi.dx = events[i].dx;
+    inputs[i].mi.dy = events[i].dy;
+    inputs[i].mi.mouseData = events[i].data;
+  }
+  UINT sent = SendInput(count, inputs, sizeof(INPUT));
+  int result = ERROR_SUCCESS;
+  if (sent != (UINT)count) result = HRESULT_FROM_WIN32(GetLastError());
+  free(inputs);
+  return result;
 }
\ No newline at end of file
@@ -2,7 +2,16 @@
 #define MOUSE_H
 #include <windows.h>
 
+// one event of a batch, flags are MOUSEEVENTF_* values
+typedef struct {
+  int flags;
+  int dx;
+  int dy;
+  int data;
+} MouseEvent;
+
 int sendInputMove(const int dx, const int dy);
 int sendInputKey(const int key);
 int sendInputScroll(const int scrollDir, const int size);
+int sendInputBatch(const MouseEvent *events, const int count);
 #endif
\ No newline at end of file
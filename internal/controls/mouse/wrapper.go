@@ -1,3 +1,5 @@
+//go:build windows
+
 package mouse
 
 /*
@@ -61,3 +63,34 @@ func (m *Mouse_c) SendInputScroll(direction types.MouseWheelDir, magnitude int)
 	}
 	return nil
 }
+
+func (m *Mouse_c) SendInputBatch(events []types.MouseEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	cevents := make([]C.MouseEvent, len(events))
+	for i, event := range events {
+		switch event.Type {
+		case types.MouseMoveEvent:
+			cevents[i] = C.MouseEvent{flags: C.MOUSEEVENTF_MOVE, dx: C.int(event.Dx), dy: C.int(event.Dy)}
+		case types.MouseKeyEvent:
+			ckey, ok := mouseKey[event.Key]
+			if !ok {
+				return pkgerrors.NewNotImplementedError("SendInputBatch", string(event.Key))
+			}
+			cevents[i] = C.MouseEvent{flags: ckey}
+		case types.MouseScrollEvent:
+			cdir, ok := mouseWheelDir[event.Direction]
+			if !ok {
+				return pkgerrors.NewNotImplementedError("SendInputBatch", fmt.Sprintf("direction %v", event.Direction))
+			}
+			cevents[i] = C.MouseEvent{flags: cdir, data: C.int(event.Magnitude)}
+		default:
+			return pkgerrors.NewNotImplementedError("SendInputBatch", fmt.Sprintf("event type %d", event.Type))
+		}
+	}
+	if code := C.sendInputBatch(&cevents[0], C.int(len(cevents))); code != C.ERROR_SUCCESS {
+		return pkgerrors.NewMouseInputError(int(code))
+	}
+	return nil
+}
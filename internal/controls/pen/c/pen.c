@@ -0,0 +1,65 @@
+#include "pen.h"
+
+#include <stdlib.h>
+
+int createPen(PenDevice **device) {
+  HSYNTHETICPOINTERDEVICE handle =
+      CreateSyntheticPointerDevice(PT_PEN, 1, POINTER_FEEDBACK_DEFAULT);
+  if (handle == NULL) return HRESULT_FROM_WIN32(GetLastError());
+  *device = calloc(1, sizeof(PenDevice));
+  if (*device == NULL) {
+    DestroySyntheticPointerDevice(handle);
+    return ERROR_NOT_ENOUGH_MEMORY;
+  }
+  (*device)->handle = handle;
+  return ERROR_SUCCESS;
+}
+void destroyPen(PenDevice *device) {
+  DestroySyntheticPointerDevice(device->handle);
+  free(device);
+}
+// report the whole pen state, windows needs down and up to be told apart from
+// updates
+int sendPenReport(PenDevice *device, const PenReport *report,
+                  const int streamWidth, const int streamHeight) {
+  if (streamWidth <= 0 || streamHeight <= 0) return ERROR_BAD_ARGUMENTS;
+  const bool inContact = report->inContact;
+  const bool inRange = report->inRange || inContact;
+  // nothing to report for a pen that stays out of range
+  if (!inRange && !device->inRange) return ERROR_SUCCESS;
+
+  POINTER_TYPE_INFO info = {0};
+  info.type = PT_PEN;
+  POINTER_PEN_INFO *pen = &info.penInfo;
+  pen->pointerInfo.pointerType = PT_PEN;
+  // the stream is scaled to the primary screen
+  pen->pointerInfo.ptPixelLocation.x =
+      MulDiv(report->x, GetSystemMetrics(SM_CXSCREEN), streamWidth);
+  pen->pointerInfo.ptPixelLocation.y =
+      MulDiv(report->y, GetSystemMetrics(SM_CYSCREEN), streamHeight);
+
+  POINTER_FLAGS flags = POINTER_FLAG_NONE;
+  if (inContact && !device->inContact)
+    flags |= POINTER_FLAG_DOWN;
+  else if (!inContact && device->inContact)
+    flags |= POINTER_FLAG_UP;
+  else
+    flags |= POINTER_FLAG_UPDATE;
+  if (inRange) flags |= POINTER_FLAG_INRANGE;
+  if (inContact) flags |= POINTER_FLAG_INCONTACT;
+  pen->pointerInfo.pointerFlags = flags;
+
+  pen->penFlags = PEN_FLAG_NONE;
+  if (report->eraser) pen->penFlags |= PEN_FLAG_ERASER | PEN_FLAG_INVERTED;
+  if (report->barrel) pen->penFlags |= PEN_FLAG_BARREL;
+  pen->penMask = PEN_MASK_PRESSURE | PEN_MASK_TILT_X | PEN_MASK_TILT_Y;
+  pen->pressure = inContact ? report->pressure : 0;
+  pen->tiltX = report->tiltX;
+  pen->tiltY = report->tiltY;
+
+  if (!InjectSyntheticPointerInput(device->handle, &info, 1))
+    return HRESULT_FROM_WIN32(GetLastError());
+  device->inRange = inRange;
+  device->inContact = inContact;
+  return ERROR_SUCCESS;
+}
\ No newline at end of file
@@ -0,0 +1,39 @@
+#ifndef PEN_H
+#define PEN_H
+// synthetic pointer devices need windows 10 1809
+#define WINVER 0x0A00
+#define _WIN32_WINNT 0x0A00
+#include <stdbool.h>
+#include <windows.h>
+
+#define PEN_MAX_PRESSURE 1024
+#define PEN_MAX_TILT 90
+
+typedef struct {
+  HSYNTHETICPOINTERDEVICE handle;
+  // the pen was in range in the last report
+  bool inRange;
+  // the pen touched the screen in the last report
+  bool inContact;
+} PenDevice;
+
+typedef struct {
+  // in stream coordinates
+  int x;
+  int y;
+  // in range 0-PEN_MAX_PRESSURE
+  unsigned int pressure;
+  // in range -PEN_MAX_TILT to PEN_MAX_TILT
+  int tiltX;
+  int tiltY;
+  bool inRange;
+  bool inContact;
+  bool eraser;
+  bool barrel;
+} PenReport;
+
+int createPen(PenDevice **device);
+void destroyPen(PenDevice *device);
+int sendPenReport(PenDevice *device, const PenReport *report,
+                  const int streamWidth, const int streamHeight);
+#endif
\ No newline at end of file
@@ -0,0 +1,108 @@
+#include "pen_linux.h"
+
+#include <errno.h>
+#include <fcntl.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <sys/ioctl.h>
+#include <unistd.h>
+
+static int setupAxis(const int fd, const int code, const int min,
+                     const int max) {
+  struct uinput_abs_setup abs = {0};
+  abs.code = code;
+  abs.absinfo.minimum = min;
+  abs.absinfo.maximum = max;
+  if (ioctl(fd, UI_SET_ABSBIT, code) < 0) return errno;
+  if (ioctl(fd, UI_ABS_SETUP, &abs) < 0) return errno;
+  return 0;
+}
+static int emit(const int fd, const int type, const int code,
+                const int value) {
+  struct input_event event = {0};
+  event.type = type;
+  event.code = code;
+  event.value = value;
+  if (write(fd, &event, sizeof(event)) != sizeof(event)) return errno;
+  return 0;
+}
+
+// create a pen tablet mapped onto the screen, positions range over the stream
+// resolution
+int createPen(PenDevice **device, const int width, const int height) {
+  int fd = open("/dev/uinput", O_WRONLY | O_NONBLOCK);
+  if (fd < 0) return errno;
+  int code = 0;
+  const int keys[] = {BTN_TOOL_PEN, BTN_TOOL_RUBBER, BTN_TOUCH, BTN_STYLUS};
+  if (ioctl(fd, UI_SET_EVBIT, EV_KEY) < 0) goto error;
+  for (size_t i = 0; i < sizeof(keys) / sizeof(keys[0]); i++)
+    if (ioctl(fd, UI_SET_KEYBIT, keys[i]) < 0) goto error;
+  if (ioctl(fd, UI_SET_EVBIT, EV_ABS) < 0) goto error;
+  if (ioctl(fd, UI_SET_PROPBIT, INPUT_PROP_DIRECT) < 0) goto error;
+  if ((code = setupAxis(fd, ABS_X, 0, width - 1)) ||
+      (code = setupAxis(fd, ABS_Y, 0, height - 1)) ||
+      (code = setupAxis(fd, ABS_PRESSURE, 0, PEN_MAX_PRESSURE)) ||
+      (code = setupAxis(fd, ABS_TILT_X, -PEN_MAX_TILT, PEN_MAX_TILT)) ||
+      (code = setupAxis(fd, ABS_TILT_Y, -PEN_MAX_TILT, PEN_MAX_TILT))) {
+    close(fd);
+    return code;
+  }
+
+  struct uinput_setup setup = {0};
+  snprintf(setup.name, UINPUT_MAX_NAME_SIZE, "Benu Virtual Pen");
+  setup.id.bustype = BUS_VIRTUAL;
+  if (ioctl(fd, UI_DEV_SETUP, &setup) < 0) goto error;
+  if (ioctl(fd, UI_DEV_CREATE) < 0) goto error;
+
+  *device = calloc(1, sizeof(PenDevice));
+  if (*device == NULL) {
+    ioctl(fd, UI_DEV_DESTROY);
+    close(fd);
+    return ENOMEM;
+  }
+  (*device)->fd = fd;
+  return 0;
+error:
+  code = errno;
+  close(fd);
+  return code;
+}
+int destroyPen(PenDevice *device) {
+  int code = 0;
+  if (ioctl(device->fd, UI_DEV_DESTROY) < 0) code = errno;
+  close(device->fd);
+  free(device);
+  return code;
+}
+// report the whole pen state, switching tools takes the old one out of range
+int sendPenReport(PenDevice *device, const PenReport *report) {
+  const int fd = device->fd;
+  int code;
+  int tool = 0;
+  if (report->inRange || report->inContact)
+    tool = report->eraser ? BTN_TOOL_RUBBER : BTN_TOOL_PEN;
+  if (device->tool != 0 && device->tool != tool) {
+    if ((code = emit(fd, EV_KEY, BTN_TOUCH, 0))) return code;
+    if ((code = emit(fd, EV_KEY, BTN_STYLUS, 0))) return code;
+    if ((code = emit(fd, EV_ABS, ABS_PRESSURE, 0))) return code;
+    if ((code = emit(fd, EV_KEY, device->tool, 0))) return code;
+    if ((code = emit(fd, EV_SYN, SYN_REPORT, 0))) return code;
+    device->tool = 0;
+  }
+  if (tool == 0) return 0;
+  if ((code = emit(fd, EV_KEY, tool, 1))) return code;
+  if ((code = emit(fd, EV_ABS, ABS_X, report->x))) return code;
+  if ((code = emit(fd, EV_ABS, ABS_Y, report->y))) return code;
+  if ((code = emit(fd, EV_ABS, ABS_PRESSURE,
+                   report->inContact ? report->pressure : 0)))
+    return code;
+  if ((code = emit(fd, EV_ABS, ABS_TILT_X, report->tiltX))) return code;
+  if ((code = emit(fd, EV_ABS, ABS_TILT_Y, report->tiltY))) return code;
+  if ((code = emit(fd, EV_KEY, BTN_TOUCH, report->inContact ? 1 : 0)))
+    return code;
+  if ((code = emit(fd, EV_KEY, BTN_STYLUS, report->barrel ? 1 : 0)))
+    return code;
+  if ((code = emit(fd, EV_SYN, SYN_REPORT, 0))) return code;
+  device->tool = tool;
+  return 0;
+}
\ No newline at end of file
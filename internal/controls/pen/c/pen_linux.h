@@ -0,0 +1,33 @@
+#ifndef PEN_LINUX_H
+#define PEN_LINUX_H
+#include <linux/input.h>
+#include <linux/uinput.h>
+#include <stdbool.h>
+
+#define PEN_MAX_PRESSURE 4095
+#define PEN_MAX_TILT 90
+
+typedef struct {
+  int fd;
+  // the tool reported in range, 0 when out of range
+  int tool;
+} PenDevice;
+
+typedef struct {
+  int x;
+  int y;
+  // in range 0-PEN_MAX_PRESSURE
+  int pressure;
+  // in range -PEN_MAX_TILT to PEN_MAX_TILT
+  int tiltX;
+  int tiltY;
+  bool inRange;
+  bool inContact;
+  bool eraser;
+  bool barrel;
+} PenReport;
+
+int createPen(PenDevice **device, const int width, const int height);
+int destroyPen(PenDevice *device);
+int sendPenReport(PenDevice *device, const PenReport *report);
+#endif
\ No newline at end of file
@@ -0,0 +1,19 @@
+package pen
+
+import "fmt"
+
+// PenInputError indicates that sending pen input to the OS failed
+type PenInputError struct {
+	// errno value on linux, HRESULT on windows
+	Code int
+}
+
+func (e *PenInputError) Error() string {
+	return fmt.Sprintf("PenInputError: Code %d", e.Code)
+}
+
+func NewPenInputError(code int) error {
+	return &PenInputError{
+		Code: code,
+	}
+}
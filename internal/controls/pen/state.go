@@ -0,0 +1,21 @@
+package pen
+
+// map a value in range 0-1 onto 0 to max
+func scalePressure(pressure float64, max int) int {
+	if pressure < 0 {
+		pressure = 0
+	} else if pressure > 1 {
+		pressure = 1
+	}
+	return int(pressure*float64(max) + 0.5)
+}
+
+// limit tilt to the range the devices report
+func clampTilt(tilt int, max int) int {
+	if tilt < -max {
+		return -max
+	} else if tilt > max {
+		return max
+	}
+	return tilt
+}
@@ -0,0 +1,45 @@
+package pen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pressureTest struct {
+	pressure float64
+	expected int
+}
+
+func TestScalePressure(t *testing.T) {
+	pressureTests := []pressureTest{
+		{0, 0},
+		{0.5, 512},
+		{1, 1024},
+		// out of range pressure is clamped
+		{-0.2, 0},
+		{1.5, 1024},
+	}
+	for _, test := range pressureTests {
+		assert.Equal(t, test.expected, scalePressure(test.pressure, 1024), test.pressure)
+	}
+}
+
+type tiltTest struct {
+	tilt     int
+	expected int
+}
+
+func TestClampTilt(t *testing.T) {
+	tiltTests := []tiltTest{
+		{0, 0},
+		{-45, -45},
+		{90, 90},
+		// a client sending more than the devices report gets the limit
+		{120, 90},
+		{-135, -90},
+	}
+	for _, test := range tiltTests {
+		assert.Equal(t, test.expected, clampTilt(test.tilt, 90), test.tilt)
+	}
+}
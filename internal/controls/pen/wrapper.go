@@ -0,0 +1,80 @@
+//go:build windows
+
+package pen
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -lpen
+#include "pen.h"
+*/
+import "C"
+
+import (
+	"sync"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// c implementation using a synthetic pointer device, positions are scaled from the stream to the primary screen
+type Pen_c struct {
+	mutex  sync.Mutex
+	device *C.PenDevice
+	width  int
+	height int
+}
+
+// NewPen_c creates a synthetic pen for a stream of the given resolution
+func NewPen_c(width int, height int) (*Pen_c, error) {
+	var device *C.PenDevice
+	if code := C.createPen(&device); code != C.ERROR_SUCCESS {
+		return nil, NewPenInputError(int(code))
+	}
+	return &Pen_c{device: device, width: width, height: height}, nil
+}
+
+func (p *Pen_c) SendInputPen(state types.PenState) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.device == nil {
+		return NewPenInputError(int(C.ERROR_INVALID_HANDLE))
+	}
+	report := C.PenReport{
+		x:         C.int(state.X),
+		y:         C.int(state.Y),
+		pressure:  C.uint(scalePressure(state.Pressure, C.PEN_MAX_PRESSURE)),
+		tiltX:     C.int(clampTilt(state.TiltX, C.PEN_MAX_TILT)),
+		tiltY:     C.int(clampTilt(state.TiltY, C.PEN_MAX_TILT)),
+		inRange:   C.bool(state.InRange),
+		inContact: C.bool(state.InContact),
+		eraser:    C.bool(state.Eraser),
+		barrel:    C.bool(state.Barrel),
+	}
+	if code := C.sendPenReport(p.device, &report, C.int(p.width), C.int(p.height)); code != C.ERROR_SUCCESS {
+		return NewPenInputError(int(code))
+	}
+	return nil
+}
+
+// SetResolution changes the resolution of the stream the pen is scaled from
+func (p *Pen_c) SetResolution(width int, height int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.width, p.height = width, height
+}
+
+func (p *Pen_c) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.device == nil {
+		return nil
+	}
+	// lift the pen before it disappears
+	var report C.PenReport
+	code := C.sendPenReport(p.device, &report, C.int(p.width), C.int(p.height))
+	C.destroyPen(p.device)
+	p.device = nil
+	if code != C.ERROR_SUCCESS {
+		return NewPenInputError(int(code))
+	}
+	return nil
+}
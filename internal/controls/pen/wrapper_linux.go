@@ -0,0 +1,69 @@
+//go:build linux
+
+package pen
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -lpen_linux
+#include <errno.h>
+#include "pen_linux.h"
+*/
+import "C"
+
+import (
+	"sync"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// uinput implementation, shows up as a pen tablet mapped onto the screen
+type Pen_uinput struct {
+	mutex  sync.Mutex
+	device *C.PenDevice
+}
+
+// NewPen_uinput creates a virtual pen covering a stream of the given resolution
+func NewPen_uinput(width int, height int) (*Pen_uinput, error) {
+	var device *C.PenDevice
+	if code := C.createPen(&device, C.int(width), C.int(height)); code != 0 {
+		return nil, NewPenInputError(int(code))
+	}
+	return &Pen_uinput{device: device}, nil
+}
+
+func (p *Pen_uinput) SendInputPen(state types.PenState) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.device == nil {
+		return NewPenInputError(int(C.ENODEV))
+	}
+	report := C.PenReport{
+		x:         C.int(state.X),
+		y:         C.int(state.Y),
+		pressure:  C.int(scalePressure(state.Pressure, C.PEN_MAX_PRESSURE)),
+		tiltX:     C.int(clampTilt(state.TiltX, C.PEN_MAX_TILT)),
+		tiltY:     C.int(clampTilt(state.TiltY, C.PEN_MAX_TILT)),
+		inRange:   C.bool(state.InRange),
+		inContact: C.bool(state.InContact),
+		eraser:    C.bool(state.Eraser),
+		barrel:    C.bool(state.Barrel),
+	}
+	if code := C.sendPenReport(p.device, &report); code != 0 {
+		return NewPenInputError(int(code))
+	}
+	return nil
+}
+
+func (p *Pen_uinput) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.device == nil {
+		return nil
+	}
+	code := C.destroyPen(p.device)
+	p.device = nil
+	if code != 0 {
+		return NewPenInputError(int(code))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+#include "touch.h"
+
+#include <stdlib.h>
+
+// half the size of the contact area in pixels
+#define CONTACT_RADIUS 2
+
+int initTouch() {
+  if (!InitializeTouchInjection(TOUCH_MAX_CONTACTS, TOUCH_FEEDBACK_DEFAULT))
+    return HRESULT_FROM_WIN32(GetLastError());
+  return ERROR_SUCCESS;
+}
+// inject a frame, every active contact has to be in it
+int sendTouchFrame(const TouchPoint *points, const int count,
+                   const int streamWidth, const int streamHeight) {
+  if (count <= 0) return ERROR_SUCCESS;
+  if (streamWidth <= 0 || streamHeight <= 0) return ERROR_BAD_ARGUMENTS;
+  POINTER_TOUCH_INFO *infos = calloc(count, sizeof(POINTER_TOUCH_INFO));
+  if (infos == NULL) return ERROR_NOT_ENOUGH_MEMORY;
+  // the stream is scaled to the primary screen
+  int screenWidth = GetSystemMetrics(SM_CXSCREEN);
+  int screenHeight = GetSystemMetrics(SM_CYSCREEN);
+  int i;
+  for (i = 0; i < count; i++) {
+    POINTER_TOUCH_INFO *info = &infos[i];
+    LONG x = MulDiv(points[i].x, screenWidth, streamWidth);
+    LONG y = MulDiv(points[i].y, screenHeight, streamHeight);
+    info->pointerInfo.pointerType = PT_TOUCH;
+    info->pointerInfo.pointerId = points[i].slot;
+    info->pointerInfo.ptPixelLocation.x = x;
+    info->pointerInfo.ptPixelLocation.y = y;
+    switch (points[i].phase) {
+      case TOUCH_PHASE_DOWN:
+        info->pointerInfo.pointerFlags =
+            POINTER_FLAG_DOWN | POINTER_FLAG_INRANGE | POINTER_FLAG_INCONTACT;
+        break;
+      case TOUCH_PHASE_MOVE:
+        info->pointerInfo.pointerFlags =
+            POINTER_FLAG_UPDATE | POINTER_FLAG_INRANGE | POINTER_FLAG_INCONTACT;
+        break;
+      case TOUCH_PHASE_UP:
+        info->pointerInfo.pointerFlags = POINTER_FLAG_UP;
+        break;
+      case TOUCH_PHASE_CANCEL:
+        info->pointerInfo.pointerFlags = POINTER_FLAG_UP | POINTER_FLAG_CANCELED;
+        break;
+    }
+    info->touchFlags = TOUCH_FLAG_NONE;
+    info->touchMask = TOUCH_MASK_CONTACTAREA | TOUCH_MASK_PRESSURE;
+    info->pressure = points[i].pressure;
+    info->rcContact.left = x - CONTACT_RADIUS;
+    info->rcContact.right = x + CONTACT_RADIUS;
+    info->rcContact.top = y - CONTACT_RADIUS;
+    info->rcContact.bottom = y + CONTACT_RADIUS;
+  }
+  int result = ERROR_SUCCESS;
+  if (!InjectTouchInput(count, infos))
+    result = HRESULT_FROM_WIN32(GetLastError());
+  free(infos);
+  return result;
+}
\ No newline at end of file
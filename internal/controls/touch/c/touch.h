@@ -0,0 +1,32 @@
+#ifndef TOUCH_H
+#define TOUCH_H
+// touch injection needs windows 8
+#define WINVER 0x0602
+#define _WIN32_WINNT 0x0602
+#include <stdbool.h>
+#include <windows.h>
+
+#define TOUCH_MAX_CONTACTS 10
+#define TOUCH_MAX_PRESSURE 1024
+
+typedef enum {
+  TOUCH_PHASE_DOWN,
+  TOUCH_PHASE_MOVE,
+  TOUCH_PHASE_UP,
+  TOUCH_PHASE_CANCEL,
+} TouchPhase;
+
+typedef struct {
+  unsigned int slot;
+  TouchPhase phase;
+  // in stream coordinates
+  int x;
+  int y;
+  // in range 0-TOUCH_MAX_PRESSURE
+  unsigned int pressure;
+} TouchPoint;
+
+int initTouch();
+int sendTouchFrame(const TouchPoint *points, const int count,
+                   const int streamWidth, const int streamHeight);
+#endif
\ No newline at end of file
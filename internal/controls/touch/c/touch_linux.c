@@ -0,0 +1,100 @@
+#include "touch_linux.h"
+
+#include <errno.h>
+#include <fcntl.h>
+#include <stdio.h>
+#include <sys/ioctl.h>
+#include <unistd.h>
+
+static int setupAxis(const int fd, const int code, const int max) {
+  struct uinput_abs_setup abs = {0};
+  abs.code = code;
+  abs.absinfo.maximum = max;
+  if (ioctl(fd, UI_SET_ABSBIT, code) < 0) return errno;
+  if (ioctl(fd, UI_ABS_SETUP, &abs) < 0) return errno;
+  return 0;
+}
+static int emit(const int fd, const int type, const int code,
+                const int value) {
+  struct input_event event = {0};
+  event.type = type;
+  event.code = code;
+  event.value = value;
+  if (write(fd, &event, sizeof(event)) != sizeof(event)) return errno;
+  return 0;
+}
+
+// create a multitouch screen, positions range over the stream resolution
+int createTouch(int *fd, const int width, const int height) {
+  *fd = open("/dev/uinput", O_WRONLY | O_NONBLOCK);
+  if (*fd < 0) return errno;
+  int code = 0;
+  if (ioctl(*fd, UI_SET_EVBIT, EV_KEY) < 0) goto error;
+  if (ioctl(*fd, UI_SET_KEYBIT, BTN_TOUCH) < 0) goto error;
+  if (ioctl(*fd, UI_SET_EVBIT, EV_ABS) < 0) goto error;
+  // a touchscreen rather than a touchpad
+  if (ioctl(*fd, UI_SET_PROPBIT, INPUT_PROP_DIRECT) < 0) goto error;
+  // single touch axes for older applications, and type B multitouch slots
+  if ((code = setupAxis(*fd, ABS_X, width - 1)) ||
+      (code = setupAxis(*fd, ABS_Y, height - 1)) ||
+      (code = setupAxis(*fd, ABS_PRESSURE, TOUCH_MAX_PRESSURE)) ||
+      (code = setupAxis(*fd, ABS_MT_SLOT, TOUCH_MAX_CONTACTS - 1)) ||
+      (code = setupAxis(*fd, ABS_MT_TRACKING_ID, 65535)) ||
+      (code = setupAxis(*fd, ABS_MT_POSITION_X, width - 1)) ||
+      (code = setupAxis(*fd, ABS_MT_POSITION_Y, height - 1)) ||
+      (code = setupAxis(*fd, ABS_MT_PRESSURE, TOUCH_MAX_PRESSURE))) {
+    close(*fd);
+    return code;
+  }
+
+  struct uinput_setup setup = {0};
+  snprintf(setup.name, UINPUT_MAX_NAME_SIZE, "Benu Virtual Touchscreen");
+  setup.id.bustype = BUS_VIRTUAL;
+  if (ioctl(*fd, UI_DEV_SETUP, &setup) < 0) goto error;
+  if (ioctl(*fd, UI_DEV_CREATE) < 0) goto error;
+  return 0;
+error:
+  code = errno;
+  close(*fd);
+  return code;
+}
+int destroyTouch(const int fd) {
+  int code = 0;
+  if (ioctl(fd, UI_DEV_DESTROY) < 0) code = errno;
+  close(fd);
+  return code;
+}
+// put a contact down in a slot, nothing is applied until sendTouchReport
+int sendTouchDown(const int fd, const int slot, const int trackingId,
+                  const int x, const int y, const int pressure) {
+  int code;
+  if ((code = emit(fd, EV_ABS, ABS_MT_SLOT, slot))) return code;
+  if ((code = emit(fd, EV_ABS, ABS_MT_TRACKING_ID, trackingId))) return code;
+  return sendTouchMove(fd, slot, x, y, pressure);
+}
+int sendTouchMove(const int fd, const int slot, const int x, const int y,
+                  const int pressure) {
+  int code;
+  if ((code = emit(fd, EV_ABS, ABS_MT_SLOT, slot))) return code;
+  if ((code = emit(fd, EV_ABS, ABS_MT_POSITION_X, x))) return code;
+  if ((code = emit(fd, EV_ABS, ABS_MT_POSITION_Y, y))) return code;
+  return emit(fd, EV_ABS, ABS_MT_PRESSURE, pressure);
+}
+int sendTouchUp(const int fd, const int slot) {
+  int code;
+  if ((code = emit(fd, EV_ABS, ABS_MT_SLOT, slot))) return code;
+  return emit(fd, EV_ABS, ABS_MT_TRACKING_ID, -1);
+}
+// finish a frame, the position is of the first contact for single touch
+int sendTouchReport(const int fd, bool touching, const int x, const int y,
+                    const int pressure) {
+  int code;
+  if ((code = emit(fd, EV_KEY, BTN_TOUCH, touching ? 1 : 0))) return code;
+  if (touching) {
+    if ((code = emit(fd, EV_ABS, ABS_X, x))) return code;
+    if ((code = emit(fd, EV_ABS, ABS_Y, y))) return code;
+  }
+  if ((code = emit(fd, EV_ABS, ABS_PRESSURE, touching ? pressure : 0)))
+    return code;
+  return emit(fd, EV_SYN, SYN_REPORT, 0);
+}
\ No newline at end of file
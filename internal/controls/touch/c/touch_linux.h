@@ -0,0 +1,19 @@
+#ifndef TOUCH_LINUX_H
+#define TOUCH_LINUX_H
+#include <linux/input.h>
+#include <linux/uinput.h>
+#include <stdbool.h>
+
+#define TOUCH_MAX_CONTACTS 10
+#define TOUCH_MAX_PRESSURE 255
+
+int createTouch(int *fd, const int width, const int height);
+int destroyTouch(const int fd);
+int sendTouchDown(const int fd, const int slot, const int trackingId,
+                  const int x, const int y, const int pressure);
+int sendTouchMove(const int fd, const int slot, const int x, const int y,
+                  const int pressure);
+int sendTouchUp(const int fd, const int slot);
+int sendTouchReport(const int fd, bool touching, const int x, const int y,
+                    const int pressure);
+#endif
\ No newline at end of file
@@ -0,0 +1,145 @@
+package touch
+
+import (
+	"sort"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// MaxContacts is the number of fingers tracked at once
+const MaxContacts = 10
+
+// a contact in a device slot
+type slotContact struct {
+	slot    int
+	contact types.TouchContact
+}
+
+// contacts gives every client contact id a device slot and remembers where active contacts are
+type contacts struct {
+	active [MaxContacts]*types.TouchContact
+}
+
+// update applies changes and returns frames of every active or just lifted contact, ordered by slot.
+// Unchanged contacts are repeated, since windows expects every active contact in each frame.
+// A contact put down and lifted in the same update, like a fast tap, gets a frame for each,
+// so the device sees it touch. A move for an unknown contact starts it, lifting an unknown
+// contact does nothing. Nothing changes if the update fails
+func (c *contacts) update(changes []types.TouchContact) ([][]slotContact, error) {
+	for _, change := range changes {
+		switch change.Phase {
+		case types.TouchDown, types.TouchMove, types.TouchUp, types.TouchCancel:
+		default:
+			return nil, NewTouchPhaseError(string(change.Phase))
+		}
+	}
+	// changes are applied to a copy, which replaces the contacts once they all fit
+	next := *c
+	frames := make([][]slotContact, 0, 1)
+	frame := make(map[int]types.TouchContact)
+	// start a new frame when the slot was lifted in this one, or is lifted after going down in it
+	flush := func(slot int, phase types.TouchPhase) {
+		previous, ok := frame[slot]
+		lifted := previous.Phase == types.TouchUp || previous.Phase == types.TouchCancel
+		tapped := previous.Phase == types.TouchDown && (phase == types.TouchUp || phase == types.TouchCancel)
+		if ok && (lifted || tapped) {
+			frames = append(frames, next.frame(frame))
+			frame = make(map[int]types.TouchContact)
+		}
+	}
+	for _, change := range changes {
+		slot := next.find(change.ID)
+		switch change.Phase {
+		case types.TouchDown, types.TouchMove:
+			if slot < 0 {
+				if slot = next.free(); slot < 0 {
+					return nil, NewTooManyContactsError(MaxContacts)
+				}
+				change.Phase = types.TouchDown
+			} else if previous, ok := frame[slot]; ok && previous.Phase == types.TouchDown {
+				// moved in the same frame it was put down
+				change.Phase = types.TouchDown
+			} else {
+				change.Phase = types.TouchMove
+			}
+			flush(slot, change.Phase)
+			active := change
+			next.active[slot] = &active
+			frame[slot] = change
+		case types.TouchUp, types.TouchCancel:
+			if slot < 0 {
+				continue
+			}
+			flush(slot, change.Phase)
+			next.active[slot] = nil
+			frame[slot] = change
+		}
+	}
+	*c = next
+	return append(frames, c.frame(frame)), nil
+}
+
+// frame adds the unchanged active contacts to the changed ones, ordered by slot
+func (c *contacts) frame(changed map[int]types.TouchContact) []slotContact {
+	for slot, contact := range c.active {
+		if _, ok := changed[slot]; contact != nil && !ok {
+			unchanged := *contact
+			unchanged.Phase = types.TouchMove
+			changed[slot] = unchanged
+		}
+	}
+	return sortFrame(changed)
+}
+
+// cancel lifts every active contact and returns them
+func (c *contacts) cancel() []slotContact {
+	frame := make(map[int]types.TouchContact)
+	for slot, contact := range c.active {
+		if contact != nil {
+			lifted := *contact
+			lifted.Phase = types.TouchCancel
+			frame[slot] = lifted
+			c.active[slot] = nil
+		}
+	}
+	return sortFrame(frame)
+}
+
+// find the slot of an active contact
+func (c *contacts) find(id int) int {
+	for slot, contact := range c.active {
+		if contact != nil && contact.ID == id {
+			return slot
+		}
+	}
+	return -1
+}
+
+// find a slot without an active contact
+func (c *contacts) free() int {
+	for slot, contact := range c.active {
+		if contact == nil {
+			return slot
+		}
+	}
+	return -1
+}
+
+func sortFrame(frame map[int]types.TouchContact) []slotContact {
+	sorted := make([]slotContact, 0, len(frame))
+	for slot, contact := range frame {
+		sorted = append(sorted, slotContact{slot: slot, contact: contact})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].slot < sorted[j].slot })
+	return sorted
+}
+
+// map a pressure in range 0-1 onto 0 to max
+func scalePressure(pressure float64, max int) int {
+	if pressure < 0 {
+		pressure = 0
+	} else if pressure > 1 {
+		pressure = 1
+	}
+	return int(pressure*float64(max) + 0.5)
+}
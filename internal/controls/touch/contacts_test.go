@@ -0,0 +1,101 @@
+package touch
+
+import (
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type updateTest struct {
+	changes  []types.TouchContact
+	expected [][]slotContact
+	err      bool
+}
+
+func TestUpdate(t *testing.T) {
+	updateTests := []updateTest{
+		// two fingers down
+		{
+			[]types.TouchContact{{ID: 7, Phase: types.TouchDown, X: 1, Y: 1}, {ID: 3, Phase: types.TouchDown, X: 2, Y: 2}},
+			[][]slotContact{{{0, types.TouchContact{ID: 7, Phase: types.TouchDown, X: 1, Y: 1}}, {1, types.TouchContact{ID: 3, Phase: types.TouchDown, X: 2, Y: 2}}}},
+			false,
+		},
+		// the unchanged finger is repeated
+		{
+			[]types.TouchContact{{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}},
+			[][]slotContact{{{0, types.TouchContact{ID: 7, Phase: types.TouchMove, X: 1, Y: 1}}, {1, types.TouchContact{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}}}},
+			false,
+		},
+		// the first slot is free again once lifted
+		{
+			[]types.TouchContact{{ID: 7, Phase: types.TouchUp, X: 1, Y: 1}},
+			[][]slotContact{{{0, types.TouchContact{ID: 7, Phase: types.TouchUp, X: 1, Y: 1}}, {1, types.TouchContact{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}}}},
+			false,
+		},
+		// a move of an unknown finger puts it down
+		{
+			[]types.TouchContact{{ID: 9, Phase: types.TouchMove, X: 5, Y: 5}},
+			[][]slotContact{{{0, types.TouchContact{ID: 9, Phase: types.TouchDown, X: 5, Y: 5}}, {1, types.TouchContact{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}}}},
+			false,
+		},
+		// a fast tap goes down in one frame and up in the next
+		{
+			[]types.TouchContact{{ID: 11, Phase: types.TouchDown, X: 6, Y: 6}, {ID: 11, Phase: types.TouchUp, X: 6, Y: 6}},
+			[][]slotContact{
+				{{0, types.TouchContact{ID: 9, Phase: types.TouchMove, X: 5, Y: 5}}, {1, types.TouchContact{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}}, {2, types.TouchContact{ID: 11, Phase: types.TouchDown, X: 6, Y: 6}}},
+				{{0, types.TouchContact{ID: 9, Phase: types.TouchMove, X: 5, Y: 5}}, {1, types.TouchContact{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}}, {2, types.TouchContact{ID: 11, Phase: types.TouchUp, X: 6, Y: 6}}},
+			},
+			false,
+		},
+		// a finger lifted and another put down in its slot get a frame each
+		{
+			[]types.TouchContact{{ID: 9, Phase: types.TouchUp, X: 5, Y: 5}, {ID: 12, Phase: types.TouchDown, X: 7, Y: 7}},
+			[][]slotContact{
+				{{0, types.TouchContact{ID: 9, Phase: types.TouchUp, X: 5, Y: 5}}, {1, types.TouchContact{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}}},
+				{{0, types.TouchContact{ID: 12, Phase: types.TouchDown, X: 7, Y: 7}}, {1, types.TouchContact{ID: 3, Phase: types.TouchMove, X: 4, Y: 4}}},
+			},
+			false,
+		},
+		{
+			[]types.TouchContact{{ID: 5, Phase: types.TouchDown}, {ID: 3, Phase: types.TouchPhase("hover")}},
+			nil,
+			true,
+		},
+	}
+	var c contacts
+	for _, test := range updateTests {
+		frame, err := c.update(test.changes)
+		if test.err {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, frame)
+	}
+	assert.Equal(t, []slotContact{
+		{0, types.TouchContact{ID: 12, Phase: types.TouchCancel, X: 7, Y: 7}},
+		{1, types.TouchContact{ID: 3, Phase: types.TouchCancel, X: 4, Y: 4}},
+	}, c.cancel())
+	assert.Empty(t, c.cancel())
+}
+
+func TestTooManyContacts(t *testing.T) {
+	var c contacts
+	changes := make([]types.TouchContact, MaxContacts+1)
+	for i := range changes {
+		changes[i] = types.TouchContact{ID: i, Phase: types.TouchDown}
+	}
+	_, err := c.update(changes[:MaxContacts])
+	assert.NoError(t, err)
+	_, err = c.update(changes[MaxContacts:])
+	assert.Equal(t, &TooManyContactsError{MaxContacts: MaxContacts}, err)
+	// a failed update leaves every contact where it was, even the ones it lifted first
+	_, err = c.update([]types.TouchContact{{ID: 0, Phase: types.TouchUp}, {ID: 20, Phase: types.TouchDown}, {ID: 21, Phase: types.TouchDown}})
+	assert.Equal(t, &TooManyContactsError{MaxContacts: MaxContacts}, err)
+	lifted := c.cancel()
+	assert.Len(t, lifted, MaxContacts)
+	for slot, contact := range lifted {
+		assert.Equal(t, slotContact{slot, types.TouchContact{ID: slot, Phase: types.TouchCancel}}, contact)
+	}
+}
@@ -0,0 +1,49 @@
+package touch
+
+import "fmt"
+
+// TouchInputError indicates that sending touch input to the OS failed
+type TouchInputError struct {
+	// errno value on linux, HRESULT on windows
+	Code int
+}
+
+func (e *TouchInputError) Error() string {
+	return fmt.Sprintf("TouchInputError: Code %d", e.Code)
+}
+
+func NewTouchInputError(code int) error {
+	return &TouchInputError{
+		Code: code,
+	}
+}
+
+// TooManyContactsError indicates input that would put down more contacts than are tracked at once
+type TooManyContactsError struct {
+	MaxContacts int
+}
+
+func (e *TooManyContactsError) Error() string {
+	return fmt.Sprintf("TooManyContactsError: more than %d contacts", e.MaxContacts)
+}
+
+func NewTooManyContactsError(maxContacts int) error {
+	return &TooManyContactsError{
+		MaxContacts: maxContacts,
+	}
+}
+
+// TouchPhaseError indicates a contact with an unknown phase
+type TouchPhaseError struct {
+	Phase string
+}
+
+func (e *TouchPhaseError) Error() string {
+	return fmt.Sprintf("TouchPhaseError: unknown phase '%s'", e.Phase)
+}
+
+func NewTouchPhaseError(phase string) error {
+	return &TouchPhaseError{
+		Phase: phase,
+	}
+}
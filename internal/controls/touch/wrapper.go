@@ -0,0 +1,91 @@
+//go:build windows
+
+package touch
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -ltouch
+#include "touch.h"
+*/
+import "C"
+
+import (
+	"sync"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+var touchPhase map[types.TouchPhase]C.TouchPhase = map[types.TouchPhase]C.TouchPhase{
+	types.TouchDown:   C.TOUCH_PHASE_DOWN,
+	types.TouchMove:   C.TOUCH_PHASE_MOVE,
+	types.TouchUp:     C.TOUCH_PHASE_UP,
+	types.TouchCancel: C.TOUCH_PHASE_CANCEL,
+}
+
+// c implementation, injected contacts are scaled from the stream to the primary screen
+type Touch_c struct {
+	mutex    sync.Mutex
+	width    int
+	height   int
+	contacts contacts
+}
+
+// NewTouch_c sets up touch injection for a stream of the given resolution
+func NewTouch_c(width int, height int) (*Touch_c, error) {
+	if code := C.initTouch(); code != C.ERROR_SUCCESS {
+		return nil, NewTouchInputError(int(code))
+	}
+	return &Touch_c{width: width, height: height}, nil
+}
+
+func (t *Touch_c) SendInputTouch(contacts []types.TouchContact) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	frames, err := t.contacts.update(contacts)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if err := t.send(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetResolution changes the resolution of the stream contacts are scaled from
+func (t *Touch_c) SetResolution(width int, height int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.width, t.height = width, height
+}
+
+func (t *Touch_c) Cancel() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.send(t.contacts.cancel())
+}
+
+func (t *Touch_c) Close() error {
+	return t.Cancel()
+}
+
+func (t *Touch_c) send(frame []slotContact) error {
+	if len(frame) == 0 {
+		return nil
+	}
+	points := make([]C.TouchPoint, len(frame))
+	for i, f := range frame {
+		points[i] = C.TouchPoint{
+			slot:     C.uint(f.slot),
+			phase:    touchPhase[f.contact.Phase],
+			x:        C.int(f.contact.X),
+			y:        C.int(f.contact.Y),
+			pressure: C.uint(scalePressure(f.contact.Pressure, C.TOUCH_MAX_PRESSURE)),
+		}
+	}
+	if code := C.sendTouchFrame(&points[0], C.int(len(points)), C.int(t.width), C.int(t.height)); code != C.ERROR_SUCCESS {
+		return NewTouchInputError(int(code))
+	}
+	return nil
+}
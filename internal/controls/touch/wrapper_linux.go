@@ -0,0 +1,115 @@
+//go:build linux
+
+package touch
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/c
+#cgo LDFLAGS: -L${SRCDIR}/c -ltouch_linux
+#include <errno.h>
+#include "touch_linux.h"
+*/
+import "C"
+
+import (
+	"sync"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// uinput implementation, shows up as a multitouch screen
+type Touch_uinput struct {
+	mutex    sync.Mutex
+	fd       C.int
+	open     bool
+	contacts contacts
+	// tracking ids have to be unique for every new contact
+	trackingId int
+}
+
+// NewTouch_uinput creates a virtual touchscreen covering a stream of the given resolution
+func NewTouch_uinput(width int, height int) (*Touch_uinput, error) {
+	t := &Touch_uinput{}
+	if code := C.createTouch(&t.fd, C.int(width), C.int(height)); code != 0 {
+		return nil, NewTouchInputError(int(code))
+	}
+	t.open = true
+	return t, nil
+}
+
+func (t *Touch_uinput) SendInputTouch(contacts []types.TouchContact) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.open {
+		return NewTouchInputError(int(C.ENODEV))
+	}
+	frames, err := t.contacts.update(contacts)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if err := t.send(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Touch_uinput) Cancel() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.open {
+		return nil
+	}
+	return t.send(t.contacts.cancel())
+}
+
+func (t *Touch_uinput) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.open {
+		return nil
+	}
+	t.open = false
+	if code := C.destroyTouch(t.fd); code != 0 {
+		return NewTouchInputError(int(code))
+	}
+	return nil
+}
+
+// write one frame, the first contact is also reported as single touch
+func (t *Touch_uinput) send(frame []slotContact) error {
+	if len(frame) == 0 {
+		return nil
+	}
+	var first *types.TouchContact
+	for i := range frame {
+		slot, c := C.int(frame[i].slot), &frame[i].contact
+		pressure := C.int(scalePressure(c.Pressure, C.TOUCH_MAX_PRESSURE))
+		var code C.int
+		switch c.Phase {
+		case types.TouchDown:
+			t.trackingId = (t.trackingId + 1) % 65536
+			code = C.sendTouchDown(t.fd, slot, C.int(t.trackingId), C.int(c.X), C.int(c.Y), pressure)
+		case types.TouchMove:
+			code = C.sendTouchMove(t.fd, slot, C.int(c.X), C.int(c.Y), pressure)
+		default:
+			code = C.sendTouchUp(t.fd, slot)
+		}
+		if code != 0 {
+			return NewTouchInputError(int(code))
+		}
+		if c.Phase != types.TouchUp && c.Phase != types.TouchCancel && first == nil {
+			first = c
+		}
+	}
+	if first == nil {
+		if code := C.sendTouchReport(t.fd, false, 0, 0, 0); code != 0 {
+			return NewTouchInputError(int(code))
+		}
+		return nil
+	}
+	if code := C.sendTouchReport(t.fd, true, C.int(first.X), C.int(first.Y), C.int(scalePressure(first.Pressure, C.TOUCH_MAX_PRESSURE))); code != 0 {
+		return NewTouchInputError(int(code))
+	}
+	return nil
+}
@@ -3,12 +3,31 @@
 #include <gst/webrtc/webrtc.h>
 #include <gst/rtp/rtp.h>
 #include <glib/gprintf.h>
+#include <stdlib.h>
 #include <windows.h>
 
 // === Initialize global variables for file ===
 static GstElement *pipeline = NULL;
 static PipelineState state = NONE;
 static PipelineOptions options;
+// virtual camera, Go decides which input is shown. The mutex keeps the selector pads
+// of peers from being released while the placeholder's streaming thread switches to them
+static GMutex cameraMutex;
+static GstElement *cameraSelector = NULL;
+static GstPad *cameraPlaceholderPad = NULL;
+// frames are reported to Go and Go is asked what to show at most this often, well within cameraTimeout of camera.go
+#define CAMERA_CALLBACK_INTERVAL_US 100000
+// the last time the placeholder asked Go, only used by its streaming thread
+static gint64 cameraLastShownCheck = 0;
+// a peer's webcam frames, reported from the streaming thread of its selector pad
+typedef struct
+{
+    gchar *peerId;
+    gint64 lastReported;
+} CameraFrameReport;
+// GStreamer text of the last failed call, see TakeLastErrorMessage
+static GMutex errorMutex;
+static gchar *lastErrorMessage = NULL;
 // === Initialize static functions ===
 static void lock();
 static void unlock();
@@ -17,6 +36,35 @@ static bool setPipelineState(PipelineState newState);
 static inline int getNumCores();
 static void PrintWebRTCStates(GstElement *webrtc);
 static void createDotFile();
+static const char *videoRawCaps(VideoEncoder encoder);
+static void setLastErrorMessage(const char *message);
+static ErrorCode createVideoEncodeLine(const PipelineOptions *opt, char **encodeLine);
+static ErrorCode setEncoderProperties(GstElement *encoder, const char *properties);
+static const char *videoEncodedCaps(VideoEncoder encoder);
+static ErrorCode createTemplateVideoSource(GstElement **videoSource);
+static bool factoryHasProperty(GstElementFactory *factory, const char *name);
+static HMONITOR findMonitor(const char *name);
+static HWND findWindow(const char *title, const char *process);
+static ErrorCode createCaptureLine(const CaptureTarget *target, char **captureLine);
+static CaptureTarget copyCaptureTarget(CaptureTarget target);
+static void freeCaptureTarget(CaptureTarget target);
+static ErrorCode parseVideoSource(const char *description, const char *name, GstElement **videoSource);
+static ErrorCode createVideoSource(GstElement **videoSource);
+static ErrorCode createWebcamLine(const char *device, char **webcamLine);
+static bool videoSourceIsBuilt(unsigned index);
+static ErrorCode createExtraVideoSource(unsigned index, GstElement **videoSource);
+static ErrorCode createCompositeVideoSource(GstElement **videoSource);
+static ErrorCode addVideoSource(GstElement *videoSource, const char *teeName);
+static ErrorCode replaceVideoSource(GstElement *newSource, const char *teeName);
+static ErrorCode rebuildVideoSource(bool extras);
+static VideoSource *copyVideoSources(const VideoSource *sources, unsigned count);
+static bool findVideoSource(const char *name, gchar **teeName, int *payload);
+static ErrorCode createVideoPayloaderLine(const char *name, int payload, char **payloaderLine);
+static void addVideoHeaderExtensions(GstElement *videopay);
+static void setVideoBitrate(GstElement *videoSource, unsigned bitrate);
+static void setCapsfilterCaps(GstElement *videoSource, const char *name, gchar *caps);
+static void updateExtraVideoSources(const PipelineOptions *opt);
+static ErrorCode addPeer(const char *peer_id, const char **sources, unsigned count);
 static ErrorCode createPipeline();
 static gboolean on_pipeline_message(GstBus *bus, GstMessage *message, G_GNUC_UNUSED gpointer none);
 static void on_connection_state_change(GstElement *webrtc, GParamSpec G_GNUC_UNUSED *pspec, G_GNUC_UNUSED gpointer none);
@@ -25,6 +73,25 @@ static void on_offer_created(GstPromise *promise, GstElement *webrtc);
 static void on_ice_candidate(GstElement G_GNUC_UNUSED *webrtc, guint mlineindex, gchar *candidate, G_GNUC_UNUSED gpointer none);
 // used for controls exclusively
 static void on_datachannel_message_string(GstWebRTCDataChannel G_GNUC_UNUSED *dc, gchar *msg, G_GNUC_UNUSED gpointer none);
+static void on_datachannel_open(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none);
+static void on_datachannel_close(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none);
+// used for file transfers exclusively
+static ErrorCode getPeerDatachannel(const char *peer_id, const char *name, GstWebRTCDataChannel **datachannel);
+static void on_files_message_string(GstWebRTCDataChannel *dc, gchar *msg, G_GNUC_UNUSED gpointer none);
+static void on_files_message_data(GstWebRTCDataChannel *dc, GBytes *data, G_GNUC_UNUSED gpointer none);
+static void on_files_open(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none);
+static void on_files_close(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none);
+static void on_files_buffered_amount_low(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none);
+// used for the microphone uplink exclusively
+static void on_microphone_pad_added(GstElement *webrtc, GstPad *pad, G_GNUC_UNUSED gpointer none);
+// used for the virtual camera exclusively
+static void on_camera_pad_added(GstElement *webrtc, GstPad *pad, G_GNUC_UNUSED gpointer none);
+static GstPadProbeReturn on_camera_frame(G_GNUC_UNUSED GstPad *pad, G_GNUC_UNUSED GstPadProbeInfo *info, gpointer report);
+static void freeCameraFrameReport(gpointer report);
+static GstPadProbeReturn on_camera_placeholder_frame(GstPad *pad, G_GNUC_UNUSED GstPadProbeInfo *info, G_GNUC_UNUSED gpointer none);
+// used for the client drawn cursor exclusively
+static void on_cursor_open(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none);
+static void on_cursor_close(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none);
 // === Initialize global mutex for all operations ===
 static GMutex mutex;
 /**
@@ -123,566 +190,2035 @@ static void createDotFile()
  *
  * @return ErrorCode
  */
-static ErrorCode createPipeline()
+/**
+ * @brief the raw video caps the converter produces for the encoder
+ * 
+ * @param encoder 
+ * @return const char* 
+ */
+static const char *videoRawCaps(VideoEncoder encoder)
 {
-    if (GST_IS_OBJECT(pipeline))
-        return ERROR_PIPELINE_ALREADY_CREATED;
-    ErrorCode returnVal = SUCCESS;
-    char *vcaptureLine;
-    char *vencoderLine;
-    char *vconverterLine;
-    char *acaptureLine;
-    char *aencoderLine;
-    // capture screen using dx9 (or d3d11 if possible)
-    GstElementFactory *d3d11VideoCapture = gst_element_factory_find("d3d11screencapturesrc");
-    vcaptureLine = g_strdup_printf(""
-                                   "%s do-timestamp=false "
-                                   "%s=%s blocksize=16384 ! ",
-                                   d3d11VideoCapture == NULL ? "dx9screencapsrc" : "d3d11screencapturesrc",
-                                   d3d11VideoCapture == NULL ? "cursor" : "show-cursor",
-                                   options.videoShowCursor ? "true" : "false");
-    // capture audio with wasapi (or wasapi2 if possible)
-    GstElementFactory *wasapi2AudioCapture = gst_element_factory_find("wasapi2src");
-    acaptureLine = g_strdup_printf(""
-                                   "%s slave-method=none "
-                                   "loopback=true low-latency=true provide-clock=false "
-                                   "do-timestamp=false ! "
-                                   "audio/x-raw,channels=2 ! ",
-                                   wasapi2AudioCapture == NULL ? "wasapisrc" : "wasapi2src");
+    return encoder == NVH264 ? "video/x-raw(memory:D3D11Memory)" : "video/x-raw";
+}
+/**
+ * @brief keep the text of a failed call for TakeLastErrorMessage, replacing an earlier one
+ * 
+ * @param message copied
+ */
+static void setLastErrorMessage(const char *message)
+{
+    g_mutex_lock(&errorMutex);
+    g_free(lastErrorMessage);
+    lastErrorMessage = g_strdup(message);
+    g_mutex_unlock(&errorMutex);
+}
+/**
+ * @brief create the converting and encoding part of the video source, from raw frames to encoded ones
+ * 
+ * @param opt the encoder, framerate, size and bitrate are used
+ * @param encodeLine set to a new string ending with the encoded caps, free it
+ * @return ErrorCode 
+ */
+static ErrorCode createVideoEncodeLine(const PipelineOptions *opt, char **encodeLine)
+{
+    char *vencoderLine = NULL;
+    char *vconverterLine = NULL;
     // encoder parameters
     // TODO: more hardware specific encoding pipelines, VMAF(iqa), more optimization on encoder parameters
-    switch (options.videoEncoder)
+    switch (opt->videoEncoder)
     {
     case VP9:
         vconverterLine = g_strdup_printf(""
-                                         "video/x-raw,framerate=%u/1 ! "
+                                         "capsfilter name=videoframerate caps=\"%s,framerate=%u/1\" ! "
                                          "videoconvert qos=true dither=none n-threads=%d ! "
                                          "video/x-raw,format=I420 ! "
                                          "videoscale qos=true n-threads=%d ! "
-                                         "video/x-raw,width=%d,height=%d ! ",
-                                         options.videoBaseFramerate,
+                                         "capsfilter name=videosize caps=\"%s,width=%u,height=%u\" ! ",
+                                         videoRawCaps(opt->videoEncoder),
+                                         opt->videoBaseFramerate,
                                          8 /*getNumCores()*/,
                                          8 /*getNumCores()*/,
-                                         options.videoWidth,
-                                         options.videoHeight);
+                                         videoRawCaps(opt->videoEncoder),
+                                         opt->videoWidth,
+                                         opt->videoHeight);
+        // the tuning is set from the profile, see setEncoderProperties
         vencoderLine = g_strdup_printf(""
-                                       "vp9enc qos=true name=videoencoder buffer-initial-size=500 "
-                                       "buffer-optimal-size=600 buffer-size=1500 "
-                                       "end-usage=cbr target-bitrate=%u lag-in-frames=0 deadline=1 "
+                                       "vp9enc qos=true name=videoencoder "
+                                       "end-usage=cbr target-bitrate=%u lag-in-frames=0 "
                                        "keyframe-max-dist=%d threads=%d "
-                                       "max-intra-bitrate=250 cpu-used=8 static-threshold=1 "
-                                       "error-resilient=default row-mt=true "
                                        "! "
-                                       "video/x-vp9 ! ",
-                                       options.videoBaseBitrate * 1000,
+                                       "video/x-vp9",
+                                       opt->videoBaseBitrate * 1000,
                                        2147483647,
                                        8 /*getNumCores()*/);
         break;
     case H264:
         vconverterLine = g_strdup_printf(""
-                                         "video/x-raw,framerate=%u/1 ! "
+                                         "capsfilter name=videoframerate caps=\"%s,framerate=%u/1\" ! "
                                          "videoconvert qos=true dither=none n-threads=%d ! "
                                          "video/x-raw,format=I420 ! "
                                          "videoscale qos=true n-threads=%d ! "
-                                         "video/x-raw,width=%d,height=%d ! ",
-                                         options.videoBaseFramerate,
+                                         "capsfilter name=videosize caps=\"%s,width=%u,height=%u\" ! ",
+                                         videoRawCaps(opt->videoEncoder),
+                                         opt->videoBaseFramerate,
                                          8 /*getNumCores()*/,
                                          8 /*getNumCores()*/,
-                                         options.videoWidth,
-                                         options.videoHeight);
+                                         videoRawCaps(opt->videoEncoder),
+                                         opt->videoWidth,
+                                         opt->videoHeight);
         // TODO: look into high-444 in case of moving away from browser
         // profile-level-id from  https://www.iana.org/assignments/media-types/video/H264-SVC
         vencoderLine = g_strdup_printf(""
-                                       "x264enc qos=true name=videoencoder vbv-buf-capacity=750 "
-                                       "bitrate=%u sliced-threads=true byte-stream=false "
-                                       "speed-preset=veryfast key-int-max=%d threads=%d "
-                                       "tune=zerolatency b-adapt=false ref=1 psy-tune=ssim bframes=0 "
+                                       "x264enc qos=true name=videoencoder "
+                                       "bitrate=%u byte-stream=false "
+                                       "key-int-max=%d threads=%d bframes=0 "
                                        "! "
-                                       "video/x-h264,profile=high,stream-format=avc ! ",
-                                       options.videoBaseBitrate,
+                                       "video/x-h264,profile=high,stream-format=avc",
+                                       opt->videoBaseBitrate,
                                        0,
                                        8 /*getNumCores()*/);
         break;
     case NVH264:
         vconverterLine = g_strdup_printf(""
-                                         "video/x-raw(memory:D3D11Memory),framerate=%u/1 ! "
+                                         "capsfilter name=videoframerate caps=\"%s,framerate=%u/1\" ! "
                                          "d3d11convert qos=true ! "
                                          "video/x-raw(memory:D3D11Memory),format=I420 ! "
                                          "d3d11scale qos=true ! "
-                                         "video/x-raw(memory:D3D11Memory),width=%d,height=%d ! "
+                                         "capsfilter name=videosize caps=\"%s,width=%u,height=%u\" ! "
                                          "d3d11download qos=true ! ",
-                                         options.videoBaseFramerate,
-                                         options.videoWidth,
-                                         options.videoHeight);
+                                         videoRawCaps(opt->videoEncoder),
+                                         opt->videoBaseFramerate,
+                                         videoRawCaps(opt->videoEncoder),
+                                         opt->videoWidth,
+                                         opt->videoHeight);
         vencoderLine = g_strdup_printf(""
                                        "nvh264enc qos=true name=videoencoder bitrate=%u "
-                                       "vbv-buffer-size=1300 bframes=0 b-adapt=false rc-lookahead=0 "
-                                       "zerolatency=true preset=low-latency-hq rc-mode=cbr "
+                                       "bframes=0 "
                                        "gop-size=%d "
                                        "! "
-                                       "video/x-h264,profile=high ! ",
-                                       options.videoBaseBitrate,
+                                       "video/x-h264,profile=high",
+                                       opt->videoBaseBitrate,
                                        -1);
         break;
     default:
-        returnVal = ERROR_ENCODER_NOT_SUPPORTED;
-        goto done;
+        return ERROR_ENCODER_NOT_SUPPORTED;
     }
-    aencoderLine = g_strdup_printf(""
-                                   "audioconvert dithering=none ! "
-                                   "opusenc name=audioencoder bitrate=%u hard-resync=true "
-                                   "bandwidth=fullband audio-type=restricted-lowdelay "
-                                   "inband-fec=true packet-loss-percentage=%u ! ",
-                                   options.audioBaseBitrate,
-                                   options.audioBasePacketLossPct);
-    GError *error = NULL;
-    char *basePipelineString;
-    basePipelineString = g_strdup_printf(""
-                                         // capture video and create rtp packets
-                                         // capture video
-                                         "%s"
-                                         // debug (time overlay)
-                                         // "timeoverlay !"
-                                         // set framerate, color format and scale
-                                         "%s"
-                                         // encode
-                                         "%s"
-                                         // tee for sending rtp packets to potentially many rtc clients
-                                         "tee name=videoenctee "
-                                         // a copy to fakesink for prerolling early (might not be needed)
-                                         "videoenctee. ! "
-                                         "queue flush-on-eos=true leaky=downstream silent=true ! "
-                                         "fakesink "
-                                         // capture audio and create rtp packets
-                                         // capture audio
-                                         "%s"
-                                         // encode
-                                         "%s"
-                                         // tee for sending rtp packets to potentially many rtc clients
-                                         "tee name=audioenctee "
-                                         // a copy to fakesink for prerolling early (might not be needed)
-                                         "audioenctee. ! "
-                                         "queue flush-on-eos=true leaky=downstream silent=true ! "
-                                         "fakesink ",
-                                         vcaptureLine,
-                                         vconverterLine,
-                                         vencoderLine,
-                                         acaptureLine,
-                                         aencoderLine);
-    g_free(vencoderLine);
+    *encodeLine = g_strdup_printf("%s%s", vconverterLine, vencoderLine);
     g_free(vconverterLine);
-    g_free(aencoderLine);
-    pipeline = gst_parse_launch(basePipelineString, &error);
-    // take ownership of floating ref
-    if (G_IS_INITIALLY_UNOWNED (pipeline))
-        g_object_ref_sink (pipeline);
-    g_free(basePipelineString);
+    g_free(vencoderLine);
+    return SUCCESS;
+}
+/**
+ * @brief set properties on the encoder, checking each against the element's property spec
+ * 
+ * @param encoder 
+ * @param properties name=value lines, values in gst-launch syntax
+ * @return ErrorCode ERROR_BAD_ENCODER_PROPERTY for an unknown property or a value out of its range,
+ * with the reason in TakeLastErrorMessage
+ */
+static ErrorCode setEncoderProperties(GstElement *encoder, const char *properties)
+{
+    ErrorCode returnVal = SUCCESS;
+    gchar **lines = g_strsplit(properties, "\n", -1);
+    for (gchar **line = lines; *line != NULL && returnVal == SUCCESS; line++)
+    {
+        if (**line == '\0')
+            continue;
+        gchar **property = g_strsplit(*line, "=", 2);
+        GParamSpec *pspec = property[1] == NULL ? NULL : g_object_class_find_property(G_OBJECT_GET_CLASS(encoder), property[0]);
+        if (pspec == NULL || !(pspec->flags & G_PARAM_WRITABLE) || (pspec->flags & G_PARAM_CONSTRUCT_ONLY))
+        {
+            gchar *message = g_strdup_printf("%s has no settable property '%s'", GST_OBJECT_NAME(gst_element_get_factory(encoder)), *line);
+            setLastErrorMessage(message);
+            g_free(message);
+            returnVal = ERROR_BAD_ENCODER_PROPERTY;
+            g_strfreev(property);
+            continue;
+        }
+        GValue value = G_VALUE_INIT;
+        g_value_init(&value, G_PARAM_SPEC_VALUE_TYPE(pspec));
+        // validating changes values out of range to fit, which is reported instead
+        if (!gst_value_deserialize(&value, property[1]) || g_param_value_validate(pspec, &value))
+        {
+            gchar *message = g_strdup_printf("'%s' is not a valid %s of %s: %s",
+                                             property[1],
+                                             property[0],
+                                             GST_OBJECT_NAME(gst_element_get_factory(encoder)),
+                                             g_param_spec_get_blurb(pspec));
+            setLastErrorMessage(message);
+            g_free(message);
+            returnVal = ERROR_BAD_ENCODER_PROPERTY;
+        }
+        else
+            g_object_set_property(G_OBJECT(encoder), property[0], &value);
+        g_value_unset(&value);
+        g_strfreev(property);
+    }
+    g_strfreev(lines);
+    return returnVal;
+}
+/**
+ * @brief the encoded caps the peers' payloaders take for the encoder
+ * 
+ * @param encoder 
+ * @return const char* 
+ */
+static const char *videoEncodedCaps(VideoEncoder encoder)
+{
+    return encoder == VP9 ? "video/x-vp9" : "video/x-h264";
+}
+/**
+ * @brief create the video source from the template of the options instead of the built-in elements.
+ * It must start with a source and end with the encoded caps of the encoder
+ * 
+ * @param videoSource set to a new floating bin named videosource with a ghost src pad
+ * @return ErrorCode ERROR_TEMPLATE_BAD_CAPS when it doesn't fit the pipeline, with the reason in TakeLastErrorMessage
+ */
+static ErrorCode createTemplateVideoSource(GstElement **videoSource)
+{
+    ErrorCode returnVal = SUCCESS;
+    GError *error = NULL;
+    GstPad *sinkPad = NULL;
+    GstPad *srcPad = NULL;
+    GstCaps *caps = NULL;
+    GstCaps *expectedCaps = NULL;
+    gchar *message = NULL;
+    *videoSource = gst_parse_bin_from_description(options.videoTemplate, TRUE, &error);
     if (error)
     {
-        // gst_println(error->message);
+        setLastErrorMessage(error->message);
         g_error_free(error);
         returnVal = ERROR_PIPELINE_PARSE_BAD_FORMAT;
         goto done;
     }
+    gst_element_set_name(*videoSource, "videosource");
+    // unlinked pads of the template are ghosted
+    sinkPad = gst_element_get_static_pad(*videoSource, "sink");
+    srcPad = gst_element_get_static_pad(*videoSource, "src");
+    if (sinkPad != NULL || srcPad == NULL)
+    {
+        message = g_strdup("video template should start with a source and end with one unlinked src pad");
+        returnVal = ERROR_TEMPLATE_BAD_CAPS;
+        goto done;
+    }
+    caps = gst_pad_query_caps(srcPad, NULL);
+    expectedCaps = gst_caps_from_string(videoEncodedCaps(options.videoEncoder));
+    if (!gst_caps_can_intersect(caps, expectedCaps))
+    {
+        gchar *capsString = gst_caps_to_string(caps);
+        message = g_strdup_printf("video template produces %s, the encoder needs %s", capsString, videoEncodedCaps(options.videoEncoder));
+        g_free(capsString);
+        returnVal = ERROR_TEMPLATE_BAD_CAPS;
+    }
 done:
-    g_free(vcaptureLine);
-    g_free(acaptureLine);
+    if (message != NULL)
+        setLastErrorMessage(message);
+    g_free(message);
+    if (caps != NULL)
+        gst_caps_unref(caps);
+    if (expectedCaps != NULL)
+        gst_caps_unref(expectedCaps);
+    if (sinkPad != NULL)
+        gst_object_unref(sinkPad);
+    if (srcPad != NULL)
+        gst_object_unref(srcPad);
+    // a recoverable parse error may still have created a bin
+    if (returnVal != SUCCESS && *videoSource != NULL)
+    {
+        gst_object_unref(gst_object_ref_sink(*videoSource));
+        *videoSource = NULL;
+    }
     return returnVal;
 }
 /**
- * @brief set up pipeline and put it in the READY state. MUST be run only once and before any other functions
- *
- * @param opt
- * @return ErrorCode
+ * @brief whether elements of the factory have a property, which depends on the GStreamer version
+ * 
+ * @param factory 
+ * @param name 
+ * @return bool 
  */
-ErrorCode SetupPipeline(PipelineOptions opt)
+static bool factoryHasProperty(GstElementFactory *factory, const char *name)
 {
-    if (getPipelineState() != NONE)
+    GstPluginFeature *loaded = gst_plugin_feature_load(GST_PLUGIN_FEATURE(factory));
+    if (loaded == NULL)
+        return false;
+    GObjectClass *elementClass = g_type_class_ref(gst_element_factory_get_element_type(GST_ELEMENT_FACTORY(loaded)));
+    bool found = g_object_class_find_property(elementClass, name) != NULL;
+    g_type_class_unref(elementClass);
+    gst_object_unref(loaded);
+    return found;
+}
+typedef struct
+{
+    const char *name;
+    HMONITOR monitor;
+} MonitorSearch;
+static BOOL CALLBACK on_monitor(HMONITOR monitor, G_GNUC_UNUSED HDC hdc, G_GNUC_UNUSED LPRECT rect, LPARAM data)
+{
+    MonitorSearch *search = (MonitorSearch *)data;
+    MONITORINFOEXA info;
+    info.cbSize = sizeof(info);
+    if (GetMonitorInfoA(monitor, (MONITORINFO *)&info) && g_ascii_strcasecmp(info.szDevice, search->name) == 0)
     {
-        return ERROR_PIPELINE_BAD_STATE;
+        search->monitor = monitor;
+        return FALSE;
     }
-    // init gstreamer
-    gst_init(NULL, NULL);
-    ErrorCode returnVal = SUCCESS;
-    // lock mutex
-    lock();
-
-    // set global options
-    options = opt;
-
-    // create pipeline
-    returnVal = createPipeline();
-    if (returnVal != SUCCESS)
-        goto done;
-
-    // set pipeline state to READY
-    if (!setPipelineState(READY))
+    return TRUE;
+}
+/**
+ * @brief find a monitor by its device name
+ * 
+ * @param name like \\.\DISPLAY2
+ * @return HMONITOR NULL if there is none
+ */
+static HMONITOR findMonitor(const char *name)
+{
+    MonitorSearch search = {name, NULL};
+    EnumDisplayMonitors(NULL, NULL, on_monitor, (LPARAM)&search);
+    return search.monitor;
+}
+typedef struct
+{
+    // one of them is set
+    const char *title;
+    const char *process;
+    HWND window;
+} WindowSearch;
+static BOOL CALLBACK on_window(HWND window, LPARAM data)
+{
+    WindowSearch *search = (WindowSearch *)data;
+    char title[256];
+    // application windows only, not tool windows or hidden ones
+    if (!IsWindowVisible(window) || GetWindow(window, GW_OWNER) != NULL || GetWindowTextA(window, title, sizeof(title)) == 0)
+        return TRUE;
+    if (search->title != NULL)
     {
-        returnVal = ERROR_PIPELINE_SET_STATE;
-        goto done;
+        if (strstr(title, search->title) == NULL)
+            return TRUE;
+        search->window = window;
+        return FALSE;
     }
-
-    // get bus
-    GstBus *bus;
-    bus = gst_pipeline_get_bus(GST_PIPELINE(pipeline));
-    gst_bus_add_watch(bus, (GstBusFunc)on_pipeline_message, NULL);
-    gst_object_unref(bus);
-
-done:
-    unlock();
-    return returnVal;
+    DWORD pid;
+    GetWindowThreadProcessId(window, &pid);
+    HANDLE process = OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, FALSE, pid);
+    if (process == NULL)
+        return TRUE;
+    char path[MAX_PATH];
+    DWORD size = MAX_PATH;
+    if (QueryFullProcessImageNameA(process, 0, path, &size))
+    {
+        const char *executable = strrchr(path, '\\');
+        if (g_ascii_strcasecmp(executable == NULL ? path : executable + 1, search->process) == 0)
+            search->window = window;
+    }
+    CloseHandle(process);
+    return search->window == NULL;
 }
 /**
- * @brief start the pipeline and put it in the PLAYING state
+ * @brief find the first application window whose title contains title, or of the process when title is NULL
  * 
- * @return ErrorCode 
+ * @param title 
+ * @param process executable name, like notepad.exe
+ * @return HWND NULL if there is none
  */
-ErrorCode StartPipeline()
+static HWND findWindow(const char *title, const char *process)
+{
+    WindowSearch search = {title, process, NULL};
+    EnumWindows(on_window, (LPARAM)&search);
+    return search.window;
+}
+/**
+ * @brief create the capture element of the video source for the capture target of the options
+ * 
+ * @param target the screen's or a source's
+ * @param captureLine set to a new string ending with " ! ", free it
+ * @return ErrorCode ERROR_CAPTURE_TARGET_NOT_FOUND or ERROR_CAPTURE_TARGET_NOT_SUPPORTED,
+ * with the reason in TakeLastErrorMessage
+ */
+static ErrorCode createCaptureLine(const CaptureTarget *target, char **captureLine)
 {
     ErrorCode returnVal = SUCCESS;
-    lock();
-    // check if state is valid
-    switch (getPipelineState())
+    bool monitorIsIndex = g_ascii_string_to_unsigned(target->monitor, 10, 0, G_MAXINT, NULL, NULL);
+    bool captureWindow = target->window[0] != '\0' || target->process[0] != '\0';
+    bool captureRegion = target->width > 0 && target->height > 0;
+    gchar *message = NULL;
+    GString *line = g_string_new(NULL);
+    // capture screen using dx9 (or d3d11 if possible)
+    GstElementFactory *d3d11VideoCapture = gst_element_factory_find("d3d11screencapturesrc");
+    if (d3d11VideoCapture == NULL)
     {
-    case NONE:
-        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
-        goto done;
-    case STOPPED:
-    case PLAYING:
-        returnVal = ERROR_PIPELINE_BAD_STATE;
+        g_string_append_printf(line, "dx9screencapsrc do-timestamp=false cursor=%s blocksize=16384",
+                               options.videoShowCursor ? "true" : "false");
+        if (captureWindow || (target->monitor[0] != '\0' && !monitorIsIndex))
+        {
+            message = g_strdup("dx9screencapsrc can only capture a monitor by index, d3d11screencapturesrc is needed");
+            returnVal = ERROR_CAPTURE_TARGET_NOT_SUPPORTED;
+            goto done;
+        }
+        if (target->monitor[0] != '\0')
+            g_string_append_printf(line, " monitor=%s", target->monitor);
+        if (captureRegion)
+            g_string_append_printf(line, " x=%u y=%u width=%u height=%u", target->x, target->y, target->width, target->height);
+        g_string_append(line, " ! ");
         goto done;
-    case READY:
-        break;
     }
-
-    // set pipeline state to PLAYING
-    if (!setPipelineState(PLAYING))
+    g_string_append_printf(line, "d3d11screencapturesrc do-timestamp=false show-cursor=%s blocksize=16384",
+                           options.videoShowCursor ? "true" : "false");
+    if (captureWindow)
     {
-        returnVal = ERROR_PIPELINE_SET_STATE;
-        goto done;
+        if (!factoryHasProperty(d3d11VideoCapture, "window-handle"))
+        {
+            message = g_strdup("capturing a window needs d3d11screencapturesrc of GStreamer 1.24 or newer");
+            returnVal = ERROR_CAPTURE_TARGET_NOT_SUPPORTED;
+            goto done;
+        }
+        HWND window = findWindow(target->window[0] != '\0' ? target->window : NULL, target->process);
+        if (window == NULL)
+        {
+            message = target->window[0] != '\0'
+                          ? g_strdup_printf("no window titled like '%s'", target->window)
+                          : g_strdup_printf("no window of process '%s'", target->process);
+            returnVal = ERROR_CAPTURE_TARGET_NOT_FOUND;
+            goto done;
+        }
+        // windows can only be captured with windows graphics capture
+        g_string_append_printf(line, " capture-api=wgc window-handle=%" G_GUINT64_FORMAT, (guint64)(guintptr)window);
+    }
+    else if (monitorIsIndex)
+        g_string_append_printf(line, " monitor-index=%s", target->monitor);
+    else if (target->monitor[0] != '\0')
+    {
+        HMONITOR monitor = findMonitor(target->monitor);
+        if (monitor == NULL)
+        {
+            message = g_strdup_printf("no monitor named '%s'", target->monitor);
+            returnVal = ERROR_CAPTURE_TARGET_NOT_FOUND;
+            goto done;
+        }
+        g_string_append_printf(line, " monitor-handle=%" G_GUINT64_FORMAT, (guint64)(guintptr)monitor);
+    }
+    if (captureRegion)
+    {
+        if (!factoryHasProperty(d3d11VideoCapture, "crop-width"))
+        {
+            message = g_strdup("capturing a region needs d3d11screencapturesrc of GStreamer 1.22 or newer");
+            returnVal = ERROR_CAPTURE_TARGET_NOT_SUPPORTED;
+            goto done;
+        }
+        g_string_append_printf(line, " crop-x=%u crop-y=%u crop-width=%u crop-height=%u", target->x, target->y, target->width, target->height);
     }
+    g_string_append(line, " ! ");
 done:
-    unlock();
+    if (d3d11VideoCapture != NULL)
+        gst_object_unref(d3d11VideoCapture);
+    if (message != NULL)
+        setLastErrorMessage(message);
+    g_free(message);
+    *captureLine = g_string_free(line, returnVal != SUCCESS);
     return returnVal;
 }
 /**
- * @brief irreversibly stop the pipeline and put it in the STOPPED state, as well as freeing the pipeline object
- * remember to remove all peers from the pipeline before calling this function
+ * @brief create the bin capturing, converting and encoding the screen from the current options, or their template.
+ * It is kept apart from the pipeline so it can be rebuilt while peers stay connected
  * 
+ * @param videoSource set to a new floating bin named videosource with a ghost src pad
  * @return ErrorCode 
  */
-ErrorCode StopPipeline()
+static ErrorCode createVideoSource(GstElement **videoSource)
 {
+    if (options.videoTemplate[0] != '\0')
+        return createTemplateVideoSource(videoSource);
     ErrorCode returnVal = SUCCESS;
-    lock();
-    // check if state is valid
-    switch (getPipelineState())
-    {
-    case NONE:
-        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
-        goto done;
-    case STOPPED:
-        returnVal =  ERROR_PIPELINE_BAD_STATE;
+    char *vcaptureLine = NULL;
+    char *vencodeLine = NULL;
+    returnVal = createCaptureLine(&options.capture, &vcaptureLine);
+    if (returnVal != SUCCESS)
         goto done;
-    case PLAYING:
-    case READY:
-        break;
-    }
-    // TODO: send eos to pipeline
-    if (!setPipelineState(STOPPED))
-    {
-        returnVal = ERROR_PIPELINE_SET_STATE;
+    returnVal = createVideoEncodeLine(&options, &vencodeLine);
+    if (returnVal != SUCCESS)
         goto done;
-    }
-    /* Free resources */
-    gst_object_unref(pipeline);
-    return SUCCESS;
+    char *videoSourceString;
+    videoSourceString = g_strdup_printf("%s%s", vcaptureLine, vencodeLine);
+    returnVal = parseVideoSource(videoSourceString, "videosource", videoSource);
+    g_free(videoSourceString);
 done:
-    unlock();
+    g_free(vcaptureLine);
+    g_free(vencodeLine);
     return returnVal;
 }
-
-// === Core functions ===
 /**
- * @brief add a webrtc peer to the pipeline
+ * @brief parse a built-in video source and tune its encoder from the options
  * 
- * @param peer_id 
+ * @param description capture, convert and encode elements ending with the encoded caps
+ * @param name of the bin
+ * @param videoSource set to a new floating bin with a ghost src pad
  * @return ErrorCode 
  */
-ErrorCode AddPeerToPipeline(const char *peer_id)
+static ErrorCode parseVideoSource(const char *description, const char *name, GstElement **videoSource)
 {
     ErrorCode returnVal = SUCCESS;
-    lock();
-    // peer id names are used a lot
-    // so define them now and free in goto
-    char *peer_id_vname = g_strdup_printf("v%s", peer_id);
-    char *peer_id_aname = g_strdup_printf("a%s", peer_id);
-
-    // check if state is valid
-    switch (getPipelineState())
+    GError *error = NULL;
+    *videoSource = gst_parse_bin_from_description(description, TRUE, &error);
+    if (error)
     {
-    case NONE:
-        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
-        goto done;
-    case STOPPED:
-    case READY:
-        returnVal = ERROR_PIPELINE_BAD_STATE;
-        goto done;
-    case PLAYING:
-        break;
+        setLastErrorMessage(error->message);
+        g_error_free(error);
+        // a recoverable error may still have created a bin
+        if (*videoSource != NULL)
+            gst_object_unref(gst_object_ref_sink(*videoSource));
+        *videoSource = NULL;
+        return ERROR_PIPELINE_PARSE_BAD_FORMAT;
     }
+    gst_element_set_name(*videoSource, name);
+    GstElement *videoEncoder = gst_bin_get_by_name(GST_BIN(*videoSource), "videoencoder");
+    g_assert_nonnull(videoEncoder);
+    returnVal = setEncoderProperties(videoEncoder, options.videoEncoderProperties);
+    gst_object_unref(videoEncoder);
+    if (returnVal != SUCCESS)
+    {
+        gst_object_unref(gst_object_ref_sink(*videoSource));
+        *videoSource = NULL;
+    }
+    return returnVal;
+}
+/**
+ * @brief create the start of a video source capturing a webcam, raw frames in system memory at any rate
+ * 
+ * @param device index or name, empty for the default webcam
+ * @param webcamLine set to a new string ending with " ! ", free it
+ * @return ErrorCode ERROR_CAPTURE_TARGET_NOT_SUPPORTED without Media Foundation, with the reason in TakeLastErrorMessage
+ */
+static ErrorCode createWebcamLine(const char *device, char **webcamLine)
+{
+    GstElementFactory *mfVideoCapture = gst_element_factory_find("mfvideosrc");
+    if (mfVideoCapture == NULL)
+    {
+        setLastErrorMessage("capturing a webcam needs mfvideosrc");
+        return ERROR_CAPTURE_TARGET_NOT_SUPPORTED;
+    }
+    gst_object_unref(mfVideoCapture);
+    GString *line = g_string_new("mfvideosrc do-timestamp=false");
+    if (g_ascii_string_to_unsigned(device, 10, 0, G_MAXINT, NULL, NULL))
+        g_string_append_printf(line, " device-index=%s", device);
+    else if (device[0] != '\0')
+        g_string_append_printf(line, " device-name=\"%s\"", device);
+    // webcams pick their own format and rate
+    g_string_append(line, " ! videoconvert ! videorate ! ");
+    *webcamLine = g_string_free(line, FALSE);
+    return SUCCESS;
+}
+/**
+ * @brief whether an extra video source has its own bin, a webcam drawn in the composite doesn't
+ * since it can only be opened once
+ * 
+ * @param index into options.videoSources
+ * @return bool 
+ */
+static bool videoSourceIsBuilt(unsigned index)
+{
+    if (!options.videoSources[index].webcam)
+        return true;
+    for (unsigned i = 0; i < options.compositeInputCount; i++)
+    {
+        if (options.compositeInputs[i].source == (int)index)
+            return false;
+    }
+    return true;
+}
+/**
+ * @brief create the bin capturing, converting and encoding an extra video source like the screen
+ * 
+ * @param index into options.videoSources
+ * @param videoSource set to a new floating bin named videosource_NAME with a ghost src pad
+ * @return ErrorCode 
+ */
+static ErrorCode createExtraVideoSource(unsigned index, GstElement **videoSource)
+{
+    ErrorCode returnVal = SUCCESS;
+    const VideoSource *source = &options.videoSources[index];
+    PipelineOptions sourceOptions = options;
+    sourceOptions.videoWidth = source->width;
+    sourceOptions.videoHeight = source->height;
+    char *vcaptureLine = NULL;
+    char *vencodeLine = NULL;
+    if (source->webcam)
+        returnVal = createWebcamLine(source->webcamDevice, &vcaptureLine);
+    else
+        returnVal = createCaptureLine(&source->capture, &vcaptureLine);
+    if (returnVal != SUCCESS)
+        goto done;
+    returnVal = createVideoEncodeLine(&sourceOptions, &vencodeLine);
+    if (returnVal != SUCCESS)
+        goto done;
+    char *name = g_strdup_printf("videosource_%s", source->name);
+    char *videoSourceString;
+    // webcam frames are in system memory
+    videoSourceString = g_strdup_printf("%s%s%s",
+                                        vcaptureLine,
+                                        source->webcam && options.videoEncoder == NVH264 ? "d3d11upload ! " : "",
+                                        vencodeLine);
+    returnVal = parseVideoSource(videoSourceString, name, videoSource);
+    g_free(videoSourceString);
+    g_free(name);
+done:
+    g_free(vcaptureLine);
+    g_free(vencodeLine);
+    return returnVal;
+}
+/**
+ * @brief create the bin drawing the composite's sources into one video and encoding it like the screen.
+ * The sources are captured again, screen capture can be shared but webcams only have this capture
+ * 
+ * @param videoSource set to a new floating bin named videosource_composite with a ghost src pad
+ * @return ErrorCode 
+ */
+static ErrorCode createCompositeVideoSource(GstElement **videoSource)
+{
+    ErrorCode returnVal = SUCCESS;
+    PipelineOptions compositeOptions = options;
+    compositeOptions.videoWidth = options.compositeWidth;
+    compositeOptions.videoHeight = options.compositeHeight;
+    char *vencodeLine = NULL;
+    char *inputLine = NULL;
+    GString *mixerLine = g_string_new("compositor name=mix background=black");
+    GString *inputLines = g_string_new(NULL);
+    for (unsigned i = 0; i < options.compositeInputCount; i++)
+    {
+        const CompositeInput *input = &options.compositeInputs[i];
+        // later inputs are drawn over earlier ones
+        g_string_append_printf(mixerLine, " sink_%u::xpos=%u sink_%u::ypos=%u sink_%u::width=%u sink_%u::height=%u sink_%u::zorder=%u",
+                               i, input->x, i, input->y, i, input->width, i, input->height, i, i);
+        if (input->source < 0)
+            returnVal = createCaptureLine(&options.capture, &inputLine);
+        else if (options.videoSources[input->source].webcam)
+            returnVal = createWebcamLine(options.videoSources[input->source].webcamDevice, &inputLine);
+        else
+            returnVal = createCaptureLine(&options.videoSources[input->source].capture, &inputLine);
+        if (returnVal != SUCCESS)
+            goto done;
+        // the mixer draws in system memory
+        g_string_append_printf(inputLines, "%svideoconvert ! queue leaky=downstream silent=true ! mix.sink_%u ", inputLine, i);
+        g_free(inputLine);
+        inputLine = NULL;
+    }
+    returnVal = createVideoEncodeLine(&compositeOptions, &vencodeLine);
+    if (returnVal != SUCCESS)
+        goto done;
+    char *videoSourceString;
+    videoSourceString = g_strdup_printf("%s ! "
+                                        "video/x-raw,width=%u,height=%u,framerate=%u/1 ! "
+                                        "videoconvert ! videorate ! %s%s %s",
+                                        mixerLine->str,
+                                        options.compositeWidth,
+                                        options.compositeHeight,
+                                        options.videoBaseFramerate,
+                                        options.videoEncoder == NVH264 ? "d3d11upload ! " : "",
+                                        vencodeLine,
+                                        inputLines->str);
+    returnVal = parseVideoSource(videoSourceString, "videosource_composite", videoSource);
+    g_free(videoSourceString);
+done:
+    g_string_free(mixerLine, TRUE);
+    g_string_free(inputLines, TRUE);
+    g_free(inputLine);
+    g_free(vencodeLine);
+    return returnVal;
+}
+/**
+ * @brief add a video source to the pipeline and link it to its tee
+ * 
+ * @param videoSource floating, owned by the pipeline afterwards
+ * @param teeName 
+ * @return ErrorCode 
+ */
+static ErrorCode addVideoSource(GstElement *videoSource, const char *teeName)
+{
+    ErrorCode returnVal = SUCCESS;
+    // ownership is transferred to parent
+    g_warn_if_fail(gst_bin_add(GST_BIN(pipeline), videoSource));
+    GstElement *videoTee = gst_bin_get_by_name(GST_BIN(pipeline), teeName);
+    g_assert_nonnull(videoTee);
+    if (!gst_element_link(videoSource, videoTee))
+        returnVal = ERROR_PIPELINE_PARSE_BAD_FORMAT;
+    gst_object_unref(videoTee);
+    return returnVal;
+}
+static ErrorCode createPipeline()
+{
+    if (GST_IS_OBJECT(pipeline))
+        return ERROR_PIPELINE_ALREADY_CREATED;
+    ErrorCode returnVal = SUCCESS;
+    GstElement *videoSource = NULL;
+    char *acaptureLine;
+    char *aencoderLine;
+    char *micLine;
+    char *cameraLine;
+    // capture audio with wasapi (or wasapi2 if possible)
+    GstElementFactory *wasapi2AudioCapture = gst_element_factory_find("wasapi2src");
+    acaptureLine = g_strdup_printf(""
+                                   "%s slave-method=none "
+                                   "loopback=true low-latency=true provide-clock=false "
+                                   "do-timestamp=false ! "
+                                   "audio/x-raw,channels=2 ! ",
+                                   wasapi2AudioCapture == NULL ? "wasapisrc" : "wasapi2src");
+    aencoderLine = g_strdup_printf(""
+                                   "audioconvert dithering=none ! "
+                                   "opusenc name=audioencoder bitrate=%u hard-resync=true "
+                                   "bandwidth=fullband audio-type=restricted-lowdelay "
+                                   "inband-fec=true packet-loss-percentage=%u ! ",
+                                   options.audioBaseBitrate,
+                                   options.audioBasePacketLossPct);
+    // peers' microphones are mixed into one output device
+    if (options.microphoneEnabled)
+    {
+        GstElementFactory *wasapi2AudioSink = gst_element_factory_find("wasapi2sink");
+        micLine = g_strdup_printf(""
+                                  // silence keeps the mixer and the device running while no peer is speaking
+                                  "audiotestsrc wave=silence is-live=true ! "
+                                  "audio/x-raw,rate=48000,channels=2 ! "
+                                  "audiomixer name=micmixer ! "
+                                  "audioconvert ! audioresample ! "
+                                  "%s low-latency=true async=false %s%s%s",
+                                  wasapi2AudioSink == NULL ? "wasapisink" : "wasapi2sink",
+                                  options.microphoneDevice[0] == '\0' ? "" : "device=\"",
+                                  options.microphoneDevice,
+                                  options.microphoneDevice[0] == '\0' ? "" : "\"");
+        if (wasapi2AudioSink != NULL)
+            gst_object_unref(wasapi2AudioSink);
+    }
+    else
+        micLine = g_strdup("");
+    // a live placeholder keeps the camera running and decides which input it shows
+    if (options.cameraEnabled)
+    {
+        // GStreamer has no virtual camera on windows, the sink comes with the camera driver
+        GError *sinkError = NULL;
+        GstElement *cameraSink = gst_parse_bin_from_description(options.cameraSink, TRUE, &sinkError);
+        if (cameraSink != NULL)
+            gst_object_unref(gst_object_ref_sink(cameraSink));
+        if (cameraSink == NULL || sinkError != NULL)
+        {
+            gchar *message = g_strdup_printf("the virtual camera sink '%s' can't be created: %s",
+                                             options.cameraSink,
+                                             sinkError == NULL ? "unknown error" : sinkError->message);
+            setLastErrorMessage(message);
+            g_free(message);
+            g_clear_error(&sinkError);
+            g_free(micLine);
+            g_free(aencoderLine);
+            returnVal = ERROR_CAMERA_NOT_SUPPORTED;
+            goto done;
+        }
+        cameraLine = g_strdup_printf(""
+                                     "videotestsrc name=cameraplaceholder is-live=true pattern=solid-color foreground-color=0xff202020 ! "
+                                     "video/x-raw,format=I420,width=%u,height=%u,framerate=%u/1 ! "
+                                     "cameraselector. "
+                                     "input-selector name=cameraselector sync-streams=false cache-buffers=false ! "
+                                     "videoconvert ! "
+                                     "%s ",
+                                     options.cameraWidth,
+                                     options.cameraHeight,
+                                     options.cameraFramerate,
+                                     options.cameraSink);
+    }
+    else
+        cameraLine = g_strdup("");
+    // a tee for each extra video source and the composite, their sources are linked below
+    GString *extraVideoTees = g_string_new(NULL);
+    for (unsigned i = 0; i < options.videoSourceCount; i++)
+    {
+        if (videoSourceIsBuilt(i))
+            g_string_append_printf(extraVideoTees,
+                                   "tee name=videoenctee_%s videoenctee_%s. ! "
+                                   "queue flush-on-eos=true leaky=downstream silent=true ! fakesink ",
+                                   options.videoSources[i].name, options.videoSources[i].name);
+    }
+    if (options.compositeInputCount > 0)
+        g_string_append(extraVideoTees,
+                        "tee name=videoenctee_composite videoenctee_composite. ! "
+                        "queue flush-on-eos=true leaky=downstream silent=true ! fakesink ");
+    GError *error = NULL;
+    char *basePipelineString;
+    basePipelineString = g_strdup_printf(""
+                                         // the video source capturing and encoding the screen is linked to the tee below,
+                                         // see createVideoSource
+                                         // tee for sending rtp packets to potentially many rtc clients
+                                         "tee name=videoenctee "
+                                         // a copy to fakesink for prerolling early (might not be needed)
+                                         "videoenctee. ! "
+                                         "queue flush-on-eos=true leaky=downstream silent=true ! "
+                                         "fakesink "
+                                         // the same for the extra video sources
+                                         "%s"
+                                         // capture audio and create rtp packets
+                                         // capture audio
+                                         "%s"
+                                         // encode
+                                         "%s"
+                                         // tee for sending rtp packets to potentially many rtc clients
+                                         "tee name=audioenctee "
+                                         // a copy to fakesink for prerolling early (might not be needed)
+                                         "audioenctee. ! "
+                                         "queue flush-on-eos=true leaky=downstream silent=true ! "
+                                         "fakesink "
+                                         // play the microphones of peers
+                                         "%s"
+                                         // write a peer's webcam to the virtual camera
+                                         "%s",
+                                         extraVideoTees->str,
+                                         acaptureLine,
+                                         aencoderLine,
+                                         micLine,
+                                         cameraLine);
+    g_string_free(extraVideoTees, TRUE);
+    g_free(aencoderLine);
+    g_free(micLine);
+    g_free(cameraLine);
+    pipeline = gst_parse_launch(basePipelineString, &error);
+    // take ownership of floating ref
+    if (G_IS_INITIALLY_UNOWNED (pipeline))
+        g_object_ref_sink (pipeline);
+    g_free(basePipelineString);
+    if (error)
+    {
+        setLastErrorMessage(error->message);
+        g_error_free(error);
+        returnVal = ERROR_PIPELINE_PARSE_BAD_FORMAT;
+        goto done;
+    }
+    returnVal = createVideoSource(&videoSource);
+    if (returnVal != SUCCESS)
+        goto done;
+    returnVal = addVideoSource(videoSource, "videoenctee");
+    if (returnVal != SUCCESS)
+        goto done;
+    for (unsigned i = 0; i < options.videoSourceCount; i++)
+    {
+        if (!videoSourceIsBuilt(i))
+            continue;
+        returnVal = createExtraVideoSource(i, &videoSource);
+        if (returnVal != SUCCESS)
+            goto done;
+        gchar *teeName = g_strdup_printf("videoenctee_%s", options.videoSources[i].name);
+        returnVal = addVideoSource(videoSource, teeName);
+        g_free(teeName);
+        if (returnVal != SUCCESS)
+            goto done;
+    }
+    if (options.compositeInputCount > 0)
+    {
+        returnVal = createCompositeVideoSource(&videoSource);
+        if (returnVal != SUCCESS)
+            goto done;
+        returnVal = addVideoSource(videoSource, "videoenctee_composite");
+        if (returnVal != SUCCESS)
+            goto done;
+    }
+    if (options.cameraEnabled)
+    {
+        cameraSelector = gst_bin_get_by_name(GST_BIN(pipeline), "cameraselector");
+        g_assert_nonnull(cameraSelector);
+        GstElement *placeholder = gst_bin_get_by_name(GST_BIN(pipeline), "cameraplaceholder");
+        g_assert_nonnull(placeholder);
+        GstPad *placeholderSrc = gst_element_get_static_pad(placeholder, "src");
+        // the placeholder is linked to the selector through a capsfilter
+        GstPad *capsSrc = gst_pad_get_peer(placeholderSrc);
+        GstElement *capsfilter = gst_pad_get_parent_element(capsSrc);
+        GstPad *capsfilterSrc = gst_element_get_static_pad(capsfilter, "src");
+        cameraPlaceholderPad = gst_pad_get_peer(capsfilterSrc);
+        g_assert_nonnull(cameraPlaceholderPad);
+        g_object_set(cameraSelector, "active-pad", cameraPlaceholderPad, NULL);
+        cameraLastShownCheck = 0;
+        gst_pad_add_probe(cameraPlaceholderPad, GST_PAD_PROBE_TYPE_BUFFER, on_camera_placeholder_frame, NULL, NULL);
+        gst_object_unref(capsfilterSrc);
+        gst_object_unref(capsfilter);
+        gst_object_unref(capsSrc);
+        gst_object_unref(placeholderSrc);
+        gst_object_unref(placeholder);
+    }
+done:
+    g_free(acaptureLine);
+    return returnVal;
+}
+/**
+ * @brief set up pipeline and put it in the READY state. MUST be run only once and before any other functions
+ *
+ * @param opt
+ * @return ErrorCode
+ */
+ErrorCode SetupPipeline(PipelineOptions opt)
+{
+    if (getPipelineState() != NONE)
+    {
+        return ERROR_PIPELINE_BAD_STATE;
+    }
+    // init gstreamer
+    gst_init(NULL, NULL);
+    ErrorCode returnVal = SUCCESS;
+    // lock mutex
+    lock();
+
+    // set global options
+    options = opt;
+    // the caller's string may not outlive the pipeline
+    options.microphoneDevice = g_strdup(opt.microphoneDevice == NULL ? "" : opt.microphoneDevice);
+    options.cameraSink = g_strdup(opt.cameraSink == NULL ? "" : opt.cameraSink);
+    options.videoEncoderProperties = g_strdup(opt.videoEncoderProperties == NULL ? "" : opt.videoEncoderProperties);
+    options.videoTemplate = g_strdup(opt.videoTemplate == NULL ? "" : opt.videoTemplate);
+    options.capture = copyCaptureTarget(opt.capture);
+    if (opt.videoSourceCount > MAX_VIDEO_SOURCES)
+    {
+        returnVal = ERROR_BAD_VIDEO_SOURCE;
+        goto done;
+    }
+    for (unsigned i = 0; i < opt.compositeInputCount; i++)
+    {
+        if (opt.compositeInputs[i].source >= (int)opt.videoSourceCount)
+        {
+            returnVal = ERROR_BAD_VIDEO_SOURCE;
+            goto done;
+        }
+    }
+    options.videoSources = copyVideoSources(opt.videoSources, opt.videoSourceCount);
+    CompositeInput *compositeInputs = g_new(CompositeInput, opt.compositeInputCount);
+    for (unsigned i = 0; i < opt.compositeInputCount; i++)
+        compositeInputs[i] = opt.compositeInputs[i];
+    options.compositeInputs = compositeInputs;
+
+    // create pipeline
+    returnVal = createPipeline();
+    if (returnVal != SUCCESS)
+        goto done;
+
+    // set pipeline state to READY
+    if (!setPipelineState(READY))
+    {
+        returnVal = ERROR_PIPELINE_SET_STATE;
+        goto done;
+    }
+
+    // get bus
+    GstBus *bus;
+    bus = gst_pipeline_get_bus(GST_PIPELINE(pipeline));
+    gst_bus_add_watch(bus, (GstBusFunc)on_pipeline_message, NULL);
+    gst_object_unref(bus);
+
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief start the pipeline and put it in the PLAYING state
+ * 
+ * @return ErrorCode 
+ */
+ErrorCode StartPipeline()
+{
+    ErrorCode returnVal = SUCCESS;
+    lock();
+    // check if state is valid
+    switch (getPipelineState())
+    {
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+    case PLAYING:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
+        goto done;
+    case READY:
+        break;
+    }
+
+    // set pipeline state to PLAYING
+    if (!setPipelineState(PLAYING))
+    {
+        returnVal = ERROR_PIPELINE_SET_STATE;
+        goto done;
+    }
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief irreversibly stop the pipeline and put it in the STOPPED state, as well as freeing the pipeline object
+ * remember to remove all peers from the pipeline before calling this function
+ * 
+ * @return ErrorCode 
+ */
+ErrorCode StopPipeline()
+{
+    ErrorCode returnVal = SUCCESS;
+    lock();
+    // check if state is valid
+    switch (getPipelineState())
+    {
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+        returnVal =  ERROR_PIPELINE_BAD_STATE;
+        goto done;
+    case PLAYING:
+    case READY:
+        break;
+    }
+    // TODO: send eos to pipeline
+    if (!setPipelineState(STOPPED))
+    {
+        returnVal = ERROR_PIPELINE_SET_STATE;
+        goto done;
+    }
+    /* Free resources */
+    g_mutex_lock(&cameraMutex);
+    g_clear_object(&cameraPlaceholderPad);
+    g_clear_object(&cameraSelector);
+    g_mutex_unlock(&cameraMutex);
+    gst_object_unref(pipeline);
+    return SUCCESS;
+done:
+    unlock();
+    return returnVal;
+}
+
+// === Core functions ===
+/**
+ * @brief find a video source peers can subscribe to
+ * 
+ * @param name screen, composite or the name of an extra source
+ * @param teeName set to the name of the tee its encoded frames come out of, free it. NULL to only check
+ * @param payload set to the payload type of its tracks, may be NULL
+ * @return bool whether there is one
+ */
+static bool findVideoSource(const char *name, gchar **teeName, int *payload)
+{
+    int found = -1;
+    if (g_strcmp0(name, "screen") == 0)
+        found = 123;
+    else if (g_strcmp0(name, "composite") == 0 && options.compositeInputCount > 0)
+        found = VIDEO_SOURCE_PAYLOAD_BASE + MAX_VIDEO_SOURCES;
+    for (unsigned i = 0; i < options.videoSourceCount && found < 0; i++)
+    {
+        if (g_strcmp0(name, options.videoSources[i].name) == 0 && videoSourceIsBuilt(i))
+            found = VIDEO_SOURCE_PAYLOAD_BASE + i;
+    }
+    if (found < 0)
+        return false;
+    if (teeName != NULL)
+        *teeName = g_strcmp0(name, "screen") == 0 ? g_strdup("videoenctee") : g_strdup_printf("videoenctee_%s", name);
+    if (payload != NULL)
+        *payload = found;
+    return true;
+}
+/**
+ * @brief create the parser and payloader of a video track, ending with its rtp caps
+ * 
+ * @param name of the source, the payloader is named videopay_NAME and the caps videocaps_NAME
+ * @param payload 
+ * @param payloaderLine set to a new string, free it
+ * @return ErrorCode 
+ */
+static ErrorCode createVideoPayloaderLine(const char *name, int payload, char **payloaderLine)
+{
+    switch (options.videoEncoder)
+    {
+    case VP9:
+        *payloaderLine = g_strdup_printf(""
+                                         "vp9parse ! "
+                                         "rtpvp9pay name=videopay_%s picture-id-mode=15-bit ! "
+                                         "capsfilter name=videocaps_%s "
+                                         "caps=\"application/x-rtp,clock-rate=90000,media=video,encoding-name=VP9,payload=%d\"",
+                                         name,
+                                         name,
+                                         payload);
+        return SUCCESS;
+    case H264:
+    case NVH264:
+        *payloaderLine = g_strdup_printf(""
+                                         "h264parse ! "
+                                         "rtph264pay name=videopay_%s config-interval=-1 aggregate-mode=zero-latency ! "
+                                         "capsfilter name=videocaps_%s "
+                                         "caps=\"application/x-rtp,clock-rate=90000,media=video,encoding-name=H264,payload=%d\"",
+                                         name,
+                                         name,
+                                         payload);
+        return SUCCESS;
+    default:
+        return ERROR_ENCODER_NOT_SUPPORTED;
+    }
+}
+/**
+ * @brief enable the video header extensions on a payloader manually,
+ * adding according to chrome support, updated for 1.21.x prerelease
+ * 
+ * @param videopay 
+ */
+static void addVideoHeaderExtensions(GstElement *videopay)
+{
+    int i;
+    GList *exts_list, *ext;
+    exts_list = gst_rtp_get_header_extension_list();
+    for (ext = exts_list, i = 1; ext; ext = ext->next)
+    {
+        GstElementFactory *extension_factory = ext->data;
+        GstRTPHeaderExtension *extension = GST_RTP_HEADER_EXTENSION_CAST(gst_element_factory_create(extension_factory, NULL));
+        const char *uri = gst_rtp_header_extension_get_uri(extension);
+        // video-only
+        if (g_str_match_string("color-space", uri, FALSE) ||
+            g_str_match_string("rtp-stream-id", uri, FALSE) ||
+            g_str_match_string("sdes:mid", uri, FALSE) ||
+            g_str_match_string("transport-wide-cc", uri, FALSE))
+        {
+            gst_rtp_header_extension_set_id(extension, i);
+            i++;
+            g_signal_emit_by_name(videopay, "add-extension", extension);
+        }
+        else
+        {
+            g_info("uri %s not added to video payloader", uri);
+        }
+    }
+    gst_plugin_feature_list_free(exts_list);
+}
+/**
+ * @brief add a webrtc peer to the pipeline, receiving the screen
+ * 
+ * @param peer_id 
+ * @return ErrorCode 
+ */
+ErrorCode AddPeerToPipeline(const char *peer_id)
+{
+    return addPeer(peer_id, NULL, 0);
+}
+/**
+ * @brief add a webrtc peer to the pipeline, receiving a video track for each source in their order.
+ * The stream id of a track is the name of its source, where webrtcbin supports setting it
+ * 
+ * @param peer_id 
+ * @param sources screen, composite or names of PipelineOptions.videoSources, each once
+ * @param count at least one
+ * @return ErrorCode ERROR_BAD_VIDEO_SOURCE for no, an unknown or a repeated source
+ */
+ErrorCode AddPeerWithVideoSources(const char *peer_id, const char **sources, unsigned count)
+{
+    if (count == 0)
+        return ERROR_BAD_VIDEO_SOURCE;
+    return addPeer(peer_id, sources, count);
+}
+/**
+ * @brief add a webrtc peer to the pipeline
+ * 
+ * @param peer_id 
+ * @param sources of its video tracks, NULL for the screen only
+ * @param count 
+ * @return ErrorCode 
+ */
+static ErrorCode addPeer(const char *peer_id, const char **sources, unsigned count)
+{
+    ErrorCode returnVal = SUCCESS;
+    const char *screenOnly[] = {"screen"};
+    if (sources == NULL)
+    {
+        sources = screenOnly;
+        count = 1;
+    }
+    lock();
+    // peer id names are used a lot
+    // so define them now and free in goto
+    char *peer_id_vname = g_strdup_printf("v%s", peer_id);
+    char *peer_id_aname = g_strdup_printf("a%s", peer_id);
+
+    // check if state is valid
+    switch (getPipelineState())
+    {
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+    case READY:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
+        goto done;
+    case PLAYING:
+        break;
+    }
+    unsigned s;
+    for (s = 0; s < count; s++)
+    {
+        bool repeated = false;
+        for (unsigned j = 0; j < s; j++)
+            repeated = repeated || g_strcmp0(sources[j], sources[s]) == 0;
+        if (repeated || !findVideoSource(sources[s], NULL, NULL))
+        {
+            gchar *message = g_strdup_printf("no video source '%s' to subscribe to, or subscribed twice", sources[s]);
+            setLastErrorMessage(message);
+            g_free(message);
+            returnVal = ERROR_BAD_VIDEO_SOURCE;
+            goto done;
+        }
+    }
+
+    // set parse / payloader settings
+    char *aPayloader;
+    aPayloader = g_strdup_printf(""
+                                 "rtpopuspay name=audiopay ! "
+                                 "application/x-rtp,clock-rate=48000,media=audio,encoding-name=OPUS,payload=%d,"
+                                 "stereo=(string)1,minptime=(string)10,rtx-time=(string)125,useinbandfec=(string)1",
+                                 97);
+
+    // create webrtc pipelines, a branch for each video source into the same webrtcbin
+    GString *vwebrtcLine;
+    char *awebrtcLine;
+    vwebrtcLine = g_string_new(""
+                               "webrtcbin name=webrtc stun-server=stun://stun.l.google.com:19302 "
+                               "bundle-policy=max-compat latency=1 ");
+    for (s = 0; s < count; s++)
+    {
+        int payload;
+        char *vParserPayloader;
+        findVideoSource(sources[s], NULL, &payload);
+        returnVal = createVideoPayloaderLine(sources[s], payload, &vParserPayloader);
+        if (returnVal != SUCCESS)
+        {
+            g_string_free(vwebrtcLine, TRUE);
+            goto done;
+        }
+        g_string_append_printf(vwebrtcLine, ""
+                                            "queue name=videoqueue_%s leaky=downstream silent=true max-size-buffers=0 "
+                                            "max-size-bytes=0 max-size-time=1000000000 flush-on-eos=true ! "
+                                            "%s ! "
+                                            "webrtc. ",
+                               sources[s],
+                               vParserPayloader);
+        g_free(vParserPayloader);
+    }
+
+    // latency only sizes the jitter buffer of received streams, which is the microphone
+    awebrtcLine = g_strdup_printf(""
+                                  "queue leaky=downstream silent=true max-size-buffers=0 "
+                                  "max-size-bytes=0 max-size-time=1000000000 flush-on-eos=true ! "
+                                  "%s ! "
+                                  "webrtcbin name=webrtc stun-server=stun://stun.l.google.com:19302 "
+                                  "bundle-policy=max-compat latency=%u",
+                                  aPayloader,
+                                  options.microphoneEnabled ? options.microphoneLatency : 1);
+    g_free(aPayloader);
+
+    // create webrtc wrapper bins (floating refs are taken ownership in gst_bin_add)
+    GstElement *videoWebrtcbin, *audioWebrtcbin;
+    GError *error = NULL;
+    // the video branches are ghosted below, one pad named after each source
+    videoWebrtcbin = gst_parse_bin_from_description(vwebrtcLine->str, FALSE, &error);
+    g_string_free(vwebrtcLine, TRUE);
+    if (videoWebrtcbin == NULL)
+    {
+        setLastErrorMessage(error->message);
+        g_error_free(error);
+        returnVal = ERROR_PIPELINE_PARSE_BAD_FORMAT;
+        goto done;
+    }
+    g_clear_error(&error);
+    for (s = 0; s < count; s++)
+    {
+        gchar *queueName = g_strdup_printf("videoqueue_%s", sources[s]);
+        GstElement *queue = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), queueName);
+        g_assert_nonnull(queue);
+        GstPad *queueSink = gst_element_get_static_pad(queue, "sink");
+        g_warn_if_fail(gst_element_add_pad(videoWebrtcbin, gst_ghost_pad_new(sources[s], queueSink)));
+        gst_object_unref(queueSink);
+        gst_object_unref(queue);
+        g_free(queueName);
+    }
+    audioWebrtcbin = gst_parse_bin_from_description(awebrtcLine, TRUE, &error);
+    g_free(awebrtcLine);
+    if (audioWebrtcbin == NULL)
+    {
+        setLastErrorMessage(error->message);
+        g_error_free(error);
+        returnVal = ERROR_PIPELINE_PARSE_BAD_FORMAT;
+        goto done;
+    }
+    g_clear_error(&error);
+    
+    // set element names
+    gst_element_set_name(videoWebrtcbin, peer_id_vname);
+    gst_element_set_name(audioWebrtcbin, peer_id_aname);
+
+    // enable all extensions manually
+    // adding according to chrome support
+    // updated for 1.21.x prerelease
+    int i;
+    GList *exts_list, *ext;
+    // video, the same ids on every track as bundled tracks need
+    for (s = 0; s < count; s++)
+    {
+        gchar *videopayName = g_strdup_printf("videopay_%s", sources[s]);
+        GstElement *videopay = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), videopayName);
+        g_warn_if_fail(videopay != NULL);
+        addVideoHeaderExtensions(videopay);
+        gst_object_unref(videopay);
+        g_free(videopayName);
+    }
+    // audio
+    GstElement *audiopay = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "audiopay");
+    g_warn_if_fail(audiopay != NULL);
+    exts_list = gst_rtp_get_header_extension_list();
+    for (ext = exts_list, i = 1; ext; ext = ext->next)
+    {
+        GstElementFactory *extension_factory = ext->data;
+        GstRTPHeaderExtension *extension = GST_RTP_HEADER_EXTENSION_CAST(gst_element_factory_create(extension_factory, NULL));
+        const char *uri = gst_rtp_header_extension_get_uri(extension);
+        // audio-only
+        if (g_str_match_string("audio-level", uri, FALSE) ||
+            g_str_match_string("sdes:mid", uri, FALSE) ||
+            g_str_match_string("transport-wide-cc", uri, FALSE))
+        {
+            gst_rtp_header_extension_set_id(extension, i);
+            i++;
+            g_signal_emit_by_name(audiopay, "add-extension", extension);
+        }
+        else
+        {
+            g_info("uri %s not added to audio payloader", uri);
+        }
+    }
+    gst_plugin_feature_list_free(exts_list);
+    gst_object_unref(audiopay);
+    // add to pipeline - ownership is transferred to parent
+    g_warn_if_fail(gst_bin_add(GST_BIN(pipeline), videoWebrtcbin));
+    g_warn_if_fail(gst_bin_add(GST_BIN(pipeline), audioWebrtcbin));
+    // link to main encoders
+    GstPad *srcpad;
+    GstElement *tee;
+    int ret;
+
+    // link vid, each source to its ghost pad
+    for (s = 0; s < count; s++)
+    {
+        gchar *teeName;
+        findVideoSource(sources[s], &teeName, NULL);
+        tee = gst_bin_get_by_name(GST_BIN(pipeline), teeName);
+        g_assert_nonnull(tee);
+        g_free(teeName);
+        srcpad = gst_element_request_pad_simple(tee, "src_%u");
+        g_assert_nonnull(srcpad);
+        GstPad *ghost = gst_element_get_static_pad(videoWebrtcbin, sources[s]);
+        ret = gst_pad_link(srcpad, ghost);
+        gst_object_unref(ghost);
+        gst_object_unref(tee);
+        gst_object_unref(srcpad);
+        if (ret != GST_PAD_LINK_OK)
+        {
+            returnVal = ERROR_LINKING_PEER;
+            goto done;
+        }
+    }
+    // link aud
+    tee = gst_bin_get_by_name(GST_BIN(pipeline), "audioenctee");
+    g_assert_nonnull(tee);
+    srcpad = gst_element_request_pad_simple(tee, "src_%u");
+    g_assert_nonnull(srcpad);
+    ret = gst_pad_link(srcpad, audioWebrtcbin->sinkpads->data);
+    gst_object_unref(tee);
+    gst_object_unref(srcpad);
+    if (ret != GST_PAD_LINK_OK)
+    {
+        returnVal = ERROR_LINKING_PEER;
+        goto done;
+    }
+    // extract webrtcbin from wrapper
+    GstElement *vwebrtcbin = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), "webrtc");
+    g_assert_nonnull(vwebrtcbin);
+    GstElement *awebrtcbin = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "webrtc");
+    g_assert_nonnull(awebrtcbin);
+    // set transceiver settings
+    GArray *transceivers;
+    GstWebRTCRTPSender *sender;
+    GstWebRTCRTPTransceiver *trans;
+    // video, a transceiver for each source
+    g_signal_emit_by_name(vwebrtcbin, "get-transceivers", &transceivers);
+    g_assert(transceivers != NULL && transceivers->len == count);
+
+    for (s = 0; s < count; s++)
+    {
+        trans = g_array_index(transceivers, GstWebRTCRTPTransceiver *, s);
+        g_object_set(trans, "direction", GST_WEBRTC_RTP_TRANSCEIVER_DIRECTION_SENDONLY, NULL);
+        g_object_set(trans, "do-nack", TRUE, NULL);
+        g_object_get(trans, "sender", &sender, NULL);
+        gst_webrtc_rtp_sender_set_priority(sender, GST_WEBRTC_PRIORITY_TYPE_HIGH);
+        g_object_unref(sender);
+    }
+    g_array_unref(transceivers);
+    // tell the peer which track is which source
+    for (s = 0; s < count; s++)
+    {
+        gchar *capsName = g_strdup_printf("videocaps_%s", sources[s]);
+        GstElement *capsfilter = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), capsName);
+        g_assert_nonnull(capsfilter);
+        GstPad *capsSrc = gst_element_get_static_pad(capsfilter, "src");
+        GstPad *webrtcSink = gst_pad_get_peer(capsSrc);
+        // since GStreamer 1.22
+        if (webrtcSink != NULL && g_object_class_find_property(G_OBJECT_GET_CLASS(webrtcSink), "msid") != NULL)
+            g_object_set(webrtcSink, "msid", sources[s], NULL);
+        if (webrtcSink != NULL)
+            gst_object_unref(webrtcSink);
+        gst_object_unref(capsSrc);
+        gst_object_unref(capsfilter);
+        g_free(capsName);
+    }
+
+    // audio
+    g_signal_emit_by_name(awebrtcbin, "get-transceivers", &transceivers);
+    g_assert(transceivers != NULL && transceivers->len > 0);
+
+    trans = g_array_index(transceivers, GstWebRTCRTPTransceiver *, 0);
+    // the peer's microphone comes back on the same transceiver
+    g_object_set(trans, "direction", options.microphoneEnabled ? GST_WEBRTC_RTP_TRANSCEIVER_DIRECTION_SENDRECV : GST_WEBRTC_RTP_TRANSCEIVER_DIRECTION_SENDONLY, NULL);
+    g_object_get(trans, "sender", &sender, NULL);
+    g_object_set(trans, "fec-type", GST_WEBRTC_FEC_TYPE_ULP_RED, NULL);
+    g_object_set(trans, "fec-percentage", options.audioBasePacketLossPct, NULL);
+    gst_webrtc_rtp_sender_set_priority(sender, GST_WEBRTC_PRIORITY_TYPE_HIGH);
+    g_object_unref(sender);
+    g_object_unref(trans);
+    g_array_unref(transceivers);
+
+    // camera, received on the video webrtcbin in vp8 which every browser sends
+    if (options.cameraEnabled)
+    {
+        GstCaps *cameraCaps = gst_caps_from_string("application/x-rtp,media=video,encoding-name=VP8,payload=96,clock-rate=90000");
+        g_signal_emit_by_name(vwebrtcbin, "add-transceiver", GST_WEBRTC_RTP_TRANSCEIVER_DIRECTION_RECVONLY, cameraCaps, &trans);
+        g_object_set(trans, "do-nack", TRUE, NULL);
+        g_object_unref(trans);
+        gst_caps_unref(cameraCaps);
+        g_signal_connect(vwebrtcbin, "pad-added", G_CALLBACK(on_camera_pad_added), NULL);
+    }
+    // add signal handlers
+    g_signal_connect(vwebrtcbin, "notify::connection-state", G_CALLBACK(on_connection_state_change), NULL);
+    g_signal_connect(awebrtcbin, "notify::connection-state", G_CALLBACK(on_connection_state_change), NULL);
+    g_signal_connect(vwebrtcbin, "on-negotiation-needed", G_CALLBACK(on_negotiation_needed), NULL);
+    g_signal_connect(awebrtcbin, "on-negotiation-needed", G_CALLBACK(on_negotiation_needed), NULL);
+    g_signal_connect(vwebrtcbin, "on-ice-candidate", G_CALLBACK(on_ice_candidate), NULL);
+    g_signal_connect(awebrtcbin, "on-ice-candidate", G_CALLBACK(on_ice_candidate), NULL); 
+    if (options.microphoneEnabled)
+        g_signal_connect(awebrtcbin, "pad-added", G_CALLBACK(on_microphone_pad_added), NULL);
+    
+    // sync states with parent
+    ret = gst_element_sync_state_with_parent(audioWebrtcbin);
+    g_assert_true(ret);
+    ret = gst_element_sync_state_with_parent(videoWebrtcbin);
+    g_assert_true(ret);
+    // datachannel is added to audio webrtcbin after state set to playing
+    GstWebRTCDataChannel *datachannel;
+    GstStructure *datachannelSettings;
+    datachannelSettings = gst_structure_new("settings",
+                                            "ordered", G_TYPE_BOOLEAN, TRUE,
+                                            "priority", GST_TYPE_WEBRTC_PRIORITY_TYPE, GST_WEBRTC_PRIORITY_TYPE_HIGH,
+                                            "max-retransmits", G_TYPE_INT, 1,
+                                            NULL);
+    g_signal_emit_by_name(awebrtcbin, "create-data-channel", "controls", datachannelSettings, &datachannel);
+    g_signal_connect(datachannel, "on-message-string", G_CALLBACK(on_datachannel_message_string), NULL);
+    g_signal_connect(datachannel, "on-open", G_CALLBACK(on_datachannel_open), NULL);
+    g_signal_connect(datachannel, "on-close", G_CALLBACK(on_datachannel_close), NULL);
+    // the datachannel callbacks find the peer through the wrapper bin, which outlives the datachannel
+    g_object_set_qdata(G_OBJECT(datachannel), g_quark_from_static_string("datachannel-controls"), audioWebrtcbin);
+    // add a reference since the next function doesn't take ownership
+    g_object_ref(datachannel);
+    // store the datachannel in the webrtcbin
+    g_object_set_qdata_full(G_OBJECT(awebrtcbin), g_quark_from_static_string("datachannel-controls"), datachannel, g_object_unref);
+
+    gst_structure_free(datachannelSettings);
+    g_object_unref(datachannel);
+    // files are sent reliably and in order, at a lower priority than controls
+    GstWebRTCDataChannel *filesDatachannel;
+    GstStructure *filesDatachannelSettings;
+    filesDatachannelSettings = gst_structure_new("settings",
+                                                 "ordered", G_TYPE_BOOLEAN, TRUE,
+                                                 "priority", GST_TYPE_WEBRTC_PRIORITY_TYPE, GST_WEBRTC_PRIORITY_TYPE_LOW,
+                                                 NULL);
+    g_signal_emit_by_name(awebrtcbin, "create-data-channel", "files", filesDatachannelSettings, &filesDatachannel);
+    g_object_set(filesDatachannel, "buffered-amount-low-threshold", (guint64)FILES_BUFFERED_AMOUNT_LOW, NULL);
+    g_signal_connect(filesDatachannel, "on-message-string", G_CALLBACK(on_files_message_string), NULL);
+    g_signal_connect(filesDatachannel, "on-message-data", G_CALLBACK(on_files_message_data), NULL);
+    g_signal_connect(filesDatachannel, "on-open", G_CALLBACK(on_files_open), NULL);
+    g_signal_connect(filesDatachannel, "on-close", G_CALLBACK(on_files_close), NULL);
+    g_signal_connect(filesDatachannel, "on-buffered-amount-low", G_CALLBACK(on_files_buffered_amount_low), NULL);
+    g_object_set_qdata(G_OBJECT(filesDatachannel), g_quark_from_static_string("datachannel-files"), audioWebrtcbin);
+    g_object_ref(filesDatachannel);
+    g_object_set_qdata_full(G_OBJECT(awebrtcbin), g_quark_from_static_string("datachannel-files"), filesDatachannel, g_object_unref);
+
+    gst_structure_free(filesDatachannelSettings);
+    g_object_unref(filesDatachannel);
+    // cursor positions may arrive out of order and carry a sequence number, so a lost one doesn't hold up the next
+    GstWebRTCDataChannel *cursorDatachannel;
+    GstStructure *cursorDatachannelSettings;
+    cursorDatachannelSettings = gst_structure_new("settings",
+                                                  "ordered", G_TYPE_BOOLEAN, FALSE,
+                                                  "priority", GST_TYPE_WEBRTC_PRIORITY_TYPE, GST_WEBRTC_PRIORITY_TYPE_HIGH,
+                                                  NULL);
+    g_signal_emit_by_name(awebrtcbin, "create-data-channel", "cursor", cursorDatachannelSettings, &cursorDatachannel);
+    g_signal_connect(cursorDatachannel, "on-open", G_CALLBACK(on_cursor_open), NULL);
+    g_signal_connect(cursorDatachannel, "on-close", G_CALLBACK(on_cursor_close), NULL);
+    g_object_set_qdata(G_OBJECT(cursorDatachannel), g_quark_from_static_string("datachannel-cursor"), audioWebrtcbin);
+    g_object_ref(cursorDatachannel);
+    g_object_set_qdata_full(G_OBJECT(awebrtcbin), g_quark_from_static_string("datachannel-cursor"), cursorDatachannel, g_object_unref);
+
+    gst_structure_free(cursorDatachannelSettings);
+    g_object_unref(cursorDatachannel);
+    gst_object_unref(vwebrtcbin);
+    gst_object_unref(awebrtcbin);
+    gst_object_unref(audioWebrtcbin);
+    gst_object_unref(videoWebrtcbin);
+done:
+    unlock();
+    g_free(peer_id_aname);
+    g_free(peer_id_vname);
+    return returnVal;
+}
+/**
+ * @brief Set the remove answer for peer
+ * Make sure peer exists when using this
+ * 
+ * @param peer_id
+ * @param answer_sdp
+ * @return ErrorCode
+ */
+ErrorCode SetRemoteAnswer(const char *peer_id, const char *answer_sdp)
+{
+    ErrorCode returnVal = SUCCESS;
+    lock();
+
+    // check if state is valid
+    switch (getPipelineState())
+    {
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+    case READY:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
+        goto done;
+    case PLAYING:
+        break;
+    }
+
+    GstSDPMessage *sdp;
+    GstPromise *promise;
+    GstWebRTCSessionDescription *answer;
+    GstElement *webrtcbin, *webrtc;
+    int ret;
+
+    // get peer
+    webrtc = gst_bin_get_by_name(GST_BIN(pipeline), peer_id);
+    if (!GST_IS_ELEMENT(webrtc))
+    {
+        returnVal = ERROR_BAD_PEER_ID;
+        goto done;
+    }
+
+    // create an sdp message and parse into it
+    ret = gst_sdp_message_new(&sdp);
+    g_assert_cmphex(ret, ==, GST_SDP_OK);
+    ret = gst_sdp_message_parse_buffer((guint8 *)answer_sdp, strlen(answer_sdp), sdp);
+    if (ret != GST_SDP_OK)
+    {
+        returnVal = ERROR_BAD_SDP;
+        goto done;
+    }
+    // sdp ownership is transferred
+    answer = gst_webrtc_session_description_new(GST_WEBRTC_SDP_TYPE_ANSWER, sdp);
+    g_warn_if_fail(answer != NULL);
+
+    webrtcbin = gst_bin_get_by_name(GST_BIN(webrtc), "webrtc");
+
+    promise = gst_promise_new();
+    g_signal_emit_by_name(webrtcbin, "set-remote-description", answer, promise);
+    gst_object_unref(webrtcbin);
+    gst_object_unref(webrtc);
+    gst_promise_interrupt(promise);
+    gst_promise_unref(promise);
+    gst_webrtc_session_description_free(answer);
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief Set the Remote ice candidate for peer
+ * Make sure peer exists when using this
+ *
+ * @param peer_id
+ * @param mlineindex
+ * @param candidate
+ * @return ErrorCode
+ */
+ErrorCode AddRemoteIceCandidate(const char *peer_id, unsigned int mlineindex, const char *candidate)
+{
+    ErrorCode returnVal = SUCCESS;
+    lock();
+
+    // check if state is valid
+    switch (getPipelineState())
+    {
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+    case READY:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
+        goto done;
+    case PLAYING:
+        break;
+    }
+
+    GstElement *webrtc, *webrtcbin;
+
+    webrtc = gst_bin_get_by_name(GST_BIN(pipeline), peer_id);
+    if (!GST_IS_ELEMENT(webrtc))
+    {
+        returnVal = ERROR_BAD_PEER_ID;
+        goto done;
+    }
+    webrtcbin = gst_bin_get_by_name(GST_BIN(webrtc), "webrtc");
+    g_signal_emit_by_name(webrtcbin, "add-ice-candidate", mlineindex, candidate);
+
+    gst_object_unref(webrtcbin);
+    gst_object_unref(webrtc);
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief Remove a peer from pipeline and free its resources
+ * Make sure peer exists when using this
+ *
+ * @param peer_id
+ * @return ErrorCode
+ */
+ErrorCode RemovePeerFromPipeline(const char *peer_id)
+{
+    ErrorCode returnVal = SUCCESS;
+    lock();
+
+    char *peer_id_vname;
+    char *peer_id_aname;
+    char *peer_id_mname;
+    char *peer_id_cname;
+    peer_id_vname = g_strdup_printf("v%s", peer_id);
+    peer_id_aname = g_strdup_printf("a%s", peer_id);
+    peer_id_mname = g_strdup_printf("m%s", peer_id);
+    peer_id_cname = g_strdup_printf("c%s", peer_id);
+
+    // check if state is valid
+    switch (getPipelineState())
+    {
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+    case READY:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
+        goto done;
+    case PLAYING:
+        break;
+    }
+    
+    GstElement *audioWebrtcbin, *videoWebrtcbin;
+
+    videoWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_vname);
+    if (!GST_IS_ELEMENT(videoWebrtcbin))
+    {
+        returnVal = ERROR_BAD_PEER_ID;
+        goto done;
+    }
+    audioWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_aname);
+    if (!GST_IS_ELEMENT(audioWebrtcbin))
+    {
+        returnVal = ERROR_BAD_PEER_ID;
+        goto done;
+    }
+
+    GstElement *tee;
+    GstPad *teepad;
+    /* tear down video branches, one for each source the peer subscribed to */
+    for (GList *sinkpad = videoWebrtcbin->sinkpads; sinkpad != NULL; sinkpad = sinkpad->next)
+    {
+        teepad = gst_pad_get_peer(sinkpad->data);
+        if (teepad == NULL)
+            continue;
+        tee = gst_pad_get_parent_element(teepad);
+        g_assert_nonnull(tee);
+        gst_element_release_request_pad(tee, teepad);
+        gst_object_unref(teepad);
+        gst_object_unref(tee);
+    }
+
+    /* tear down audio branch */
+    tee = gst_bin_get_by_name(GST_BIN(pipeline), "audioenctee");
+    g_assert_nonnull(tee);
+    teepad = gst_pad_get_peer(audioWebrtcbin->sinkpads->data);
+    gst_element_release_request_pad(tee, teepad);
+    gst_object_unref(teepad);
+    gst_object_unref(tee);
+
+    /* tear down microphone branch, if the peer sent one */
+    GstElement *micbin;
+    micbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_mname);
+    if (micbin != NULL)
+    {
+        GstElement *mixer = gst_bin_get_by_name(GST_BIN(pipeline), "micmixer");
+        g_assert_nonnull(mixer);
+        GstPad *mixerpad = gst_pad_get_peer(micbin->srcpads->data);
+        g_warn_if_fail(gst_element_set_state(micbin, GST_STATE_NULL));
+        if (mixerpad != NULL)
+        {
+            gst_element_release_request_pad(mixer, mixerpad);
+            gst_object_unref(mixerpad);
+        }
+        gst_object_unref(mixer);
+        // also unrefs
+        g_warn_if_fail(gst_bin_remove(GST_BIN(pipeline), micbin));
+        gst_object_unref(micbin);
+    }
+
+    /* tear down camera branch, if the peer sent one. Go stopped showing it already */
+    GstElement *camerabin;
+    camerabin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_cname);
+    if (camerabin != NULL)
+    {
+        GstPad *selectorpad = gst_pad_get_peer(camerabin->srcpads->data);
+        g_warn_if_fail(gst_element_set_state(camerabin, GST_STATE_NULL));
+        g_mutex_lock(&cameraMutex);
+        if (selectorpad != NULL)
+        {
+            gst_element_release_request_pad(cameraSelector, selectorpad);
+            gst_object_unref(selectorpad);
+        }
+        // also unrefs
+        g_warn_if_fail(gst_bin_remove(GST_BIN(pipeline), camerabin));
+        g_mutex_unlock(&cameraMutex);
+        gst_object_unref(camerabin);
+    }
+
+
+    // remove webrtcbin from webrtc wrappers
+    GstElement *webrtc;
+
+    // video
+    webrtc = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), "webrtc");
+    g_assert_nonnull(webrtc);
+    // remove signal handlers
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_connection_state_change), NULL);
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_negotiation_needed), NULL);
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_ice_candidate), NULL);
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_camera_pad_added), NULL);
+    // remove bin
+    g_warn_if_fail(gst_element_set_state(webrtc, GST_STATE_NULL));
+    // also unrefs
+    g_warn_if_fail(gst_bin_remove(GST_BIN(videoWebrtcbin), webrtc));
+    // gst_object_unref(webrtc);
+
+    // audio
+    webrtc = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "webrtc");
+    g_assert_nonnull(webrtc);
+    // remove signal handlers
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_connection_state_change), NULL);
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_negotiation_needed), NULL);
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_ice_candidate), NULL);
+    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_microphone_pad_added), NULL);
+     // stop datachannel(s)
+    GstWebRTCDataChannel* datachannel;
+    datachannel = GST_WEBRTC_DATA_CHANNEL(g_object_get_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-controls")));
+    g_assert_nonnull(datachannel);
+    g_signal_handlers_disconnect_by_func(datachannel, G_CALLBACK(on_datachannel_message_string), NULL);
+    g_signal_handlers_disconnect_by_func(datachannel, G_CALLBACK(on_datachannel_open), NULL);
+    g_signal_handlers_disconnect_by_func(datachannel, G_CALLBACK(on_datachannel_close), NULL);
+    gst_webrtc_data_channel_close(datachannel);
+    gst_object_unref(datachannel);
+    // free the datachannel object, since set_qdata_full was used freeing is done automatically
+    g_object_set_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-controls"), NULL);
+    GstWebRTCDataChannel *filesDatachannel;
+    filesDatachannel = GST_WEBRTC_DATA_CHANNEL(g_object_get_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-files")));
+    g_assert_nonnull(filesDatachannel);
+    g_signal_handlers_disconnect_by_func(filesDatachannel, G_CALLBACK(on_files_message_string), NULL);
+    g_signal_handlers_disconnect_by_func(filesDatachannel, G_CALLBACK(on_files_message_data), NULL);
+    g_signal_handlers_disconnect_by_func(filesDatachannel, G_CALLBACK(on_files_open), NULL);
+    g_signal_handlers_disconnect_by_func(filesDatachannel, G_CALLBACK(on_files_close), NULL);
+    g_signal_handlers_disconnect_by_func(filesDatachannel, G_CALLBACK(on_files_buffered_amount_low), NULL);
+    gst_webrtc_data_channel_close(filesDatachannel);
+    g_object_set_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-files"), NULL);
+    GstWebRTCDataChannel *cursorDatachannel;
+    cursorDatachannel = GST_WEBRTC_DATA_CHANNEL(g_object_get_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-cursor")));
+    g_assert_nonnull(cursorDatachannel);
+    g_signal_handlers_disconnect_by_func(cursorDatachannel, G_CALLBACK(on_cursor_open), NULL);
+    g_signal_handlers_disconnect_by_func(cursorDatachannel, G_CALLBACK(on_cursor_close), NULL);
+    gst_webrtc_data_channel_close(cursorDatachannel);
+    g_object_set_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-cursor"), NULL);
+    // remove bin
+    g_warn_if_fail(gst_element_set_state(webrtc, GST_STATE_NULL));
+    // also unrefs
+    g_warn_if_fail(gst_bin_remove(GST_BIN(audioWebrtcbin), webrtc));
+    // gst_object_unref(webrtc);
 
-    // set parse / payloader settings
-    char *vParserPayloader;
-    char *aPayloader;
-    switch (options.videoEncoder)
-    {
-    case VP9:
-        vParserPayloader = g_strdup_printf(""
-                                           "vp9parse ! "
-                                           "rtpvp9pay name=videopay picture-id-mode=15-bit ! "
-                                           "application/x-rtp,clock-rate=90000,media=video,encoding-name=VP9,payload=%d",
-                                           123);
-        break;
-    case H264:
-    case NVH264:
-        vParserPayloader = g_strdup_printf(""
-                                           "h264parse ! "
-                                           "rtph264pay name=videopay config-interval=-1 aggregate-mode=zero-latency ! "
-                                           "application/x-rtp,clock-rate=90000,media=video,encoding-name=H264,payload=%d",
-                                           123);
-        break;
-    default:
-        returnVal = ERROR_ENCODER_NOT_SUPPORTED;
-        goto done;
-    }
+    // set states to null
+    g_warn_if_fail(gst_element_set_state(videoWebrtcbin, GST_STATE_NULL));
+    g_warn_if_fail(gst_element_set_state(audioWebrtcbin, GST_STATE_NULL));
 
-    aPayloader = g_strdup_printf(""
-                                 "rtpopuspay name=audiopay ! "
-                                 "application/x-rtp,clock-rate=48000,media=audio,encoding-name=OPUS,payload=%d,"
-                                 "stereo=(string)1,minptime=(string)10,rtx-time=(string)125,useinbandfec=(string)1",
-                                 97);
+    // remove elements from pipeline, also unrefs
+    g_warn_if_fail(gst_bin_remove(GST_BIN(pipeline), videoWebrtcbin));
+    g_warn_if_fail(gst_bin_remove(GST_BIN(pipeline), audioWebrtcbin));
+    // gst_object_unref(audioWebrtcbin);
+    // gst_object_unref(videoWebrtcbin);
 
-    // create webrtc pipelines
-    char *vwebrtcLine;
-    char *awebrtcLine;
-    vwebrtcLine = g_strdup_printf(""
-                                  "queue leaky=downstream silent=true max-size-buffers=0 "
-                                  "max-size-bytes=0 max-size-time=1000000000 flush-on-eos=true ! "
-                                  "%s ! "
-                                  "webrtcbin name=webrtc stun-server=stun://stun.l.google.com:19302 "
-                                  "bundle-policy=max-compat latency=1",
-                                  vParserPayloader);
+done:
+    g_free(peer_id_vname);
+    g_free(peer_id_aname);
+    g_free(peer_id_mname);
+    g_free(peer_id_cname);
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief Send a string message to a peer over its controls datachannel
+ * Make sure peer exists when using this
+ *
+ * @param peer_id
+ * @param message
+ * @return ErrorCode
+ */
+ErrorCode SendControlsMessage(const char *peer_id, const char *message)
+{
+    ErrorCode returnVal = SUCCESS;
+    lock();
 
-    awebrtcLine = g_strdup_printf(""
-                                  "queue leaky=downstream silent=true max-size-buffers=0 "
-                                  "max-size-bytes=0 max-size-time=1000000000 flush-on-eos=true ! "
-                                  "%s ! "
-                                  "webrtcbin name=webrtc stun-server=stun://stun.l.google.com:19302 "
-                                  "bundle-policy=max-compat latency=1",
-                                  aPayloader);
-    g_free(vParserPayloader);
-    g_free(aPayloader);
+    char *peer_id_aname;
+    peer_id_aname = g_strdup_printf("a%s", peer_id);
 
-    // create webrtc wrapper bins (floating refs are taken ownership in gst_bin_add)
-    GstElement *videoWebrtcbin, *audioWebrtcbin;
-    videoWebrtcbin = gst_parse_bin_from_description(vwebrtcLine, TRUE, NULL);
-    g_free(vwebrtcLine);
-    if (videoWebrtcbin == NULL)
+    // check if state is valid
+    switch (getPipelineState())
     {
-        returnVal = ERROR_PIPELINE_PARSE_BAD_FORMAT;
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
         goto done;
-    }
-    audioWebrtcbin = gst_parse_bin_from_description(awebrtcLine, TRUE, NULL);
-    g_free(awebrtcLine);
-    if (audioWebrtcbin == NULL)
-    {
-        returnVal = ERROR_PIPELINE_PARSE_BAD_FORMAT;
+    case STOPPED:
+    case READY:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
         goto done;
+    case PLAYING:
+        break;
     }
-    
-    // set element names
-    gst_element_set_name(videoWebrtcbin, peer_id_vname);
-    gst_element_set_name(audioWebrtcbin, peer_id_aname);
 
-    // enable all extensions manually
-    // adding according to chrome support
-    // updated for 1.21.x prerelease
-    int i;
-    GList *exts_list, *ext;
-    // video
-    GstElement *videopay = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), "videopay");
-    g_warn_if_fail(videopay != NULL);
-    exts_list = gst_rtp_get_header_extension_list();
-    for (ext = exts_list, i = 1; ext; ext = ext->next)
-    {
-        GstElementFactory *extension_factory = ext->data;
-        GstRTPHeaderExtension *extension = GST_RTP_HEADER_EXTENSION_CAST(gst_element_factory_create(extension_factory, NULL));
-        const char *uri = gst_rtp_header_extension_get_uri(extension);
-        // video-only
-        if (g_str_match_string("color-space", uri, FALSE) ||
-            g_str_match_string("rtp-stream-id", uri, FALSE) ||
-            g_str_match_string("sdes:mid", uri, FALSE) ||
-            g_str_match_string("transport-wide-cc", uri, FALSE))
-        {
-            gst_rtp_header_extension_set_id(extension, i);
-            i++;
-            g_signal_emit_by_name(videopay, "add-extension", extension);
-        }
-        else
-        {
-            g_info("uri %s not added to video payloader", uri);
-        }
-    }
-    gst_plugin_feature_list_free(exts_list);
-    gst_object_unref(videopay);
-    // audio
-    GstElement *audiopay = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "audiopay");
-    g_warn_if_fail(audiopay != NULL);
-    exts_list = gst_rtp_get_header_extension_list();
-    for (ext = exts_list, i = 1; ext; ext = ext->next)
+    GstElement *audioWebrtcbin, *webrtc;
+
+    audioWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_aname);
+    if (!GST_IS_ELEMENT(audioWebrtcbin))
     {
-        GstElementFactory *extension_factory = ext->data;
-        GstRTPHeaderExtension *extension = GST_RTP_HEADER_EXTENSION_CAST(gst_element_factory_create(extension_factory, NULL));
-        const char *uri = gst_rtp_header_extension_get_uri(extension);
-        // audio-only
-        if (g_str_match_string("audio-level", uri, FALSE) ||
-            g_str_match_string("sdes:mid", uri, FALSE) ||
-            g_str_match_string("transport-wide-cc", uri, FALSE))
-        {
-            gst_rtp_header_extension_set_id(extension, i);
-            i++;
-            g_signal_emit_by_name(audiopay, "add-extension", extension);
-        }
-        else
-        {
-            g_info("uri %s not added to audio payloader", uri);
-        }
+        returnVal = ERROR_BAD_PEER_ID;
+        goto done;
     }
-    gst_plugin_feature_list_free(exts_list);
-    gst_object_unref(audiopay);
-    // add to pipeline - ownership is transferred to parent
-    g_warn_if_fail(gst_bin_add(GST_BIN(pipeline), videoWebrtcbin));
-    g_warn_if_fail(gst_bin_add(GST_BIN(pipeline), audioWebrtcbin));
-    // link to main encoders
-    GstPad *srcpad;
-    GstElement *tee;
-    int ret;
+    webrtc = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "webrtc");
+    g_assert_nonnull(webrtc);
 
-    // link vid
-    tee = gst_bin_get_by_name(GST_BIN(pipeline), "videoenctee");
-    g_assert_nonnull(tee);
-    srcpad = gst_element_request_pad_simple(tee, "src_%u");
-    g_assert_nonnull(srcpad);
-    ret = gst_pad_link(srcpad, videoWebrtcbin->sinkpads->data);
-    gst_object_unref(tee);
-    gst_object_unref(srcpad);
-    if (ret != GST_PAD_LINK_OK)
+    GstWebRTCDataChannel *datachannel;
+    GstWebRTCDataChannelState datachannelState;
+    datachannel = GST_WEBRTC_DATA_CHANNEL(g_object_get_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-controls")));
+    g_assert_nonnull(datachannel);
+    g_object_get(datachannel, "ready-state", &datachannelState, NULL);
+    if (datachannelState != GST_WEBRTC_DATA_CHANNEL_STATE_OPEN)
+        returnVal = ERROR_DATACHANNEL_NOT_OPEN;
+    else
+        gst_webrtc_data_channel_send_string(datachannel, message);
+
+    gst_object_unref(webrtc);
+    gst_object_unref(audioWebrtcbin);
+done:
+    g_free(peer_id_aname);
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief Get a datachannel of a peer by its qdata name, which must be unreffed after use
+ * Call with the lock held
+ *
+ * @param peer_id
+ * @param name
+ * @param datachannel
+ * @return ErrorCode
+ */
+static ErrorCode getPeerDatachannel(const char *peer_id, const char *name, GstWebRTCDataChannel **datachannel)
+{
+    ErrorCode returnVal = SUCCESS;
+    char *peer_id_aname;
+    peer_id_aname = g_strdup_printf("a%s", peer_id);
+
+    switch (getPipelineState())
     {
-        returnVal = ERROR_LINKING_PEER;
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+    case READY:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
         goto done;
+    case PLAYING:
+        break;
     }
-    // link aud
-    tee = gst_bin_get_by_name(GST_BIN(pipeline), "audioenctee");
-    g_assert_nonnull(tee);
-    srcpad = gst_element_request_pad_simple(tee, "src_%u");
-    g_assert_nonnull(srcpad);
-    ret = gst_pad_link(srcpad, audioWebrtcbin->sinkpads->data);
-    gst_object_unref(tee);
-    gst_object_unref(srcpad);
-    if (ret != GST_PAD_LINK_OK)
+
+    GstElement *audioWebrtcbin, *webrtc;
+
+    audioWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_aname);
+    if (!GST_IS_ELEMENT(audioWebrtcbin))
     {
-        returnVal = ERROR_LINKING_PEER;
+        returnVal = ERROR_BAD_PEER_ID;
         goto done;
     }
-    // extract webrtcbin from wrapper
-    GstElement *vwebrtcbin = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), "webrtc");
-    g_assert_nonnull(vwebrtcbin);
-    GstElement *awebrtcbin = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "webrtc");
-    g_assert_nonnull(awebrtcbin);
-    // set transceiver settings
-    GArray *transceivers;
-    GstWebRTCRTPSender *sender;
-    GstWebRTCRTPTransceiver *trans;
-    // video
-    g_signal_emit_by_name(vwebrtcbin, "get-transceivers", &transceivers);
-    g_assert(transceivers != NULL && transceivers->len > 0);
+    webrtc = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "webrtc");
+    g_assert_nonnull(webrtc);
 
-    trans = g_array_index(transceivers, GstWebRTCRTPTransceiver *, 0);
-    g_object_set(trans, "direction", GST_WEBRTC_RTP_TRANSCEIVER_DIRECTION_SENDONLY, NULL);
-    g_object_set(trans, "do-nack", TRUE, NULL);
-    g_object_get(trans, "sender", &sender, NULL);
-    gst_webrtc_rtp_sender_set_priority(sender, GST_WEBRTC_PRIORITY_TYPE_HIGH);
-    g_object_unref(sender);
-    g_object_unref(trans);
-    g_array_unref(transceivers);
+    *datachannel = GST_WEBRTC_DATA_CHANNEL(g_object_get_qdata(G_OBJECT(webrtc), g_quark_from_string(name)));
+    g_assert_nonnull(*datachannel);
+    g_object_ref(*datachannel);
+
+    gst_object_unref(webrtc);
+    gst_object_unref(audioWebrtcbin);
+done:
+    g_free(peer_id_aname);
+    return returnVal;
+}
+/**
+ * @brief Send a string message to a peer over its files datachannel
+ * Make sure peer exists when using this
+ *
+ * @param peer_id
+ * @param message
+ * @return ErrorCode
+ */
+ErrorCode SendFilesMessage(const char *peer_id, const char *message)
+{
+    ErrorCode returnVal;
+    lock();
+
+    GstWebRTCDataChannel *datachannel;
+    GstWebRTCDataChannelState datachannelState;
+    returnVal = getPeerDatachannel(peer_id, "datachannel-files", &datachannel);
+    if (returnVal != SUCCESS)
+        goto done;
+    g_object_get(datachannel, "ready-state", &datachannelState, NULL);
+    if (datachannelState != GST_WEBRTC_DATA_CHANNEL_STATE_OPEN)
+        returnVal = ERROR_DATACHANNEL_NOT_OPEN;
+    else
+        gst_webrtc_data_channel_send_string(datachannel, message);
+    g_object_unref(datachannel);
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief Send binary data to a peer over its files datachannel, the data is copied
+ * Make sure peer exists when using this
+ *
+ * @param peer_id
+ * @param data
+ * @param size
+ * @return ErrorCode
+ */
+ErrorCode SendFilesData(const char *peer_id, const void *data, size_t size)
+{
+    ErrorCode returnVal;
+    lock();
 
-    // audio
-    g_signal_emit_by_name(awebrtcbin, "get-transceivers", &transceivers);
-    g_assert(transceivers != NULL && transceivers->len > 0);
+    GstWebRTCDataChannel *datachannel;
+    GstWebRTCDataChannelState datachannelState;
+    returnVal = getPeerDatachannel(peer_id, "datachannel-files", &datachannel);
+    if (returnVal != SUCCESS)
+        goto done;
+    g_object_get(datachannel, "ready-state", &datachannelState, NULL);
+    if (datachannelState != GST_WEBRTC_DATA_CHANNEL_STATE_OPEN)
+        returnVal = ERROR_DATACHANNEL_NOT_OPEN;
+    else
+    {
+        GBytes *bytes = g_bytes_new(data, size);
+        gst_webrtc_data_channel_send_data(datachannel, bytes);
+        g_bytes_unref(bytes);
+    }
+    g_object_unref(datachannel);
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief Get the bytes queued on the files datachannel of a peer and not sent yet
+ * Make sure peer exists when using this
+ *
+ * @param peer_id
+ * @param amount
+ * @return ErrorCode
+ */
+ErrorCode GetFilesBufferedAmount(const char *peer_id, unsigned long long *amount)
+{
+    ErrorCode returnVal;
+    lock();
 
-    trans = g_array_index(transceivers, GstWebRTCRTPTransceiver *, 0);
-    g_object_set(trans, "direction", GST_WEBRTC_RTP_TRANSCEIVER_DIRECTION_SENDONLY, NULL);
-    g_object_get(trans, "sender", &sender, NULL);
-    g_object_set(trans, "fec-type", GST_WEBRTC_FEC_TYPE_ULP_RED, NULL);
-    g_object_set(trans, "fec-percentage", options.audioBasePacketLossPct, NULL);
-    gst_webrtc_rtp_sender_set_priority(sender, GST_WEBRTC_PRIORITY_TYPE_HIGH);
-    g_object_unref(sender);
-    g_object_unref(trans);
-    g_array_unref(transceivers);
-    // add signal handlers
-    g_signal_connect(vwebrtcbin, "notify::connection-state", G_CALLBACK(on_connection_state_change), NULL);
-    g_signal_connect(awebrtcbin, "notify::connection-state", G_CALLBACK(on_connection_state_change), NULL);
-    g_signal_connect(vwebrtcbin, "on-negotiation-needed", G_CALLBACK(on_negotiation_needed), NULL);
-    g_signal_connect(awebrtcbin, "on-negotiation-needed", G_CALLBACK(on_negotiation_needed), NULL);
-    g_signal_connect(vwebrtcbin, "on-ice-candidate", G_CALLBACK(on_ice_candidate), NULL);
-    g_signal_connect(awebrtcbin, "on-ice-candidate", G_CALLBACK(on_ice_candidate), NULL); 
-    
-    // sync states with parent
-    ret = gst_element_sync_state_with_parent(audioWebrtcbin);
-    g_assert_true(ret);
-    ret = gst_element_sync_state_with_parent(videoWebrtcbin);
-    g_assert_true(ret);
-    // datachannel is added to audio webrtcbin after state set to playing
     GstWebRTCDataChannel *datachannel;
-    GstStructure *datachannelSettings;
-    datachannelSettings = gst_structure_new("settings",
-                                            "ordered", G_TYPE_BOOLEAN, TRUE,
-                                            "priority", GST_TYPE_WEBRTC_PRIORITY_TYPE, GST_WEBRTC_PRIORITY_TYPE_HIGH,
-                                            "max-retransmits", G_TYPE_INT, 1,
-                                            NULL);
-    g_signal_emit_by_name(awebrtcbin, "create-data-channel", "controls", datachannelSettings, &datachannel);
-    g_signal_connect(datachannel, "on-message-string", G_CALLBACK(on_datachannel_message_string), NULL);
-    // add a reference since the next function doesn't take ownership
-    g_object_ref(datachannel);
-    // store the datachannel in the webrtcbin
-    g_object_set_qdata_full(G_OBJECT(awebrtcbin), g_quark_from_static_string("datachannel-controls"), datachannel, g_object_unref);
+    guint64 bufferedAmount;
+    returnVal = getPeerDatachannel(peer_id, "datachannel-files", &datachannel);
+    if (returnVal != SUCCESS)
+        goto done;
+    g_object_get(datachannel, "buffered-amount", &bufferedAmount, NULL);
+    *amount = bufferedAmount;
+    g_object_unref(datachannel);
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief Send a string message to a peer over its cursor datachannel
+ * Make sure peer exists when using this
+ *
+ * @param peer_id
+ * @param message
+ * @return ErrorCode
+ */
+ErrorCode SendCursorMessage(const char *peer_id, const char *message)
+{
+    ErrorCode returnVal;
+    lock();
 
-    gst_structure_free(datachannelSettings);
+    GstWebRTCDataChannel *datachannel;
+    GstWebRTCDataChannelState datachannelState;
+    returnVal = getPeerDatachannel(peer_id, "datachannel-cursor", &datachannel);
+    if (returnVal != SUCCESS)
+        goto done;
+    g_object_get(datachannel, "ready-state", &datachannelState, NULL);
+    if (datachannelState != GST_WEBRTC_DATA_CHANNEL_STATE_OPEN)
+        returnVal = ERROR_DATACHANNEL_NOT_OPEN;
+    else
+        gst_webrtc_data_channel_send_string(datachannel, message);
     g_object_unref(datachannel);
-    gst_object_unref(vwebrtcbin);
-    gst_object_unref(awebrtcbin);
-    gst_object_unref(audioWebrtcbin);
-    gst_object_unref(videoWebrtcbin);
 done:
     unlock();
-    g_free(peer_id_aname);
-    g_free(peer_id_vname);
     return returnVal;
 }
 /**
- * @brief Set the remove answer for peer
+ * @brief Mute or unmute the microphone of a peer, before or after its track arrives
  * Make sure peer exists when using this
- * 
+ *
  * @param peer_id
- * @param answer_sdp
+ * @param muted
  * @return ErrorCode
  */
-ErrorCode SetRemoteAnswer(const char *peer_id, const char *answer_sdp)
+ErrorCode SetMicrophoneMuted(const char *peer_id, bool muted)
 {
     ErrorCode returnVal = SUCCESS;
     lock();
 
+    char *peer_id_aname;
+    char *peer_id_mname;
+    peer_id_aname = g_strdup_printf("a%s", peer_id);
+    peer_id_mname = g_strdup_printf("m%s", peer_id);
+
+    if (!options.microphoneEnabled)
+    {
+        returnVal = ERROR_MICROPHONE_DISABLED;
+        goto done;
+    }
     // check if state is valid
     switch (getPipelineState())
     {
@@ -697,60 +2233,51 @@ ErrorCode SetRemoteAnswer(const char *peer_id, const char *answer_sdp)
         break;
     }
 
-    GstSDPMessage *sdp;
-    GstPromise *promise;
-    GstWebRTCSessionDescription *answer;
-    GstElement *webrtcbin, *webrtc;
-    int ret;
+    GstElement *audioWebrtcbin, *micbin;
 
-    // get peer
-    webrtc = gst_bin_get_by_name(GST_BIN(pipeline), peer_id);
-    if (!GST_IS_ELEMENT(webrtc))
+    audioWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_aname);
+    if (!GST_IS_ELEMENT(audioWebrtcbin))
     {
         returnVal = ERROR_BAD_PEER_ID;
         goto done;
     }
-
-    // create an sdp message and parse into it
-    ret = gst_sdp_message_new(&sdp);
-    g_assert_cmphex(ret, ==, GST_SDP_OK);
-    ret = gst_sdp_message_parse_buffer((guint8 *)answer_sdp, strlen(answer_sdp), sdp);
-    if (ret != GST_SDP_OK)
+    // kept on the wrapper bin for a track that arrives later, 0 means unset
+    g_object_set_qdata(G_OBJECT(audioWebrtcbin), g_quark_from_static_string("microphone-muted"), GINT_TO_POINTER(muted ? 2 : 1));
+    micbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_mname);
+    if (micbin != NULL)
     {
-        returnVal = ERROR_BAD_SDP;
-        goto done;
+        GstElement *volume = gst_bin_get_by_name(GST_BIN(micbin), "volume");
+        g_assert_nonnull(volume);
+        g_object_set(volume, "mute", (gboolean)muted, NULL);
+        gst_object_unref(volume);
+        gst_object_unref(micbin);
     }
-    // sdp ownership is transferred
-    answer = gst_webrtc_session_description_new(GST_WEBRTC_SDP_TYPE_ANSWER, sdp);
-    g_warn_if_fail(answer != NULL);
-
-    webrtcbin = gst_bin_get_by_name(GST_BIN(webrtc), "webrtc");
-
-    promise = gst_promise_new();
-    g_signal_emit_by_name(webrtcbin, "set-remote-description", answer, promise);
-    gst_object_unref(webrtcbin);
-    gst_object_unref(webrtc);
-    gst_promise_interrupt(promise);
-    gst_promise_unref(promise);
-    gst_webrtc_session_description_free(answer);
+    gst_object_unref(audioWebrtcbin);
 done:
+    g_free(peer_id_aname);
+    g_free(peer_id_mname);
     unlock();
     return returnVal;
 }
 /**
- * @brief Set the Remote ice candidate for peer
- * Make sure peer exists when using this
+ * @brief Check that the webcam of a peer can be shown on the virtual camera, Go decides what is shown
  *
  * @param peer_id
- * @param mlineindex
- * @param candidate
  * @return ErrorCode
  */
-ErrorCode AddRemoteIceCandidate(const char *peer_id, unsigned int mlineindex, const char *candidate)
+ErrorCode CheckCameraPeer(const char *peer_id)
 {
     ErrorCode returnVal = SUCCESS;
     lock();
 
+    char *peer_id_vname;
+    peer_id_vname = g_strdup_printf("v%s", peer_id);
+
+    if (!options.cameraEnabled)
+    {
+        returnVal = ERROR_CAMERA_DISABLED;
+        goto done;
+    }
     // check if state is valid
     switch (getPipelineState())
     {
@@ -765,40 +2292,314 @@ ErrorCode AddRemoteIceCandidate(const char *peer_id, unsigned int mlineindex, co
         break;
     }
 
-    GstElement *webrtc, *webrtcbin;
+    GstElement *videoWebrtcbin;
 
-    webrtc = gst_bin_get_by_name(GST_BIN(pipeline), peer_id);
-    if (!GST_IS_ELEMENT(webrtc))
+    videoWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_vname);
+    if (!GST_IS_ELEMENT(videoWebrtcbin))
     {
         returnVal = ERROR_BAD_PEER_ID;
         goto done;
     }
-    webrtcbin = gst_bin_get_by_name(GST_BIN(webrtc), "webrtc");
-    g_signal_emit_by_name(webrtcbin, "add-ice-candidate", mlineindex, candidate);
-
-    gst_object_unref(webrtcbin);
-    gst_object_unref(webrtc);
+    gst_object_unref(videoWebrtcbin);
 done:
+    g_free(peer_id_vname);
     unlock();
     return returnVal;
 }
 /**
- * @brief Remove a peer from pipeline and free its resources
- * Make sure peer exists when using this
- *
- * @param peer_id
- * @return ErrorCode
+ * @brief replace a video source with a new one of the same name, peers keep their link to its tee.
+ * The old source is put back when the new one can't be added or started, so the tee is never left without one
+ * 
+ * @param newSource floating, owned by the pipeline afterwards, or freed on failure
+ * @param teeName 
+ * @return ErrorCode 
  */
-ErrorCode RemovePeerFromPipeline(const char *peer_id)
+static ErrorCode replaceVideoSource(GstElement *newSource, const char *teeName)
+{
+    ErrorCode returnVal = SUCCESS;
+    GstElement *oldSource, *videoTee;
+    // our reference keeps the old source alive outside of the pipeline
+    oldSource = gst_bin_get_by_name(GST_BIN(pipeline), GST_ELEMENT_NAME(newSource));
+    g_assert_nonnull(oldSource);
+    videoTee = gst_bin_get_by_name(GST_BIN(pipeline), teeName);
+    g_assert_nonnull(videoTee);
+    // the tee takes one source, and the new one takes the name of the old one, so the old one goes first
+    gst_element_unlink(oldSource, videoTee);
+    g_warn_if_fail(gst_element_set_state(oldSource, GST_STATE_NULL));
+    g_warn_if_fail(gst_bin_remove(GST_BIN(pipeline), oldSource));
+    gst_object_ref_sink(newSource);
+    returnVal = addVideoSource(newSource, teeName);
+    if (returnVal == SUCCESS && !gst_element_sync_state_with_parent(newSource))
+        returnVal = ERROR_PIPELINE_SET_STATE;
+    if (returnVal != SUCCESS)
+    {
+        if (GST_OBJECT_PARENT(newSource) == GST_OBJECT(pipeline))
+        {
+            gst_element_set_state(newSource, GST_STATE_NULL);
+            gst_bin_remove(GST_BIN(pipeline), newSource);
+        }
+        if (!gst_bin_add(GST_BIN(pipeline), oldSource) ||
+            !gst_element_link(oldSource, videoTee) ||
+            !gst_element_sync_state_with_parent(oldSource))
+            g_warning("could not put back video source %s", GST_ELEMENT_NAME(oldSource));
+    }
+    gst_object_unref(newSource);
+    gst_object_unref(oldSource);
+    gst_object_unref(videoTee);
+    return returnVal;
+}
+/**
+ * @brief rebuild the video source from the current options, peers keep their link to the video tee.
+ * The composite is rebuilt along when it draws the screen
+ * 
+ * @param extras rebuild the extra sources and the composite as well, for options they all use
+ * @return ErrorCode 
+ */
+static ErrorCode rebuildVideoSource(bool extras)
+{
+    GstElement *newSource;
+    ErrorCode returnVal = createVideoSource(&newSource);
+    if (returnVal != SUCCESS)
+        return returnVal;
+    returnVal = replaceVideoSource(newSource, "videoenctee");
+    for (unsigned i = 0; extras && i < options.videoSourceCount && returnVal == SUCCESS; i++)
+    {
+        // webcams don't show the cursor
+        if (!videoSourceIsBuilt(i) || options.videoSources[i].webcam)
+            continue;
+        returnVal = createExtraVideoSource(i, &newSource);
+        if (returnVal != SUCCESS)
+            break;
+        gchar *teeName = g_strdup_printf("videoenctee_%s", options.videoSources[i].name);
+        returnVal = replaceVideoSource(newSource, teeName);
+        g_free(teeName);
+    }
+    bool drawsScreen = false;
+    for (unsigned i = 0; i < options.compositeInputCount; i++)
+        drawsScreen = drawsScreen || options.compositeInputs[i].source < 0;
+    if (returnVal == SUCCESS && options.compositeInputCount > 0 && (extras || drawsScreen))
+    {
+        returnVal = createCompositeVideoSource(&newSource);
+        if (returnVal == SUCCESS)
+            returnVal = replaceVideoSource(newSource, "videoenctee_composite");
+    }
+    return returnVal;
+}
+/**
+ * @brief copy the strings of video sources, which the caller's may not outlive
+ * 
+ * @param sources 
+ * @param count 
+ * @return VideoSource* 
+ */
+static VideoSource *copyVideoSources(const VideoSource *sources, unsigned count)
+{
+    VideoSource *copied = g_new0(VideoSource, count);
+    for (unsigned i = 0; i < count; i++)
+    {
+        copied[i] = sources[i];
+        copied[i].name = g_strdup(sources[i].name);
+        copied[i].webcamDevice = g_strdup(sources[i].webcamDevice == NULL ? "" : sources[i].webcamDevice);
+        copied[i].capture = copyCaptureTarget(sources[i].capture);
+    }
+    return copied;
+}
+/**
+ * @brief set the bitrate of the encoder of a built-in video source
+ * 
+ * @param videoSource 
+ * @param bitrate in kbit/s
+ */
+static void setVideoBitrate(GstElement *videoSource, unsigned bitrate)
+{
+    GstElement *videoEncoder = gst_bin_get_by_name(GST_BIN(videoSource), "videoencoder");
+    g_assert_nonnull(videoEncoder);
+    // vp9enc takes bit/s, the others kbit/s
+    if (options.videoEncoder == VP9)
+        g_object_set(videoEncoder, "target-bitrate", (gint)(bitrate * 1000), NULL);
+    else
+        g_object_set(videoEncoder, "bitrate", bitrate, NULL);
+    gst_object_unref(videoEncoder);
+}
+/**
+ * @brief apply the bitrate and framerate to the extra video sources and the composite,
+ * their resolution is their own
+ * 
+ * @param opt 
+ */
+static void updateExtraVideoSources(const PipelineOptions *opt)
+{
+    for (unsigned i = 0; i <= options.videoSourceCount; i++)
+    {
+        gchar *name;
+        if (i < options.videoSourceCount && videoSourceIsBuilt(i))
+            name = g_strdup_printf("videosource_%s", options.videoSources[i].name);
+        else if (i == options.videoSourceCount && options.compositeInputCount > 0)
+            name = g_strdup("videosource_composite");
+        else
+            continue;
+        GstElement *videoSource = gst_bin_get_by_name(GST_BIN(pipeline), name);
+        g_assert_nonnull(videoSource);
+        if (opt->videoBaseBitrate != options.videoBaseBitrate)
+            setVideoBitrate(videoSource, opt->videoBaseBitrate);
+        if (opt->videoBaseFramerate != options.videoBaseFramerate)
+            setCapsfilterCaps(videoSource, "videoframerate", g_strdup_printf("%s,framerate=%u/1", videoRawCaps(options.videoEncoder), opt->videoBaseFramerate));
+        gst_object_unref(videoSource);
+        g_free(name);
+    }
+}
+/**
+ * @brief set the caps of a capsfilter in a video source, downstream renegotiates
+ * 
+ * @param videoSource 
+ * @param name 
+ * @param caps freed
+ */
+static void setCapsfilterCaps(GstElement *videoSource, const char *name, gchar *caps)
+{
+    GstElement *capsfilter = gst_bin_get_by_name(GST_BIN(videoSource), name);
+    g_assert_nonnull(capsfilter);
+    GstCaps *newCaps = gst_caps_from_string(caps);
+    g_object_set(capsfilter, "caps", newCaps, NULL);
+    gst_caps_unref(newCaps);
+    gst_object_unref(capsfilter);
+    g_free(caps);
+}
+/**
+ * @brief apply new settings to the pipeline without dropping peers.
+ * Bitrates, the audio packet loss percentage, the framerate and the resolution are applied live,
+ * showing or hiding the cursor rebuilds the video sources, as does any video change with a template.
+ * The extra video sources and the composite take the bitrate and the framerate, their resolution is their own.
+ * The encoder can't change, the remaining options are only read by SetupPipeline
+ * 
+ * @param opt 
+ * @return ErrorCode 
+ */
+ErrorCode UpdateSettings(PipelineOptions opt)
 {
     ErrorCode returnVal = SUCCESS;
     lock();
+    // check if state is valid
+    switch (getPipelineState())
+    {
+    case NONE:
+        returnVal = ERROR_PIPELINE_DOESNT_EXIST;
+        goto done;
+    case STOPPED:
+        returnVal = ERROR_PIPELINE_BAD_STATE;
+        goto done;
+    case READY:
+    case PLAYING:
+        break;
+    }
+    if (opt.videoEncoder != options.videoEncoder)
+    {
+        returnVal = ERROR_SETTINGS_NOT_UPDATABLE;
+        goto done;
+    }
 
-    char *peer_id_vname;
-    char *peer_id_aname;
-    peer_id_vname = g_strdup_printf("v%s", peer_id);
-    peer_id_aname = g_strdup_printf("a%s", peer_id);
+    // audio
+    GstElement *audioEncoder = gst_bin_get_by_name(GST_BIN(pipeline), "audioencoder");
+    g_assert_nonnull(audioEncoder);
+    g_object_set(audioEncoder,
+                 "bitrate", opt.audioBaseBitrate,
+                 "packet-loss-percentage", opt.audioBasePacketLossPct,
+                 NULL);
+    gst_object_unref(audioEncoder);
+    options.audioBaseBitrate = opt.audioBaseBitrate;
+    options.audioBasePacketLossPct = opt.audioBasePacketLossPct;
+    // before the screen's options change, the cursor is applied when rebuilding below
+    updateExtraVideoSources(&opt);
 
+    // the elements of a template are unknown, it is expanded with the new settings and rebuilt
+    if (options.videoTemplate[0] != '\0')
+    {
+        bool templateChanged = opt.videoTemplate != NULL && g_strcmp0(opt.videoTemplate, options.videoTemplate) != 0;
+        // the extra sources only capture the cursor again when it changed
+        bool cursorChanged = opt.videoShowCursor != options.videoShowCursor;
+        if (!templateChanged && !cursorChanged &&
+            opt.videoBaseBitrate == options.videoBaseBitrate &&
+            opt.videoBaseFramerate == options.videoBaseFramerate &&
+            opt.videoWidth == options.videoWidth &&
+            opt.videoHeight == options.videoHeight)
+            goto done;
+        if (templateChanged)
+        {
+            g_free((gchar *)options.videoTemplate);
+            options.videoTemplate = g_strdup(opt.videoTemplate);
+        }
+        options.videoShowCursor = opt.videoShowCursor;
+        options.videoBaseBitrate = opt.videoBaseBitrate;
+        options.videoBaseFramerate = opt.videoBaseFramerate;
+        options.videoWidth = opt.videoWidth;
+        options.videoHeight = opt.videoHeight;
+        returnVal = rebuildVideoSource(cursorChanged);
+        goto done;
+    }
+    // video, the capture source can't show or hide the cursor once created
+    if (opt.videoShowCursor != options.videoShowCursor)
+    {
+        options.videoShowCursor = opt.videoShowCursor;
+        options.videoBaseBitrate = opt.videoBaseBitrate;
+        options.videoBaseFramerate = opt.videoBaseFramerate;
+        options.videoWidth = opt.videoWidth;
+        options.videoHeight = opt.videoHeight;
+        returnVal = rebuildVideoSource(true);
+        goto done;
+    }
+    GstElement *videoSource = gst_bin_get_by_name(GST_BIN(pipeline), "videosource");
+    g_assert_nonnull(videoSource);
+    if (opt.videoBaseBitrate != options.videoBaseBitrate)
+    {
+        setVideoBitrate(videoSource, opt.videoBaseBitrate);
+        options.videoBaseBitrate = opt.videoBaseBitrate;
+    }
+    if (opt.videoBaseFramerate != options.videoBaseFramerate)
+    {
+        setCapsfilterCaps(videoSource, "videoframerate", g_strdup_printf("%s,framerate=%u/1", videoRawCaps(options.videoEncoder), opt.videoBaseFramerate));
+        options.videoBaseFramerate = opt.videoBaseFramerate;
+    }
+    if (opt.videoWidth != options.videoWidth || opt.videoHeight != options.videoHeight)
+    {
+        setCapsfilterCaps(videoSource, "videosize", g_strdup_printf("%s,width=%u,height=%u", videoRawCaps(options.videoEncoder), opt.videoWidth, opt.videoHeight));
+        options.videoWidth = opt.videoWidth;
+        options.videoHeight = opt.videoHeight;
+    }
+    gst_object_unref(videoSource);
+done:
+    unlock();
+    return returnVal;
+}
+/**
+ * @brief copy the strings of a capture target, which the caller's may not outlive
+ * 
+ * @param target 
+ * @return CaptureTarget free it with freeCaptureTarget
+ */
+static CaptureTarget copyCaptureTarget(CaptureTarget target)
+{
+    target.monitor = g_strdup(target.monitor == NULL ? "" : target.monitor);
+    target.window = g_strdup(target.window == NULL ? "" : target.window);
+    target.process = g_strdup(target.process == NULL ? "" : target.process);
+    return target;
+}
+static void freeCaptureTarget(CaptureTarget target)
+{
+    g_free((gchar *)target.monitor);
+    g_free((gchar *)target.window);
+    g_free((gchar *)target.process);
+}
+/**
+ * @brief switch what the video source captures while peers stay connected, by rebuilding it.
+ * The previous target is kept when the new one can't be captured
+ * 
+ * @param target 
+ * @return ErrorCode 
+ */
+ErrorCode SetCaptureTarget(CaptureTarget target)
+{
+    ErrorCode returnVal = SUCCESS;
+    lock();
     // check if state is valid
     switch (getPipelineState())
     {
@@ -806,101 +2607,135 @@ ErrorCode RemovePeerFromPipeline(const char *peer_id)
         returnVal = ERROR_PIPELINE_DOESNT_EXIST;
         goto done;
     case STOPPED:
-    case READY:
         returnVal = ERROR_PIPELINE_BAD_STATE;
         goto done;
+    case READY:
     case PLAYING:
         break;
     }
-    
-    GstElement *audioWebrtcbin, *videoWebrtcbin;
-
-    videoWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_vname);
-    if (!GST_IS_ELEMENT(videoWebrtcbin))
+    // a template captures by itself
+    if (options.videoTemplate[0] != '\0')
     {
-        returnVal = ERROR_BAD_PEER_ID;
+        returnVal = ERROR_SETTINGS_NOT_UPDATABLE;
         goto done;
     }
-    audioWebrtcbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_aname);
-    if (!GST_IS_ELEMENT(audioWebrtcbin))
+    CaptureTarget previous = options.capture;
+    options.capture = copyCaptureTarget(target);
+    returnVal = rebuildVideoSource(false);
+    if (returnVal != SUCCESS)
     {
-        returnVal = ERROR_BAD_PEER_ID;
+        freeCaptureTarget(options.capture);
+        options.capture = previous;
+        // the failing source was put back, the ones switched before it capture the previous target again
+        if (rebuildVideoSource(false) != SUCCESS)
+            g_warning("could not capture the previous target again");
         goto done;
     }
-
-    GstElement *tee;
-    GstPad *teepad;
-    /* tear down video branch */
-    tee = gst_bin_get_by_name(GST_BIN(pipeline), "videoenctee");
-    g_assert_nonnull(tee);
-    teepad = gst_pad_get_peer(videoWebrtcbin->sinkpads->data);
-    gst_element_release_request_pad(tee, teepad);
-    gst_object_unref(teepad);
-    gst_object_unref(tee);
-
-    /* tear down audio branch */
-    tee = gst_bin_get_by_name(GST_BIN(pipeline), "audioenctee");
-    g_assert_nonnull(tee);
-    teepad = gst_pad_get_peer(audioWebrtcbin->sinkpads->data);
-    gst_element_release_request_pad(tee, teepad);
-    gst_object_unref(teepad);
-    gst_object_unref(tee);
-
-
-    // remove webrtcbin from webrtc wrappers
-    GstElement *webrtc;
-
-    // video
-    webrtc = gst_bin_get_by_name(GST_BIN(videoWebrtcbin), "webrtc");
-    g_assert_nonnull(webrtc);
-    // remove signal handlers
-    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_connection_state_change), NULL);
-    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_negotiation_needed), NULL);
-    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_ice_candidate), NULL);
-    // remove bin
-    g_warn_if_fail(gst_element_set_state(webrtc, GST_STATE_NULL));
-    // also unrefs
-    g_warn_if_fail(gst_bin_remove(GST_BIN(videoWebrtcbin), webrtc));
-    // gst_object_unref(webrtc);
-
-    // audio
-    webrtc = gst_bin_get_by_name(GST_BIN(audioWebrtcbin), "webrtc");
-    g_assert_nonnull(webrtc);
-    // remove signal handlers
-    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_connection_state_change), NULL);
-    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_negotiation_needed), NULL);
-    g_signal_handlers_disconnect_by_func(webrtc, G_CALLBACK(on_ice_candidate), NULL);
-     // stop datachannel(s)
-    GstWebRTCDataChannel* datachannel;
-    datachannel = GST_WEBRTC_DATA_CHANNEL(g_object_get_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-controls")));
-    g_assert_nonnull(datachannel);
-    g_signal_handlers_disconnect_by_func(datachannel, G_CALLBACK(on_datachannel_message_string), NULL);
-    gst_webrtc_data_channel_close(datachannel);
-    gst_object_unref(datachannel);
-    // free the datachannel object, since set_qdata_full was used freeing is done automatically
-    g_object_set_qdata(G_OBJECT(webrtc), g_quark_from_static_string("datachannel-controls"), NULL);
-    // remove bin
-    g_warn_if_fail(gst_element_set_state(webrtc, GST_STATE_NULL));
-    // also unrefs
-    g_warn_if_fail(gst_bin_remove(GST_BIN(audioWebrtcbin), webrtc));
-    // gst_object_unref(webrtc);
-
-    // set states to null
-    g_warn_if_fail(gst_element_set_state(videoWebrtcbin, GST_STATE_NULL));
-    g_warn_if_fail(gst_element_set_state(audioWebrtcbin, GST_STATE_NULL));
-
-    // remove elements from pipeline, also unrefs
-    g_warn_if_fail(gst_bin_remove(GST_BIN(pipeline), videoWebrtcbin));
-    g_warn_if_fail(gst_bin_remove(GST_BIN(pipeline), audioWebrtcbin));
-    // gst_object_unref(audioWebrtcbin);
-    // gst_object_unref(videoWebrtcbin);
-
+    freeCaptureTarget(previous);
 done:
-    g_free(peer_id_vname);
-    g_free(peer_id_aname);
     unlock();
     return returnVal;
 }
+/**
+ * @brief check that an encoder works on this host: its elements are installed and it encodes test frames.
+ * Hardware encoders are installed on machines without the hardware, only the test encode finds out
+ * 
+ * @param encoder 
+ * @return ErrorCode ERROR_ENCODER_NOT_SUPPORTED when it doesn't, with the reason in TakeLastErrorMessage
+ */
+ErrorCode ProbeVideoEncoder(VideoEncoder encoder)
+{
+    gst_init(NULL, NULL);
+    ErrorCode returnVal = SUCCESS;
+    char *encodeLine = NULL;
+    char *probeString = NULL;
+    GstElement *probe = NULL;
+    GstBus *bus = NULL;
+    GstMessage *message = NULL;
+    GError *error = NULL;
+    // the same lines as the video source, fed by test frames instead of the screen
+    PipelineOptions probeOptions = {0};
+    probeOptions.videoEncoder = encoder;
+    probeOptions.videoBaseBitrate = 1000;
+    probeOptions.videoBaseFramerate = 30;
+    probeOptions.videoWidth = 320;
+    probeOptions.videoHeight = 240;
+    returnVal = createVideoEncodeLine(&probeOptions, &encodeLine);
+    if (returnVal != SUCCESS)
+        goto done;
+    probeString = g_strdup_printf(""
+                                  "videotestsrc num-buffers=%d ! "
+                                  "video/x-raw,format=I420,width=%u,height=%u,framerate=%u/1 ! "
+                                  // the converter of the hardware encoder takes frames in gpu memory
+                                  "%s"
+                                  "%s ! "
+                                  "fakesink",
+                                  ENCODER_PROBE_FRAMES,
+                                  probeOptions.videoWidth,
+                                  probeOptions.videoHeight,
+                                  probeOptions.videoBaseFramerate,
+                                  encoder == NVH264 ? "d3d11upload ! " : "",
+                                  encodeLine);
+    // a missing element is a parse error naming it
+    probe = gst_parse_launch(probeString, &error);
+    if (probe != NULL)
+        gst_object_ref_sink(probe);
+    if (error)
+    {
+        setLastErrorMessage(error->message);
+        g_error_free(error);
+        returnVal = ERROR_ENCODER_NOT_SUPPORTED;
+        goto done;
+    }
+    gst_element_set_state(probe, GST_STATE_PLAYING);
+    bus = gst_element_get_bus(probe);
+    message = gst_bus_timed_pop_filtered(bus, ENCODER_PROBE_TIMEOUT_US * GST_USECOND, GST_MESSAGE_EOS | GST_MESSAGE_ERROR);
+    if (message == NULL)
+    {
+        setLastErrorMessage("timed out encoding test frames");
+        returnVal = ERROR_ENCODER_NOT_SUPPORTED;
+    }
+    else if (GST_MESSAGE_TYPE(message) == GST_MESSAGE_ERROR)
+    {
+        gst_message_parse_error(message, &error, NULL);
+        setLastErrorMessage(error->message);
+        g_error_free(error);
+        returnVal = ERROR_ENCODER_NOT_SUPPORTED;
+    }
+    gst_element_set_state(probe, GST_STATE_NULL);
+done:
+    if (message != NULL)
+        gst_message_unref(message);
+    if (bus != NULL)
+        gst_object_unref(bus);
+    if (probe != NULL)
+        gst_object_unref(probe);
+    g_free(probeString);
+    g_free(encodeLine);
+    return returnVal;
+}
+/**
+ * @brief take the GStreamer text of the last failed call, like a parse error naming a missing element
+ * 
+ * @return char* NULL if there is none, free it with FreeString
+ */
+char *TakeLastErrorMessage()
+{
+    g_mutex_lock(&errorMutex);
+    gchar *message = lastErrorMessage;
+    lastErrorMessage = NULL;
+    g_mutex_unlock(&errorMutex);
+    return message;
+}
+/**
+ * @brief free a string returned by this file
+ * 
+ * @param s 
+ */
+void FreeString(char *s)
+{
+    g_free(s);
+}
 // === Callbacks and event handlers ===
 /**
  * @brief callback for messages on the pipeline bus
@@ -1095,4 +2930,322 @@ static void on_datachannel_message_string(GstWebRTCDataChannel G_GNUC_UNUSED *dc
     gchar *peerId = gst_element_get_name(parent);
     got_client_datachannel_message_cb(peerId, msg);
     g_free(peerId);
+}
+/**
+ * @brief callback to notify Go that a user datachannel is open and can be sent to
+ * 
+ * @param dc 
+ * @param none 
+ */
+static void on_datachannel_open(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-controls")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_datachannel_opened_cb(peerId);
+    g_free(peerId);
+}
+/**
+ * @brief callback to notify Go that a user datachannel was closed
+ * 
+ * @param dc 
+ * @param none 
+ */
+static void on_datachannel_close(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-controls")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_datachannel_closed_cb(peerId);
+    g_free(peerId);
+}
+/**
+ * @brief callback to send file transfer messages to Go
+ * 
+ * @param dc 
+ * @param msg 
+ * @param none 
+ */
+static void on_files_message_string(GstWebRTCDataChannel *dc, gchar *msg, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-files")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_files_message_cb(peerId, msg);
+    g_free(peerId);
+}
+/**
+ * @brief callback to send file chunks to Go
+ * 
+ * @param dc 
+ * @param data 
+ * @param none 
+ */
+static void on_files_message_data(GstWebRTCDataChannel *dc, GBytes *data, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-files")));
+    gchar *peerId = gst_element_get_name(parent);
+    gsize size;
+    gconstpointer bytes = g_bytes_get_data(data, &size);
+    got_client_files_data_cb(peerId, (void *)bytes, size);
+    g_free(peerId);
+}
+/**
+ * @brief callback to notify Go that a files datachannel is open and can be sent to
+ * 
+ * @param dc 
+ * @param none 
+ */
+static void on_files_open(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-files")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_files_opened_cb(peerId);
+    g_free(peerId);
+}
+/**
+ * @brief callback to notify Go that a files datachannel was closed
+ * 
+ * @param dc 
+ * @param none 
+ */
+static void on_files_close(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-files")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_files_closed_cb(peerId);
+    g_free(peerId);
+}
+/**
+ * @brief callback to notify Go that a files datachannel has room for more data
+ * 
+ * @param dc 
+ * @param none 
+ */
+static void on_files_buffered_amount_low(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-files")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_files_buffered_amount_low_cb(peerId);
+    g_free(peerId);
+}
+/**
+ * @brief callback to notify Go that a cursor datachannel is open and can be sent to
+ * 
+ * @param dc 
+ * @param none 
+ */
+static void on_cursor_open(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-cursor")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_cursor_opened_cb(peerId);
+    g_free(peerId);
+}
+/**
+ * @brief callback to notify Go that a cursor datachannel was closed
+ * 
+ * @param dc 
+ * @param none 
+ */
+static void on_cursor_close(GstWebRTCDataChannel *dc, G_GNUC_UNUSED gpointer none)
+{
+    GstElement *parent = GST_ELEMENT(g_object_get_qdata(G_OBJECT(dc), g_quark_from_static_string("datachannel-cursor")));
+    gchar *peerId = gst_element_get_name(parent);
+    got_client_cursor_closed_cb(peerId);
+    g_free(peerId);
+}
+/**
+ * @brief callback to decode the microphone track of a peer and mix it into the microphone output
+ * The global lock isn't taken, since removing a peer holds it while stopping the thread this runs on
+ * 
+ * @param webrtc 
+ * @param pad 
+ * @param none 
+ */
+static void on_microphone_pad_added(GstElement *webrtc, GstPad *pad, G_GNUC_UNUSED gpointer none)
+{
+    if (GST_PAD_DIRECTION(pad) != GST_PAD_SRC)
+        return;
+    GstElement *audioWebrtcbin = GST_ELEMENT(gst_element_get_parent(webrtc));
+    g_assert_nonnull(audioWebrtcbin);
+    gchar *peerId = gst_element_get_name(audioWebrtcbin);
+    // the wrapper bin is named a<peer_id>, the microphone bin m<peer_id>
+    gchar *peer_id_mname = g_strdup_printf("m%s", peerId + 1);
+    GstElement *micbin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_mname);
+    // renegotiation may add the pad again
+    if (micbin != NULL)
+    {
+        gst_object_unref(micbin);
+        goto done;
+    }
+    micbin = gst_parse_bin_from_description(""
+                                            "rtpopusdepay ! "
+                                            "opusdec plc=true use-inband-fec=true ! "
+                                            "audioconvert ! audioresample ! "
+                                            "audio/x-raw,rate=48000,channels=2 ! "
+                                            "volume name=volume ! "
+                                            "queue leaky=downstream silent=true max-size-buffers=0 "
+                                            "max-size-bytes=0 max-size-time=200000000",
+                                            TRUE, NULL);
+    if (micbin == NULL)
+    {
+        g_warning("could not create the microphone branch of %s", peerId);
+        goto done;
+    }
+    gst_element_set_name(micbin, peer_id_mname);
+    gpointer muted = g_object_get_qdata(G_OBJECT(audioWebrtcbin), g_quark_from_static_string("microphone-muted"));
+    GstElement *volume = gst_bin_get_by_name(GST_BIN(micbin), "volume");
+    g_assert_nonnull(volume);
+    g_object_set(volume, "mute", muted == NULL ? (gboolean)options.microphoneStartMuted : GPOINTER_TO_INT(muted) == 2, NULL);
+    gst_object_unref(volume);
+    // the webrtcbin pad is only reachable from the pipeline through its wrapper bin
+    GstPad *ghost = gst_ghost_pad_new("microphone", pad);
+    gst_pad_set_active(ghost, TRUE);
+    g_warn_if_fail(gst_element_add_pad(audioWebrtcbin, ghost));
+    // ownership is transferred to parent
+    g_warn_if_fail(gst_bin_add(GST_BIN(pipeline), micbin));
+    GstElement *mixer = gst_bin_get_by_name(GST_BIN(pipeline), "micmixer");
+    g_assert_nonnull(mixer);
+    GstPad *mixerpad = gst_element_request_pad_simple(mixer, "sink_%u");
+    g_assert_nonnull(mixerpad);
+    if (gst_pad_link(ghost, micbin->sinkpads->data) != GST_PAD_LINK_OK ||
+        gst_pad_link(micbin->srcpads->data, mixerpad) != GST_PAD_LINK_OK)
+        g_warning("could not link the microphone branch of %s", peerId);
+    gst_object_unref(mixerpad);
+    gst_object_unref(mixer);
+    g_warn_if_fail(gst_element_sync_state_with_parent(micbin));
+done:
+    g_free(peer_id_mname);
+    g_free(peerId);
+    gst_object_unref(audioWebrtcbin);
+}
+/**
+ * @brief callback to decode the webcam track of a peer and offer it to the virtual camera
+ * The global lock isn't taken, since removing a peer holds it while stopping the thread this runs on
+ * 
+ * @param webrtc 
+ * @param pad 
+ * @param none 
+ */
+static void on_camera_pad_added(GstElement *webrtc, GstPad *pad, G_GNUC_UNUSED gpointer none)
+{
+    if (GST_PAD_DIRECTION(pad) != GST_PAD_SRC)
+        return;
+    GstElement *videoWebrtcbin = GST_ELEMENT(gst_element_get_parent(webrtc));
+    g_assert_nonnull(videoWebrtcbin);
+    gchar *peerId = gst_element_get_name(videoWebrtcbin);
+    // the wrapper bin is named v<peer_id>, the camera bin c<peer_id>
+    gchar *peer_id_cname = g_strdup_printf("c%s", peerId + 1);
+    gchar *cameraLine = NULL;
+    GstElement *camerabin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_cname);
+    // renegotiation may add the pad again
+    if (camerabin != NULL)
+    {
+        gst_object_unref(camerabin);
+        goto done;
+    }
+    // whatever the browser sends is scaled and retimed to what the camera was set up with
+    cameraLine = g_strdup_printf(""
+                                 "rtpvp8depay ! "
+                                 "vp8dec ! "
+                                 "videoconvert ! videoscale add-borders=true ! videorate ! "
+                                 "video/x-raw,format=I420,width=%u,height=%u,framerate=%u/1,pixel-aspect-ratio=1/1 ! "
+                                 "queue leaky=downstream silent=true max-size-buffers=2 "
+                                 "max-size-bytes=0 max-size-time=0",
+                                 options.cameraWidth,
+                                 options.cameraHeight,
+                                 options.cameraFramerate);
+    camerabin = gst_parse_bin_from_description(cameraLine, TRUE, NULL);
+    if (camerabin == NULL)
+    {
+        g_warning("could not create the camera branch of %s", peerId);
+        goto done;
+    }
+    gst_element_set_name(camerabin, peer_id_cname);
+    // the webrtcbin pad is only reachable from the pipeline through its wrapper bin
+    GstPad *ghost = gst_ghost_pad_new("camera", pad);
+    gst_pad_set_active(ghost, TRUE);
+    g_warn_if_fail(gst_element_add_pad(videoWebrtcbin, ghost));
+    // ownership is transferred to parent
+    g_warn_if_fail(gst_bin_add(GST_BIN(pipeline), camerabin));
+    GstPad *selectorpad = gst_element_request_pad_simple(cameraSelector, "sink_%u");
+    g_assert_nonnull(selectorpad);
+    if (gst_pad_link(ghost, camerabin->sinkpads->data) != GST_PAD_LINK_OK ||
+        gst_pad_link(camerabin->srcpads->data, selectorpad) != GST_PAD_LINK_OK)
+        g_warning("could not link the camera branch of %s", peerId);
+    CameraFrameReport *report = g_new0(CameraFrameReport, 1);
+    report->peerId = g_strdup(peerId + 1);
+    gst_pad_add_probe(selectorpad, GST_PAD_PROBE_TYPE_BUFFER, on_camera_frame, report, freeCameraFrameReport);
+    gst_object_unref(selectorpad);
+    g_warn_if_fail(gst_element_sync_state_with_parent(camerabin));
+done:
+    g_free(cameraLine);
+    g_free(peer_id_cname);
+    g_free(peerId);
+    gst_object_unref(videoWebrtcbin);
+}
+/**
+ * @brief probe telling Go that a peer sends frames of its webcam, at most every CAMERA_CALLBACK_INTERVAL_US
+ * 
+ * @param pad 
+ * @param info 
+ * @param report CameraFrameReport of the peer
+ * @return GstPadProbeReturn 
+ */
+static GstPadProbeReturn on_camera_frame(G_GNUC_UNUSED GstPad *pad, G_GNUC_UNUSED GstPadProbeInfo *info, gpointer report)
+{
+    CameraFrameReport *r = report;
+    gint64 now = g_get_monotonic_time();
+    if (now - r->lastReported >= CAMERA_CALLBACK_INTERVAL_US)
+    {
+        r->lastReported = now;
+        got_camera_frame_cb(r->peerId);
+    }
+    return GST_PAD_PROBE_OK;
+}
+static void freeCameraFrameReport(gpointer report)
+{
+    g_free(((CameraFrameReport *)report)->peerId);
+    g_free(report);
+}
+/**
+ * @brief probe switching the virtual camera between the enabled peer and the placeholder,
+ * on placeholder frames at most every CAMERA_CALLBACK_INTERVAL_US
+ * 
+ * @param pad 
+ * @param info 
+ * @param none 
+ * @return GstPadProbeReturn 
+ */
+static GstPadProbeReturn on_camera_placeholder_frame(GstPad *pad, G_GNUC_UNUSED GstPadProbeInfo *info, G_GNUC_UNUSED gpointer none)
+{
+    gint64 now = g_get_monotonic_time();
+    if (now - cameraLastShownCheck < CAMERA_CALLBACK_INTERVAL_US)
+        return GST_PAD_PROBE_OK;
+    cameraLastShownCheck = now;
+    GstPad *target = NULL;
+    char *peerId = get_camera_shown_cb();
+    g_mutex_lock(&cameraMutex);
+    if (peerId != NULL)
+    {
+        gchar *peer_id_cname = g_strdup_printf("c%s", peerId);
+        GstElement *camerabin = gst_bin_get_by_name(GST_BIN(pipeline), peer_id_cname);
+        // NULL while the branch is being removed
+        if (camerabin != NULL)
+        {
+            target = gst_pad_get_peer(camerabin->srcpads->data);
+            gst_object_unref(camerabin);
+        }
+        g_free(peer_id_cname);
+        free(peerId);
+    }
+    if (target == NULL)
+        target = gst_object_ref(pad);
+    GstPad *active;
+    g_object_get(cameraSelector, "active-pad", &active, NULL);
+    if (active != target)
+        g_object_set(cameraSelector, "active-pad", target, NULL);
+    g_mutex_unlock(&cameraMutex);
+    if (active != NULL)
+        gst_object_unref(active);
+    gst_object_unref(target);
+    return GST_PAD_PROBE_OK;
 }
\ No newline at end of file
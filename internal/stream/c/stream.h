@@ -2,6 +2,7 @@
 #define STREAM_H
 #define GST_USE_UNSTABLE_API
 #include <stdbool.h>
+#include <stddef.h>
 
 typedef enum
 {
@@ -15,8 +16,30 @@ typedef enum
     ERROR_BAD_PEER_ID,
     ERROR_PIPELINE_DOESNT_EXIST,
     ERROR_BAD_SDP,
+    ERROR_DATACHANNEL_NOT_OPEN,
+    ERROR_MICROPHONE_DISABLED,
+    ERROR_CAMERA_DISABLED,
+    ERROR_CAMERA_NOT_SUPPORTED,
+    ERROR_SETTINGS_NOT_UPDATABLE,
+    ERROR_BAD_ENCODER_PROPERTY,
+    ERROR_TEMPLATE_BAD_CAPS,
+    ERROR_CAPTURE_TARGET_NOT_FOUND,
+    ERROR_CAPTURE_TARGET_NOT_SUPPORTED,
+    ERROR_BAD_VIDEO_SOURCE,
 } ErrorCode;
 
+// ProbeVideoEncoder encodes this many test frames, and gives up on an encoder after this long in microseconds
+#define ENCODER_PROBE_FRAMES 10
+#define ENCODER_PROBE_TIMEOUT_US 5000000
+
+// extra video sources besides the screen and the composite, and the payload types of their tracks.
+// The screen is sent with payload type 123, the composite after the last source
+#define MAX_VIDEO_SOURCES 8
+#define VIDEO_SOURCE_PAYLOAD_BASE 110
+
+// the files datachannel signals Go when its buffered amount falls to this many bytes
+#define FILES_BUFFERED_AMOUNT_LOW 262144
+
 typedef enum
 {
     STOPPED = 0,
@@ -32,6 +55,47 @@ typedef enum
     NVH264
 } VideoEncoder;
 
+// what the screen capture records, the primary monitor when everything is empty
+typedef struct
+{
+    // index like 1 or device name like \\.\DISPLAY2
+    const char *monitor;
+    // the first application window whose title contains this, instead of a monitor
+    const char *window;
+    // the first application window of this executable, like notepad.exe, instead of a monitor
+    const char *process;
+    // part of the monitor or window, all of it when the width or height is 0
+    unsigned x;
+    unsigned y;
+    unsigned width;
+    unsigned height;
+} CaptureTarget;
+
+// a video peers can subscribe to next to the screen, encoded like it at its framerate and bitrate
+typedef struct
+{
+    // lowercase letters and digits, the stream id of its track
+    const char *name;
+    // capture a webcam instead of the capture target
+    bool webcam;
+    // webcam index or device name, empty for the default webcam
+    const char *webcamDevice;
+    CaptureTarget capture;
+    unsigned width;
+    unsigned height;
+} VideoSource;
+
+// where the composite draws one of its sources
+typedef struct
+{
+    // index into PipelineOptions.videoSources, -1 for the screen's capture target
+    int source;
+    unsigned x;
+    unsigned y;
+    unsigned width;
+    unsigned height;
+} CompositeInput;
+
 typedef struct
 {
     // bitrate in bit/s
@@ -44,6 +108,35 @@ typedef struct
     unsigned videoHeight;
     unsigned videoWidth;
     bool videoShowCursor;
+    CaptureTarget capture;
+    // the encoder tuning of the profile and its overrides, name=value lines with values in gst-launch syntax
+    const char *videoEncoderProperties;
+    // capture, convert and encode elements in gst-launch syntax replacing the built-in ones, empty for those.
+    // Its encoded caps must match videoEncoder, which the peers' payloaders are made for
+    const char *videoTemplate;
+    // more videos, a webcam drawn in the composite is only sent as part of it since it can be opened once
+    const VideoSource *videoSources;
+    unsigned videoSourceCount;
+    // sources drawn into one video named composite, none for no composite
+    const CompositeInput *compositeInputs;
+    unsigned compositeInputCount;
+    unsigned compositeWidth;
+    unsigned compositeHeight;
+    // play a microphone track from each peer on the host
+    bool microphoneEnabled;
+    // jitter buffer in ms
+    unsigned microphoneLatency;
+    // whether peers start muted until SetMicrophoneMuted
+    bool microphoneStartMuted;
+    // output device id, empty for the default device
+    const char *microphoneDevice;
+    // write the webcam of one peer at a time to a virtual camera on the host
+    bool cameraEnabled;
+    // sink of the virtual camera driver as in a pipeline description, it gets raw video
+    const char *cameraSink;
+    unsigned cameraWidth;
+    unsigned cameraHeight;
+    unsigned cameraFramerate;
 } PipelineOptions;
 
 // callbacks defined in Go
@@ -51,15 +144,40 @@ extern void got_gstreamer_pipeline_error_cb(char *from, char *message);
 extern void got_server_offer_sdp_cb(char *peerId, char *offer);
 extern void got_server_ice_candidate_cb(char *peerId, unsigned int mlineindex, char *candidate);
 extern void got_client_datachannel_message_cb(char *peerId, char *message);
+extern void got_client_datachannel_opened_cb(char *peerId);
+extern void got_client_datachannel_closed_cb(char *peerId);
+extern void got_client_files_message_cb(char *peerId, char *message);
+extern void got_client_files_data_cb(char *peerId, void *data, size_t size);
+extern void got_client_files_opened_cb(char *peerId);
+extern void got_client_files_closed_cb(char *peerId);
+extern void got_client_files_buffered_amount_low_cb(char *peerId);
+extern void got_client_cursor_opened_cb(char *peerId);
+extern void got_client_cursor_closed_cb(char *peerId);
 extern void got_webrtc_connection_disconnected_cb(char *peerId);
+extern void got_camera_frame_cb(char *peerId);
+// the peer whose webcam the virtual camera shows, NULL for the placeholder. Free it with free
+extern char *get_camera_shown_cb();
 
 // globally accessible - managed by C
 ErrorCode SetupPipeline(PipelineOptions opt);
 ErrorCode StartPipeline();
 ErrorCode StopPipeline();
 ErrorCode AddPeerToPipeline(const char *peer_id);
+ErrorCode AddPeerWithVideoSources(const char *peer_id, const char **sources, unsigned count);
 ErrorCode SetRemoteAnswer(const char *peer_id, const char *answer_sdp);
 ErrorCode AddRemoteIceCandidate(const char *peer_id, unsigned int mlineindex, const char *candidate);
 ErrorCode RemovePeerFromPipeline(const char *peer_id);
+ErrorCode SendControlsMessage(const char *peer_id, const char *message);
+ErrorCode SendFilesMessage(const char *peer_id, const char *message);
+ErrorCode SendFilesData(const char *peer_id, const void *data, size_t size);
+ErrorCode GetFilesBufferedAmount(const char *peer_id, unsigned long long *amount);
+ErrorCode SendCursorMessage(const char *peer_id, const char *message);
+ErrorCode SetMicrophoneMuted(const char *peer_id, bool muted);
+ErrorCode CheckCameraPeer(const char *peer_id);
+ErrorCode UpdateSettings(PipelineOptions opt);
+ErrorCode SetCaptureTarget(CaptureTarget target);
+ErrorCode ProbeVideoEncoder(VideoEncoder encoder);
+char *TakeLastErrorMessage();
+void FreeString(char *s);
 
 #endif
\ No newline at end of file
@@ -1,3 +1,5 @@
+//go:build windows
+
 package stream
 
 /*
@@ -9,7 +11,11 @@ package stream
 import "C"
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"time"
+	"unsafe"
 
 	pkgerrors "github.com/benu-cloud/benu-errors"
 	"github.com/benu-cloud/benu-message/message"
@@ -97,10 +103,181 @@ func got_server_ice_candidate_cb(peerId *C.char, mlineindex C.uint, candidate *C
 
 //export got_client_datachannel_message_cb
 func got_client_datachannel_message_cb(peerId *C.char, message *C.char) {
-	fmt.Println(4)
+	if checkStreamInstance() != nil {
+		return
+	}
+	// controls datachannels live on the audio webrtcbin
+	pid := C.GoString(peerId)[1:]
+	instance.mutex.Lock()
+	handler := instance.controlsHandler
+	instance.mutex.Unlock()
+	if handler != nil {
+		handler.OnControlsMessage(pid, C.GoString(message))
+	}
+}
+
+//export got_client_datachannel_opened_cb
+func got_client_datachannel_opened_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	instance.mutex.Lock()
+	handler := instance.controlsHandler
+	instance.mutex.Unlock()
+	if handler != nil {
+		handler.OnControlsOpened(pid)
+	}
+}
+
+//export got_client_datachannel_closed_cb
+func got_client_datachannel_closed_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	if !datachannelClosed(pid, "controls") {
+		return
+	}
+	instance.mutex.Lock()
+	handler := instance.controlsHandler
+	instance.mutex.Unlock()
+	if handler != nil {
+		handler.OnControlsClosed(pid)
+	}
+}
+
+func getFilesHandler() FilesHandler {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	return instance.filesHandler
+}
+
+//export got_client_files_message_cb
+func got_client_files_message_cb(peerId *C.char, message *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	// files datachannels live on the audio webrtcbin too
+	pid := C.GoString(peerId)[1:]
+	if handler := getFilesHandler(); handler != nil {
+		handler.OnFilesMessage(pid, C.GoString(message))
+	}
+}
+
+//export got_client_files_data_cb
+func got_client_files_data_cb(peerId *C.char, data unsafe.Pointer, size C.size_t) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	if handler := getFilesHandler(); handler != nil {
+		handler.OnFilesData(pid, C.GoBytes(data, C.int(size)))
+	}
+}
+
+//export got_client_files_opened_cb
+func got_client_files_opened_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	if handler := getFilesHandler(); handler != nil {
+		handler.OnFilesOpened(pid)
+	}
+}
+
+//export got_client_files_closed_cb
+func got_client_files_closed_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	if !datachannelClosed(pid, "files") {
+		return
+	}
+	if handler := getFilesHandler(); handler != nil {
+		handler.OnFilesClosed(pid)
+	}
+}
+
+//export got_client_files_buffered_amount_low_cb
+func got_client_files_buffered_amount_low_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	if handler := getFilesHandler(); handler != nil {
+		handler.OnFilesBufferedAmountLow(pid)
+	}
+}
+
+func getCursorHandler() CursorHandler {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	return instance.cursorHandler
+}
+
+//export got_client_cursor_opened_cb
+func got_client_cursor_opened_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	if handler := getCursorHandler(); handler != nil {
+		handler.OnCursorOpened(pid)
+	}
+}
+
+//export got_client_cursor_closed_cb
+func got_client_cursor_closed_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	pid := C.GoString(peerId)[1:]
+	if !datachannelClosed(pid, "cursor") {
+		return
+	}
+	if handler := getCursorHandler(); handler != nil {
+		handler.OnCursorClosed(pid)
+	}
+}
+
+//export got_camera_frame_cb
+func got_camera_frame_cb(peerId *C.char) {
+	if checkStreamInstance() != nil {
+		return
+	}
+	instance.camera.frame(C.GoString(peerId), time.Now())
+}
+
+// the C code frees the returned peer id
+//
+//export get_camera_shown_cb
+func get_camera_shown_cb() *C.char {
+	if checkStreamInstance() != nil {
+		return nil
+	}
+	peerId := instance.camera.shown(time.Now())
+	if peerId == "" {
+		return nil
+	}
+	return C.CString(peerId)
 }
 
 //export got_webrtc_connection_disconnected_cb
 func got_webrtc_connection_disconnected_cb(peerId *C.char) {
-	fmt.Println(5)
+	if checkStreamInstance() != nil {
+		return
+	}
+	// called by the audio and the video webrtcbin, whichever is first removes the peer.
+	// The pipeline is locked during the callback, so it is removed once it returns
+	pid := C.GoString(peerId)[1:]
+	go func() {
+		// a StreamError when the other webrtcbin already removed it
+		var streamErr *pkgerrors.StreamError
+		if err := RemovePeerFromPipeline(pid); err != nil && !errors.As(err, &streamErr) {
+			log.Printf("removing disconnected peer '%s': %v", pid, err)
+		}
+	}()
 }
@@ -0,0 +1,55 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// the virtual camera shows the placeholder when the enabled peer sent no frame for this long
+const cameraTimeout = 500 * time.Millisecond
+
+// camera decides what the virtual camera shows: the webcam of the enabled peer
+// while its frames keep arriving, the placeholder otherwise
+type camera struct {
+	mutex   sync.Mutex
+	timeout time.Duration
+	// empty if no peer is enabled
+	enabled string
+	// when the enabled peer last sent a frame, zero if it sent none since it was enabled
+	lastFrame time.Time
+}
+
+func newCamera(timeout time.Duration) *camera {
+	return &camera{timeout: timeout}
+}
+
+// setEnabled shows the webcam of a peer, replacing the peer shown before, or stops showing it
+func (c *camera) setEnabled(peerId string, enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if enabled {
+		c.enabled = peerId
+		c.lastFrame = time.Time{}
+	} else if c.enabled == peerId {
+		c.enabled = ""
+	}
+}
+
+// frame notes a frame of the webcam of a peer
+func (c *camera) frame(peerId string, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if peerId != "" && peerId == c.enabled {
+		c.lastFrame = now
+	}
+}
+
+// shown returns the peer whose webcam is shown at now, empty for the placeholder
+func (c *camera) shown(now time.Time) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.enabled == "" || c.lastFrame.IsZero() || now.Sub(c.lastFrame) >= c.timeout {
+		return ""
+	}
+	return c.enabled
+}
@@ -0,0 +1,44 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCamera(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+	c := newCamera(500 * time.Millisecond)
+	assert.Equal(t, "", c.shown(at(0)))
+	// an enabled peer is shown once its frames arrive
+	c.setEnabled("a", true)
+	assert.Equal(t, "", c.shown(at(0)))
+	c.frame("a", at(10))
+	assert.Equal(t, "a", c.shown(at(20)))
+	// and the placeholder once they stop
+	assert.Equal(t, "", c.shown(at(510)))
+	c.frame("a", at(600))
+	assert.Equal(t, "a", c.shown(at(610)))
+	// frames of other peers don't keep it shown
+	c.frame("b", at(1000))
+	assert.Equal(t, "", c.shown(at(1200)))
+	// enabling another peer replaces it, frames sent before don't count
+	c.frame("a", at(1300))
+	c.setEnabled("b", true)
+	assert.Equal(t, "", c.shown(at(1310)))
+	c.frame("a", at(1320))
+	assert.Equal(t, "", c.shown(at(1330)))
+	c.frame("b", at(1340))
+	assert.Equal(t, "b", c.shown(at(1350)))
+	// disabling a peer that isn't shown changes nothing
+	c.setEnabled("a", false)
+	assert.Equal(t, "b", c.shown(at(1360)))
+	c.setEnabled("b", false)
+	assert.Equal(t, "", c.shown(at(1370)))
+	c.frame("b", at(1380))
+	assert.Equal(t, "", c.shown(at(1390)))
+}
@@ -0,0 +1,54 @@
+package stream
+
+import (
+	"errors"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/internal/config"
+)
+
+// settingsPipeline is what changing the settings of the running pipeline needs from the C code
+type settingsPipeline interface {
+	setCaptureTarget(target *config.CaptureTarget) error
+	update(settings *config.StreamSettings, videoTemplate string) error
+}
+
+// applySettings changes the pipeline from the current settings to new ones and returns the settings it runs with afterwards.
+// Everything that can be checked is checked before the pipeline is touched, and the capture target is
+// switched back if switching it or applying the rest fails, so the current settings are returned on failure
+func applySettings(p settingsPipeline, current config.StreamSettings, settings config.StreamSettings) (config.StreamSettings, error) {
+	if err := settings.Validate(); err != nil {
+		return current, err
+	}
+	if settings.VideoEncoder == config.Auto {
+		// resolved by SetupPipeline
+		settings.VideoEncoder = current.VideoEncoder
+	}
+	// the template is expanded again with the new settings
+	template, err := settings.ExpandVideoTemplate()
+	if err != nil {
+		return current, pkgerrors.NewStreamError(err)
+	}
+	captureChanged := settings.Capture != current.Capture
+	if captureChanged {
+		if err := p.setCaptureTarget(&settings.Capture); err != nil {
+			// it may have switched some sources before failing
+			if restoreErr := p.setCaptureTarget(&current.Capture); restoreErr != nil {
+				return current, errors.Join(err, restoreErr)
+			}
+			return current, err
+		}
+	}
+	if err := p.update(&settings, template); err != nil {
+		if !captureChanged {
+			return current, err
+		}
+		if restoreErr := p.setCaptureTarget(&current.Capture); restoreErr != nil {
+			// the new target is captured
+			current.Capture = settings.Capture
+			return current, errors.Join(err, restoreErr)
+		}
+		return current, err
+	}
+	return settings, nil
+}
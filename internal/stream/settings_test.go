@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// records the capture targets it switches to, failing the calls it is told to
+type fakePipeline struct {
+	captured    []config.CaptureTarget
+	captureErrs []error
+	updated     []config.StreamSettings
+	updateErr   error
+}
+
+func (f *fakePipeline) setCaptureTarget(target *config.CaptureTarget) error {
+	var err error
+	if len(f.captureErrs) > 0 {
+		err, f.captureErrs = f.captureErrs[0], f.captureErrs[1:]
+	}
+	if err == nil {
+		f.captured = append(f.captured, *target)
+	}
+	return err
+}
+
+func (f *fakePipeline) update(settings *config.StreamSettings, videoTemplate string) error {
+	if f.updateErr != nil {
+		return f.updateErr
+	}
+	f.updated = append(f.updated, *settings)
+	return nil
+}
+
+func testSettings(t *testing.T) config.StreamSettings {
+	cfg, err := config.Load([]string{"-vresolution", "1920x1080", "-rmqusername", "user", "-rmqpassword", "password"}, nil)
+	assert.NoError(t, err)
+	return cfg.Stream
+}
+
+func TestApplySettings(t *testing.T) {
+	current := testSettings(t)
+	settings := current
+	settings.Capture = config.CaptureTarget{Window: "Notepad"}
+	settings.VideoBaseBitrate = 2000
+	p := &fakePipeline{}
+	applied, err := applySettings(p, current, settings)
+	assert.NoError(t, err)
+	assert.Equal(t, settings, applied)
+	assert.Equal(t, []config.CaptureTarget{settings.Capture}, p.captured)
+	assert.Len(t, p.updated, 1)
+
+	// invalid settings don't touch the pipeline
+	p = &fakePipeline{}
+	invalid := settings
+	invalid.VideoBaseFramerate = 0
+	applied, err = applySettings(p, current, invalid)
+	assert.Error(t, err)
+	assert.Equal(t, current, applied)
+	assert.Empty(t, p.captured)
+	assert.Empty(t, p.updated)
+}
+
+func TestApplySettingsRestoresCapture(t *testing.T) {
+	current := testSettings(t)
+	settings := current
+	settings.Capture = config.CaptureTarget{Window: "Notepad"}
+	settings.VideoBaseBitrate = 2000
+	updateErr := errors.New("update failed")
+	// the previous target is captured again when the rest fails
+	p := &fakePipeline{updateErr: updateErr}
+	applied, err := applySettings(p, current, settings)
+	assert.Equal(t, updateErr, err)
+	assert.Equal(t, current, applied)
+	assert.Equal(t, []config.CaptureTarget{settings.Capture, current.Capture}, p.captured)
+
+	// and the new one is reported as captured if that fails too
+	restoreErr := errors.New("restore failed")
+	p = &fakePipeline{updateErr: updateErr, captureErrs: []error{nil, restoreErr}}
+	applied, err = applySettings(p, current, settings)
+	assert.ErrorIs(t, err, updateErr)
+	assert.ErrorIs(t, err, restoreErr)
+	assert.Equal(t, settings.Capture, applied.Capture)
+	assert.Equal(t, current.VideoBaseBitrate, applied.VideoBaseBitrate)
+
+	// a target that can't be captured changes nothing, the previous one is captured again
+	captureErr := errors.New("no such window")
+	p = &fakePipeline{captureErrs: []error{captureErr}}
+	applied, err = applySettings(p, current, settings)
+	assert.Equal(t, captureErr, err)
+	assert.Equal(t, current, applied)
+	assert.Equal(t, []config.CaptureTarget{current.Capture}, p.captured)
+	assert.Empty(t, p.updated)
+
+	// both failures are reported when that fails too
+	p = &fakePipeline{captureErrs: []error{captureErr, restoreErr}}
+	applied, err = applySettings(p, current, settings)
+	assert.ErrorIs(t, err, captureErr)
+	assert.ErrorIs(t, err, restoreErr)
+	assert.Equal(t, current, applied)
+	assert.Empty(t, p.updated)
+}
@@ -1,9 +1,12 @@
+//go:build windows
+
 package stream
 
 /*
 #cgo pkg-config: gstreamer-1.0 gstreamer-webrtc-1.0 gstreamer-sdp-1.0 gstreamer-rtp-1.0
 #cgo CFLAGS: -I${SRCDIR}/c
 #cgo LDFLAGS: -L${SRCDIR}/c -lstream
+#include <stdlib.h>
 #include "stream.h"
 */
 import "C"
@@ -11,11 +14,15 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"sync"
+	"unsafe"
 
 	pkgerrors "github.com/benu-cloud/benu-errors"
 	"github.com/benu-cloud/benu-message/message"
 	"github.com/benu-cloud/benu-webrtc/internal/config"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
 )
 
 type peer struct {
@@ -23,12 +30,86 @@ type peer struct {
 	serverSessionDescriptions chan *message.SessionDescriptionPayload
 	serverIceCandidates       chan *message.IceCandidatePayload
 	serverDatachannelMessages chan *message.GenericPayload
+	// datachannels whose handlers were told they closed, guarded by the stream mutex
+	closed map[string]bool
+}
+
+// closing marks a datachannel of the peer closed and reports whether it wasn't already,
+// so its handler is told once, by the datachannel or when the peer is removed.
+// The stream mutex must be held
+func (p *peer) closing(channel string) bool {
+	if p.closed[channel] {
+		return false
+	}
+	p.closed[channel] = true
+	return true
+}
+
+// datachannelClosed is closing for a peer of the stream, false once the peer is removed
+func datachannelClosed(peerId string, channel string) bool {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	for _, p := range instance.users {
+		if p.peer_id == peerId {
+			return p.closing(channel)
+		}
+	}
+	return false
+}
+
+// PeersHandler is told about the peers joining the stream, their datachannel handlers
+// are told once they leave, when the datachannels close
+type PeersHandler interface {
+	// called once a peer is added to the pipeline, before its datachannels open
+	OnPeerAdded(peerId string)
+}
+
+// ControlsHandler receives what peers send over their controls datachannel
+type ControlsHandler interface {
+	// called once the datachannel of a peer is open and messages can be sent to it
+	OnControlsOpened(peerId string)
+	// called for every message a peer sends
+	OnControlsMessage(peerId string, message string)
+	// called once when the datachannel of a peer is closed or the peer is removed, whichever comes first
+	OnControlsClosed(peerId string)
+}
+
+// FilesHandler receives what peers send over their files datachannel
+type FilesHandler interface {
+	// called once the datachannel of a peer is open and messages can be sent to it
+	OnFilesOpened(peerId string)
+	// called for every string message a peer sends
+	OnFilesMessage(peerId string, message string)
+	// called for every binary message a peer sends, data is only valid during the call
+	OnFilesData(peerId string, data []byte)
+	// called when the buffered amount of the datachannel falls below its low threshold
+	OnFilesBufferedAmountLow(peerId string)
+	// called once when the datachannel of a peer is closed or the peer is removed, whichever comes first
+	OnFilesClosed(peerId string)
+}
+
+// CursorHandler is told when a peer's cursor datachannel can be sent to
+type CursorHandler interface {
+	// called once the datachannel of a peer is open and messages can be sent to it
+	OnCursorOpened(peerId string)
+	// called once when the datachannel of a peer is closed or the peer is removed, whichever comes first
+	OnCursorClosed(peerId string)
 }
 
 type stream struct {
 	mutex                 sync.Mutex
 	users                 []*peer
 	serverGStreamerErrors chan error
+	peersHandler          PeersHandler
+	controlsHandler       ControlsHandler
+	filesHandler          FilesHandler
+	cursorHandler         CursorHandler
+	// what the pipeline runs with, changed by UpdateSettings. Guarded apart from
+	// mutex, which callbacks take while the pipeline is locked
+	settingsMutex sync.Mutex
+	settings      config.StreamSettings
+	// what the virtual camera shows
+	camera *camera
 }
 
 var instance *stream = nil
@@ -48,7 +129,197 @@ func SetupPipeline(settings *config.StreamSettings) (<-chan error, error) {
 	if checkStreamInstance() == nil {
 		return nil, pkgerrors.NewStreamError(errors.New("pipeline setup function should be run only once"))
 	}
-	options := C.PipelineOptions{
+	microphoneDevice := C.CString(settings.MicrophoneDevice)
+	defer C.free(unsafe.Pointer(microphoneDevice))
+	cameraSink := C.CString(settings.CameraSink)
+	defer C.free(unsafe.Pointer(cameraSink))
+	encoder, err := resolveVideoEncoder(settings)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *settings
+	resolved.VideoEncoder = encoder
+	encoderProperties := C.CString(strings.Join(resolved.EncoderProperties(encoder), "\n"))
+	defer C.free(unsafe.Pointer(encoderProperties))
+	template, err := resolved.ExpandVideoTemplate()
+	if err != nil {
+		return nil, pkgerrors.NewStreamError(err)
+	}
+	videoTemplate := C.CString(template)
+	defer C.free(unsafe.Pointer(videoTemplate))
+	options := pipelineOptions(&resolved)
+	options.microphoneDevice = microphoneDevice
+	options.cameraSink = cameraSink
+	options.videoEncoderProperties = encoderProperties
+	options.videoTemplate = videoTemplate
+	capture, freeCapture := captureTarget(&resolved.Capture)
+	defer freeCapture()
+	options.capture = capture
+	sources, inputs, freeSources := videoSources(&resolved)
+	defer freeSources()
+	if len(sources) > 0 {
+		options.videoSources = &sources[0]
+		options.videoSourceCount = (C.uint)(len(sources))
+	}
+	if len(inputs) > 0 {
+		composite := resolved.CompositeResolution()
+		options.compositeInputs = &inputs[0]
+		options.compositeInputCount = (C.uint)(len(inputs))
+		options.compositeWidth = (C.uint)(composite.Width)
+		options.compositeHeight = (C.uint)(composite.Height)
+	}
+	result := C.SetupPipeline(options)
+	if result != C.SUCCESS {
+		return nil, cStreamError(result)
+	}
+	instance = &stream{
+		users:                 make([]*peer, 0),
+		serverGStreamerErrors: make(chan error),
+		settings:              resolved,
+		camera:                newCamera(cameraTimeout),
+	}
+	return instance.serverGStreamerErrors, nil
+}
+
+// cStreamError converts an error code of the C code, with the GStreamer error text when there is one
+func cStreamError(result C.ErrorCode) error {
+	message := C.TakeLastErrorMessage()
+	if message == nil {
+		return pkgerrors.NewCStreamError(int(result))
+	}
+	defer C.FreeString(message)
+	return &pkgerrors.CStreamError{
+		ErrorCode:    int(result),
+		ErrorMessage: C.GoString(message),
+	}
+}
+
+// captureTarget converts the target, call free once C is done with it
+func captureTarget(target *config.CaptureTarget) (capture C.CaptureTarget, free func()) {
+	capture = C.CaptureTarget{
+		monitor: C.CString(target.Monitor),
+		window:  C.CString(target.Window),
+		process: C.CString(target.Process),
+		x:       (C.uint)(target.Region.X),
+		y:       (C.uint)(target.Region.Y),
+		width:   (C.uint)(target.Region.Width),
+		height:  (C.uint)(target.Region.Height),
+	}
+	return capture, func() {
+		C.free(unsafe.Pointer(capture.monitor))
+		C.free(unsafe.Pointer(capture.window))
+		C.free(unsafe.Pointer(capture.process))
+	}
+}
+
+// videoSources converts the extra video sources and where the composite draws them, call free once C is done with them
+func videoSources(settings *config.StreamSettings) (sources []C.VideoSource, inputs []C.CompositeInput, free func()) {
+	frees := make([]func(), 0, len(settings.VideoSources))
+	sources = make([]C.VideoSource, len(settings.VideoSources))
+	for i, v := range settings.VideoSources {
+		var target config.CaptureTarget
+		switch v.Kind {
+		case config.MonitorSource:
+			target.Monitor = v.Target
+		case config.WindowSource:
+			target.Window = v.Target
+		case config.ProcessSource:
+			target.Process = v.Target
+		}
+		capture, freeCapture := captureTarget(&target)
+		resolution := settings.SourceResolution(v)
+		sources[i] = C.VideoSource{
+			name:         C.CString(v.Name),
+			webcam:       (C.bool)(v.Kind == config.WebcamSource),
+			webcamDevice: C.CString(v.Target),
+			capture:      capture,
+			width:        (C.uint)(resolution.Width),
+			height:       (C.uint)(resolution.Height),
+		}
+		source := &sources[i]
+		frees = append(frees, func() {
+			C.free(unsafe.Pointer(source.name))
+			C.free(unsafe.Pointer(source.webcamDevice))
+			freeCapture()
+		})
+	}
+	regions := settings.CompositeRegions()
+	inputs = make([]C.CompositeInput, len(regions))
+	for i, name := range settings.Composite.Sources {
+		inputs[i] = C.CompositeInput{
+			source: -1,
+			x:      (C.uint)(regions[i].X),
+			y:      (C.uint)(regions[i].Y),
+			width:  (C.uint)(regions[i].Width),
+			height: (C.uint)(regions[i].Height),
+		}
+		for j, v := range settings.VideoSources {
+			if v.Name == name {
+				inputs[i].source = (C.int)(j)
+			}
+		}
+	}
+	return sources, inputs, func() {
+		for _, free := range frees {
+			free()
+		}
+	}
+}
+
+// SetCaptureTarget switches what is captured while peers stay connected,
+// the previous target is kept when the new one can't be captured
+func SetCaptureTarget(target config.CaptureTarget) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	instance.settingsMutex.Lock()
+	defer instance.settingsMutex.Unlock()
+	settings := instance.settings
+	settings.Capture = target
+	return updateSettings(&settings)
+}
+
+// ProbeVideoEncoder returns why an encoder doesn't work on this host, nil if it does.
+// It encodes a few test frames, which takes a moment
+func ProbeVideoEncoder(encoder config.VideoEncoder) error {
+	result := C.ProbeVideoEncoder((C.VideoEncoder)(encoder))
+	if result != C.SUCCESS {
+		return cStreamError(result)
+	}
+	return nil
+}
+
+// ProbeVideoEncoders lists the encoders that work on this host, see ProbeVideoEncoder
+func ProbeVideoEncoders() []config.VideoEncoder {
+	usable := make([]config.VideoEncoder, 0)
+	for _, encoder := range []config.VideoEncoder{config.VP9, config.H264, config.NVH264} {
+		if ProbeVideoEncoder(encoder) == nil {
+			usable = append(usable, encoder)
+		}
+	}
+	return usable
+}
+
+// resolveVideoEncoder picks the first encoder of the preference order that works for auto,
+// logging why the ones before it don't
+func resolveVideoEncoder(settings *config.StreamSettings) (config.VideoEncoder, error) {
+	if settings.VideoEncoder != config.Auto {
+		return settings.VideoEncoder, nil
+	}
+	for _, encoder := range settings.VideoEncoderOrder {
+		if err := ProbeVideoEncoder(encoder); err != nil {
+			log.Printf("video encoder %s doesn't work here: %v\n", encoder.String(), err)
+			continue
+		}
+		log.Println("using video encoder", encoder.String())
+		return encoder, nil
+	}
+	return config.Auto, pkgerrors.NewStreamError(fmt.Errorf("none of the video encoders %s work here", settings.VideoEncoderOrder.String()))
+}
+
+// pipelineOptions converts the settings, the strings are left for the caller to set and free
+func pipelineOptions(settings *config.StreamSettings) C.PipelineOptions {
+	return C.PipelineOptions{
 		audioBaseBitrate:       (C.uint)(settings.AudioBaseBitrate),
 		audioBasePacketLossPct: (C.uint)(settings.AudioBasePacketLossPct),
 		videoBaseBitrate:       (C.uint)(settings.VideoBaseBitrate),
@@ -56,17 +327,121 @@ func SetupPipeline(settings *config.StreamSettings) (<-chan error, error) {
 		videoEncoder:           (C.VideoEncoder)(settings.VideoEncoder),
 		videoHeight:            (C.uint)(settings.VideoResolution.Height),
 		videoWidth:             (C.uint)(settings.VideoResolution.Width),
-		videoShowCursor:        (C.bool)(settings.VideoShowCursor),
+		// clients draw the cursor themselves, it mustn't show twice
+		videoShowCursor:      (C.bool)(settings.CaptureCursor()),
+		microphoneEnabled:    (C.bool)(settings.MicrophoneEnabled),
+		microphoneLatency:    (C.uint)(settings.MicrophoneLatency),
+		microphoneStartMuted: (C.bool)(settings.MicrophoneStartMuted),
+		cameraEnabled:        (C.bool)(settings.CameraEnabled),
+		cameraWidth:          (C.uint)(settings.CameraResolution.Width),
+		cameraHeight:         (C.uint)(settings.CameraResolution.Height),
+		cameraFramerate:      (C.uint)(settings.CameraFramerate),
 	}
-	result := C.SetupPipeline(options)
+}
+
+// UpdateSettings applies new settings to the running pipeline while peers stay connected.
+// Bitrates, the audio packet loss percentage, the framerate, the resolution, the cursor and the capture target can change,
+// the encoder can't and the remaining settings are only used by SetupPipeline
+func UpdateSettings(settings *config.StreamSettings) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	instance.settingsMutex.Lock()
+	defer instance.settingsMutex.Unlock()
+	return updateSettings(settings)
+}
+
+// ApplySettingsUpdate changes the fields set in the update, see UpdateSettings
+func ApplySettingsUpdate(update types.StreamSettingsUpdate) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	instance.settingsMutex.Lock()
+	defer instance.settingsMutex.Unlock()
+	settings := instance.settings.Update(update)
+	return updateSettings(&settings)
+}
+
+// Settings returns the settings the pipeline runs with, after the updates that were applied
+func Settings() (config.StreamSettings, error) {
+	if err := checkStreamInstance(); err != nil {
+		return config.StreamSettings{}, err
+	}
+	instance.settingsMutex.Lock()
+	defer instance.settingsMutex.Unlock()
+	return instance.settings, nil
+}
+
+// the settings mutex must be held
+func updateSettings(settings *config.StreamSettings) error {
+	applied, err := applySettings(cPipeline{}, instance.settings, *settings)
+	instance.settings = applied
+	return err
+}
+
+// cPipeline changes the settings of the pipeline of the C code
+type cPipeline struct{}
+
+func (cPipeline) setCaptureTarget(target *config.CaptureTarget) error {
+	capture, freeCapture := captureTarget(target)
+	defer freeCapture()
+	result := C.SetCaptureTarget(capture)
 	if result != C.SUCCESS {
-		return nil, pkgerrors.NewCStreamError(int(result))
+		return cStreamError(result)
 	}
-	instance = &stream{
-		users:                 make([]*peer, 0),
-		serverGStreamerErrors: make(chan error),
+	return nil
+}
+
+func (cPipeline) update(settings *config.StreamSettings, videoTemplate string) error {
+	template := C.CString(videoTemplate)
+	defer C.free(unsafe.Pointer(template))
+	options := pipelineOptions(settings)
+	options.videoTemplate = template
+	result := C.UpdateSettings(options)
+	if result != C.SUCCESS {
+		return cStreamError(result)
 	}
-	return instance.serverGStreamerErrors, nil
+	return nil
+}
+
+func SetPeersHandler(handler PeersHandler) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.peersHandler = handler
+	return nil
+}
+
+func SetControlsHandler(handler ControlsHandler) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.controlsHandler = handler
+	return nil
+}
+
+func SetFilesHandler(handler FilesHandler) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.filesHandler = handler
+	return nil
+}
+
+func SetCursorHandler(handler CursorHandler) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+	instance.cursorHandler = handler
+	return nil
 }
 
 func StartPipeline() error {
@@ -75,7 +450,7 @@ func StartPipeline() error {
 	}
 	result := C.StartPipeline()
 	if result != C.SUCCESS {
-		return pkgerrors.NewCStreamError(int(result))
+		return cStreamError(result)
 	}
 	return nil
 }
@@ -86,15 +461,36 @@ func StopPipeline() error {
 	}
 	result := C.StopPipeline()
 	if result != C.SUCCESS {
-		return pkgerrors.NewCStreamError(int(result))
+		return cStreamError(result)
 	}
 	return nil
 }
 
 func AddPeerToPipeline(peerId string) (<-chan *message.SessionDescriptionPayload, <-chan *message.IceCandidatePayload, error) {
+	return addPeer(peerId, nil)
+}
+
+// AddPeerWithVideoSources adds a peer receiving a video track for each source in their order, with the source's name as stream id.
+// The sources are those of config.StreamSettings.SubscribableSources
+func AddPeerWithVideoSources(peerId string, sources []string) (<-chan *message.SessionDescriptionPayload, <-chan *message.IceCandidatePayload, error) {
+	if sources == nil {
+		sources = []string{}
+	}
+	return addPeer(peerId, sources)
+}
+
+// addPeer adds a peer receiving the sources, nil for the screen only
+func addPeer(peerId string, sources []string) (<-chan *message.SessionDescriptionPayload, <-chan *message.IceCandidatePayload, error) {
 	if err := checkStreamInstance(); err != nil {
 		return nil, nil, err
 	}
+	// notify the handler after the mutex is unlocked, before the datachannels can open
+	var handler PeersHandler
+	defer func() {
+		if handler != nil {
+			handler.OnPeerAdded(peerId)
+		}
+	}()
 	instance.mutex.Lock()
 	defer instance.mutex.Unlock()
 	// check if peer already exists
@@ -108,12 +504,28 @@ func AddPeerToPipeline(peerId string) (<-chan *message.SessionDescriptionPayload
 		serverSessionDescriptions: make(chan *message.SessionDescriptionPayload),
 		serverIceCandidates:       make(chan *message.IceCandidatePayload),
 		serverDatachannelMessages: make(chan *message.GenericPayload),
+		closed:                    make(map[string]bool),
+	}
+	var result C.ErrorCode
+	if sources == nil {
+		result = C.AddPeerToPipeline(C.CString(peerId))
+	} else {
+		names := make([]*C.char, len(sources))
+		for i, source := range sources {
+			names[i] = C.CString(source)
+			defer C.free(unsafe.Pointer(names[i]))
+		}
+		var first **C.char
+		if len(names) > 0 {
+			first = &names[0]
+		}
+		result = C.AddPeerWithVideoSources(C.CString(peerId), first, (C.uint)(len(names)))
 	}
-	result := C.AddPeerToPipeline(C.CString(peerId))
 	if result != C.SUCCESS {
-		return nil, nil, pkgerrors.NewCStreamError(int(result))
+		return nil, nil, cStreamError(result)
 	}
 	instance.users = append(instance.users, peer)
+	handler = instance.peersHandler
 	return peer.serverSessionDescriptions, peer.serverIceCandidates, nil
 }
 
@@ -121,6 +533,21 @@ func RemovePeerFromPipeline(peerId string) error {
 	if err := checkStreamInstance(); err != nil {
 		return err
 	}
+	// notify the handlers after the mutex is unlocked
+	var handler ControlsHandler
+	var filesHandler FilesHandler
+	var cursorHandler CursorHandler
+	defer func() {
+		if handler != nil {
+			handler.OnControlsClosed(peerId)
+		}
+		if filesHandler != nil {
+			filesHandler.OnFilesClosed(peerId)
+		}
+		if cursorHandler != nil {
+			cursorHandler.OnCursorClosed(peerId)
+		}
+	}()
 	instance.mutex.Lock()
 	defer instance.mutex.Unlock()
 	// check if peer exists
@@ -134,15 +561,27 @@ func RemovePeerFromPipeline(peerId string) error {
 	if index == -1 {
 		return pkgerrors.NewStreamError(fmt.Errorf("no peer to remove with id '%s'", peerId))
 	}
+	// the camera switches to the placeholder before the peer's webcam is torn down
+	instance.camera.setEnabled(peerId, false)
 	result := C.RemovePeerFromPipeline(C.CString(peerId))
 	if result != C.SUCCESS {
-		return pkgerrors.NewCStreamError(int(result))
+		return cStreamError(result)
 	}
 	// close channels
 	close(instance.users[index].serverIceCandidates)
 	close(instance.users[index].serverSessionDescriptions)
-	// remove
+	// remove, telling the handlers of the datachannels that didn't close by themselves
+	removed := instance.users[index]
 	instance.users = append(instance.users[:index], instance.users[index+1:]...)
+	if removed.closing("controls") {
+		handler = instance.controlsHandler
+	}
+	if removed.closing("files") {
+		filesHandler = instance.filesHandler
+	}
+	if removed.closing("cursor") {
+		cursorHandler = instance.cursorHandler
+	}
 	return nil
 }
 
@@ -152,18 +591,139 @@ func SetRemoteAnswer(peerId string, answer string) error {
 	}
 	result := C.SetRemoteAnswer(C.CString(peerId), C.CString(answer))
 	if result != C.SUCCESS {
-		return pkgerrors.NewCStreamError(int(result))
+		return cStreamError(result)
 	}
 	return nil
 }
 
+func SendControlsMessage(peerId string, message string) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	cPeerId := C.CString(peerId)
+	defer C.free(unsafe.Pointer(cPeerId))
+	cMessage := C.CString(message)
+	defer C.free(unsafe.Pointer(cMessage))
+	result := C.SendControlsMessage(cPeerId, cMessage)
+	if result != C.SUCCESS {
+		return cStreamError(result)
+	}
+	return nil
+}
+
+func SendFilesMessage(peerId string, message string) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	cPeerId := C.CString(peerId)
+	defer C.free(unsafe.Pointer(cPeerId))
+	cMessage := C.CString(message)
+	defer C.free(unsafe.Pointer(cMessage))
+	result := C.SendFilesMessage(cPeerId, cMessage)
+	if result != C.SUCCESS {
+		return cStreamError(result)
+	}
+	return nil
+}
+
+func SendFilesData(peerId string, data []byte) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	cPeerId := C.CString(peerId)
+	defer C.free(unsafe.Pointer(cPeerId))
+	cData := C.CBytes(data)
+	defer C.free(cData)
+	result := C.SendFilesData(cPeerId, cData, C.size_t(len(data)))
+	if result != C.SUCCESS {
+		return cStreamError(result)
+	}
+	return nil
+}
+
+// FilesBufferedAmount is the bytes queued on the files datachannel of a peer and not sent yet
+func FilesBufferedAmount(peerId string) (uint64, error) {
+	if err := checkStreamInstance(); err != nil {
+		return 0, err
+	}
+	cPeerId := C.CString(peerId)
+	defer C.free(unsafe.Pointer(cPeerId))
+	var amount C.ulonglong
+	result := C.GetFilesBufferedAmount(cPeerId, &amount)
+	if result != C.SUCCESS {
+		return 0, cStreamError(result)
+	}
+	return uint64(amount), nil
+}
+
+func SendCursorMessage(peerId string, message string) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	cPeerId := C.CString(peerId)
+	defer C.free(unsafe.Pointer(cPeerId))
+	cMessage := C.CString(message)
+	defer C.free(unsafe.Pointer(cMessage))
+	result := C.SendCursorMessage(cPeerId, cMessage)
+	if result != C.SUCCESS {
+		return cStreamError(result)
+	}
+	return nil
+}
+
+// SetMicrophoneMuted mutes or unmutes what a peer says into the host microphone,
+// it may be called before the peer's track arrives
+func SetMicrophoneMuted(peerId string, muted bool) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	cPeerId := C.CString(peerId)
+	defer C.free(unsafe.Pointer(cPeerId))
+	result := C.SetMicrophoneMuted(cPeerId, C.bool(muted))
+	if result != C.SUCCESS {
+		return cStreamError(result)
+	}
+	return nil
+}
+
+// SetCameraEnabled shows the webcam of a peer on the virtual camera, replacing the peer shown before,
+// or stops showing it. It may be called before the peer's track arrives
+func SetCameraEnabled(peerId string, enabled bool) error {
+	if err := checkStreamInstance(); err != nil {
+		return err
+	}
+	cPeerId := C.CString(peerId)
+	defer C.free(unsafe.Pointer(cPeerId))
+	result := C.CheckCameraPeer(cPeerId)
+	if result != C.SUCCESS {
+		return cStreamError(result)
+	}
+	instance.camera.setEnabled(peerId, enabled)
+	return nil
+}
+
+// FilesChannel sends over the files datachannels of the stream
+type FilesChannel struct{}
+
+func (FilesChannel) SendMessage(peerId string, message string) error {
+	return SendFilesMessage(peerId, message)
+}
+
+func (FilesChannel) SendData(peerId string, data []byte) error {
+	return SendFilesData(peerId, data)
+}
+
+func (FilesChannel) BufferedAmount(peerId string) (uint64, error) {
+	return FilesBufferedAmount(peerId)
+}
+
 func AddRemoteIceCandidate(peerId string, mlineindex uint, candidate string) error {
 	if err := checkStreamInstance(); err != nil {
 		return err
 	}
 	result := C.AddRemoteIceCandidate(C.CString(peerId), C.uint(mlineindex), C.CString(candidate))
 	if result != C.SUCCESS {
-		return pkgerrors.NewCStreamError(int(result))
+		return cStreamError(result)
 	}
 	return nil
 }
@@ -0,0 +1,16 @@
+package clipboard
+
+import "github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+
+type Clipboard interface {
+	Read() (types.ClipboardContent, error)
+	// replace the clipboard, backends that can't hold text and an image at once keep the image
+	Write(content types.ClipboardContent) error
+}
+
+// SequenceClipboard is implemented by clipboards that count their changes,
+// so they can be watched without reading them
+type SequenceClipboard interface {
+	Clipboard
+	Sequence() (uint64, error)
+}
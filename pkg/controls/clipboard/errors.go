@@ -0,0 +1,20 @@
+package clipboard
+
+import "fmt"
+
+// ClipboardTooLargeError indicates clipboard content over the size limit
+type ClipboardTooLargeError struct {
+	Size    int
+	MaxSize int
+}
+
+func (e *ClipboardTooLargeError) Error() string {
+	return fmt.Sprintf("ClipboardTooLargeError: %d bytes, the limit is %d", e.Size, e.MaxSize)
+}
+
+func NewClipboardTooLargeError(size int, maxSize int) error {
+	return &ClipboardTooLargeError{
+		Size:    size,
+		MaxSize: maxSize,
+	}
+}
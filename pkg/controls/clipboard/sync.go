@@ -0,0 +1,156 @@
+package clipboard
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// Sync keeps the host clipboard and the clients' in step.
+// Host changes are found by polling, and content over the size limit never crosses:
+// an image is dropped first, then the whole change
+type Sync struct {
+	clipboard Clipboard
+	// zero means no limit
+	maxSize  int
+	interval time.Duration
+	mutex    sync.Mutex
+	// how the host clipboard was last seen, to find changes
+	sequence    uint64
+	fingerprint [sha256.Size]byte
+	stop        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewSync syncs c, looking for host changes every interval
+func NewSync(c Clipboard, maxSize int, interval time.Duration) *Sync {
+	return &Sync{
+		clipboard: c,
+		maxSize:   maxSize,
+		interval:  interval,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Watch calls onChange with the host clipboard each time it changes on the host, until Close.
+// Changes made with Set aren't reported back
+func (s *Sync) Watch(onChange func(content types.ClipboardContent)) {
+	// what is there already isn't a change
+	s.poll()
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				content, changed, err := s.poll()
+				// content too large or unreadable is skipped, the next change may do better
+				if err == nil && changed && !content.Empty() {
+					onChange(content)
+				}
+			}
+		}
+	}()
+}
+
+// Get reads the host clipboard, within the size limit
+func (s *Sync) Get() (types.ClipboardContent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	content, err := s.clipboard.Read()
+	if err != nil {
+		return types.ClipboardContent{}, err
+	}
+	return s.limit(content)
+}
+
+// Set writes content from a client to the host clipboard
+func (s *Sync) Set(content types.ClipboardContent) error {
+	if s.maxSize > 0 && content.Size() > s.maxSize {
+		return NewClipboardTooLargeError(content.Size(), s.maxSize)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.clipboard.Write(content); err != nil {
+		return err
+	}
+	// so the change isn't sent back to the clients
+	s.seen()
+	return nil
+}
+
+// Close stops watching
+func (s *Sync) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+}
+
+// reads the host clipboard if it changed since it was last seen
+func (s *Sync) poll() (types.ClipboardContent, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if sc, ok := s.clipboard.(SequenceClipboard); ok {
+		sequence, err := sc.Sequence()
+		if err != nil || sequence == s.sequence {
+			return types.ClipboardContent{}, false, err
+		}
+		s.sequence = sequence
+		content, err := s.clipboard.Read()
+		if err != nil {
+			return types.ClipboardContent{}, false, err
+		}
+		content, err = s.limit(content)
+		return content, err == nil, err
+	}
+	content, err := s.clipboard.Read()
+	if err != nil {
+		return types.ClipboardContent{}, false, err
+	}
+	f := fingerprint(content)
+	if f == s.fingerprint {
+		return types.ClipboardContent{}, false, nil
+	}
+	s.fingerprint = f
+	content, err = s.limit(content)
+	return content, err == nil, err
+}
+
+// remember how the host clipboard is now (LOCK MUTEX BEFORE USING THIS)
+func (s *Sync) seen() {
+	if sc, ok := s.clipboard.(SequenceClipboard); ok {
+		if sequence, err := sc.Sequence(); err == nil {
+			s.sequence = sequence
+		}
+		return
+	}
+	// read back rather than kept, the host may have converted what was written
+	if content, err := s.clipboard.Read(); err == nil {
+		s.fingerprint = fingerprint(content)
+	}
+}
+
+func (s *Sync) limit(content types.ClipboardContent) (types.ClipboardContent, error) {
+	if s.maxSize <= 0 || content.Size() <= s.maxSize {
+		return content, nil
+	}
+	size := content.Size()
+	content.Image = nil
+	if content.Size() > s.maxSize || content.Empty() {
+		return types.ClipboardContent{}, NewClipboardTooLargeError(size, s.maxSize)
+	}
+	return content, nil
+}
+
+func fingerprint(content types.ClipboardContent) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(content.Text))
+	// keeps text and image apart
+	h.Write([]byte{0})
+	h.Write(content.Image)
+	var f [sha256.Size]byte
+	copy(f[:], h.Sum(nil))
+	return f
+}
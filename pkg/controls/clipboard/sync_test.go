@@ -0,0 +1,66 @@
+package clipboard
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type limitTest struct {
+	content  types.ClipboardContent
+	expected types.ClipboardContent
+	err      bool
+}
+
+func TestLimit(t *testing.T) {
+	limitTests := []limitTest{
+		{types.ClipboardContent{Text: "short"}, types.ClipboardContent{Text: "short"}, false},
+		{types.ClipboardContent{Text: "0123456789"}, types.ClipboardContent{Text: "0123456789"}, false},
+		{types.ClipboardContent{Text: "01234567890"}, types.ClipboardContent{}, true},
+		// the image goes first
+		{types.ClipboardContent{Text: "text", Image: make([]byte, 20)}, types.ClipboardContent{Text: "text"}, false},
+		{types.ClipboardContent{Image: make([]byte, 20)}, types.ClipboardContent{}, true},
+	}
+	c := &fake.Clipboard{}
+	s := NewSync(c, 10, time.Hour)
+	for _, test := range limitTests {
+		c.Write(test.content)
+		content, err := s.Get()
+		if test.err {
+			assert.IsType(t, &ClipboardTooLargeError{}, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, test.expected, content)
+	}
+	assert.Error(t, s.Set(types.ClipboardContent{Text: "much too long"}))
+}
+
+func TestWatch(t *testing.T) {
+	for _, c := range []Clipboard{&fake.Clipboard{}, &fake.SequenceClipboard{}} {
+		c.Write(types.ClipboardContent{Text: "before"})
+		s := NewSync(c, 0, time.Millisecond)
+		var mutex sync.Mutex
+		changes := make([]string, 0)
+		s.Watch(func(content types.ClipboardContent) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			changes = append(changes, content.Text)
+		})
+		// what a client sets doesn't come back
+		assert.NoError(t, s.Set(types.ClipboardContent{Text: "from client"}))
+		time.Sleep(10 * time.Millisecond)
+		c.Write(types.ClipboardContent{Text: "from host"})
+		assert.Eventually(t, func() bool {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return len(changes) == 1
+		}, time.Second, time.Millisecond)
+		s.Close()
+		assert.Equal(t, []string{"from host"}, changes)
+	}
+}
@@ -0,0 +1,44 @@
+package cursor
+
+import "image"
+
+// what a backend sees of the host cursor
+type State struct {
+	// on the screen
+	X       int
+	Y       int
+	Visible bool
+	// changes whenever the shape does, the image is only read then
+	Shape uint64
+	// size of the screen, positions are scaled from it to the stream
+	ScreenWidth  int
+	ScreenHeight int
+}
+
+// the current cursor shape, the hotspot is the pixel at the cursor position
+type Image struct {
+	Pixels   *image.NRGBA
+	HotspotX int
+	HotspotY int
+}
+
+// Area is a rectangle of the host screen, in the coordinates of State
+type Area struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Monitors is implemented by backends that know where the monitors of the host are
+type Monitors interface {
+	// MonitorArea finds a monitor by index or device name, empty for the primary one
+	MonitorArea(monitor string) (Area, error)
+}
+
+// Cursor reads the host cursor, it is polled
+type Cursor interface {
+	State() (State, error)
+	Image() (Image, error)
+	Close() error
+}
@@ -0,0 +1,235 @@
+package cursor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"image/png"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// DefaultInterval polls the cursor a little faster than the video framerate
+const DefaultInterval = 8 * time.Millisecond
+
+// a failing poll logs the same error again only after this
+const errorLogInterval = 10 * time.Second
+
+// Sender sends a message over the cursor datachannel of a peer
+type Sender func(peerId string, message string) error
+
+// Watcher follows the host cursor and sends its shape and position to every peer.
+// Each shape image is sent to a peer once, after that only its hash is
+type Watcher struct {
+	cursor Cursor
+	send   Sender
+	width  int
+	height int
+	// the part of the screen the stream shows, all of it if empty
+	area     Area
+	interval time.Duration
+	mutex    sync.Mutex
+	// hashes of the shapes each peer was sent the image of
+	peers     map[string]map[string]bool
+	shapeId   uint64
+	haveShape bool
+	shape     types.CursorShape
+	position  types.CursorPosition
+	seq       uint64
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWatcher follows c for a stream of the given resolution, polling it every interval
+func NewWatcher(c Cursor, send Sender, width int, height int, interval time.Duration) *Watcher {
+	return &Watcher{
+		cursor:   c,
+		send:     send,
+		width:    width,
+		height:   height,
+		interval: interval,
+		peers:    map[string]map[string]bool{},
+		stop:     make(chan struct{}),
+	}
+}
+
+// SetGeometry changes the resolution of the stream and the area of the screen it shows,
+// an empty area is the whole screen
+func (w *Watcher) SetGeometry(width int, height int, area Area) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.width, w.height, w.area = width, height, area
+}
+
+// Start polls the cursor until Close
+func (w *Watcher) Start() {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		// a cursor that can't be read, like on the secure desktop, fails every poll
+		var lastError string
+		var logged time.Time
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				err := w.poll()
+				if err == nil {
+					lastError = ""
+					continue
+				}
+				if err.Error() != lastError || time.Since(logged) >= errorLogInterval {
+					log.Println(err)
+					lastError, logged = err.Error(), time.Now()
+				}
+			}
+		}
+	}()
+}
+
+// OnCursorOpened sends the current shape and position to a peer whose cursor datachannel opened
+func (w *Watcher) OnCursorOpened(peerId string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.peers[peerId] = map[string]bool{}
+	if !w.haveShape {
+		return
+	}
+	w.sendShape(peerId, w.peers[peerId])
+	w.sendMessage(peerId, &message.CursorPositionPayload{CursorPosition: w.position, Seq: w.seq})
+}
+
+func (w *Watcher) OnCursorClosed(peerId string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.peers, peerId)
+}
+
+// Close stops polling and closes the cursor
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		err = w.cursor.Close()
+	})
+	return err
+}
+
+// poll sends the shape to every peer if it changed, then the position if it moved
+func (w *Watcher) poll() error {
+	state, err := w.cursor.State()
+	if err != nil {
+		return err
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.haveShape || state.Shape != w.shapeId {
+		img, err := w.cursor.Image()
+		if err != nil {
+			return err
+		}
+		shape, err := encodeShape(img)
+		if err != nil {
+			return err
+		}
+		w.shapeId, w.haveShape = state.Shape, true
+		// the id may change without the image, like an animation looping back
+		if shape.Hash != w.shape.Hash {
+			w.shape = shape
+			for peerId, sent := range w.peers {
+				w.sendShape(peerId, sent)
+			}
+		}
+	}
+	position := w.scale(state)
+	if position == w.position && w.seq != 0 {
+		return nil
+	}
+	w.position = position
+	w.seq++
+	for peerId := range w.peers {
+		w.sendMessage(peerId, &message.CursorPositionPayload{CursorPosition: position, Seq: w.seq})
+	}
+	return nil
+}
+
+// scale maps a position on the screen to the stream, the cursor is hidden outside the area the stream shows.
+// The mutex must be held
+func (w *Watcher) scale(state State) types.CursorPosition {
+	area := w.area
+	if area.Width <= 0 || area.Height <= 0 {
+		area = Area{Width: state.ScreenWidth, Height: state.ScreenHeight}
+	}
+	position := types.CursorPosition{X: state.X, Y: state.Y, Visible: state.Visible}
+	if area.Width <= 0 || area.Height <= 0 {
+		return position
+	}
+	x, y := state.X-area.X, state.Y-area.Y
+	if x < 0 || y < 0 || x >= area.Width || y >= area.Height {
+		position.Visible = false
+		x, y = clamp(x, area.Width-1), clamp(y, area.Height-1)
+	}
+	position.X = x * w.width / area.Width
+	position.Y = y * w.height / area.Height
+	return position
+}
+
+func clamp(value int, max int) int {
+	if value < 0 {
+		return 0
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// sendShape sends the current shape, with the image only if the peer wasn't sent it before
+func (w *Watcher) sendShape(peerId string, sent map[string]bool) {
+	shape := w.shape
+	if sent[shape.Hash] {
+		shape.Image = nil
+	}
+	if w.sendMessage(peerId, &message.CursorShapePayload{CursorShape: shape}) {
+		sent[shape.Hash] = true
+	}
+}
+
+func (w *Watcher) sendMessage(peerId string, payload message.Payload) bool {
+	bytes, err := message.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	if err := w.send(peerId, string(bytes)); err != nil {
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// encodeShape encodes the image as PNG, the hash covers the pixels and the hotspot
+func encodeShape(img Image) (types.CursorShape, error) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img.Pixels); err != nil {
+		return types.CursorShape{}, err
+	}
+	bounds := img.Pixels.Bounds()
+	hash := sha256.New()
+	binary.Write(hash, binary.BigEndian, [4]int32{int32(bounds.Dx()), int32(bounds.Dy()), int32(img.HotspotX), int32(img.HotspotY)})
+	hash.Write(img.Pixels.Pix)
+	return types.CursorShape{
+		Hash:     hex.EncodeToString(hash.Sum(nil)[:8]),
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		HotspotX: img.HotspotX,
+		HotspotY: img.HotspotY,
+		Image:    buffer.Bytes(),
+	}, nil
+}
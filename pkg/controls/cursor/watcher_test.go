@@ -0,0 +1,149 @@
+package cursor
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCursor struct {
+	state  State
+	image  Image
+	images int
+}
+
+func (c *fakeCursor) State() (State, error) { return c.state, nil }
+
+func (c *fakeCursor) Image() (Image, error) {
+	c.images++
+	return c.image, nil
+}
+
+func (c *fakeCursor) Close() error { return nil }
+
+func newImage(c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < 4; i++ {
+		img.SetNRGBA(i%2, i/2, c)
+	}
+	return img
+}
+
+type fakeSender struct {
+	mutex    sync.Mutex
+	messages map[string][]message.Payload
+}
+
+func (s *fakeSender) send(peerId string, msg string) error {
+	payload, err := message.Unmarshal([]byte(msg))
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages[peerId] = append(s.messages[peerId], payload)
+	return nil
+}
+
+// take returns and forgets what was sent to a peer
+func (s *fakeSender) take(peerId string) []message.Payload {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	messages := s.messages[peerId]
+	delete(s.messages, peerId)
+	return messages
+}
+
+func TestWatcher(t *testing.T) {
+	arrow := newImage(color.NRGBA{R: 255, A: 255})
+	c := &fakeCursor{
+		state: State{X: 100, Y: 50, Visible: true, Shape: 1, ScreenWidth: 200, ScreenHeight: 100},
+		image: Image{Pixels: arrow, HotspotX: 1},
+	}
+	s := &fakeSender{messages: map[string][]message.Payload{}}
+	w := NewWatcher(c, s.send, 100, 50, DefaultInterval)
+	w.OnCursorOpened("1")
+	assert.Empty(t, s.take("1"))
+
+	assert.NoError(t, w.poll())
+	messages := s.take("1")
+	assert.Len(t, messages, 2)
+	shape := messages[0].(*message.CursorShapePayload)
+	assert.Equal(t, 2, shape.Width)
+	assert.Equal(t, 1, shape.HotspotX)
+	assert.NotEmpty(t, shape.Image)
+	// scaled from the screen to the stream
+	assert.Equal(t, &message.CursorPositionPayload{CursorPosition: types.CursorPosition{X: 50, Y: 25, Visible: true}, Seq: 1}, messages[1])
+
+	// nothing changed
+	assert.NoError(t, w.poll())
+	assert.Empty(t, s.take("1"))
+	assert.Equal(t, 1, c.images)
+
+	// a new shape id with the same image isn't sent again
+	c.state.Shape = 2
+	assert.NoError(t, w.poll())
+	assert.Empty(t, s.take("1"))
+
+	// a different shape, then the first one again without its image
+	c.state.Shape = 3
+	c.image = Image{Pixels: newImage(color.NRGBA{B: 255, A: 255})}
+	assert.NoError(t, w.poll())
+	messages = s.take("1")
+	assert.Len(t, messages, 1)
+	assert.NotEmpty(t, messages[0].(*message.CursorShapePayload).Image)
+	c.state.Shape = 4
+	c.image = Image{Pixels: arrow, HotspotX: 1}
+	assert.NoError(t, w.poll())
+	messages = s.take("1")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, shape.Hash, messages[0].(*message.CursorShapePayload).Hash)
+	assert.Empty(t, messages[0].(*message.CursorShapePayload).Image)
+
+	// a peer joining gets the current shape and position
+	w.OnCursorOpened("2")
+	messages = s.take("2")
+	assert.Len(t, messages, 2)
+	assert.NotEmpty(t, messages[0].(*message.CursorShapePayload).Image)
+
+	c.state.Visible = false
+	assert.NoError(t, w.poll())
+	assert.Equal(t, []message.Payload{&message.CursorPositionPayload{CursorPosition: types.CursorPosition{X: 50, Y: 25}, Seq: 2}}, s.take("1"))
+	assert.Len(t, s.take("2"), 1)
+
+	w.OnCursorClosed("1")
+	c.state.X = 0
+	assert.NoError(t, w.poll())
+	assert.Empty(t, s.take("1"))
+	assert.Len(t, s.take("2"), 1)
+}
+
+type scaleTest struct {
+	state    State
+	area     Area
+	expected types.CursorPosition
+}
+
+func TestScale(t *testing.T) {
+	scaleTests := []scaleTest{
+		// the whole screen
+		{State{X: 100, Y: 50, Visible: true, ScreenWidth: 200, ScreenHeight: 100}, Area{}, types.CursorPosition{X: 50, Y: 25, Visible: true}},
+		// a second monitor right of the first
+		{State{X: 300, Y: 50, Visible: true, ScreenWidth: 200, ScreenHeight: 100}, Area{X: 200, Width: 400, Height: 200}, types.CursorPosition{X: 25, Y: 12, Visible: true}},
+		// on the first monitor, hidden at the edge of the second
+		{State{X: 100, Y: 50, Visible: true, ScreenWidth: 200, ScreenHeight: 100}, Area{X: 200, Width: 400, Height: 200}, types.CursorPosition{X: 0, Y: 12}},
+		// a region
+		{State{X: 60, Y: 30, Visible: true, ScreenWidth: 200, ScreenHeight: 100}, Area{X: 50, Y: 20, Width: 20, Height: 20}, types.CursorPosition{X: 50, Y: 25, Visible: true}},
+		{State{X: 70, Y: 40, Visible: true, ScreenWidth: 200, ScreenHeight: 100}, Area{X: 50, Y: 20, Width: 20, Height: 20}, types.CursorPosition{X: 95, Y: 47}},
+	}
+	for _, test := range scaleTests {
+		w := NewWatcher(&fakeCursor{}, nil, 100, 50, DefaultInterval)
+		w.SetGeometry(100, 50, test.area)
+		assert.Equal(t, test.expected, w.scale(test.state), test)
+	}
+}
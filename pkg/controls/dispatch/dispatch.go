@@ -0,0 +1,512 @@
+package dispatch
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/clipboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/gamepad"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/macro"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/pen"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/throttle"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/touch"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/tracker"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// messages that are passed on to the keyboard, mouse, touchscreen and pen
+var inputMessages map[message.MessageType]bool = map[message.MessageType]bool{
+	message.KeyCharMessage:       true,
+	message.KeySpecialKeyMessage: true,
+	message.KeyPhysicalMessage:   true,
+	message.KeyChordMessage:      true,
+	message.TextMessage:          true,
+	message.MouseMoveMessage:     true,
+	message.MouseKeyMessage:      true,
+	message.MouseScrollMessage:   true,
+	message.TouchMessage:         true,
+	message.PenMessage:           true,
+}
+
+// messages that are passed on to the gamepad of the peer
+var gamepadMessages map[message.MessageType]bool = map[message.MessageType]bool{
+	message.GamepadButtonMessage:  true,
+	message.GamepadStickMessage:   true,
+	message.GamepadTriggerMessage: true,
+}
+
+// Sender sends a message to a peer over its controls datachannel
+type Sender func(peerId string, message string) error
+
+// SettingsUpdater applies a change of the stream settings
+type SettingsUpdater func(update types.StreamSettingsUpdate) error
+
+// Dispatcher handles the messages peers send over their controls datachannel.
+// Input only reaches the keyboard and mouse from the peer that has control,
+// and every change of control is announced to all peers
+type Dispatcher struct {
+	tracker     *tracker.Tracker
+	permissions *permissions.Permissions
+	send        Sender
+	// nil if gamepads aren't supported
+	gamepads *gamepad.Pool
+	// nil if touch or pen input isn't supported
+	touch touch.Touch
+	pen   pen.Pen
+	// nil if the clipboard isn't synced
+	clipboard *clipboard.Sync
+	// nil if input isn't rate limited
+	limiter *throttle.Limiter
+	// nil if mouse moves and scrolls aren't coalesced
+	coalescer *throttle.Coalescer
+	// nil if the stream settings can't be changed
+	updateSettings SettingsUpdater
+	// listed in every announced change of the stream settings
+	fixedSettings []string
+	mutex         sync.Mutex
+	// peers whose keyboard and mouse input is being recorded
+	recorders map[string]*macro.Recorder
+	// how the physical keys of each peer are sent, physical mode if missing
+	mappers map[string]*keyboard.Mapper
+}
+
+// Option changes how a dispatcher passes input on to the backends
+type Option func(o *options)
+
+type options struct {
+	coalesceInterval time.Duration
+	blocklist        []types.Chord
+}
+
+// WithCoalescing merges mouse moves and scrolls arriving within interval before they reach the mouse,
+// usually the duration of a frame
+func WithCoalescing(interval time.Duration) Option {
+	return func(o *options) {
+		o.coalesceInterval = interval
+	}
+}
+
+// WithBlockedShortcuts refuses key presses from peers that would complete one of the shortcuts,
+// see keyboard.Guard
+func WithBlockedShortcuts(blocklist []types.Chord) Option {
+	return func(o *options) {
+		o.blocklist = blocklist
+	}
+}
+
+// NewDispatcher creates a dispatcher for the given input backends,
+// held keys are released after timeout without input (zero disables it)
+func NewDispatcher(k keyboard.Keyboard, m mouse.Mouse, send Sender, timeout time.Duration, opts ...Option) *Dispatcher {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var coalescer *throttle.Coalescer
+	if o.coalesceInterval > 0 {
+		coalescer = throttle.NewCoalescer(k, m, o.coalesceInterval)
+		k, m = coalescer, coalescer
+	}
+	if len(o.blocklist) > 0 {
+		k = keyboard.NewGuard(k, o.blocklist)
+	}
+	return &Dispatcher{
+		tracker:     tracker.NewTracker(k, m, timeout),
+		permissions: permissions.NewPermissions(),
+		send:        send,
+		coalescer:   coalescer,
+		recorders:   make(map[string]*macro.Recorder),
+		mappers:     make(map[string]*keyboard.Mapper),
+	}
+}
+
+// AddPeer registers a peer with a role, this should be done when adding it to the pipeline
+func (d *Dispatcher) AddPeer(peerId string, role permissions.Role) {
+	d.permissions.AddPeer(peerId, role)
+}
+
+// SetRole changes the role of a peer and announces it
+func (d *Dispatcher) SetRole(peerId string, role permissions.Role) error {
+	controller, err := d.permissions.SetRole(peerId, role)
+	if err != nil {
+		return err
+	}
+	if d.gamepads != nil && !d.permissions.CanUseGamepad(peerId) {
+		d.gamepads.Release(peerId)
+	}
+	d.broadcast(&message.RolePayload{Peer: peerId, Role: string(role)})
+	d.controlChanged(controller)
+	return nil
+}
+
+// SetGamepads lets every peer that isn't a viewer play with its own gamepad from the pool,
+// this should be done before peers are added
+func (d *Dispatcher) SetGamepads(pool *gamepad.Pool) {
+	d.gamepads = pool
+}
+
+// SetTouch lets the controller use a touchscreen
+func (d *Dispatcher) SetTouch(t touch.Touch) {
+	d.touch = t
+}
+
+// SetPen lets the controller use a pen
+func (d *Dispatcher) SetPen(p pen.Pen) {
+	d.pen = p
+}
+
+// SetClipboard syncs the host clipboard with the peers. The controller can set it,
+// and the peers allowed to read it get every change
+func (d *Dispatcher) SetClipboard(s *clipboard.Sync) {
+	d.clipboard = s
+	s.Watch(d.clipboardChanged)
+}
+
+// SetRateLimit drops input events of peers that send more than the limiter allows,
+// this should be done before peers are added
+func (d *Dispatcher) SetRateLimit(l *throttle.Limiter) {
+	d.limiter = l
+}
+
+// SetSettingsUpdater lets admins change the stream settings, every change is announced to all peers
+// with the fixed settings, those only a restart of the host changes
+func (d *Dispatcher) SetSettingsUpdater(u SettingsUpdater, fixed ...string) {
+	d.updateSettings = u
+	d.fixedSettings = fixed
+}
+
+// Record records the keyboard and mouse input of a peer, nil stops recording
+func (d *Dispatcher) Record(peerId string, r *macro.Recorder) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if r == nil {
+		delete(d.recorders, peerId)
+		return
+	}
+	d.recorders[peerId] = r
+}
+
+// Coalescer gives access to the counters of coalesced input, nil without WithCoalescing
+func (d *Dispatcher) Coalescer() *throttle.Coalescer {
+	return d.coalescer
+}
+
+// Permissions gives access to the roles and the current controller
+func (d *Dispatcher) Permissions() *permissions.Permissions {
+	return d.permissions
+}
+
+// ReleaseAll releases every key and button held down by any peer
+func (d *Dispatcher) ReleaseAll() error {
+	return d.tracker.ReleaseAll()
+}
+
+func (d *Dispatcher) OnControlsOpened(peerId string) {
+	role, ok := d.permissions.Role(peerId)
+	if !ok {
+		return
+	}
+	d.sendTo(peerId, &message.RolePayload{Peer: peerId, Role: string(role)})
+	d.sendTo(peerId, d.controlState())
+}
+
+func (d *Dispatcher) OnControlsMessage(peerId string, msg string) {
+	payload, err := message.Unmarshal([]byte(msg))
+	if err == nil && d.limited(peerId, payload) {
+		// dropped without an answer, which would only add to the flood
+		return
+	}
+	if err == nil {
+		err = d.handle(peerId, payload)
+	}
+	if err != nil {
+		d.sendTo(peerId, message.NewErrorPayload(err))
+	}
+}
+
+func (d *Dispatcher) OnControlsClosed(peerId string) {
+	d.tracker.Release(peerId)
+	if d.gamepads != nil {
+		d.gamepads.Release(peerId)
+	}
+	controller := d.permissions.RemovePeer(peerId)
+	d.Record(peerId, nil)
+	d.mutex.Lock()
+	delete(d.mappers, peerId)
+	d.mutex.Unlock()
+	if d.limiter != nil {
+		d.limiter.Remove(peerId)
+	}
+	d.controlChanged(controller)
+}
+
+func (d *Dispatcher) handle(peerId string, payload message.Payload) error {
+	switch p := payload.(type) {
+	case *message.ControlRequestPayload:
+		controller, err := d.permissions.Request(peerId)
+		if err != nil {
+			return err
+		}
+		d.controlChanged(controller)
+		return nil
+	case *message.ControlGrantPayload:
+		controller, err := d.permissions.Grant(peerId, p.Peer)
+		if err != nil {
+			return err
+		}
+		d.controlChanged(controller)
+		return nil
+	case *message.KeyboardLayoutPayload:
+		return d.setKeyboardLayout(peerId, p)
+	case *message.ClipboardPayload:
+		return d.setClipboard(peerId, p.ClipboardContent)
+	case *message.ClipboardRequestPayload:
+		return d.sendClipboard(peerId)
+	case *message.StreamSettingsPayload:
+		return d.setStreamSettings(peerId, p)
+	case *message.ControlRevokePayload:
+		controller, err := d.permissions.Revoke(peerId)
+		if err != nil {
+			return err
+		}
+		d.controlChanged(controller)
+		return nil
+	}
+	if gamepadMessages[payload.Type()] {
+		return d.handleGamepad(peerId, payload)
+	}
+	if !inputMessages[payload.Type()] {
+		return pkgerrors.NewUnsupportedMessageTypeError(string(payload.Type()))
+	}
+	if !d.permissions.CanSendInput(peerId) {
+		return permissions.NewPermissionError(peerId, "send input without control")
+	}
+	switch p := payload.(type) {
+	case *message.KeyCharPayload:
+		key, err := p.Rune()
+		if err != nil {
+			return err
+		}
+		return d.keyboard(peerId).SendInputKeyChar(key, p.Down)
+	case *message.KeySpecialKeyPayload:
+		return d.keyboard(peerId).SendInputKeySpecialKey(p.Key, p.Down)
+	case *message.KeyPhysicalPayload:
+		return d.mapper(peerId).SendInputPhysicalKey(d.keyboard(peerId), p.Key, p.Down)
+	case *message.KeyChordPayload:
+		chord, err := p.Chord()
+		if err != nil {
+			return err
+		}
+		return keyboard.SendChord(d.keyboard(peerId), chord)
+	case *message.TextPayload:
+		return d.keyboard(peerId).TypeText(p.Text)
+	case *message.MouseMovePayload:
+		return d.mouse(peerId).SendInputMove(p.Dx, p.Dy)
+	case *message.MouseKeyPayload:
+		return d.mouse(peerId).SendInputKey(p.Key)
+	case *message.MouseScrollPayload:
+		return d.mouse(peerId).SendInputScroll(p.Direction, p.Magnitude)
+	case *message.TouchPayload:
+		if d.touch == nil {
+			return pkgerrors.NewNotImplementedError("Dispatcher", "touch")
+		}
+		return d.touch.SendInputTouch(p.Contacts)
+	case *message.PenPayload:
+		if d.pen == nil {
+			return pkgerrors.NewNotImplementedError("Dispatcher", "pen")
+		}
+		return d.pen.SendInputPen(p.PenState)
+	}
+	return pkgerrors.NewUnsupportedMessageTypeError(string(payload.Type()))
+}
+
+// keys held down with the old settings are released, since they might not map to the same input
+func (d *Dispatcher) setKeyboardLayout(peerId string, p *message.KeyboardLayoutPayload) error {
+	var layout *keyboard.Layout
+	if p.Layout != "" {
+		var ok bool
+		if layout, ok = keyboard.LayoutByName(p.Layout); !ok {
+			return pkgerrors.NewNotImplementedError("Dispatcher", fmt.Sprintf("layout %s", p.Layout))
+		}
+	}
+	mapper, err := keyboard.NewMapper(p.Mode, layout)
+	if err != nil {
+		return err
+	}
+	d.tracker.Release(peerId)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.mappers[peerId] = mapper
+	return nil
+}
+
+func (d *Dispatcher) mapper(peerId string) *keyboard.Mapper {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	mapper, ok := d.mappers[peerId]
+	if !ok {
+		mapper, _ = keyboard.NewMapper(types.PhysicalMode, nil)
+		d.mappers[peerId] = mapper
+	}
+	return mapper
+}
+
+// pasting into the host is input, so it needs control
+func (d *Dispatcher) setClipboard(peerId string, content types.ClipboardContent) error {
+	if d.clipboard == nil {
+		return pkgerrors.NewNotImplementedError("Dispatcher", "clipboard")
+	}
+	if !d.permissions.CanSendInput(peerId) {
+		return permissions.NewPermissionError(peerId, "write the clipboard without control")
+	}
+	return d.clipboard.Set(content)
+}
+
+func (d *Dispatcher) sendClipboard(peerId string) error {
+	if d.clipboard == nil {
+		return pkgerrors.NewNotImplementedError("Dispatcher", "clipboard")
+	}
+	if !d.permissions.CanReadClipboard(peerId) {
+		return permissions.NewPermissionError(peerId, "read the clipboard")
+	}
+	content, err := d.clipboard.Get()
+	if err != nil {
+		return err
+	}
+	d.sendTo(peerId, &message.ClipboardPayload{ClipboardContent: content})
+	return nil
+}
+
+func (d *Dispatcher) setStreamSettings(peerId string, p *message.StreamSettingsPayload) error {
+	if d.updateSettings == nil {
+		return pkgerrors.NewNotImplementedError("Dispatcher", "stream settings")
+	}
+	if !d.permissions.CanUpdateSettings(peerId) {
+		return permissions.NewPermissionError(peerId, "change the stream settings")
+	}
+	if err := d.updateSettings(p.StreamSettingsUpdate); err != nil {
+		return err
+	}
+	d.broadcast(&message.StreamSettingsPayload{StreamSettingsUpdate: p.StreamSettingsUpdate, Fixed: d.fixedSettings})
+	return nil
+}
+
+// send a change of the host clipboard to the peers allowed to read it
+func (d *Dispatcher) clipboardChanged(content types.ClipboardContent) {
+	for _, peerId := range d.permissions.Peers() {
+		if d.permissions.CanReadClipboard(peerId) {
+			d.sendTo(peerId, &message.ClipboardPayload{ClipboardContent: content})
+		}
+	}
+}
+
+// input is rate limited, control messages never are
+func (d *Dispatcher) limited(peerId string, payload message.Payload) bool {
+	if d.limiter == nil || !(inputMessages[payload.Type()] || gamepadMessages[payload.Type()]) {
+		return false
+	}
+	return !d.limiter.Allow(peerId)
+}
+
+// the keyboard a peer's input goes to, wrapped by its recorder if it is being recorded
+func (d *Dispatcher) keyboard(peerId string) keyboard.Keyboard {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if r, ok := d.recorders[peerId]; ok {
+		return r.Keyboard(d.tracker.Keyboard(peerId))
+	}
+	return d.tracker.Keyboard(peerId)
+}
+
+// the mouse a peer's input goes to, wrapped by its recorder if it is being recorded
+func (d *Dispatcher) mouse(peerId string) mouse.Mouse {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if r, ok := d.recorders[peerId]; ok {
+		return r.Mouse(d.tracker.Mouse(peerId))
+	}
+	return d.tracker.Mouse(peerId)
+}
+
+func (d *Dispatcher) handleGamepad(peerId string, payload message.Payload) error {
+	if d.gamepads == nil {
+		return pkgerrors.NewNotImplementedError("Dispatcher", "gamepads")
+	}
+	if !d.permissions.CanUseGamepad(peerId) {
+		return permissions.NewPermissionError(peerId, "use a gamepad")
+	}
+	pad, created, err := d.gamepads.Assign(peerId)
+	if err != nil {
+		return err
+	}
+	if created {
+		go d.forwardRumble(peerId, pad.Rumble())
+	}
+	switch p := payload.(type) {
+	case *message.GamepadButtonPayload:
+		return pad.SendInputButton(p.Button, p.Down)
+	case *message.GamepadStickPayload:
+		return pad.SendInputStick(p.Stick, p.X, p.Y)
+	case *message.GamepadTriggerPayload:
+		return pad.SendInputTrigger(p.Trigger, p.Value)
+	}
+	return pkgerrors.NewUnsupportedMessageTypeError(string(payload.Type()))
+}
+
+// send rumble to the peer until its gamepad is closed
+func (d *Dispatcher) forwardRumble(peerId string, rumble <-chan types.GamepadRumble) {
+	for r := range rumble {
+		d.sendTo(peerId, &message.GamepadRumblePayload{
+			Strong:   r.Strong,
+			Weak:     r.Weak,
+			Duration: r.Duration.Milliseconds(),
+		})
+	}
+}
+
+// announce the control state, releasing the input of the previous controller if it lost control
+func (d *Dispatcher) controlChanged(previous string) {
+	if previous != "" && previous != d.permissions.Controller() {
+		d.tracker.Release(previous)
+		d.mutex.Lock()
+		if mapper, ok := d.mappers[previous]; ok {
+			mapper.Reset()
+		}
+		d.mutex.Unlock()
+		// touch and pen belong to whoever has control, so lift them
+		if d.touch != nil {
+			d.touch.Cancel()
+		}
+		if d.pen != nil {
+			d.pen.SendInputPen(types.PenState{})
+		}
+	}
+	d.broadcast(d.controlState())
+}
+
+func (d *Dispatcher) controlState() *message.ControlStatePayload {
+	return &message.ControlStatePayload{
+		Controller: d.permissions.Controller(),
+		Requests:   d.permissions.Requests(),
+	}
+}
+
+func (d *Dispatcher) broadcast(payload message.Payload) {
+	for _, peerId := range d.permissions.Peers() {
+		d.sendTo(peerId, payload)
+	}
+}
+
+// announcements are best effort, a peer whose datachannel isn't open gets the state once it opens
+func (d *Dispatcher) sendTo(peerId string, payload message.Payload) {
+	bytes, err := message.Marshal(payload)
+	if err != nil {
+		return
+	}
+	d.send(peerId, string(bytes))
+}
@@ -0,0 +1,356 @@
+package dispatch
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/clipboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/gamepad"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/macro"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/throttle"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// records touch and pen input
+type fakeTouchPen struct {
+	contacts []types.TouchContact
+	pen      []types.PenState
+	cancels  int
+}
+
+func (f *fakeTouchPen) SendInputTouch(contacts []types.TouchContact) error {
+	f.contacts = append(f.contacts, contacts...)
+	return nil
+}
+
+func (f *fakeTouchPen) Cancel() error {
+	f.cancels++
+	return nil
+}
+
+func (f *fakeTouchPen) SendInputPen(state types.PenState) error {
+	f.pen = append(f.pen, state)
+	return nil
+}
+
+func (f *fakeTouchPen) Close() error {
+	return nil
+}
+
+// records every gamepad call as a string
+type fakeGamepad struct {
+	calls  []string
+	rumble chan types.GamepadRumble
+}
+
+func (f *fakeGamepad) SendInputButton(button types.GamepadButton, down bool) error {
+	f.calls = append(f.calls, "button "+string(button))
+	return nil
+}
+
+func (f *fakeGamepad) SendInputStick(stick types.GamepadStick, x float64, y float64) error {
+	f.calls = append(f.calls, "stick "+string(stick))
+	return nil
+}
+
+func (f *fakeGamepad) SendInputTrigger(trigger types.GamepadTrigger, value float64) error {
+	f.calls = append(f.calls, "trigger "+string(trigger))
+	return nil
+}
+
+func (f *fakeGamepad) Rumble() <-chan types.GamepadRumble {
+	return f.rumble
+}
+
+func (f *fakeGamepad) Close() error {
+	close(f.rumble)
+	return nil
+}
+
+// records every message sent to each peer
+type fakeSender struct {
+	mutex sync.Mutex
+	sent  map[string][]string
+}
+
+func (f *fakeSender) send(peerId string, message string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sent[peerId] = append(f.sent[peerId], message)
+	return nil
+}
+
+func (f *fakeSender) sentTo(peerId string) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.sent[peerId]...)
+}
+
+func newTestDispatcher() (*Dispatcher, *fake.Keyboard, *fake.Mouse, *fakeSender) {
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	sender := &fakeSender{sent: make(map[string][]string)}
+	d := NewDispatcher(k, m, sender.send, 0)
+	d.AddPeer("v", permissions.Viewer)
+	d.AddPeer("c", permissions.Controller)
+	return d, k, m, sender
+}
+
+func TestInputNeedsControl(t *testing.T) {
+	d, k, _, sender := newTestDispatcher()
+	d.OnControlsMessage("c", `{"type":"keychar","payload":{"key":"a","down":true}}`)
+	d.OnControlsMessage("v", `{"type":"controlrequest"}`)
+	assert.Empty(t, k.Calls())
+	assert.Equal(t, []string{
+		`{"type":"error","payload":{"message":"PermissionError: peer 'c' is not allowed to send input without control"}}`,
+	}, sender.sentTo("c"))
+	assert.Equal(t, []string{
+		`{"type":"error","payload":{"message":"PermissionError: peer 'v' is not allowed to request control"}}`,
+	}, sender.sentTo("v"))
+}
+
+func TestControllerInput(t *testing.T) {
+	d, k, m, sender := newTestDispatcher()
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"keyspecial","payload":{"key":"SHIFT","down":true}}`)
+	d.OnControlsMessage("c", `{"type":"mousekey","payload":{"key":"LMBDown"}}`)
+	d.OnControlsMessage("c", `{"type":"text","payload":{"text":"hi"}}`)
+	assert.Equal(t, []string{"special SHIFT true", "text hi"}, k.Calls())
+	assert.Equal(t, []string{"mouse LMBDown"}, m.Calls())
+	state := `{"type":"controlstate","payload":{"controller":"c","requests":[]}}`
+	assert.Equal(t, []string{state}, sender.sentTo("c"))
+	assert.Equal(t, []string{state}, sender.sentTo("v"))
+	// giving up control releases everything held down
+	k.Reset()
+	m.Reset()
+	d.OnControlsMessage("c", `{"type":"controlrevoke"}`)
+	assert.Equal(t, []string{"special SHIFT false"}, k.Calls())
+	assert.Equal(t, []string{"mouse LMBUp"}, m.Calls())
+	assert.Equal(t, "", d.Permissions().Controller())
+}
+
+func TestClosedControllerIsReleased(t *testing.T) {
+	d, k, _, sender := newTestDispatcher()
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"keyspecial","payload":{"key":"LALT","down":true}}`)
+	k.Reset()
+	d.OnControlsClosed("c")
+	assert.Equal(t, []string{"special LALT false"}, k.Calls())
+	sent := sender.sentTo("v")
+	assert.Equal(t, `{"type":"controlstate","payload":{"controller":"","requests":[]}}`, sent[len(sent)-1])
+}
+
+func TestGamepad(t *testing.T) {
+	d, _, _, sender := newTestDispatcher()
+	pads := make([]*fakeGamepad, 0)
+	d.SetGamepads(gamepad.NewPool(2, func(index int) (gamepad.Gamepad, error) {
+		pad := &fakeGamepad{rumble: make(chan types.GamepadRumble, 1)}
+		pads = append(pads, pad)
+		return pad, nil
+	}))
+	// a controller doesn't need control to play
+	d.OnControlsMessage("c", `{"type":"gamepadbutton","payload":{"button":"A","down":true}}`)
+	d.OnControlsMessage("c", `{"type":"gamepadstick","payload":{"stick":"LEFT","x":0.5,"y":-1}}`)
+	d.OnControlsMessage("v", `{"type":"gamepadtrigger","payload":{"trigger":"LT","value":1}}`)
+	assert.Len(t, pads, 1)
+	assert.Equal(t, []string{"button A", "stick LEFT"}, pads[0].calls)
+	assert.Equal(t, []string{
+		`{"type":"error","payload":{"message":"PermissionError: peer 'v' is not allowed to use a gamepad"}}`,
+	}, sender.sentTo("v"))
+	// rumble goes back to the peer
+	pads[0].rumble <- types.GamepadRumble{Strong: 1, Weak: 0.5, Duration: 200 * time.Millisecond}
+	assert.Eventually(t, func() bool {
+		sent := sender.sentTo("c")
+		return len(sent) == 1 && sent[0] == `{"type":"gamepadrumble","payload":{"strong":1,"weak":0.5,"duration":200}}`
+	}, time.Second, time.Millisecond)
+	d.OnControlsClosed("c")
+	_, ok := d.gamepads.Gamepad("c")
+	assert.False(t, ok)
+}
+
+func TestTouchAndPen(t *testing.T) {
+	d, _, _, sender := newTestDispatcher()
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"touch","payload":{"contacts":[{"id":1,"phase":"down","x":5,"y":6}]}}`)
+	assert.Equal(t, `{"type":"error","payload":{"message":"NotImplementedError: touch not implemented in Dispatcher"}}`, sender.sentTo("c")[1])
+	d.OnControlsMessage("c", `{"type":"pen","payload":{"x":1,"y":2,"inRange":true}}`)
+	assert.Equal(t, `{"type":"error","payload":{"message":"NotImplementedError: pen not implemented in Dispatcher"}}`, sender.sentTo("c")[2])
+	touchPen := &fakeTouchPen{}
+	d.SetTouch(touchPen)
+	d.SetPen(touchPen)
+	d.OnControlsMessage("c", `{"type":"touch","payload":{"contacts":[{"id":1,"phase":"down","x":5,"y":6}]}}`)
+	d.OnControlsMessage("c", `{"type":"pen","payload":{"x":1,"y":2,"inRange":true,"inContact":true,"pressure":0.5}}`)
+	d.OnControlsMessage("v", `{"type":"pen","payload":{"x":3,"y":4,"inRange":true}}`)
+	assert.Equal(t, []types.TouchContact{{ID: 1, Phase: types.TouchDown, X: 5, Y: 6}}, touchPen.contacts)
+	assert.Equal(t, []types.PenState{{X: 1, Y: 2, Pressure: 0.5, InRange: true, InContact: true}}, touchPen.pen)
+	// losing control lifts every contact and the pen
+	d.OnControlsMessage("c", `{"type":"controlrevoke"}`)
+	assert.Equal(t, 1, touchPen.cancels)
+	assert.Equal(t, types.PenState{}, touchPen.pen[len(touchPen.pen)-1])
+}
+
+func TestRecord(t *testing.T) {
+	d, _, _, _ := newTestDispatcher()
+	r := macro.NewRecorder()
+	d.Record("c", r)
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"mousemove","payload":{"dx":1,"dy":2}}`)
+	d.Record("c", nil)
+	d.OnControlsMessage("c", `{"type":"mousemove","payload":{"dx":3,"dy":4}}`)
+	events := r.Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, &message.MouseMovePayload{Dx: 1, Dy: 2}, events[0].Payload)
+}
+
+func TestRateLimit(t *testing.T) {
+	d, _, m, sender := newTestDispatcher()
+	limiter := throttle.NewLimiter(0.001, 2)
+	d.SetRateLimit(limiter)
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	for i := 0; i < 5; i++ {
+		d.OnControlsMessage("c", `{"type":"mousemove","payload":{"dx":1,"dy":1}}`)
+	}
+	// control messages aren't limited
+	d.OnControlsMessage("c", `{"type":"controlrevoke"}`)
+	assert.Equal(t, []string{"move 1 1", "move 1 1"}, m.Calls())
+	assert.Len(t, sender.sentTo("c"), 2)
+	assert.Equal(t, throttle.PeerStats{Allowed: 2, Dropped: 3}, limiter.Stats()["c"])
+}
+
+func TestCoalescing(t *testing.T) {
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	sender := &fakeSender{sent: make(map[string][]string)}
+	d := NewDispatcher(k, m, sender.send, 0, WithCoalescing(time.Hour))
+	d.AddPeer("c", permissions.Controller)
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	for i := 0; i < 3; i++ {
+		d.OnControlsMessage("c", `{"type":"mousemove","payload":{"dx":1,"dy":2}}`)
+	}
+	assert.Empty(t, m.Calls())
+	// other input sends the merged moves first
+	d.OnControlsMessage("c", `{"type":"keychar","payload":{"key":"a","down":true}}`)
+	assert.Equal(t, []string{"move 3 6"}, m.Calls())
+	assert.Equal(t, []string{"char a true"}, k.Calls())
+	assert.Equal(t, throttle.CoalescerStats{Received: 3, Sent: 1}, d.Coalescer().Stats())
+	plain, _, _, _ := newTestDispatcher()
+	assert.Nil(t, plain.Coalescer())
+}
+
+func TestBlockedShortcuts(t *testing.T) {
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	sender := &fakeSender{sent: make(map[string][]string)}
+	blocklist, err := keyboard.ParseBlocklist(keyboard.DefaultBlocklist)
+	assert.NoError(t, err)
+	d := NewDispatcher(k, m, sender.send, 0, WithBlockedShortcuts(blocklist))
+	d.AddPeer("c", permissions.Controller)
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"keychord","payload":{"modifiers":["LALT"],"key":"F4"}}`)
+	assert.Empty(t, k.Calls())
+	assert.Equal(t, `{"type":"error","payload":{"message":"ShortcutBlockedError: shortcut 'ALT+F4' is blocked"}}`, sender.sentTo("c")[1])
+	// pressing the keys one at a time is refused at the key completing the shortcut
+	d.OnControlsMessage("c", `{"type":"keyspecial","payload":{"key":"LMETA","down":true}}`)
+	d.OnControlsMessage("c", `{"type":"keychar","payload":{"key":"l","down":true}}`)
+	d.OnControlsMessage("c", `{"type":"keyspecial","payload":{"key":"LMETA","down":false}}`)
+	assert.Equal(t, []string{"special LMETA true", "special LMETA false"}, k.Calls())
+	d.OnControlsMessage("c", `{"type":"keychord","payload":{"modifiers":["LMETA"],"char":"r"}}`)
+	assert.Equal(t, []string{"special LMETA true", "special LMETA false", "special LMETA true", "char r true", "char r false", "special LMETA false"}, k.Calls())
+}
+
+func TestKeyboardLayout(t *testing.T) {
+	d, k, _, sender := newTestDispatcher()
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"keyphysical","payload":{"key":"KeyQ","down":true}}`)
+	assert.Equal(t, []string{"physical KeyQ true"}, k.Calls())
+	// changing the layout releases held keys
+	k.Reset()
+	d.OnControlsMessage("c", `{"type":"keyboardlayout","payload":{"mode":"character","layout":"fr"}}`)
+	d.OnControlsMessage("c", `{"type":"keyphysical","payload":{"key":"KeyQ","down":true}}`)
+	assert.Equal(t, []string{"physical KeyQ false", "char a true"}, k.Calls())
+	d.OnControlsMessage("c", `{"type":"keyboardlayout","payload":{"mode":"character","layout":"klingon"}}`)
+	assert.Equal(t, `{"type":"error","payload":{"message":"NotImplementedError: layout klingon not implemented in Dispatcher"}}`, sender.sentTo("c")[1])
+}
+
+func TestKeyChord(t *testing.T) {
+	d, k, _, _ := newTestDispatcher()
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"keychord","payload":{"modifiers":["LMETA"],"char":"r"}}`)
+	assert.Equal(t, []string{"special LMETA true", "char r true", "char r false", "special LMETA false"}, k.Calls())
+}
+
+func TestClipboard(t *testing.T) {
+	d, _, _, sender := newTestDispatcher()
+	host := &fake.Clipboard{}
+	s := clipboard.NewSync(host, 0, time.Millisecond)
+	defer s.Close()
+	d.SetClipboard(s)
+	d.OnControlsMessage("c", `{"type":"clipboard","payload":{"text":"pasted"}}`)
+	d.OnControlsMessage("v", `{"type":"clipboardrequest"}`)
+	assert.Equal(t, []string{
+		`{"type":"error","payload":{"message":"PermissionError: peer 'c' is not allowed to write the clipboard without control"}}`,
+	}, sender.sentTo("c"))
+	assert.Equal(t, []string{
+		`{"type":"error","payload":{"message":"PermissionError: peer 'v' is not allowed to read the clipboard"}}`,
+	}, sender.sentTo("v"))
+	d.OnControlsMessage("c", `{"type":"controlrequest"}`)
+	d.OnControlsMessage("c", `{"type":"clipboard","payload":{"text":"pasted"}}`)
+	content, _ := host.Read()
+	assert.Equal(t, "pasted", content.Text)
+	d.OnControlsMessage("c", `{"type":"clipboardrequest"}`)
+	sent := sender.sentTo("c")
+	assert.Equal(t, `{"type":"clipboard","payload":{"text":"pasted"}}`, sent[len(sent)-1])
+	// host changes only go to the peers allowed to read them
+	d.Permissions().SetClipboardRead("v", true)
+	d.Permissions().SetClipboardRead("c", false)
+	host.Write(types.ClipboardContent{Text: "copied"})
+	assert.Eventually(t, func() bool {
+		sent := sender.sentTo("v")
+		return sent[len(sent)-1] == `{"type":"clipboard","payload":{"text":"copied"}}`
+	}, time.Second, time.Millisecond)
+	assert.Len(t, sender.sentTo("c"), len(sent))
+}
+
+func TestOpenedAnnouncesRole(t *testing.T) {
+	d, _, _, sender := newTestDispatcher()
+	d.OnControlsOpened("v")
+	assert.Equal(t, []string{
+		`{"type":"role","payload":{"peer":"v","role":"viewer"}}`,
+		`{"type":"controlstate","payload":{"controller":"","requests":[]}}`,
+	}, sender.sentTo("v"))
+}
+
+func TestStreamSettings(t *testing.T) {
+	d, _, _, sender := newTestDispatcher()
+	d.AddPeer("a", permissions.Admin)
+	msg := `{"type":"streamsettings","payload":{"videoBitrate":8000}}`
+	d.OnControlsMessage("a", msg)
+	assert.Equal(t, []string{
+		`{"type":"error","payload":{"message":"NotImplementedError: stream settings not implemented in Dispatcher"}}`,
+	}, sender.sentTo("a"))
+
+	updates := make([]types.StreamSettingsUpdate, 0)
+	d.SetSettingsUpdater(func(update types.StreamSettingsUpdate) error {
+		updates = append(updates, update)
+		return nil
+	})
+	d.OnControlsMessage("c", msg)
+	assert.Equal(t, []string{
+		`{"type":"error","payload":{"message":"PermissionError: peer 'c' is not allowed to change the stream settings"}}`,
+	}, sender.sentTo("c"))
+	d.OnControlsMessage("a", msg)
+	if assert.Len(t, updates, 1) {
+		assert.Equal(t, uint(8000), *updates[0].VideoBitrate)
+		assert.Nil(t, updates[0].VideoFramerate)
+	}
+	// announced to everyone
+	assert.Equal(t, msg, sender.sentTo("v")[0])
+	assert.Equal(t, msg, sender.sentTo("c")[1])
+
+	d.SetSettingsUpdater(func(update types.StreamSettingsUpdate) error { return nil }, "video.encoder", "video.template")
+	d.OnControlsMessage("a", msg)
+	assert.Equal(t, `{"type":"streamsettings","payload":{"videoBitrate":8000,"fixed":["video.encoder","video.template"]}}`, sender.sentTo("v")[1])
+}
@@ -0,0 +1,39 @@
+package fake
+
+import (
+	"sync"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// Clipboard is an in-memory clipboard.Clipboard, the zero value is empty and ready to use
+type Clipboard struct {
+	mutex    sync.Mutex
+	content  types.ClipboardContent
+	sequence uint64
+}
+
+func (c *Clipboard) Read() (types.ClipboardContent, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.content, nil
+}
+
+func (c *Clipboard) Write(content types.ClipboardContent) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.content = content
+	c.sequence++
+	return nil
+}
+
+// SequenceClipboard is a Clipboard that counts its writes
+type SequenceClipboard struct {
+	Clipboard
+}
+
+func (c *SequenceClipboard) Sequence() (uint64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.sequence, nil
+}
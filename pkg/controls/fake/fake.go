@@ -0,0 +1,72 @@
+// Package fake has in-memory implementations of the input interfaces for tests.
+// They record every call instead of sending input, and can be told to fail
+package fake
+
+import (
+	"encoding"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// recorder keeps the calls of a fake as strings like "char a true",
+// a name followed by the arguments
+type recorder struct {
+	mutex sync.Mutex
+	calls []string
+	// errors by call prefix
+	failures map[string]error
+}
+
+// Calls returns the calls that succeeded, in order, nil if there were none
+func (r *recorder) Calls() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// Reset forgets the recorded calls, failures are kept
+func (r *recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = nil
+}
+
+// Fail makes the calls starting with prefix fail with err instead of being recorded,
+// whole words only: "special" fails every special key, "special SHIFT true" only pressing shift
+// and "" every call. A nil err removes the failure
+func (r *recorder) Fail(prefix string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err == nil {
+		delete(r.failures, prefix)
+		return
+	}
+	if r.failures == nil {
+		r.failures = make(map[string]error)
+	}
+	r.failures[prefix] = err
+}
+
+func (r *recorder) call(name string, args ...any) error {
+	parts := []string{name}
+	for _, arg := range args {
+		// directions print as vertical/horizontal rather than true/false
+		if text, ok := arg.(encoding.TextMarshaler); ok {
+			b, _ := text.MarshalText()
+			parts = append(parts, string(b))
+		} else {
+			parts = append(parts, fmt.Sprint(arg))
+		}
+	}
+	call := strings.Join(parts, " ")
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for prefix, err := range r.failures {
+		if prefix == "" || call == prefix || strings.HasPrefix(call, prefix+" ") {
+			return err
+		}
+	}
+	r.calls = append(r.calls, call)
+	return nil
+}
@@ -0,0 +1,61 @@
+package fake
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type failTest struct {
+	prefix   string
+	expected []string
+}
+
+func TestFail(t *testing.T) {
+	failTests := []failTest{
+		{"", nil},
+		{"special", []string{"char a true", "move 1 -2", "scroll vertical 120"}},
+		{"special SHIFT true", []string{"char a true", "special SHIFT false", "move 1 -2", "scroll vertical 120"}},
+		// whole words only
+		{"special SHIFT t", []string{"char a true", "special SHIFT true", "special SHIFT false", "move 1 -2", "scroll vertical 120"}},
+		{"move", []string{"char a true", "special SHIFT true", "special SHIFT false", "scroll vertical 120"}},
+	}
+	failed := errors.New("failed")
+	for _, test := range failTests {
+		k, m := &Keyboard{}, &Mouse{}
+		k.Fail(test.prefix, failed)
+		m.Fail(test.prefix, failed)
+		var errs []error
+		errs = append(errs,
+			k.SendInputKeyChar('a', true),
+			k.SendInputKeySpecialKey(types.SHIFT, true),
+			k.SendInputKeySpecialKey(types.SHIFT, false),
+			m.SendInputMove(1, -2),
+			m.SendInputScroll(types.VWheel, 120),
+		)
+		calls := append(k.Calls(), m.Calls()...)
+		assert.Equal(t, test.expected, calls)
+		// every call is either recorded or failed
+		failures := 0
+		for _, err := range errs {
+			if err != nil {
+				assert.Equal(t, failed, err)
+				failures++
+			}
+		}
+		assert.Len(t, calls, len(errs)-failures)
+	}
+}
+
+func TestFailUndo(t *testing.T) {
+	k := &Keyboard{}
+	k.Fail("text", errors.New("failed"))
+	assert.Error(t, k.TypeText("hi"))
+	k.Fail("text", nil)
+	assert.NoError(t, k.TypeText("hi"))
+	assert.Equal(t, []string{"text hi"}, k.Calls())
+	k.Reset()
+	assert.Empty(t, k.Calls())
+}
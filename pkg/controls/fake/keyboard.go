@@ -0,0 +1,67 @@
+package fake
+
+import (
+	"unicode/utf8"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// the code the windows backend fails with on a character it can't send (ERROR_BAD_ARGUMENTS)
+const badCharacterCode = 160
+
+// Keyboard is a keyboard.Keyboard that records calls as
+// "char <key> <down>", "text <text>", "special <key> <down>" and "physical <key> <down>".
+// It rejects what the real backends reject, with the same errors.
+// The zero value is ready to use
+type Keyboard struct {
+	recorder
+}
+
+var _ keyboard.Keyboard = (*Keyboard)(nil)
+
+func (k *Keyboard) SendInputKeyChar(key rune, down bool) error {
+	if !utf8.ValidRune(key) {
+		return pkgerrors.NewKeyboardInputError(badCharacterCode)
+	}
+	return k.call("char", string(key), down)
+}
+
+func (k *Keyboard) TypeText(text string) error {
+	return k.call("text", text)
+}
+
+func (k *Keyboard) SendInputKeySpecialKey(key types.SpecialKeyboardKey, down bool) error {
+	if !key.Valid() {
+		return pkgerrors.NewNotImplementedError("SendInputKeySpecialKey", string(key))
+	}
+	return k.call("special", key, down)
+}
+
+func (k *Keyboard) SendInputPhysicalKey(key types.PhysicalKey, down bool) error {
+	if !keyboard.IsPhysicalKey(key) {
+		return pkgerrors.NewNotImplementedError("SendInputPhysicalKey", string(key))
+	}
+	return k.call("physical", key, down)
+}
+
+// ChordKeyboard is a Keyboard that also sends chords natively,
+// recorded as "chord <chord>" in the format of keyboard.ParseChord
+type ChordKeyboard struct {
+	Keyboard
+}
+
+var _ keyboard.ChordKeyboard = (*ChordKeyboard)(nil)
+
+func (k *ChordKeyboard) SendInputChord(chord types.Chord) error {
+	for _, modifier := range chord.Modifiers {
+		if !modifier.Valid() {
+			return pkgerrors.NewNotImplementedError("SendInputChord", string(modifier))
+		}
+	}
+	if chord.Key != "" && !chord.Key.Valid() {
+		return pkgerrors.NewNotImplementedError("SendInputChord", string(chord.Key))
+	}
+	return k.call("chord", keyboard.ChordString(chord))
+}
@@ -0,0 +1,59 @@
+package fake
+
+import (
+	"fmt"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// Mouse is a mouse.Mouse that records calls as
+// "move <dx> <dy>", "mouse <key>" and "scroll <direction> <magnitude>".
+// It rejects what the real backends reject, with the same errors.
+// The zero value is ready to use
+type Mouse struct {
+	recorder
+}
+
+var _ mouse.Mouse = (*Mouse)(nil)
+
+func (m *Mouse) SendInputMove(dx int, dy int) error {
+	return m.call("move", dx, dy)
+}
+
+func (m *Mouse) SendInputKey(button types.MouseKey) error {
+	if !button.Valid() {
+		return pkgerrors.NewNotImplementedError("SendInputKey", string(button))
+	}
+	return m.call("mouse", button)
+}
+
+func (m *Mouse) SendInputScroll(direction types.MouseWheelDir, magnitude int) error {
+	return m.call("scroll", direction, magnitude)
+}
+
+// BatchMouse is a Mouse that also sends batches, recorded as "batch <number of events>"
+type BatchMouse struct {
+	Mouse
+}
+
+var _ mouse.BatchMouse = (*BatchMouse)(nil)
+
+func (m *BatchMouse) SendInputBatch(events []types.MouseEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for _, event := range events {
+		switch event.Type {
+		case types.MouseMoveEvent, types.MouseScrollEvent:
+		case types.MouseKeyEvent:
+			if !event.Key.Valid() {
+				return pkgerrors.NewNotImplementedError("SendInputBatch", string(event.Key))
+			}
+		default:
+			return pkgerrors.NewNotImplementedError("SendInputBatch", fmt.Sprintf("event type %d", event.Type))
+		}
+	}
+	return m.call("batch", len(events))
+}
@@ -0,0 +1,13 @@
+package gamepad
+
+import "github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+
+// sticks take x and y in range -1 to 1 (positive is right and down), triggers take 0 to 1
+type Gamepad interface {
+	SendInputButton(button types.GamepadButton, down bool) error
+	SendInputStick(stick types.GamepadStick, x float64, y float64) error
+	SendInputTrigger(trigger types.GamepadTrigger, value float64) error
+	// closed when the gamepad is closed
+	Rumble() <-chan types.GamepadRumble
+	Close() error
+}
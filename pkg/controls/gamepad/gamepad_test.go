@@ -0,0 +1,53 @@
+//go:build linux
+
+package gamepad
+
+import (
+	"os"
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/internal/controls/gamepad"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type sendInputButtonTest struct {
+	button   types.GamepadButton
+	down     bool
+	expected bool
+}
+
+func newUinputGamepad(t *testing.T) Gamepad {
+	if _, err := os.Stat("/dev/uinput"); err != nil {
+		t.Skip("uinput is not available")
+	}
+	pad, err := gamepad.NewGamepad_uinput(0)
+	if err != nil {
+		t.Skipf("can't create a uinput gamepad: %v", err)
+	}
+	return pad
+}
+
+func TestSendInputButton(t *testing.T) {
+	sendInputButtonTests := []sendInputButtonTest{
+		{types.GamepadA, true, true},
+		{types.GamepadA, false, true},
+		{types.GamepadDpadUp, true, true},
+		{types.GamepadDpadUp, false, true},
+		{types.GamepadButton("this_is_not_a_button"), true, false},
+	}
+	var gamepad_impl Gamepad = newUinputGamepad(t)
+	defer gamepad_impl.Close()
+	for _, test := range sendInputButtonTests {
+		assert.Equal(t, gamepad_impl.SendInputButton(test.button, test.down) == nil, test.expected)
+	}
+}
+
+func TestSendInputAxes(t *testing.T) {
+	var gamepad_impl Gamepad = newUinputGamepad(t)
+	defer gamepad_impl.Close()
+	assert.Nil(t, gamepad_impl.SendInputStick(types.LeftStick, -1, 0.5))
+	assert.Nil(t, gamepad_impl.SendInputStick(types.RightStick, 2, -2))
+	assert.Nil(t, gamepad_impl.SendInputTrigger(types.LeftTrigger, 0.5))
+	assert.NotNil(t, gamepad_impl.SendInputTrigger(types.GamepadTrigger("this_is_not_a_trigger"), 1))
+}
@@ -0,0 +1,115 @@
+package gamepad
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PoolFullError indicates that every gamepad of a pool is assigned
+type PoolFullError struct {
+	Size int
+}
+
+func (e *PoolFullError) Error() string {
+	return fmt.Sprintf("PoolFullError: all %d gamepads are assigned", e.Size)
+}
+
+func NewPoolFullError(size int) error {
+	return &PoolFullError{
+		Size: size,
+	}
+}
+
+// Pool gives every peer its own gamepad, up to a fixed number of gamepads.
+// Gamepads are created when assigned and closed when released
+type Pool struct {
+	mutex      sync.Mutex
+	newGamepad func(index int) (Gamepad, error)
+	// peer id using each index, empty if free
+	slots    []string
+	gamepads map[string]Gamepad
+}
+
+// NewPool creates a pool of size gamepads, newGamepad creates the gamepad for an index
+func NewPool(size int, newGamepad func(index int) (Gamepad, error)) *Pool {
+	return &Pool{
+		newGamepad: newGamepad,
+		slots:      make([]string, size),
+		gamepads:   make(map[string]Gamepad),
+	}
+}
+
+// Assign returns the gamepad of a peer, creating one at the lowest free index if it has none
+func (p *Pool) Assign(peerId string) (pad Gamepad, created bool, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if pad, ok := p.gamepads[peerId]; ok {
+		return pad, false, nil
+	}
+	for index, slot := range p.slots {
+		if slot != "" {
+			continue
+		}
+		pad, err := p.newGamepad(index)
+		if err != nil {
+			return nil, false, err
+		}
+		p.slots[index] = peerId
+		p.gamepads[peerId] = pad
+		return pad, true, nil
+	}
+	return nil, false, NewPoolFullError(len(p.slots))
+}
+
+func (p *Pool) Gamepad(peerId string) (Gamepad, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pad, ok := p.gamepads[peerId]
+	return pad, ok
+}
+
+// Index returns the index of the gamepad assigned to a peer
+func (p *Pool) Index(peerId string) (int, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for index, slot := range p.slots {
+		if slot == peerId && peerId != "" {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// Release closes the gamepad of a peer and frees its index
+func (p *Pool) Release(peerId string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.release(peerId)
+}
+
+// Close closes every gamepad in the pool
+func (p *Pool) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var errs []error
+	for peerId := range p.gamepads {
+		errs = append(errs, p.release(peerId))
+	}
+	return errors.Join(errs...)
+}
+
+// (LOCK MUTEX BEFORE USING THIS)
+func (p *Pool) release(peerId string) error {
+	pad, ok := p.gamepads[peerId]
+	if !ok {
+		return nil
+	}
+	delete(p.gamepads, peerId)
+	for index, slot := range p.slots {
+		if slot == peerId {
+			p.slots[index] = ""
+		}
+	}
+	return pad.Close()
+}
@@ -0,0 +1,69 @@
+package gamepad
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGamepad struct {
+	index  int
+	closed bool
+}
+
+func (f *fakeGamepad) SendInputButton(button types.GamepadButton, down bool) error { return nil }
+func (f *fakeGamepad) SendInputStick(stick types.GamepadStick, x float64, y float64) error {
+	return nil
+}
+func (f *fakeGamepad) SendInputTrigger(trigger types.GamepadTrigger, value float64) error { return nil }
+func (f *fakeGamepad) Rumble() <-chan types.GamepadRumble                                 { return nil }
+func (f *fakeGamepad) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newFakeGamepad(index int) (Gamepad, error) {
+	return &fakeGamepad{index: index}, nil
+}
+
+func TestAssign(t *testing.T) {
+	pool := NewPool(2, newFakeGamepad)
+	p1, created, err := pool.Assign("p1")
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, 0, p1.(*fakeGamepad).index)
+	// the same peer keeps its gamepad
+	again, created, err := pool.Assign("p1")
+	assert.Nil(t, err)
+	assert.False(t, created)
+	assert.Same(t, p1, again)
+	p2, _, err := pool.Assign("p2")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, p2.(*fakeGamepad).index)
+	_, _, err = pool.Assign("p3")
+	assert.Equal(t, &PoolFullError{Size: 2}, err)
+	// releasing frees the index for the next peer
+	assert.Nil(t, pool.Release("p1"))
+	assert.True(t, p1.(*fakeGamepad).closed)
+	p3, created, err := pool.Assign("p3")
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, 0, p3.(*fakeGamepad).index)
+	index, ok := pool.Index("p3")
+	assert.True(t, ok)
+	assert.Equal(t, 0, index)
+	assert.Nil(t, pool.Close())
+	assert.True(t, p2.(*fakeGamepad).closed)
+	assert.True(t, p3.(*fakeGamepad).closed)
+}
+
+func TestAssignError(t *testing.T) {
+	failure := errors.New("no uinput")
+	pool := NewPool(1, func(index int) (Gamepad, error) { return nil, failure })
+	_, _, err := pool.Assign("p1")
+	assert.Equal(t, failure, err)
+	_, ok := pool.Gamepad("p1")
+	assert.False(t, ok)
+}
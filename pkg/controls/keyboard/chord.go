@@ -0,0 +1,121 @@
+package keyboard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// ChordKeyboard is implemented by keyboards that can press a whole chord in a single call
+type ChordKeyboard interface {
+	Keyboard
+	SendInputChord(chord types.Chord) error
+}
+
+// other names for special keys in chords, besides their own
+var chordKeyNames map[string]types.SpecialKeyboardKey = map[string]types.SpecialKeyboardKey{
+	"CTRL":  types.CONTROL,
+	"WIN":   types.META,
+	"SUPER": types.META,
+	"DEL":   types.DELETE,
+	"ESC":   types.ESCAPE,
+	"ENTER": types.RETURN,
+}
+
+// SendChord presses the modifiers in order, then the key, and releases them in reverse.
+// Everything pressed is released even if a key fails, so no modifier is left held
+func SendChord(k Keyboard, chord types.Chord) error {
+	if err := validateChord(chord); err != nil {
+		return err
+	}
+	if ck, ok := k.(ChordKeyboard); ok {
+		return ck.SendInputChord(chord)
+	}
+	var errs []error
+	pressed := 0
+	for _, modifier := range chord.Modifiers {
+		if err := k.SendInputKeySpecialKey(modifier, true); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		pressed++
+	}
+	if pressed == len(chord.Modifiers) {
+		errs = append(errs, pressChordKey(k, chord))
+	}
+	for i := pressed - 1; i >= 0; i-- {
+		errs = append(errs, k.SendInputKeySpecialKey(chord.Modifiers[i], false))
+	}
+	return errors.Join(errs...)
+}
+
+func pressChordKey(k Keyboard, chord types.Chord) error {
+	if chord.Key != "" {
+		if err := k.SendInputKeySpecialKey(chord.Key, true); err != nil {
+			return err
+		}
+		return k.SendInputKeySpecialKey(chord.Key, false)
+	}
+	if err := k.SendInputKeyChar(chord.Char, true); err != nil {
+		return err
+	}
+	return k.SendInputKeyChar(chord.Char, false)
+}
+
+func validateChord(chord types.Chord) error {
+	if (chord.Key == "") == (chord.Char == 0) {
+		return pkgerrors.NewNotImplementedError("SendChord", "a chord without exactly one key")
+	}
+	return nil
+}
+
+// ParseChord reads a chord like "Ctrl+Alt+Del" or "Win+r", names are case insensitive.
+// The last part is the key, either a special key or a single character
+func ParseChord(s string) (types.Chord, error) {
+	var chord types.Chord
+	parts := strings.Split(s, "+")
+	// "Ctrl++" presses plus
+	if strings.HasSuffix(s, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		last := i == len(parts)-1
+		if last && utf8.RuneCountInString(part) == 1 {
+			chord.Char, _ = utf8.DecodeRuneInString(part)
+			break
+		}
+		key, ok := chordKeyNames[strings.ToUpper(part)]
+		if !ok {
+			key = types.SpecialKeyboardKey(strings.ToUpper(part))
+		}
+		if part == "" || !key.Valid() {
+			return types.Chord{}, pkgerrors.NewUnmarshalError(fmt.Errorf("unknown key '%s' in chord '%s'", part, s))
+		}
+		if last {
+			chord.Key = key
+		} else {
+			chord.Modifiers = append(chord.Modifiers, key)
+		}
+	}
+	return chord, nil
+}
+
+// ChordString formats a chord the way ParseChord reads it
+func ChordString(chord types.Chord) string {
+	parts := make([]string, 0, len(chord.Modifiers)+1)
+	for _, modifier := range chord.Modifiers {
+		parts = append(parts, string(modifier))
+	}
+	if chord.Key != "" {
+		parts = append(parts, string(chord.Key))
+	} else {
+		parts = append(parts, string(unicode.ToLower(chord.Char)))
+	}
+	return strings.Join(parts, "+")
+}
@@ -0,0 +1,158 @@
+package keyboard_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type parseChordTest struct {
+	chord    string
+	expected types.Chord
+	err      bool
+}
+
+func TestParseChord(t *testing.T) {
+	parseChordTests := []parseChordTest{
+		{"Ctrl+Alt+Del", types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL, types.ALT}, Key: types.DELETE}, false},
+		{"Win+r", types.Chord{Modifiers: []types.SpecialKeyboardKey{types.META}, Char: 'r'}, false},
+		{"CONTROL+SHIFT+ESCAPE", types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL, types.SHIFT}, Key: types.ESCAPE}, false},
+		{"LALT + F4", types.Chord{Modifiers: []types.SpecialKeyboardKey{types.LALT}, Key: types.F4}, false},
+		{"Ctrl++", types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL}, Char: '+'}, false},
+		{"é", types.Chord{Char: 'é'}, false},
+		{"Hyper+x", types.Chord{}, true},
+		{"Ctrl+", types.Chord{}, true},
+		{"Ctrl+xy", types.Chord{}, true},
+	}
+	for _, test := range parseChordTests {
+		chord, err := keyboard.ParseChord(test.chord)
+		if test.err {
+			assert.Error(t, err, test.chord)
+			continue
+		}
+		assert.NoError(t, err, test.chord)
+		assert.Equal(t, test.expected, chord, test.chord)
+	}
+}
+
+type sendChordTest struct {
+	chord    types.Chord
+	fail     types.SpecialKeyboardKey
+	expected []string
+	err      bool
+}
+
+func TestSendChord(t *testing.T) {
+	sendChordTests := []sendChordTest{
+		{
+			types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL, types.SHIFT}, Key: types.ESCAPE}, "",
+			[]string{"special CONTROL true", "special SHIFT true", "special ESCAPE true", "special ESCAPE false", "special SHIFT false", "special CONTROL false"},
+			false,
+		},
+		{
+			types.Chord{Modifiers: []types.SpecialKeyboardKey{types.META}, Char: 'r'}, "",
+			[]string{"special META true", "char r true", "char r false", "special META false"},
+			false,
+		},
+		// modifiers already pressed are released if one fails
+		{
+			types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL, types.ALT}, Key: types.DELETE}, types.ALT,
+			[]string{"special CONTROL true", "special CONTROL false"},
+			true,
+		},
+		{
+			types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL}, Key: types.DELETE}, types.DELETE,
+			[]string{"special CONTROL true", "special CONTROL false"},
+			true,
+		},
+		{types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL}}, "", nil, true},
+		{types.Chord{Key: types.DELETE, Char: 'x'}, "", nil, true},
+	}
+	for _, test := range sendChordTests {
+		k := &fake.Keyboard{}
+		if test.fail != "" {
+			k.Fail(fmt.Sprintf("special %s true", test.fail), errors.New("failed"))
+		}
+		err := keyboard.SendChord(k, test.chord)
+		assert.Equal(t, test.err, err != nil, keyboard.ChordString(test.chord))
+		assert.Equal(t, test.expected, k.Calls(), keyboard.ChordString(test.chord))
+	}
+}
+
+type guardTest struct {
+	send     func(g *keyboard.Guard) error
+	expected []string
+	err      bool
+}
+
+func TestGuard(t *testing.T) {
+	blocklist, err := keyboard.ParseBlocklist(keyboard.DefaultBlocklist)
+	assert.NoError(t, err)
+	guardTests := []guardTest{
+		{
+			func(g *keyboard.Guard) error {
+				return keyboard.SendChord(g, types.Chord{Modifiers: []types.SpecialKeyboardKey{types.LMETA}, Char: 'l'})
+			},
+			nil, true,
+		},
+		{
+			func(g *keyboard.Guard) error {
+				return keyboard.SendChord(g, types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL, types.SHIFT}, Key: types.ESCAPE})
+			},
+			[]string{"special CONTROL true", "special SHIFT true", "special ESCAPE true", "special ESCAPE false", "special SHIFT false", "special CONTROL false"},
+			false,
+		},
+		// more modifiers than the shortcut are still blocked
+		{
+			func(g *keyboard.Guard) error {
+				g.SendInputKeySpecialKey(types.RCONTROL, true)
+				g.SendInputKeySpecialKey(types.LALT, true)
+				return g.SendInputKeySpecialKey(types.F4, true)
+			},
+			[]string{"special RCONTROL true", "special LALT true"},
+			true,
+		},
+		// once the modifier is released the key is fine
+		{
+			func(g *keyboard.Guard) error {
+				g.SendInputKeySpecialKey(types.LMETA, true)
+				g.SendInputKeySpecialKey(types.LMETA, false)
+				return g.SendInputKeyChar('L', true)
+			},
+			[]string{"special LMETA true", "special LMETA false", "char L true"},
+			false,
+		},
+		{
+			func(g *keyboard.Guard) error {
+				g.SendInputPhysicalKey("MetaLeft", true)
+				return g.SendInputPhysicalKey("KeyL", true)
+			},
+			[]string{"physical MetaLeft true"},
+			true,
+		},
+		// releasing always gets through
+		{
+			func(g *keyboard.Guard) error {
+				g.SendInputKeySpecialKey(types.ALT, true)
+				return g.SendInputKeySpecialKey(types.F4, false)
+			},
+			[]string{"special ALT true", "special F4 false"},
+			false,
+		},
+	}
+	for i, test := range guardTests {
+		k := &fake.Keyboard{}
+		err := test.send(keyboard.NewGuard(k, blocklist))
+		if test.err {
+			assert.IsType(t, &keyboard.ShortcutBlockedError{}, err, i)
+		} else {
+			assert.NoError(t, err, i)
+		}
+		assert.Equal(t, test.expected, k.Calls(), i)
+	}
+}
@@ -0,0 +1,189 @@
+package keyboard
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// DefaultBlocklist holds host shortcuts no client should be able to press:
+// locking the screen, killing the X server and closing the focused window (which may be the streamer)
+const DefaultBlocklist = "META+L,CONTROL+ALT+BACKSPACE,ALT+F4"
+
+// which modifier a key is, left and right keys count as the same
+var modifierKind map[types.SpecialKeyboardKey]types.SpecialKeyboardKey = map[types.SpecialKeyboardKey]types.SpecialKeyboardKey{
+	types.CONTROL:  types.CONTROL,
+	types.LCONTROL: types.CONTROL,
+	types.RCONTROL: types.CONTROL,
+	types.ALT:      types.ALT,
+	types.LALT:     types.ALT,
+	types.RALT:     types.ALT,
+	types.SHIFT:    types.SHIFT,
+	types.LSHIFT:   types.SHIFT,
+	types.RSHIFT:   types.SHIFT,
+	types.META:     types.META,
+	types.LMETA:    types.META,
+	types.RMETA:    types.META,
+}
+
+// ShortcutBlockedError indicates that a key would have pressed a blocked shortcut
+type ShortcutBlockedError struct {
+	Shortcut string
+}
+
+func (e *ShortcutBlockedError) Error() string {
+	return fmt.Sprintf("ShortcutBlockedError: shortcut '%s' is blocked", e.Shortcut)
+}
+
+func NewShortcutBlockedError(shortcut string) error {
+	return &ShortcutBlockedError{
+		Shortcut: shortcut,
+	}
+}
+
+// ParseBlocklist reads a comma separated list of chords, as for ParseChord
+func ParseBlocklist(s string) ([]types.Chord, error) {
+	blocklist := make([]types.Chord, 0)
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		chord, err := ParseChord(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		blocklist = append(blocklist, chord)
+	}
+	return blocklist, nil
+}
+
+// Guard wraps a keyboard and refuses key presses that would complete a blocked shortcut
+// with the modifiers currently held. A shortcut is blocked even if more modifiers are held.
+// Releasing keys is always passed on, so nothing gets stuck
+type Guard struct {
+	mutex     sync.Mutex
+	keyboard  Keyboard
+	blocklist []types.Chord
+	// held modifier keys, by the key that was pressed
+	held map[any]types.SpecialKeyboardKey
+}
+
+func NewGuard(k Keyboard, blocklist []types.Chord) *Guard {
+	return &Guard{
+		keyboard:  k,
+		blocklist: blocklist,
+		held:      make(map[any]types.SpecialKeyboardKey),
+	}
+}
+
+func (g *Guard) SendInputKeyChar(key rune, down bool) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if down {
+		if err := g.check(g.heldModifiers(), types.Chord{Char: key}); err != nil {
+			return err
+		}
+	}
+	return g.keyboard.SendInputKeyChar(key, down)
+}
+
+// text is typed as unicode characters, which don't trigger shortcuts
+func (g *Guard) TypeText(text string) error {
+	return g.keyboard.TypeText(text)
+}
+
+func (g *Guard) SendInputKeySpecialKey(key types.SpecialKeyboardKey, down bool) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.sendKey(key, key, types.Chord{Key: key}, down, func() error {
+		return g.keyboard.SendInputKeySpecialKey(key, down)
+	})
+}
+
+// physical keys are checked as the key they are on a US layout
+func (g *Guard) SendInputPhysicalKey(key types.PhysicalKey, down bool) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	var chord types.Chord
+	if special, ok := physicalSpecialKey[key]; ok {
+		chord.Key = special
+	} else {
+		chord.Char, _ = LayoutUS.Char(key, false, false, false)
+	}
+	return g.sendKey(key, chord.Key, chord, down, func() error {
+		return g.keyboard.SendInputPhysicalKey(key, down)
+	})
+}
+
+func (g *Guard) SendInputChord(chord types.Chord) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	modifiers := g.heldModifiers()
+	for _, modifier := range chord.Modifiers {
+		if kind, ok := modifierKind[modifier]; ok {
+			modifiers[kind] = true
+		}
+	}
+	if err := g.check(modifiers, chord); err != nil {
+		return err
+	}
+	return SendChord(g.keyboard, chord)
+}
+
+// track a modifier or check a key before sending it (LOCK MUTEX BEFORE USING THIS)
+func (g *Guard) sendKey(id any, special types.SpecialKeyboardKey, chord types.Chord, down bool, send func() error) error {
+	if kind, ok := modifierKind[special]; ok {
+		if err := send(); err != nil {
+			return err
+		}
+		if down {
+			g.held[id] = kind
+		} else {
+			delete(g.held, id)
+		}
+		return nil
+	}
+	if down {
+		if err := g.check(g.heldModifiers(), chord); err != nil {
+			return err
+		}
+	}
+	return send()
+}
+
+// (LOCK MUTEX BEFORE USING THIS)
+func (g *Guard) heldModifiers() map[types.SpecialKeyboardKey]bool {
+	modifiers := make(map[types.SpecialKeyboardKey]bool)
+	for _, kind := range g.held {
+		modifiers[kind] = true
+	}
+	return modifiers
+}
+
+// (LOCK MUTEX BEFORE USING THIS)
+func (g *Guard) check(modifiers map[types.SpecialKeyboardKey]bool, chord types.Chord) error {
+	for _, blocked := range g.blocklist {
+		if blocks(blocked, modifiers, chord) {
+			return NewShortcutBlockedError(ChordString(blocked))
+		}
+	}
+	return nil
+}
+
+func blocks(blocked types.Chord, modifiers map[types.SpecialKeyboardKey]bool, chord types.Chord) bool {
+	if blocked.Key != "" && blocked.Key != chord.Key {
+		return false
+	}
+	if blocked.Char != 0 && unicode.ToLower(blocked.Char) != unicode.ToLower(chord.Char) {
+		return false
+	}
+	for _, modifier := range blocked.Modifiers {
+		if !modifiers[modifierKind[modifier]] {
+			return false
+		}
+	}
+	return true
+}
@@ -4,5 +4,9 @@ import "github.com/benu-cloud/benu-webrtc/pkg/controls/types"
 
 type Keyboard interface {
 	SendInputKeyChar(key rune, down bool) error
+	// line breaks, \r\n included, are typed as one enter
+	TypeText(text string) error
 	SendInputKeySpecialKey(key types.SpecialKeyboardKey, down bool) error
+	// press the key at a position, whatever the layout of the host
+	SendInputPhysicalKey(key types.PhysicalKey, down bool) error
 }
@@ -1,20 +1,42 @@
-package keyboard
+package keyboard_test
 
 import (
 	"testing"
 
 	pkgerrors "github.com/benu-cloud/benu-errors"
-	"github.com/benu-cloud/benu-webrtc/internal/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
 	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
 	"github.com/stretchr/testify/assert"
 )
 
+// the keyboards the table tests run against, keyboard_windows_test.go adds the real backend
+var keyboards = map[string]func() keyboard.ChordKeyboard{
+	"fake": func() keyboard.ChordKeyboard { return &fake.ChordKeyboard{} },
+}
+
 type sendKeyCharTest struct {
 	key      rune
 	down     bool
 	expected error
 }
 
+type typeTextTest struct {
+	text     string
+	expected error
+}
+
+type sendPhysicalKeyTest struct {
+	key      types.PhysicalKey
+	down     bool
+	expected error
+}
+
+type sendInputChordTest struct {
+	chord    types.Chord
+	expected error
+}
+
 type sendKeySpecialKeyTest struct {
 	key      types.SpecialKeyboardKey
 	down     bool
@@ -26,10 +48,31 @@ func TestSendKeyChar(t *testing.T) {
 		{'a', true, nil},
 		{'b', false, nil},
 		{'\n', true, nil},
+		{'é', true, nil},
+		{'日', false, nil},
+		{'😀', true, nil},
+		{rune(0xD800), true, &pkgerrors.KeyboardInputError{Code: 160}},
 	}
-	var keyboard_impl Keyboard = &keyboard.Keyboard_c{}
-	for _, test := range sendKeyCharTests {
-		assert.Equal(t, keyboard_impl.SendInputKeyChar(test.key, test.down), test.expected)
+	for name, newKeyboard := range keyboards {
+		keyboard_impl := newKeyboard()
+		for _, test := range sendKeyCharTests {
+			assert.Equal(t, test.expected, keyboard_impl.SendInputKeyChar(test.key, test.down), name)
+		}
+	}
+}
+
+func TestTypeText(t *testing.T) {
+	typeTextTests := []typeTextTest{
+		{"", nil},
+		{"hello\n", nil},
+		{"Zoë Ångström", nil},
+		{"名前 😀", nil},
+	}
+	for name, newKeyboard := range keyboards {
+		keyboard_impl := newKeyboard()
+		for _, test := range typeTextTests {
+			assert.Equal(t, test.expected, keyboard_impl.TypeText(test.text), name)
+		}
 	}
 }
 
@@ -39,8 +82,41 @@ func TestSendKeySpecialKey(t *testing.T) {
 		{types.CAPSLOCK, false, nil},
 		{types.SpecialKeyboardKey("this_is_not_a_key"), true, &pkgerrors.NotImplementedError{Where: "SendInputKeySpecialKey", Feature: "this_is_not_a_key"}},
 	}
-	var keyboard_impl Keyboard = &keyboard.Keyboard_c{}
-	for _, test := range sendKeySpecialKeyTests {
-		assert.Equal(t, keyboard_impl.SendInputKeySpecialKey(test.key, test.down), test.expected)
+	for name, newKeyboard := range keyboards {
+		keyboard_impl := newKeyboard()
+		for _, test := range sendKeySpecialKeyTests {
+			assert.Equal(t, test.expected, keyboard_impl.SendInputKeySpecialKey(test.key, test.down), name)
+		}
+	}
+}
+
+func TestSendPhysicalKey(t *testing.T) {
+	sendPhysicalKeyTests := []sendPhysicalKeyTest{
+		{"KeyQ", true, nil},
+		{"KeyQ", false, nil},
+		{"ArrowLeft", true, nil},
+		{"ArrowLeft", false, nil},
+		{"NotAKey", true, &pkgerrors.NotImplementedError{Where: "SendInputPhysicalKey", Feature: "NotAKey"}},
+	}
+	for name, newKeyboard := range keyboards {
+		keyboard_impl := newKeyboard()
+		for _, test := range sendPhysicalKeyTests {
+			assert.Equal(t, test.expected, keyboard_impl.SendInputPhysicalKey(test.key, test.down), name)
+		}
+	}
+}
+
+func TestSendInputChord(t *testing.T) {
+	sendInputChordTests := []sendInputChordTest{
+		{types.Chord{Modifiers: []types.SpecialKeyboardKey{types.CONTROL, types.SHIFT}, Key: types.ESCAPE}, nil},
+		{types.Chord{Key: types.F5}, nil},
+		{types.Chord{Modifiers: []types.SpecialKeyboardKey{types.SHIFT}, Char: 'a'}, nil},
+		{types.Chord{Modifiers: []types.SpecialKeyboardKey{"this_is_not_a_key"}, Char: 'a'}, &pkgerrors.NotImplementedError{Where: "SendInputChord", Feature: "this_is_not_a_key"}},
+	}
+	for name, newKeyboard := range keyboards {
+		keyboard_impl := newKeyboard()
+		for _, test := range sendInputChordTests {
+			assert.Equal(t, test.expected, keyboard_impl.SendInputChord(test.chord), name)
+		}
 	}
 }
@@ -0,0 +1,13 @@
+//go:build realinput
+
+package keyboard_test
+
+import (
+	backend "github.com/benu-cloud/benu-webrtc/internal/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+)
+
+// the real backend types on the desktop the tests run on, so it is only tested with -tags realinput
+func init() {
+	keyboards["windows"] = func() keyboard.ChordKeyboard { return &backend.Keyboard_c{} }
+}
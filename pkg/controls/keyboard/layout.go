@@ -0,0 +1,223 @@
+package keyboard
+
+import (
+	"unicode"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// the characters a key types without a modifier, with shift and with AltGr, 0 for none.
+// Dead keys type their accent right away
+type layoutKey [3]rune
+
+// Layout maps physical keys to the characters they type.
+// Only keys that type characters are in it, the space bar is left to the host
+type Layout struct {
+	Name string
+	// the right alt key is AltGr rather than alt
+	altGr bool
+	keys  map[types.PhysicalKey]layoutKey
+}
+
+// US QWERTY
+var LayoutUS *Layout = &Layout{
+	Name: "us",
+	keys: map[types.PhysicalKey]layoutKey{
+		"Backquote":     {'`', '~', 0},
+		"Digit1":        {'1', '!', 0},
+		"Digit2":        {'2', '@', 0},
+		"Digit3":        {'3', '#', 0},
+		"Digit4":        {'4', '$', 0},
+		"Digit5":        {'5', '%', 0},
+		"Digit6":        {'6', '^', 0},
+		"Digit7":        {'7', '&', 0},
+		"Digit8":        {'8', '*', 0},
+		"Digit9":        {'9', '(', 0},
+		"Digit0":        {'0', ')', 0},
+		"Minus":         {'-', '_', 0},
+		"Equal":         {'=', '+', 0},
+		"KeyQ":          {'q', 'Q', 0},
+		"KeyW":          {'w', 'W', 0},
+		"KeyE":          {'e', 'E', 0},
+		"KeyR":          {'r', 'R', 0},
+		"KeyT":          {'t', 'T', 0},
+		"KeyY":          {'y', 'Y', 0},
+		"KeyU":          {'u', 'U', 0},
+		"KeyI":          {'i', 'I', 0},
+		"KeyO":          {'o', 'O', 0},
+		"KeyP":          {'p', 'P', 0},
+		"BracketLeft":   {'[', '{', 0},
+		"BracketRight":  {']', '}', 0},
+		"KeyA":          {'a', 'A', 0},
+		"KeyS":          {'s', 'S', 0},
+		"KeyD":          {'d', 'D', 0},
+		"KeyF":          {'f', 'F', 0},
+		"KeyG":          {'g', 'G', 0},
+		"KeyH":          {'h', 'H', 0},
+		"KeyJ":          {'j', 'J', 0},
+		"KeyK":          {'k', 'K', 0},
+		"KeyL":          {'l', 'L', 0},
+		"Semicolon":     {';', ':', 0},
+		"Quote":         {'\'', '"', 0},
+		"Backslash":     {'\\', '|', 0},
+		"IntlBackslash": {'\\', '|', 0},
+		"KeyZ":          {'z', 'Z', 0},
+		"KeyX":          {'x', 'X', 0},
+		"KeyC":          {'c', 'C', 0},
+		"KeyV":          {'v', 'V', 0},
+		"KeyB":          {'b', 'B', 0},
+		"KeyN":          {'n', 'N', 0},
+		"KeyM":          {'m', 'M', 0},
+		"Comma":         {',', '<', 0},
+		"Period":        {'.', '>', 0},
+		"Slash":         {'/', '?', 0},
+	},
+}
+
+// French AZERTY
+var LayoutFR *Layout = &Layout{
+	Name:  "fr",
+	altGr: true,
+	keys: map[types.PhysicalKey]layoutKey{
+		"Backquote":     {'²', 0, 0},
+		"Digit1":        {'&', '1', 0},
+		"Digit2":        {'é', '2', '~'},
+		"Digit3":        {'"', '3', '#'},
+		"Digit4":        {'\'', '4', '{'},
+		"Digit5":        {'(', '5', '['},
+		"Digit6":        {'-', '6', '|'},
+		"Digit7":        {'è', '7', '`'},
+		"Digit8":        {'_', '8', '\\'},
+		"Digit9":        {'ç', '9', '^'},
+		"Digit0":        {'à', '0', '@'},
+		"Minus":         {')', '°', ']'},
+		"Equal":         {'=', '+', '}'},
+		"KeyQ":          {'a', 'A', 0},
+		"KeyW":          {'z', 'Z', 0},
+		"KeyE":          {'e', 'E', '€'},
+		"KeyR":          {'r', 'R', 0},
+		"KeyT":          {'t', 'T', 0},
+		"KeyY":          {'y', 'Y', 0},
+		"KeyU":          {'u', 'U', 0},
+		"KeyI":          {'i', 'I', 0},
+		"KeyO":          {'o', 'O', 0},
+		"KeyP":          {'p', 'P', 0},
+		"BracketLeft":   {'^', '¨', 0},
+		"BracketRight":  {'$', '£', '¤'},
+		"KeyA":          {'q', 'Q', 0},
+		"KeyS":          {'s', 'S', 0},
+		"KeyD":          {'d', 'D', 0},
+		"KeyF":          {'f', 'F', 0},
+		"KeyG":          {'g', 'G', 0},
+		"KeyH":          {'h', 'H', 0},
+		"KeyJ":          {'j', 'J', 0},
+		"KeyK":          {'k', 'K', 0},
+		"KeyL":          {'l', 'L', 0},
+		"Semicolon":     {'m', 'M', 0},
+		"Quote":         {'ù', '%', 0},
+		"Backslash":     {'*', 'µ', 0},
+		"IntlBackslash": {'<', '>', 0},
+		"KeyZ":          {'w', 'W', 0},
+		"KeyX":          {'x', 'X', 0},
+		"KeyC":          {'c', 'C', 0},
+		"KeyV":          {'v', 'V', 0},
+		"KeyB":          {'b', 'B', 0},
+		"KeyN":          {'n', 'N', 0},
+		"KeyM":          {',', '?', 0},
+		"Comma":         {';', '.', 0},
+		"Period":        {':', '/', 0},
+		"Slash":         {'!', '§', 0},
+	},
+}
+
+// German QWERTZ
+var LayoutDE *Layout = &Layout{
+	Name:  "de",
+	altGr: true,
+	keys: map[types.PhysicalKey]layoutKey{
+		"Backquote":     {'^', '°', 0},
+		"Digit1":        {'1', '!', 0},
+		"Digit2":        {'2', '"', '²'},
+		"Digit3":        {'3', '§', '³'},
+		"Digit4":        {'4', '$', 0},
+		"Digit5":        {'5', '%', 0},
+		"Digit6":        {'6', '&', 0},
+		"Digit7":        {'7', '/', '{'},
+		"Digit8":        {'8', '(', '['},
+		"Digit9":        {'9', ')', ']'},
+		"Digit0":        {'0', '=', '}'},
+		"Minus":         {'ß', '?', '\\'},
+		"Equal":         {'´', '`', 0},
+		"KeyQ":          {'q', 'Q', '@'},
+		"KeyW":          {'w', 'W', 0},
+		"KeyE":          {'e', 'E', '€'},
+		"KeyR":          {'r', 'R', 0},
+		"KeyT":          {'t', 'T', 0},
+		"KeyY":          {'z', 'Z', 0},
+		"KeyU":          {'u', 'U', 0},
+		"KeyI":          {'i', 'I', 0},
+		"KeyO":          {'o', 'O', 0},
+		"KeyP":          {'p', 'P', 0},
+		"BracketLeft":   {'ü', 'Ü', 0},
+		"BracketRight":  {'+', '*', '~'},
+		"KeyA":          {'a', 'A', 0},
+		"KeyS":          {'s', 'S', 0},
+		"KeyD":          {'d', 'D', 0},
+		"KeyF":          {'f', 'F', 0},
+		"KeyG":          {'g', 'G', 0},
+		"KeyH":          {'h', 'H', 0},
+		"KeyJ":          {'j', 'J', 0},
+		"KeyK":          {'k', 'K', 0},
+		"KeyL":          {'l', 'L', 0},
+		"Semicolon":     {'ö', 'Ö', 0},
+		"Quote":         {'ä', 'Ä', 0},
+		"Backslash":     {'#', '\'', 0},
+		"IntlBackslash": {'<', '>', '|'},
+		"KeyZ":          {'y', 'Y', 0},
+		"KeyX":          {'x', 'X', 0},
+		"KeyC":          {'c', 'C', 0},
+		"KeyV":          {'v', 'V', 0},
+		"KeyB":          {'b', 'B', 0},
+		"KeyN":          {'n', 'N', 0},
+		"KeyM":          {'m', 'M', 'µ'},
+		"Comma":         {',', ';', 0},
+		"Period":        {'.', ':', 0},
+		"Slash":         {'-', '_', 0},
+	},
+}
+
+var layouts map[string]*Layout = map[string]*Layout{
+	LayoutUS.Name: LayoutUS,
+	LayoutFR.Name: LayoutFR,
+	LayoutDE.Name: LayoutDE,
+}
+
+// LayoutByName finds a layout a client declared, by its name
+func LayoutByName(name string) (*Layout, bool) {
+	layout, ok := layouts[name]
+	return layout, ok
+}
+
+// Char returns the character a key types with the given modifiers, false if it types nothing.
+// Caps lock only affects letters
+func (l *Layout) Char(key types.PhysicalKey, shift bool, altGr bool, capsLock bool) (rune, bool) {
+	chars, ok := l.keys[key]
+	if !ok {
+		return 0, false
+	}
+	var char rune
+	switch {
+	case altGr:
+		char = chars[2]
+	case shift != (capsLock && unicode.IsLetter(chars[0]) && unicode.ToUpper(chars[0]) == chars[1]):
+		char = chars[1]
+	default:
+		char = chars[0]
+	}
+	return char, char != 0
+}
+
+// HasAltGr reports whether the right alt key is AltGr
+func (l *Layout) HasAltGr() bool {
+	return l.altGr
+}
@@ -0,0 +1,72 @@
+package keyboard
+
+import (
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type charTest struct {
+	layout   *Layout
+	key      types.PhysicalKey
+	shift    bool
+	altGr    bool
+	capsLock bool
+	expected rune
+	ok       bool
+}
+
+func TestChar(t *testing.T) {
+	charTests := []charTest{
+		{LayoutUS, "KeyQ", false, false, false, 'q', true},
+		{LayoutUS, "KeyQ", true, false, false, 'Q', true},
+		{LayoutUS, "KeyQ", false, false, true, 'Q', true},
+		{LayoutUS, "KeyQ", true, false, true, 'q', true},
+		{LayoutUS, "Digit2", true, false, false, '@', true},
+		{LayoutUS, "Digit2", false, false, true, '2', true},
+		{LayoutUS, "Digit2", false, true, false, 0, false},
+		{LayoutUS, "Enter", false, false, false, 0, false},
+		{LayoutFR, "KeyQ", false, false, false, 'a', true},
+		{LayoutFR, "KeyA", true, false, false, 'Q', true},
+		{LayoutFR, "Semicolon", false, false, false, 'm', true},
+		{LayoutFR, "Digit2", false, false, false, 'é', true},
+		{LayoutFR, "Digit2", true, false, false, '2', true},
+		{LayoutFR, "Digit0", false, true, false, '@', true},
+		{LayoutFR, "KeyE", false, true, false, '€', true},
+		{LayoutFR, "KeyM", false, false, false, ',', true},
+		{LayoutFR, "Backquote", true, false, false, 0, false},
+		{LayoutDE, "KeyY", false, false, false, 'z', true},
+		{LayoutDE, "KeyZ", true, false, false, 'Y', true},
+		{LayoutDE, "Minus", false, false, false, 'ß', true},
+		{LayoutDE, "Semicolon", false, false, true, 'Ö', true},
+		{LayoutDE, "KeyQ", false, true, false, '@', true},
+		{LayoutDE, "IntlBackslash", false, true, false, '|', true},
+	}
+	for _, test := range charTests {
+		char, ok := test.layout.Char(test.key, test.shift, test.altGr, test.capsLock)
+		assert.Equal(t, test.ok, ok, "%s %s", test.layout.Name, test.key)
+		assert.Equal(t, test.expected, char, "%s %s", test.layout.Name, test.key)
+	}
+}
+
+func TestLayoutByName(t *testing.T) {
+	for _, name := range []string{"us", "fr", "de"} {
+		layout, ok := LayoutByName(name)
+		assert.True(t, ok)
+		assert.Equal(t, name, layout.Name)
+	}
+	_, ok := LayoutByName("dvorak")
+	assert.False(t, ok)
+}
+
+// every layout has the same keys and a character for each of them without modifiers
+func TestLayoutsComplete(t *testing.T) {
+	for _, layout := range layouts {
+		assert.Len(t, layout.keys, len(LayoutUS.keys), layout.Name)
+		for key := range LayoutUS.keys {
+			_, ok := layout.Char(key, false, false, false)
+			assert.True(t, ok, "%s %s", layout.Name, key)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package keyboard
+
+import (
+	"fmt"
+	"sync"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// keys that don't type characters, sent as special keys in character mode
+var physicalSpecialKey map[types.PhysicalKey]types.SpecialKeyboardKey = map[types.PhysicalKey]types.SpecialKeyboardKey{
+	"Backspace":   types.BACKSPACE,
+	"Delete":      types.DELETE,
+	"Enter":       types.RETURN,
+	"Tab":         types.TAB,
+	"Escape":      types.ESCAPE,
+	"ArrowUp":     types.UP,
+	"ArrowDown":   types.DOWN,
+	"ArrowRight":  types.RIGHT,
+	"ArrowLeft":   types.LEFT,
+	"Home":        types.HOME,
+	"End":         types.END,
+	"PageUp":      types.PAGEUP,
+	"PageDown":    types.PAGEDOWN,
+	"Insert":      types.INSERT,
+	"Space":       types.SPACE,
+	"PrintScreen": types.PRINTSCREEN,
+	"ContextMenu": types.MENU,
+	"CapsLock":    types.CAPSLOCK,
+
+	"F1":  types.F1,
+	"F2":  types.F2,
+	"F3":  types.F3,
+	"F4":  types.F4,
+	"F5":  types.F5,
+	"F6":  types.F6,
+	"F7":  types.F7,
+	"F8":  types.F8,
+	"F9":  types.F9,
+	"F10": types.F10,
+	"F11": types.F11,
+	"F12": types.F12,
+	"F13": types.F13,
+	"F14": types.F14,
+	"F15": types.F15,
+	"F16": types.F16,
+	"F17": types.F17,
+	"F18": types.F18,
+	"F19": types.F19,
+	"F20": types.F20,
+	"F21": types.F21,
+	"F22": types.F22,
+	"F23": types.F23,
+	"F24": types.F24,
+
+	"ControlLeft":  types.LCONTROL,
+	"ControlRight": types.RCONTROL,
+	"AltLeft":      types.LALT,
+	"AltRight":     types.RALT,
+	"ShiftLeft":    types.LSHIFT,
+	"ShiftRight":   types.RSHIFT,
+	"MetaLeft":     types.LMETA,
+	"MetaRight":    types.RMETA,
+
+	"Numpad0":        types.NUMPAD_0,
+	"Numpad1":        types.NUMPAD_1,
+	"Numpad2":        types.NUMPAD_2,
+	"Numpad3":        types.NUMPAD_3,
+	"Numpad4":        types.NUMPAD_4,
+	"Numpad5":        types.NUMPAD_5,
+	"Numpad6":        types.NUMPAD_6,
+	"Numpad7":        types.NUMPAD_7,
+	"Numpad8":        types.NUMPAD_8,
+	"Numpad9":        types.NUMPAD_9,
+	"NumLock":        types.NUMPAD_LOCK,
+	"NumpadDecimal":  types.NUMPAD_DECIMAL,
+	"NumpadAdd":      types.NUMPAD_PLUS,
+	"NumpadSubtract": types.NUMPAD_MINUS,
+	"NumpadMultiply": types.NUMPAD_MUL,
+	"NumpadDivide":   types.NUMPAD_DIV,
+	"NumpadEnter":    types.NUMPAD_ENTER,
+	"NumpadEqual":    types.NUMPAD_EQUAL,
+
+	"AudioVolumeMute":    types.AUDIO_VOLUME_MUTE,
+	"AudioVolumeDown":    types.AUDIO_VOLUME_DOWN,
+	"AudioVolumeUp":      types.AUDIO_VOLUME_UP,
+	"MediaPlayPause":     types.AUDIO_PLAY,
+	"MediaStop":          types.AUDIO_STOP,
+	"MediaTrackPrevious": types.AUDIO_PREV,
+	"MediaTrackNext":     types.AUDIO_NEXT,
+}
+
+// IsPhysicalKey tells if key is a key this package knows
+func IsPhysicalKey(key types.PhysicalKey) bool {
+	if _, ok := physicalSpecialKey[key]; ok {
+		return true
+	}
+	_, ok := LayoutUS.keys[key]
+	return ok
+}
+
+// keys that make a shortcut with the key pressed after them, so shift is kept for it
+var chordModifier map[types.PhysicalKey]bool = map[types.PhysicalKey]bool{
+	"ControlLeft":  true,
+	"ControlRight": true,
+	"AltLeft":      true,
+	"AltRight":     true,
+	"MetaLeft":     true,
+	"MetaRight":    true,
+}
+
+// Mapper turns physical keys from a client into input for the host, either
+// pressing the same keys or typing the characters they make in the client's layout.
+// In character mode AltGr and caps lock only choose characters and aren't sent,
+// since the host would apply them a second time. Shift is sent, for shortcuts and to select
+// or click with it, but lifted while typing a character it chose
+type Mapper struct {
+	mutex    sync.Mutex
+	mode     types.KeyboardMode
+	layout   *Layout
+	shift    map[types.PhysicalKey]bool
+	altGr    bool
+	capsLock bool
+	// control, alt and meta keys held down
+	modifiers map[types.PhysicalKey]bool
+	// characters typed by keys that are held down, released with the key
+	pressed map[types.PhysicalKey]rune
+}
+
+// NewMapper creates a mapper for a client, layout is only used in character mode
+// and defaults to LayoutUS
+func NewMapper(mode types.KeyboardMode, layout *Layout) (*Mapper, error) {
+	switch mode {
+	case types.PhysicalMode, types.CharacterMode:
+	default:
+		return nil, pkgerrors.NewNotImplementedError("NewMapper", fmt.Sprintf("mode %s", mode))
+	}
+	if layout == nil {
+		layout = LayoutUS
+	}
+	return &Mapper{
+		mode:      mode,
+		layout:    layout,
+		shift:     make(map[types.PhysicalKey]bool),
+		modifiers: make(map[types.PhysicalKey]bool),
+		pressed:   make(map[types.PhysicalKey]rune),
+	}, nil
+}
+
+func (m *Mapper) Mode() types.KeyboardMode {
+	return m.mode
+}
+
+func (m *Mapper) Layout() *Layout {
+	return m.layout
+}
+
+// SendInputPhysicalKey sends a key the client pressed or released to k
+func (m *Mapper) SendInputPhysicalKey(k Keyboard, key types.PhysicalKey, down bool) error {
+	if m.mode == types.PhysicalMode {
+		return k.SendInputPhysicalKey(key, down)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch {
+	case key == "ShiftLeft" || key == "ShiftRight":
+		m.shift[key] = down
+		return k.SendInputKeySpecialKey(physicalSpecialKey[key], down)
+	case key == "AltRight" && m.layout.HasAltGr():
+		m.altGr = down
+		return nil
+	case key == "CapsLock":
+		if down {
+			m.capsLock = !m.capsLock
+		}
+		return nil
+	}
+	if special, ok := physicalSpecialKey[key]; ok {
+		if chordModifier[key] {
+			m.modifiers[key] = down
+		}
+		return k.SendInputKeySpecialKey(special, down)
+	}
+	if !down {
+		char, ok := m.pressed[key]
+		if !ok {
+			return nil
+		}
+		delete(m.pressed, key)
+		return k.SendInputKeyChar(char, false)
+	}
+	// in a shortcut the host applies shift itself
+	chord := m.chord()
+	shift := (m.shift["ShiftLeft"] || m.shift["ShiftRight"]) && !chord
+	char, ok := m.layout.Char(key, shift, m.altGr, m.capsLock)
+	if !ok {
+		return pkgerrors.NewNotImplementedError("SendInputPhysicalKey", fmt.Sprintf("%s in layout %s", key, m.layout.Name))
+	}
+	// a repeated key down keeps the character it started with
+	if previous, ok := m.pressed[key]; ok {
+		char = previous
+	}
+	m.pressed[key] = char
+	if !shift {
+		return k.SendInputKeyChar(char, true)
+	}
+	return m.withoutShift(k, func() error {
+		return k.SendInputKeyChar(char, true)
+	})
+}
+
+func (m *Mapper) chord() bool {
+	for _, down := range m.modifiers {
+		if down {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutShift lifts the held shift keys on the host while send runs, since shift already chose the character
+func (m *Mapper) withoutShift(k Keyboard, send func() error) error {
+	held := make([]types.PhysicalKey, 0, 2)
+	for _, key := range []types.PhysicalKey{"ShiftLeft", "ShiftRight"} {
+		if m.shift[key] {
+			held = append(held, key)
+		}
+	}
+	for _, key := range held {
+		if err := k.SendInputKeySpecialKey(physicalSpecialKey[key], false); err != nil {
+			return err
+		}
+	}
+	err := send()
+	for _, key := range held {
+		if downErr := k.SendInputKeySpecialKey(physicalSpecialKey[key], true); err == nil {
+			err = downErr
+		}
+	}
+	return err
+}
+
+// Reset forgets held modifiers and keys, once they have been released some other way
+func (m *Mapper) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.shift = make(map[types.PhysicalKey]bool)
+	m.modifiers = make(map[types.PhysicalKey]bool)
+	m.altGr = false
+	m.pressed = make(map[types.PhysicalKey]rune)
+}
@@ -0,0 +1,119 @@
+package keyboard_test
+
+import (
+	"testing"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type keyPress struct {
+	key  types.PhysicalKey
+	down bool
+}
+
+type mapperTest struct {
+	mode     types.KeyboardMode
+	layout   *keyboard.Layout
+	presses  []keyPress
+	expected []string
+}
+
+func TestMapper(t *testing.T) {
+	mapperTests := []mapperTest{
+		// physical mode ignores the layout
+		{
+			types.PhysicalMode, keyboard.LayoutFR,
+			[]keyPress{{"ShiftLeft", true}, {"KeyQ", true}, {"KeyQ", false}, {"ShiftLeft", false}},
+			[]string{"physical ShiftLeft true", "physical KeyQ true", "physical KeyQ false", "physical ShiftLeft false"},
+		},
+		// an AZERTY client types on any host, shift is lifted while typing the character it chose
+		{
+			types.CharacterMode, keyboard.LayoutFR,
+			[]keyPress{{"KeyQ", true}, {"KeyQ", false}, {"ShiftLeft", true}, {"Digit1", true}, {"Digit1", false}, {"ShiftLeft", false}},
+			[]string{"char a true", "char a false", "special LSHIFT true", "special LSHIFT false", "char 1 true", "special LSHIFT true", "char 1 false", "special LSHIFT false"},
+		},
+		// AltGr picks the third level instead of being sent
+		{
+			types.CharacterMode, keyboard.LayoutDE,
+			[]keyPress{{"AltRight", true}, {"KeyQ", true}, {"AltRight", false}, {"KeyQ", false}},
+			[]string{"char @ true", "char @ false"},
+		},
+		// on a layout without AltGr it is a plain alt key
+		{
+			types.CharacterMode, nil,
+			[]keyPress{{"AltRight", true}, {"AltRight", false}},
+			[]string{"special RALT true", "special RALT false"},
+		},
+		// a key is released with the character it typed, even if shift changed in between
+		{
+			types.CharacterMode, keyboard.LayoutUS,
+			[]keyPress{{"ShiftRight", true}, {"KeyA", true}, {"ShiftRight", false}, {"KeyA", false}},
+			[]string{"special RSHIFT true", "special RSHIFT false", "char A true", "special RSHIFT true", "special RSHIFT false", "char A false"},
+		},
+		// shift goes through for keys that don't type characters, to select or move back
+		{
+			types.CharacterMode, keyboard.LayoutUS,
+			[]keyPress{{"ShiftLeft", true}, {"ArrowLeft", true}, {"ArrowLeft", false}, {"Tab", true}, {"Tab", false}, {"ShiftLeft", false}},
+			[]string{"special LSHIFT true", "special LEFT true", "special LEFT false", "special TAB true", "special TAB false", "special LSHIFT false"},
+		},
+		// and for shortcuts, which get the unshifted character
+		{
+			types.CharacterMode, keyboard.LayoutUS,
+			[]keyPress{{"ControlLeft", true}, {"ShiftLeft", true}, {"Escape", true}, {"Escape", false}, {"KeyT", true}, {"KeyT", false}, {"ShiftLeft", false}, {"ControlLeft", false}},
+			[]string{"special LCONTROL true", "special LSHIFT true", "special ESCAPE true", "special ESCAPE false", "char t true", "char t false", "special LSHIFT false", "special LCONTROL false"},
+		},
+		{
+			types.CharacterMode, keyboard.LayoutUS,
+			[]keyPress{{"MetaLeft", true}, {"ShiftRight", true}, {"KeyS", true}, {"KeyS", false}, {"ShiftRight", false}, {"MetaLeft", false}},
+			[]string{"special LMETA true", "special RSHIFT true", "char s true", "char s false", "special RSHIFT false", "special LMETA false"},
+		},
+		{
+			types.CharacterMode, keyboard.LayoutUS,
+			[]keyPress{{"CapsLock", true}, {"CapsLock", false}, {"KeyA", true}, {"KeyA", false}, {"Digit1", true}, {"Digit1", false}},
+			[]string{"char A true", "char A false", "char 1 true", "char 1 false"},
+		},
+		// shortcuts keep their modifiers
+		{
+			types.CharacterMode, keyboard.LayoutFR,
+			[]keyPress{{"ControlLeft", true}, {"KeyW", true}, {"KeyW", false}, {"ControlLeft", false}},
+			[]string{"special LCONTROL true", "char z true", "char z false", "special LCONTROL false"},
+		},
+		{
+			types.CharacterMode, keyboard.LayoutUS,
+			[]keyPress{{"Enter", true}, {"Space", true}, {"ArrowLeft", false}, {"Numpad5", true}},
+			[]string{"special RETURN true", "special SPACE true", "special LEFT false", "special NUMPAD_5 true"},
+		},
+		// releasing a key that was never pressed does nothing
+		{
+			types.CharacterMode, keyboard.LayoutUS,
+			[]keyPress{{"KeyA", false}},
+			nil,
+		},
+	}
+	for _, test := range mapperTests {
+		m, err := keyboard.NewMapper(test.mode, test.layout)
+		assert.NoError(t, err)
+		k := &fake.Keyboard{}
+		for _, press := range test.presses {
+			assert.NoError(t, m.SendInputPhysicalKey(k, press.key, press.down))
+		}
+		assert.Equal(t, test.expected, k.Calls())
+	}
+}
+
+func TestMapperErrors(t *testing.T) {
+	_, err := keyboard.NewMapper(types.KeyboardMode("telepathic"), nil)
+	assert.Error(t, err)
+	m, _ := keyboard.NewMapper(types.CharacterMode, keyboard.LayoutFR)
+	k := &fake.Keyboard{}
+	assert.Error(t, m.SendInputPhysicalKey(k, "NotAKey", true))
+	m.SendInputPhysicalKey(k, "ShiftLeft", true)
+	// shift+² types nothing on AZERTY
+	assert.Error(t, m.SendInputPhysicalKey(k, "Backquote", true))
+	m.Reset()
+	assert.NoError(t, m.SendInputPhysicalKey(k, "Backquote", true))
+	assert.Equal(t, []string{"special LSHIFT true", "char ² true"}, k.Calls())
+}
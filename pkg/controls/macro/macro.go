@@ -0,0 +1,115 @@
+package macro
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+)
+
+// Version of the file format, the first line of every file is {"version":Version}
+const Version = 1
+
+// input messages a macro can hold
+var macroMessages map[message.MessageType]bool = map[message.MessageType]bool{
+	message.KeyCharMessage:       true,
+	message.KeySpecialKeyMessage: true,
+	message.KeyPhysicalMessage:   true,
+	message.KeyChordMessage:      true,
+	message.TextMessage:          true,
+	message.MouseMoveMessage:     true,
+	message.MouseKeyMessage:      true,
+	message.MouseScrollMessage:   true,
+}
+
+// Event is a single input call, At is the time since recording started
+type Event struct {
+	At      time.Duration
+	Payload message.Payload
+}
+
+type header struct {
+	Version int `json:"version"`
+}
+
+// every following line is an event in the format of the controls datachannel,
+// with the time in microseconds since recording started
+type line struct {
+	At int64 `json:"at"`
+	message.GenericMessage
+}
+
+// Write saves events as JSON lines
+func Write(w io.Writer, events []Event) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(header{Version: Version}); err != nil {
+		return pkgerrors.NewMarshalError(err)
+	}
+	for _, event := range events {
+		if event.Payload == nil || !macroMessages[event.Payload.Type()] {
+			return pkgerrors.NewUnsupportedMessageTypeError(fmt.Sprintf("%T", event.Payload))
+		}
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return pkgerrors.NewMarshalError(err)
+		}
+		l := line{
+			At:             event.At.Microseconds(),
+			GenericMessage: message.GenericMessage{Type: event.Payload.Type(), Payload: payload},
+		}
+		if err := encoder.Encode(l); err != nil {
+			return pkgerrors.NewMarshalError(err)
+		}
+	}
+	return nil
+}
+
+// Read loads events saved by Write, they have to be in order
+func Read(r io.Reader) ([]Event, error) {
+	scanner := bufio.NewScanner(r)
+	// text events can be long
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, pkgerrors.NewUnmarshalError(err)
+		}
+		return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("missing header"))
+	}
+	var h header
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return nil, pkgerrors.NewUnmarshalError(err)
+	}
+	if h.Version != Version {
+		return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("unsupported version %d", h.Version))
+	}
+	events := make([]Event, 0)
+	for number := 2; scanner.Scan(); number++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("line %d: %w", number, err))
+		}
+		if !macroMessages[l.Type] {
+			return nil, pkgerrors.NewUnsupportedMessageTypeError(string(l.Type))
+		}
+		payload, err := message.Unmarshal(scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		at := time.Duration(l.At) * time.Microsecond
+		if len(events) > 0 && at < events[len(events)-1].At {
+			return nil, pkgerrors.NewUnmarshalError(fmt.Errorf("line %d: event is out of order", number))
+		}
+		events = append(events, Event{At: at, Payload: payload})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, pkgerrors.NewUnmarshalError(err)
+	}
+	return events, nil
+}
@@ -0,0 +1,120 @@
+package macro
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const recorded = `{"version":1}
+{"at":0,"type":"keychar","payload":{"key":"é","down":true}}
+{"at":1500,"type":"keyspecial","payload":{"key":"SHIFT","down":true}}
+{"at":2000,"type":"mousemove","payload":{"dx":3,"dy":-4}}
+{"at":2000,"type":"mousekey","payload":{"key":"LMBDown"}}
+{"at":3000,"type":"mousescroll","payload":{"direction":"horizontal","magnitude":120}}
+{"at":4000,"type":"text","payload":{"text":"hi"}}
+`
+
+func TestRecord(t *testing.T) {
+	keyboard, mouse := &fake.Keyboard{}, &fake.Mouse{}
+	r := NewRecorder()
+	now := r.start
+	r.now = func() time.Time { return now }
+	k, m := r.Keyboard(keyboard), r.Mouse(mouse)
+	k.SendInputKeyChar('é', true)
+	now = now.Add(1500 * time.Microsecond)
+	k.SendInputKeySpecialKey(types.SHIFT, true)
+	now = now.Add(500 * time.Microsecond)
+	m.SendInputMove(3, -4)
+	m.SendInputKey(types.LMBDown)
+	now = now.Add(time.Millisecond)
+	m.SendInputScroll(types.HWheel, 120)
+	now = now.Add(time.Millisecond)
+	k.TypeText("hi")
+	// failed input isn't recorded
+	keyboard.Fail("", fmt.Errorf("failed"))
+	assert.Error(t, k.TypeText("lost"))
+
+	assert.Len(t, append(keyboard.Calls(), mouse.Calls()...), 6)
+	var saved bytes.Buffer
+	assert.NoError(t, Write(&saved, r.Events()))
+	assert.Equal(t, recorded, saved.String())
+}
+
+type readTest struct {
+	file string
+	err  bool
+}
+
+func TestRead(t *testing.T) {
+	readTests := []readTest{
+		{recorded, false},
+		{`{"version":1}`, false},
+		{``, true},
+		{`{"version":2}`, true},
+		{"{\"version\":1}\n{\"at\":0,\"type\":\"controlrequest\"}", true},
+		{"{\"version\":1}\n{\"at\":5,\"type\":\"text\",\"payload\":{\"text\":\"a\"}}\n{\"at\":4,\"type\":\"text\",\"payload\":{\"text\":\"b\"}}", true},
+		{"{\"version\":1}\nnot json", true},
+	}
+	for _, test := range readTests {
+		events, err := Read(strings.NewReader(test.file))
+		if test.err {
+			assert.Error(t, err, test.file)
+			continue
+		}
+		assert.NoError(t, err, test.file)
+		// reading and writing again gives the same file
+		var saved bytes.Buffer
+		assert.NoError(t, Write(&saved, events))
+		assert.Equal(t, strings.TrimSuffix(test.file, "\n"), strings.TrimSuffix(saved.String(), "\n"))
+	}
+}
+
+func TestWriteOnlyInput(t *testing.T) {
+	err := Write(&bytes.Buffer{}, []Event{{Payload: &message.ControlRequestPayload{}}})
+	assert.Error(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	events, err := Read(strings.NewReader(recorded))
+	assert.NoError(t, err)
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	assert.NoError(t, Replay(context.Background(), events, k, m, 0))
+	// keys still held down at the end are released
+	assert.Equal(t, []string{"char é true", "special SHIFT true", "text hi"}, k.Calls()[:3])
+	assert.ElementsMatch(t, []string{"char é false", "special SHIFT false"}, k.Calls()[3:])
+	assert.Equal(t, []string{"move 3 -4", "mouse LMBDown", "scroll horizontal 120", "mouse LMBUp"}, m.Calls())
+}
+
+func TestReplaySpeed(t *testing.T) {
+	events := []Event{
+		{At: 0, Payload: &message.TextPayload{Text: "a"}},
+		{At: 100 * time.Millisecond, Payload: &message.TextPayload{Text: "b"}},
+	}
+	k := &fake.Keyboard{}
+	start := time.Now()
+	assert.NoError(t, Replay(context.Background(), events, k, &fake.Mouse{}, 2))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, []string{"text a", "text b"}, k.Calls())
+}
+
+func TestReplayCanceled(t *testing.T) {
+	events := []Event{
+		{At: 0, Payload: &message.KeySpecialKeyPayload{Key: types.LALT, Down: true}},
+		{At: time.Hour, Payload: &message.TextPayload{Text: "never"}},
+	}
+	k := &fake.Keyboard{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := Replay(ctx, events, k, &fake.Mouse{}, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"special LALT true", "special LALT false"}, k.Calls())
+}
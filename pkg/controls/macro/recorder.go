@@ -0,0 +1,98 @@
+package macro
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// Recorder records the input that passes through the keyboards and mice it wraps.
+// Only input the backend accepted is recorded
+type Recorder struct {
+	mutex  sync.Mutex
+	start  time.Time
+	events []Event
+	// replaced in tests
+	now func() time.Time
+}
+
+// NewRecorder starts recording, event times are relative to now
+func NewRecorder() *Recorder {
+	return &Recorder{
+		start: time.Now(),
+		now:   time.Now,
+	}
+}
+
+// Keyboard wraps a keyboard so its input is recorded
+func (r *Recorder) Keyboard(k keyboard.Keyboard) keyboard.Keyboard {
+	return &recordingKeyboard{recorder: r, keyboard: k}
+}
+
+// Mouse wraps a mouse so its input is recorded
+func (r *Recorder) Mouse(m mouse.Mouse) mouse.Mouse {
+	return &recordingMouse{recorder: r, mouse: m}
+}
+
+// Events returns everything recorded so far
+func (r *Recorder) Events() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Event{}, r.events...)
+}
+
+func (r *Recorder) record(payload message.Payload, err error) error {
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, Event{At: r.now().Sub(r.start), Payload: payload})
+	return nil
+}
+
+type recordingKeyboard struct {
+	recorder *Recorder
+	keyboard keyboard.Keyboard
+}
+
+func (k *recordingKeyboard) SendInputKeyChar(key rune, down bool) error {
+	return k.recorder.record(&message.KeyCharPayload{Key: string(key), Down: down}, k.keyboard.SendInputKeyChar(key, down))
+}
+
+func (k *recordingKeyboard) TypeText(text string) error {
+	return k.recorder.record(&message.TextPayload{Text: text}, k.keyboard.TypeText(text))
+}
+
+func (k *recordingKeyboard) SendInputKeySpecialKey(key types.SpecialKeyboardKey, down bool) error {
+	return k.recorder.record(&message.KeySpecialKeyPayload{Key: key, Down: down}, k.keyboard.SendInputKeySpecialKey(key, down))
+}
+
+func (k *recordingKeyboard) SendInputPhysicalKey(key types.PhysicalKey, down bool) error {
+	return k.recorder.record(&message.KeyPhysicalPayload{Key: key, Down: down}, k.keyboard.SendInputPhysicalKey(key, down))
+}
+
+func (k *recordingKeyboard) SendInputChord(chord types.Chord) error {
+	return k.recorder.record(message.NewKeyChordPayload(chord), keyboard.SendChord(k.keyboard, chord))
+}
+
+type recordingMouse struct {
+	recorder *Recorder
+	mouse    mouse.Mouse
+}
+
+func (m *recordingMouse) SendInputMove(dx int, dy int) error {
+	return m.recorder.record(&message.MouseMovePayload{Dx: dx, Dy: dy}, m.mouse.SendInputMove(dx, dy))
+}
+
+func (m *recordingMouse) SendInputKey(button types.MouseKey) error {
+	return m.recorder.record(&message.MouseKeyPayload{Key: button}, m.mouse.SendInputKey(button))
+}
+
+func (m *recordingMouse) SendInputScroll(direction types.MouseWheelDir, magnitude int) error {
+	return m.recorder.record(&message.MouseScrollPayload{Direction: direction, Magnitude: magnitude}, m.mouse.SendInputScroll(direction, magnitude))
+}
@@ -0,0 +1,85 @@
+package macro
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/message"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/tracker"
+)
+
+// Replay sends events to a keyboard and mouse with their recorded timing divided by speed,
+// a speed of zero or less sends them without waiting.
+// Keys and buttons still held down when the replay ends or is canceled are released
+func Replay(ctx context.Context, events []Event, k keyboard.Keyboard, m mouse.Mouse, speed float64) error {
+	t := tracker.NewTracker(k, m, 0)
+	err := replay(ctx, events, t.Keyboard(""), t.Mouse(""), speed)
+	return errors.Join(err, t.ReleaseAll())
+}
+
+func replay(ctx context.Context, events []Event, k keyboard.Keyboard, m mouse.Mouse, speed float64) error {
+	start := time.Now()
+	for _, event := range events {
+		if speed > 0 {
+			// wait relative to the start so delays don't add up
+			wait := time.Until(start.Add(time.Duration(float64(event.At) / speed)))
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(event.Payload, k, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func send(payload message.Payload, k keyboard.Keyboard, m mouse.Mouse) error {
+	switch p := payload.(type) {
+	case *message.KeyCharPayload:
+		key, err := p.Rune()
+		if err != nil {
+			return err
+		}
+		return k.SendInputKeyChar(key, p.Down)
+	case *message.KeySpecialKeyPayload:
+		return k.SendInputKeySpecialKey(p.Key, p.Down)
+	case *message.KeyPhysicalPayload:
+		return k.SendInputPhysicalKey(p.Key, p.Down)
+	case *message.KeyChordPayload:
+		chord, err := p.Chord()
+		if err != nil {
+			return err
+		}
+		return keyboard.SendChord(k, chord)
+	case *message.TextPayload:
+		return k.TypeText(p.Text)
+	case *message.MouseMovePayload:
+		return m.SendInputMove(p.Dx, p.Dy)
+	case *message.MouseKeyPayload:
+		return m.SendInputKey(p.Key)
+	case *message.MouseScrollPayload:
+		return m.SendInputScroll(p.Direction, p.Magnitude)
+	}
+	return pkgerrors.NewUnsupportedMessageTypeError(string(payload.Type()))
+}
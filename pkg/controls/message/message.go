@@ -0,0 +1,113 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// creates an empty payload for every known message type
+var payloadTypes map[MessageType]func() Payload = map[MessageType]func() Payload{
+	KeyCharMessage:          func() Payload { return &KeyCharPayload{} },
+	KeySpecialKeyMessage:    func() Payload { return &KeySpecialKeyPayload{} },
+	KeyPhysicalMessage:      func() Payload { return &KeyPhysicalPayload{} },
+	KeyboardLayoutMessage:   func() Payload { return &KeyboardLayoutPayload{} },
+	KeyChordMessage:         func() Payload { return &KeyChordPayload{} },
+	TextMessage:             func() Payload { return &TextPayload{} },
+	MouseMoveMessage:        func() Payload { return &MouseMovePayload{} },
+	MouseKeyMessage:         func() Payload { return &MouseKeyPayload{} },
+	MouseScrollMessage:      func() Payload { return &MouseScrollPayload{} },
+	GamepadButtonMessage:    func() Payload { return &GamepadButtonPayload{} },
+	GamepadStickMessage:     func() Payload { return &GamepadStickPayload{} },
+	GamepadTriggerMessage:   func() Payload { return &GamepadTriggerPayload{} },
+	TouchMessage:            func() Payload { return &TouchPayload{} },
+	PenMessage:              func() Payload { return &PenPayload{} },
+	ClipboardMessage:        func() Payload { return &ClipboardPayload{} },
+	ClipboardRequestMessage: func() Payload { return &ClipboardRequestPayload{} },
+	ControlRequestMessage:   func() Payload { return &ControlRequestPayload{} },
+	ControlGrantMessage:     func() Payload { return &ControlGrantPayload{} },
+	ControlRevokeMessage:    func() Payload { return &ControlRevokePayload{} },
+	ControlStateMessage:     func() Payload { return &ControlStatePayload{} },
+	RoleMessage:             func() Payload { return &RolePayload{} },
+	GamepadRumbleMessage:    func() Payload { return &GamepadRumblePayload{} },
+	ErrorMessage:            func() Payload { return &ErrorPayload{} },
+	CursorShapeMessage:      func() Payload { return &CursorShapePayload{} },
+	CursorPositionMessage:   func() Payload { return &CursorPositionPayload{} },
+	StreamSettingsMessage:   func() Payload { return &StreamSettingsPayload{} },
+}
+
+func Unmarshal(bytes []byte) (Payload, error) {
+	genericMessage := &GenericMessage{}
+	if err := json.Unmarshal(bytes, genericMessage); err != nil {
+		return nil, pkgerrors.NewUnmarshalError(err)
+	}
+	newPayload, ok := payloadTypes[genericMessage.Type]
+	if !ok {
+		return nil, pkgerrors.NewUnsupportedMessageTypeError(string(genericMessage.Type))
+	}
+	payload := newPayload()
+	// payloads without fields may be sent without a payload
+	if len(genericMessage.Payload) == 0 {
+		return payload, nil
+	}
+	if err := json.Unmarshal(genericMessage.Payload, payload); err != nil {
+		return nil, pkgerrors.NewUnmarshalError(err)
+	}
+	return payload, nil
+}
+
+func Marshal(payload Payload) ([]byte, error) {
+	if payload == nil {
+		return nil, pkgerrors.NewUnsupportedMessageTypeError("")
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, pkgerrors.NewMarshalError(err)
+	}
+	genericMessage := &GenericMessage{
+		Type:    payload.Type(),
+		Payload: payloadBytes,
+	}
+	bytes, err := json.Marshal(genericMessage)
+	if err != nil {
+		return nil, pkgerrors.NewMarshalError(err)
+	}
+	return bytes, nil
+}
+
+// Rune returns the character of the key, which must be exactly one character
+func (p *KeyCharPayload) Rune() (rune, error) {
+	key, size := utf8.DecodeRuneInString(p.Key)
+	if key == utf8.RuneError || size != len(p.Key) {
+		return 0, pkgerrors.NewUnmarshalError(fmt.Errorf("key '%s' is not a single character", p.Key))
+	}
+	return key, nil
+}
+
+// Chord returns the chord to press
+func (p *KeyChordPayload) Chord() (types.Chord, error) {
+	chord := types.Chord{Modifiers: p.Modifiers, Key: p.Key}
+	if p.Key != "" {
+		return chord, nil
+	}
+	var err error
+	chord.Char, err = (&KeyCharPayload{Key: p.Char}).Rune()
+	return chord, err
+}
+
+// NewKeyChordPayload creates the message for a chord
+func NewKeyChordPayload(chord types.Chord) *KeyChordPayload {
+	p := &KeyChordPayload{Modifiers: chord.Modifiers, Key: chord.Key}
+	if chord.Key == "" {
+		p.Char = string(chord.Char)
+	}
+	return p
+}
+
+// NewErrorPayload creates an error message to send back to a client
+func NewErrorPayload(err error) *ErrorPayload {
+	return &ErrorPayload{Message: err.Error()}
+}
@@ -0,0 +1,87 @@
+package message
+
+import (
+	"testing"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type unmarshalTest struct {
+	input       string
+	expected    Payload
+	expectedErr bool
+}
+
+type marshalTest struct {
+	payload  Payload
+	expected string
+}
+
+type runeTest struct {
+	key         string
+	expected    rune
+	expectedErr bool
+}
+
+func TestUnmarshal(t *testing.T) {
+	videoBitrate, videoCursor := uint(8000), false
+	unmarshalTests := []unmarshalTest{
+		{`{"type":"keychar","payload":{"key":"é","down":true}}`, &KeyCharPayload{Key: "é", Down: true}, false},
+		{`{"type":"keyspecial","payload":{"key":"SHIFT","down":false}}`, &KeySpecialKeyPayload{Key: types.SHIFT}, false},
+		{`{"type":"keyphysical","payload":{"key":"KeyQ","down":true}}`, &KeyPhysicalPayload{Key: "KeyQ", Down: true}, false},
+		{`{"type":"keyboardlayout","payload":{"mode":"character","layout":"fr"}}`, &KeyboardLayoutPayload{Mode: types.CharacterMode, Layout: "fr"}, false},
+		{`{"type":"text","payload":{"text":"hello"}}`, &TextPayload{Text: "hello"}, false},
+		{`{"type":"mousemove","payload":{"dx":-3,"dy":4}}`, &MouseMovePayload{Dx: -3, Dy: 4}, false},
+		{`{"type":"mousekey","payload":{"key":"LMBDown"}}`, &MouseKeyPayload{Key: types.LMBDown}, false},
+		{`{"type":"mousescroll","payload":{"direction":"horizontal","magnitude":120}}`, &MouseScrollPayload{Direction: types.HWheel, Magnitude: 120}, false},
+		{`{"type":"touch","payload":{"contacts":[{"id":3,"phase":"down","x":10,"y":20,"pressure":0.5}]}}`, &TouchPayload{Contacts: []types.TouchContact{{ID: 3, Phase: types.TouchDown, X: 10, Y: 20, Pressure: 0.5}}}, false},
+		{`{"type":"pen","payload":{"x":1,"y":2,"pressure":1,"tiltX":-30,"inRange":true,"inContact":true,"eraser":true}}`, &PenPayload{types.PenState{X: 1, Y: 2, Pressure: 1, TiltX: -30, InRange: true, InContact: true, Eraser: true}}, false},
+		{`{"type":"clipboard","payload":{"text":"copied","image":"iVBORw=="}}`, &ClipboardPayload{types.ClipboardContent{Text: "copied", Image: []byte{0x89, 0x50, 0x4e, 0x47}}}, false},
+		{`{"type":"clipboardrequest"}`, &ClipboardRequestPayload{}, false},
+		{`{"type":"controlrequest"}`, &ControlRequestPayload{}, false},
+		{`{"type":"controlgrant","payload":{"peer":"p2"}}`, &ControlGrantPayload{Peer: "p2"}, false},
+		{`{"type":"streamsettings","payload":{"videoBitrate":8000,"videoCursor":false}}`, &StreamSettingsPayload{StreamSettingsUpdate: types.StreamSettingsUpdate{VideoBitrate: &videoBitrate, VideoCursor: &videoCursor}}, false},
+		{`{"type":"mousescroll","payload":{"direction":"diagonal","magnitude":1}}`, nil, true},
+		{`{"type":"teleport"}`, nil, true},
+		{`not json`, nil, true},
+	}
+	for _, test := range unmarshalTests {
+		payload, err := Unmarshal([]byte(test.input))
+		assert.Equal(t, test.expected, payload)
+		assert.Equal(t, test.expectedErr, err != nil)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	marshalTests := []marshalTest{
+		{&ControlStatePayload{Controller: "p1", Requests: []string{"p2"}}, `{"type":"controlstate","payload":{"controller":"p1","requests":["p2"]}}`},
+		{&RolePayload{Peer: "p1", Role: "viewer"}, `{"type":"role","payload":{"peer":"p1","role":"viewer"}}`},
+		{&ClipboardPayload{types.ClipboardContent{Text: "copied"}}, `{"type":"clipboard","payload":{"text":"copied"}}`},
+		{&MouseScrollPayload{Direction: types.VWheel, Magnitude: -1}, `{"type":"mousescroll","payload":{"direction":"vertical","magnitude":-1}}`},
+		{&CursorShapePayload{types.CursorShape{Hash: "ab", Width: 32, Height: 32, HotspotX: 1, HotspotY: 2}}, `{"type":"cursorshape","payload":{"hash":"ab","width":32,"height":32,"hotspotX":1,"hotspotY":2}}`},
+		{&CursorPositionPayload{types.CursorPosition{X: 5, Y: 6, Visible: true}, 7}, `{"type":"cursorposition","payload":{"x":5,"y":6,"visible":true,"seq":7}}`},
+	}
+	for _, test := range marshalTests {
+		bytes, err := Marshal(test.payload)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, string(bytes))
+	}
+	_, err := Marshal(nil)
+	assert.Equal(t, &pkgerrors.UnsupportedMessageTypeError{}, err)
+}
+
+func TestKeyCharRune(t *testing.T) {
+	runeTests := []runeTest{
+		{"a", 'a', false},
+		{"😀", '😀', false},
+		{"", 0, true},
+		{"ab", 0, true},
+	}
+	for _, test := range runeTests {
+		key, err := (&KeyCharPayload{Key: test.key}).Rune()
+		assert.Equal(t, test.expected, key)
+		assert.Equal(t, test.expectedErr, err != nil)
+	}
+}
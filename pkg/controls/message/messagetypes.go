@@ -0,0 +1,216 @@
+package message
+
+import (
+	"encoding/json"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// type of a message sent over the controls datachannel
+type MessageType string
+
+const (
+	// input, client to server
+	KeyCharMessage       MessageType = "keychar"
+	KeySpecialKeyMessage MessageType = "keyspecial"
+	KeyPhysicalMessage   MessageType = "keyphysical"
+	KeyChordMessage      MessageType = "keychord"
+	TextMessage          MessageType = "text"
+	MouseMoveMessage     MessageType = "mousemove"
+	MouseKeyMessage      MessageType = "mousekey"
+	MouseScrollMessage   MessageType = "mousescroll"
+	// gamepad input, client to server
+	GamepadButtonMessage  MessageType = "gamepadbutton"
+	GamepadStickMessage   MessageType = "gamepadstick"
+	GamepadTriggerMessage MessageType = "gamepadtrigger"
+	// touch and pen input, client to server
+	TouchMessage MessageType = "touch"
+	PenMessage   MessageType = "pen"
+	// keyboard settings, client to server
+	KeyboardLayoutMessage MessageType = "keyboardlayout"
+	// clipboard, both ways
+	ClipboardMessage MessageType = "clipboard"
+	// ask for the host clipboard, client to server
+	ClipboardRequestMessage MessageType = "clipboardrequest"
+	// control handoff, client to server
+	ControlRequestMessage MessageType = "controlrequest"
+	ControlGrantMessage   MessageType = "controlgrant"
+	ControlRevokeMessage  MessageType = "controlrevoke"
+	// announcements, server to client
+	ControlStateMessage  MessageType = "controlstate"
+	RoleMessage          MessageType = "role"
+	GamepadRumbleMessage MessageType = "gamepadrumble"
+	ErrorMessage         MessageType = "error"
+	// host cursor, server to client over the cursor datachannel
+	CursorShapeMessage    MessageType = "cursorshape"
+	CursorPositionMessage MessageType = "cursorposition"
+	// stream settings, from an admin to the server, then announced to every client
+	StreamSettingsMessage MessageType = "streamsettings"
+)
+
+type GenericMessage struct {
+	Type    MessageType     `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Payload is implemented by every message payload
+type Payload interface {
+	Type() MessageType
+}
+
+// a single unicode character, as in the DOM KeyboardEvent.key
+type KeyCharPayload struct {
+	Key  string `json:"key"`
+	Down bool   `json:"down"`
+}
+
+type KeySpecialKeyPayload struct {
+	Key  types.SpecialKeyboardKey `json:"key"`
+	Down bool                     `json:"down"`
+}
+
+// a key by its position, as in the DOM KeyboardEvent.code
+type KeyPhysicalPayload struct {
+	Key  types.PhysicalKey `json:"key"`
+	Down bool              `json:"down"`
+}
+
+// Char is a single character, as in KeyCharPayload, if Key is empty
+type KeyChordPayload struct {
+	Modifiers []types.SpecialKeyboardKey `json:"modifiers"`
+	Key       types.SpecialKeyboardKey   `json:"key,omitempty"`
+	Char      string                     `json:"char,omitempty"`
+}
+
+type TextPayload struct {
+	Text string `json:"text"`
+}
+
+type MouseMovePayload struct {
+	Dx int `json:"dx"`
+	Dy int `json:"dy"`
+}
+
+type MouseKeyPayload struct {
+	Key types.MouseKey `json:"key"`
+}
+
+type MouseScrollPayload struct {
+	Direction types.MouseWheelDir `json:"direction"`
+	Magnitude int                 `json:"magnitude"`
+}
+
+type GamepadButtonPayload struct {
+	Button types.GamepadButton `json:"button"`
+	Down   bool                `json:"down"`
+}
+
+// X and Y in range -1 to 1, positive is right and down
+type GamepadStickPayload struct {
+	Stick types.GamepadStick `json:"stick"`
+	X     float64            `json:"x"`
+	Y     float64            `json:"y"`
+}
+
+// Value in range 0 to 1
+type GamepadTriggerPayload struct {
+	Trigger types.GamepadTrigger `json:"trigger"`
+	Value   float64              `json:"value"`
+}
+
+// only the contacts that changed, the others keep their last position
+type TouchPayload struct {
+	Contacts []types.TouchContact `json:"contacts"`
+}
+
+type PenPayload struct {
+	types.PenState
+}
+
+// how keyphysical messages are sent to the host, the layout is the client's
+// and only used in character mode
+type KeyboardLayoutPayload struct {
+	Mode   types.KeyboardMode `json:"mode"`
+	Layout string             `json:"layout"`
+}
+
+// a client's clipboard to put on the host, or the host's clipboard after it changed
+type ClipboardPayload struct {
+	types.ClipboardContent
+}
+
+type ClipboardRequestPayload struct{}
+
+type ControlRequestPayload struct{}
+
+// Peer is the peer to hand control to
+type ControlGrantPayload struct {
+	Peer string `json:"peer"`
+}
+
+type ControlRevokePayload struct{}
+
+// Controller is empty if nobody has control
+type ControlStatePayload struct {
+	Controller string   `json:"controller"`
+	Requests   []string `json:"requests"`
+}
+
+type RolePayload struct {
+	Peer string `json:"peer"`
+	Role string `json:"role"`
+}
+
+// Strong and Weak in range 0 to 1, Duration in milliseconds (0 until stopped)
+type GamepadRumblePayload struct {
+	Strong   float64 `json:"strong"`
+	Weak     float64 `json:"weak"`
+	Duration int64   `json:"duration"`
+}
+
+type ErrorPayload struct {
+	Message string `json:"message"`
+}
+
+type CursorShapePayload struct {
+	types.CursorShape
+}
+
+// positions may arrive out of order, clients drop any with a Seq lower than the last one
+type CursorPositionPayload struct {
+	types.CursorPosition
+	Seq uint64 `json:"seq"`
+}
+
+type StreamSettingsPayload struct {
+	types.StreamSettingsUpdate
+	// set in the announcement of a change, the settings only a restart of the host changes
+	Fixed []string `json:"fixed,omitempty"`
+}
+
+func (*KeyCharPayload) Type() MessageType          { return KeyCharMessage }
+func (*KeySpecialKeyPayload) Type() MessageType    { return KeySpecialKeyMessage }
+func (*KeyPhysicalPayload) Type() MessageType      { return KeyPhysicalMessage }
+func (*KeyboardLayoutPayload) Type() MessageType   { return KeyboardLayoutMessage }
+func (*KeyChordPayload) Type() MessageType         { return KeyChordMessage }
+func (*TextPayload) Type() MessageType             { return TextMessage }
+func (*MouseMovePayload) Type() MessageType        { return MouseMoveMessage }
+func (*MouseKeyPayload) Type() MessageType         { return MouseKeyMessage }
+func (*MouseScrollPayload) Type() MessageType      { return MouseScrollMessage }
+func (*GamepadButtonPayload) Type() MessageType    { return GamepadButtonMessage }
+func (*GamepadStickPayload) Type() MessageType     { return GamepadStickMessage }
+func (*GamepadTriggerPayload) Type() MessageType   { return GamepadTriggerMessage }
+func (*TouchPayload) Type() MessageType            { return TouchMessage }
+func (*PenPayload) Type() MessageType              { return PenMessage }
+func (*ClipboardPayload) Type() MessageType        { return ClipboardMessage }
+func (*ClipboardRequestPayload) Type() MessageType { return ClipboardRequestMessage }
+func (*ControlRequestPayload) Type() MessageType   { return ControlRequestMessage }
+func (*ControlGrantPayload) Type() MessageType     { return ControlGrantMessage }
+func (*ControlRevokePayload) Type() MessageType    { return ControlRevokeMessage }
+func (*ControlStatePayload) Type() MessageType     { return ControlStateMessage }
+func (*RolePayload) Type() MessageType             { return RoleMessage }
+func (*GamepadRumblePayload) Type() MessageType    { return GamepadRumbleMessage }
+func (*ErrorPayload) Type() MessageType            { return ErrorMessage }
+func (*CursorShapePayload) Type() MessageType      { return CursorShapeMessage }
+func (*CursorPositionPayload) Type() MessageType   { return CursorPositionMessage }
+func (*StreamSettingsPayload) Type() MessageType   { return StreamSettingsMessage }
@@ -7,3 +7,9 @@ type Mouse interface {
 	SendInputKey(button types.MouseKey) error
 	SendInputScroll(direction types.MouseWheelDir, magnitude int) error
 }
+
+// BatchMouse is implemented by mice that can send several events at once
+type BatchMouse interface {
+	Mouse
+	SendInputBatch(events []types.MouseEvent) error
+}
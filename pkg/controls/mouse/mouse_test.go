@@ -1,13 +1,20 @@
-package mouse
+package mouse_test
 
 import (
 	"testing"
 
-	"github.com/benu-cloud/benu-webrtc/internal/controls/mouse"
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
 	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
 	"github.com/stretchr/testify/assert"
 )
 
+// the mice the table tests run against, mouse_windows_test.go adds the real backend
+var mice = map[string]func() mouse.BatchMouse{
+	"fake": func() mouse.BatchMouse { return &fake.BatchMouse{} },
+}
+
 type sentInputMoveTest struct {
 	dx       int
 	dy       int
@@ -31,9 +38,11 @@ func TestSendInputMove(t *testing.T) {
 		{0, 0, nil},
 		{-10, -10, nil},
 	}
-	var mouse_impl Mouse = &mouse.Mouse_c{}
-	for _, test := range sendInputMoveTests {
-		assert.Equal(t, mouse_impl.SendInputMove(test.dx, test.dy), test.expected)
+	for name, newMouse := range mice {
+		mouse_impl := newMouse()
+		for _, test := range sendInputMoveTests {
+			assert.Equal(t, test.expected, mouse_impl.SendInputMove(test.dx, test.dy), name)
+		}
 	}
 }
 
@@ -42,10 +51,13 @@ func TestSendInputKey(t *testing.T) {
 		{types.MMBDown, nil},
 		{types.MMBUp, nil},
 		{types.RMBUp, nil},
+		{types.MouseKey("this_is_not_a_key"), &pkgerrors.NotImplementedError{Where: "SendInputKey", Feature: "this_is_not_a_key"}},
 	}
-	var mouse_impl Mouse = &mouse.Mouse_c{}
-	for _, test := range sendInputKeyTests {
-		assert.Equal(t, mouse_impl.SendInputKey(test.key), test.expected)
+	for name, newMouse := range mice {
+		mouse_impl := newMouse()
+		for _, test := range sendInputKeyTests {
+			assert.Equal(t, test.expected, mouse_impl.SendInputKey(test.key), name)
+		}
 	}
 }
 
@@ -55,8 +67,25 @@ func TestSendInputScroll(t *testing.T) {
 		{types.HWheel, -10, nil},
 		{types.VWheel, 10, nil},
 	}
-	var mouse_impl Mouse = &mouse.Mouse_c{}
-	for _, test := range sendInputScrollTests {
-		assert.Equal(t, mouse_impl.SendInputScroll(test.direction, test.magnitude), test.expected)
+	for name, newMouse := range mice {
+		mouse_impl := newMouse()
+		for _, test := range sendInputScrollTests {
+			assert.Equal(t, test.expected, mouse_impl.SendInputScroll(test.direction, test.magnitude), name)
+		}
+	}
+}
+
+func TestSendInputBatch(t *testing.T) {
+	for name, newMouse := range mice {
+		mouse_impl := newMouse()
+		assert.Nil(t, mouse_impl.SendInputBatch(nil), name)
+		assert.Nil(t, mouse_impl.SendInputBatch([]types.MouseEvent{
+			{Type: types.MouseMoveEvent, Dx: 10, Dy: -10},
+			{Type: types.MouseScrollEvent, Direction: types.VWheel, Magnitude: 10},
+			{Type: types.MouseKeyEvent, Key: types.MMBDown},
+			{Type: types.MouseKeyEvent, Key: types.MMBUp},
+		}), name)
+		assert.Equal(t, &pkgerrors.NotImplementedError{Where: "SendInputBatch", Feature: "this_is_not_a_key"},
+			mouse_impl.SendInputBatch([]types.MouseEvent{{Type: types.MouseKeyEvent, Key: types.MouseKey("this_is_not_a_key")}}), name)
 	}
 }
@@ -0,0 +1,13 @@
+//go:build realinput
+
+package mouse_test
+
+import (
+	backend "github.com/benu-cloud/benu-webrtc/internal/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+)
+
+// the real backend moves the cursor of the desktop the tests run on, so it is only tested with -tags realinput
+func init() {
+	mice["windows"] = func() mouse.BatchMouse { return &backend.Mouse_c{} }
+}
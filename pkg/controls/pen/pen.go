@@ -0,0 +1,8 @@
+package pen
+
+import "github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+
+type Pen interface {
+	SendInputPen(state types.PenState) error
+	Close() error
+}
@@ -0,0 +1,289 @@
+package permissions
+
+import (
+	"fmt"
+	"sync"
+)
+
+// what a peer is allowed to do with the host's input
+type Role string
+
+const (
+	// can only watch
+	Viewer Role = "viewer"
+	// can request control and send input while holding it
+	Controller Role = "controller"
+	// can also take control directly, and grant or revoke it for anyone
+	Admin Role = "admin"
+)
+
+// PermissionError indicates a peer tried something its role doesn't allow
+type PermissionError struct {
+	PeerId string
+	Action string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("PermissionError: peer '%s' is not allowed to %s", e.PeerId, e.Action)
+}
+
+func NewPermissionError(peerId string, action string) error {
+	return &PermissionError{
+		PeerId: peerId,
+		Action: action,
+	}
+}
+
+// UnknownPeerError indicates a peer that was never added
+type UnknownPeerError struct {
+	PeerId string
+}
+
+func (e *UnknownPeerError) Error() string {
+	return fmt.Sprintf("UnknownPeerError: no peer with id '%s'", e.PeerId)
+}
+
+func NewUnknownPeerError(peerId string) error {
+	return &UnknownPeerError{
+		PeerId: peerId,
+	}
+}
+
+// Permissions keeps the role of every peer and who the single active controller is
+type Permissions struct {
+	mutex sync.Mutex
+	roles map[string]Role
+	// empty if nobody has control
+	controller string
+	// peers waiting for control, in the order they asked
+	requests []string
+	// roles that can read the host clipboard, unless set for the peer
+	clipboardRoles map[Role]bool
+	clipboardPeers map[string]bool
+}
+
+func NewPermissions() *Permissions {
+	return &Permissions{
+		roles:          make(map[string]Role),
+		clipboardRoles: map[Role]bool{Admin: true, Controller: true},
+		clipboardPeers: make(map[string]bool),
+	}
+}
+
+func (p *Permissions) AddPeer(peerId string, role Role) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.roles[peerId] = role
+}
+
+// RemovePeer forgets a peer, taking away control if it had it.
+// It returns the controller from before the peer was removed
+func (p *Permissions) RemovePeer(peerId string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	previous := p.controller
+	delete(p.roles, peerId)
+	delete(p.clipboardPeers, peerId)
+	p.removeRequest(peerId)
+	if p.controller == peerId {
+		p.controller = ""
+	}
+	return previous
+}
+
+// SetRole changes the role of a peer, a viewer loses control and its request.
+// It returns the controller from before the change
+func (p *Permissions) SetRole(peerId string, role Role) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	previous := p.controller
+	if _, ok := p.roles[peerId]; !ok {
+		return previous, NewUnknownPeerError(peerId)
+	}
+	p.roles[peerId] = role
+	if role == Viewer {
+		p.removeRequest(peerId)
+		if p.controller == peerId {
+			p.controller = ""
+		}
+	}
+	return previous, nil
+}
+
+func (p *Permissions) Role(peerId string) (Role, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	role, ok := p.roles[peerId]
+	return role, ok
+}
+
+// Peers returns the ids of all peers
+func (p *Permissions) Peers() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	peers := make([]string, 0, len(p.roles))
+	for peerId := range p.roles {
+		peers = append(peers, peerId)
+	}
+	return peers
+}
+
+// Controller returns the peer that has control, empty if nobody has it
+func (p *Permissions) Controller() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.controller
+}
+
+// Requests returns the peers waiting for control
+func (p *Permissions) Requests() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string{}, p.requests...)
+}
+
+// CanSendInput reports whether input from the peer should reach the host
+func (p *Permissions) CanSendInput(peerId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return peerId != "" && p.controller == peerId
+}
+
+// CanUseGamepad reports whether the peer may play with a gamepad of its own,
+// which unlike the keyboard and mouse doesn't need control
+func (p *Permissions) CanUseGamepad(peerId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	role, ok := p.roles[peerId]
+	return ok && role != Viewer
+}
+
+// CanTransferFiles reports whether the peer may upload files to the host and download them,
+// which like a gamepad doesn't need control
+func (p *Permissions) CanTransferFiles(peerId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	role, ok := p.roles[peerId]
+	return ok && role != Viewer
+}
+
+// CanUpdateSettings reports whether the peer may change the stream settings, which affect everyone
+func (p *Permissions) CanUpdateSettings(peerId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.roles[peerId] == Admin
+}
+
+// SetClipboardRoles chooses the roles that can read the host clipboard,
+// admins and controllers by default
+func (p *Permissions) SetClipboardRoles(roles ...Role) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.clipboardRoles = make(map[Role]bool)
+	for _, role := range roles {
+		p.clipboardRoles[role] = true
+	}
+}
+
+// SetClipboardRead allows or forbids a peer to read the host clipboard, whatever its role
+func (p *Permissions) SetClipboardRead(peerId string, allowed bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.roles[peerId]; !ok {
+		return NewUnknownPeerError(peerId)
+	}
+	p.clipboardPeers[peerId] = allowed
+	return nil
+}
+
+// CanReadClipboard reports whether the peer may receive the host clipboard
+func (p *Permissions) CanReadClipboard(peerId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	role, ok := p.roles[peerId]
+	if !ok {
+		return false
+	}
+	if allowed, ok := p.clipboardPeers[peerId]; ok {
+		return allowed
+	}
+	return p.clipboardRoles[role]
+}
+
+// Request asks for control. It is given right away to an admin, or to a
+// controller if nobody has control, otherwise the request waits for a grant.
+// It returns the controller from before the request
+func (p *Permissions) Request(peerId string) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	previous := p.controller
+	role, ok := p.roles[peerId]
+	if !ok {
+		return previous, NewUnknownPeerError(peerId)
+	}
+	switch {
+	case role == Viewer:
+		return previous, NewPermissionError(peerId, "request control")
+	case p.controller == peerId:
+		return previous, nil
+	case role == Admin || p.controller == "":
+		p.removeRequest(peerId)
+		p.controller = peerId
+	default:
+		p.removeRequest(peerId)
+		p.requests = append(p.requests, peerId)
+	}
+	return previous, nil
+}
+
+// Grant hands control from the current controller, or from an admin, to another peer.
+// It returns the controller from before the grant
+func (p *Permissions) Grant(from string, to string) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	previous := p.controller
+	fromRole, ok := p.roles[from]
+	if !ok {
+		return previous, NewUnknownPeerError(from)
+	}
+	toRole, ok := p.roles[to]
+	if !ok {
+		return previous, NewUnknownPeerError(to)
+	}
+	if fromRole != Admin && p.controller != from {
+		return previous, NewPermissionError(from, "grant control")
+	}
+	if toRole == Viewer {
+		return previous, NewPermissionError(to, "receive control")
+	}
+	p.removeRequest(to)
+	p.controller = to
+	return previous, nil
+}
+
+// Revoke takes control away. An admin can revoke anyone, the controller can only give it up.
+// It returns the controller from before the revoke
+func (p *Permissions) Revoke(from string) (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	previous := p.controller
+	role, ok := p.roles[from]
+	if !ok {
+		return previous, NewUnknownPeerError(from)
+	}
+	if role != Admin && p.controller != from {
+		return previous, NewPermissionError(from, "revoke control")
+	}
+	p.controller = ""
+	return previous, nil
+}
+
+// (LOCK MUTEX BEFORE USING THIS)
+func (p *Permissions) removeRequest(peerId string) {
+	for i, request := range p.requests {
+		if request == peerId {
+			p.requests = append(p.requests[:i], p.requests[i+1:]...)
+			return
+		}
+	}
+}
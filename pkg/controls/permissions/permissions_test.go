@@ -0,0 +1,128 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type handoffTest struct {
+	name               string
+	action             func(p *Permissions) (string, error)
+	expected           error
+	expectedPrevious   string
+	expectedController string
+	expectedRequests   []string
+}
+
+// v is a viewer, c1 and c2 are controllers, a is an admin
+func newTestPermissions() *Permissions {
+	p := NewPermissions()
+	p.AddPeer("v", Viewer)
+	p.AddPeer("c1", Controller)
+	p.AddPeer("c2", Controller)
+	p.AddPeer("a", Admin)
+	return p
+}
+
+func TestHandoff(t *testing.T) {
+	handoffTests := []handoffTest{
+		{"viewer can't request", func(p *Permissions) (string, error) {
+			return p.Request("v")
+		}, &PermissionError{PeerId: "v", Action: "request control"}, "", "", []string{}},
+		{"first request is granted", func(p *Permissions) (string, error) {
+			return p.Request("c1")
+		}, nil, "", "c1", []string{}},
+		{"second request waits", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			return p.Request("c2")
+		}, nil, "c1", "c1", []string{"c2"}},
+		{"controller grants", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			p.Request("c2")
+			return p.Grant("c1", "c2")
+		}, nil, "c1", "c2", []string{}},
+		{"waiting peer can't grant itself", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			p.Request("c2")
+			return p.Grant("c2", "c2")
+		}, &PermissionError{PeerId: "c2", Action: "grant control"}, "c1", "c1", []string{"c2"}},
+		{"can't grant to a viewer", func(p *Permissions) (string, error) {
+			return p.Grant("a", "v")
+		}, &PermissionError{PeerId: "v", Action: "receive control"}, "", "", []string{}},
+		{"admin takes control", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			return p.Request("a")
+		}, nil, "c1", "a", []string{}},
+		{"admin revokes", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			return p.Revoke("a")
+		}, nil, "c1", "", []string{}},
+		{"controller gives up", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			return p.Revoke("c1")
+		}, nil, "c1", "", []string{}},
+		{"other controller can't revoke", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			return p.Revoke("c2")
+		}, &PermissionError{PeerId: "c2", Action: "revoke control"}, "c1", "c1", []string{}},
+		{"removed controller loses control", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			p.Request("c2")
+			p.RemovePeer("c2")
+			return p.RemovePeer("c1"), nil
+		}, nil, "c1", "", []string{}},
+		{"demoted controller loses control", func(p *Permissions) (string, error) {
+			p.Request("c1")
+			return p.SetRole("c1", Viewer)
+		}, nil, "c1", "", []string{}},
+		{"unknown peer", func(p *Permissions) (string, error) {
+			return p.Request("x")
+		}, &UnknownPeerError{PeerId: "x"}, "", "", []string{}},
+	}
+	for _, test := range handoffTests {
+		p := newTestPermissions()
+		previous, err := test.action(p)
+		assert.Equal(t, test.expected, err, test.name)
+		assert.Equal(t, test.expectedPrevious, previous, test.name)
+		assert.Equal(t, test.expectedController, p.Controller(), test.name)
+		assert.Equal(t, test.expectedRequests, p.Requests(), test.name)
+		assert.Equal(t, test.expectedController != "", p.CanSendInput(test.expectedController), test.name)
+	}
+}
+
+func TestClipboardRead(t *testing.T) {
+	p := newTestPermissions()
+	assert.False(t, p.CanReadClipboard("v"))
+	assert.True(t, p.CanReadClipboard("c1"))
+	assert.True(t, p.CanReadClipboard("a"))
+	assert.False(t, p.CanReadClipboard("x"))
+	p.SetClipboardRoles(Admin)
+	assert.False(t, p.CanReadClipboard("c1"))
+	// a peer's own setting wins over its role
+	assert.NoError(t, p.SetClipboardRead("v", true))
+	assert.NoError(t, p.SetClipboardRead("a", false))
+	assert.True(t, p.CanReadClipboard("v"))
+	assert.False(t, p.CanReadClipboard("a"))
+	assert.Equal(t, &UnknownPeerError{PeerId: "x"}, p.SetClipboardRead("x", true))
+	// and is forgotten with the peer
+	p.RemovePeer("v")
+	p.AddPeer("v", Viewer)
+	assert.False(t, p.CanReadClipboard("v"))
+}
+
+func TestUpdateSettings(t *testing.T) {
+	p := newTestPermissions()
+	assert.False(t, p.CanUpdateSettings("v"))
+	assert.False(t, p.CanUpdateSettings("c1"))
+	assert.True(t, p.CanUpdateSettings("a"))
+	assert.False(t, p.CanUpdateSettings("x"))
+}
+
+func TestTransferFiles(t *testing.T) {
+	p := newTestPermissions()
+	assert.False(t, p.CanTransferFiles("v"))
+	assert.True(t, p.CanTransferFiles("c1"))
+	assert.True(t, p.CanTransferFiles("a"))
+	assert.False(t, p.CanTransferFiles("x"))
+}
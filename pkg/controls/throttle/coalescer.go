@@ -0,0 +1,175 @@
+package throttle
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// flush early if this many events are waiting, so alternating moves and scrolls can't pile up
+const maxPending = 64
+
+// CoalescerStats counts the events passing through a coalescer
+type CoalescerStats struct {
+	// moves and scrolls received
+	Received uint64
+	// moves and scrolls sent to the mouse after merging
+	Sent uint64
+	// calls to the keyboard or mouse that failed while flushing in the background
+	Errors uint64
+}
+
+// Coalescer wraps a keyboard and a mouse and merges consecutive relative moves
+// and scrolls in the same direction that arrive within an interval.
+// Pending moves and scrolls are sent before any other input so order is kept,
+// in a single call if the mouse is a mouse.BatchMouse
+type Coalescer struct {
+	mutex    sync.Mutex
+	keyboard keyboard.Keyboard
+	mouse    mouse.Mouse
+	interval time.Duration
+	pending  []types.MouseEvent
+	timer    *time.Timer
+	stats    CoalescerStats
+}
+
+// NewCoalescer creates a coalescer sending merged moves and scrolls at most once per interval,
+// usually the duration of a frame
+func NewCoalescer(k keyboard.Keyboard, m mouse.Mouse, interval time.Duration) *Coalescer {
+	return &Coalescer{
+		keyboard: k,
+		mouse:    m,
+		interval: interval,
+	}
+}
+
+func (c *Coalescer) SendInputMove(dx int, dy int) error {
+	return c.add(types.MouseEvent{Type: types.MouseMoveEvent, Dx: dx, Dy: dy})
+}
+
+func (c *Coalescer) SendInputScroll(direction types.MouseWheelDir, magnitude int) error {
+	return c.add(types.MouseEvent{Type: types.MouseScrollEvent, Direction: direction, Magnitude: magnitude})
+}
+
+func (c *Coalescer) SendInputKey(button types.MouseKey) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.flush(types.MouseEvent{Type: types.MouseKeyEvent, Key: button})
+}
+
+func (c *Coalescer) SendInputKeyChar(key rune, down bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return errors.Join(c.flush(), c.keyboard.SendInputKeyChar(key, down))
+}
+
+func (c *Coalescer) TypeText(text string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return errors.Join(c.flush(), c.keyboard.TypeText(text))
+}
+
+func (c *Coalescer) SendInputKeySpecialKey(key types.SpecialKeyboardKey, down bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return errors.Join(c.flush(), c.keyboard.SendInputKeySpecialKey(key, down))
+}
+
+func (c *Coalescer) SendInputPhysicalKey(key types.PhysicalKey, down bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return errors.Join(c.flush(), c.keyboard.SendInputPhysicalKey(key, down))
+}
+
+func (c *Coalescer) SendInputChord(chord types.Chord) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return errors.Join(c.flush(), keyboard.SendChord(c.keyboard, chord))
+}
+
+// Flush sends pending moves and scrolls right away
+func (c *Coalescer) Flush() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.flush()
+}
+
+// Stats returns the counters since the coalescer was created
+func (c *Coalescer) Stats() CoalescerStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// merge an event into the last pending one if possible and make sure a flush is scheduled
+func (c *Coalescer) add(event types.MouseEvent) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Received++
+	if n := len(c.pending); n > 0 {
+		last := &c.pending[n-1]
+		switch {
+		case last.Type == types.MouseMoveEvent && event.Type == types.MouseMoveEvent:
+			last.Dx += event.Dx
+			last.Dy += event.Dy
+			return nil
+		case last.Type == types.MouseScrollEvent && event.Type == types.MouseScrollEvent && last.Direction == event.Direction:
+			last.Magnitude += event.Magnitude
+			return nil
+		}
+	}
+	c.pending = append(c.pending, event)
+	if len(c.pending) >= maxPending {
+		return c.flush()
+	}
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.interval, c.flushLater)
+	}
+	return nil
+}
+
+func (c *Coalescer) flushLater() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.flush(); err != nil {
+		c.stats.Errors++
+	}
+}
+
+// send pending events followed by the given ones, the lock must be held
+func (c *Coalescer) flush(after ...types.MouseEvent) error {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.stats.Sent += uint64(len(c.pending))
+	events := append(c.pending, after...)
+	c.pending = nil
+	if len(events) == 0 {
+		return nil
+	}
+	if batch, ok := c.mouse.(mouse.BatchMouse); ok && len(events) > 1 {
+		return batch.SendInputBatch(events)
+	}
+	for _, event := range events {
+		if err := sendMouseEvent(c.mouse, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sendMouseEvent(m mouse.Mouse, event types.MouseEvent) error {
+	switch event.Type {
+	case types.MouseMoveEvent:
+		return m.SendInputMove(event.Dx, event.Dy)
+	case types.MouseScrollEvent:
+		return m.SendInputScroll(event.Direction, event.Magnitude)
+	default:
+		return m.SendInputKey(event.Key)
+	}
+}
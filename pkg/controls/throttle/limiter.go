@@ -0,0 +1,97 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// PeerStats counts the input events of a peer
+type PeerStats struct {
+	Allowed uint64
+	Dropped uint64
+}
+
+// a token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+	stats  PeerStats
+}
+
+// Limiter limits the rate of input events per peer with a token bucket,
+// a peer can send burst events at once and rate events per second after that
+type Limiter struct {
+	mutex sync.Mutex
+	rate  float64
+	burst int
+	peers map[string]*bucket
+	// events dropped from peers that have been removed
+	removedDropped uint64
+	// replaced in tests
+	now func() time.Time
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:  rate,
+		burst: burst,
+		peers: make(map[string]*bucket),
+		now:   time.Now,
+	}
+}
+
+// Allow reports whether the peer may send another event, and counts it as allowed or dropped
+func (l *Limiter) Allow(peerId string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	b, ok := l.peers[peerId]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.peers[peerId] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > float64(l.burst) {
+		b.tokens = float64(l.burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.stats.Dropped++
+		return false
+	}
+	b.tokens--
+	b.stats.Allowed++
+	return true
+}
+
+// Remove forgets a peer, its dropped events still count towards Dropped
+func (l *Limiter) Remove(peerId string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if b, ok := l.peers[peerId]; ok {
+		l.removedDropped += b.stats.Dropped
+		delete(l.peers, peerId)
+	}
+}
+
+// Stats returns the counters of every current peer
+func (l *Limiter) Stats() map[string]PeerStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	stats := make(map[string]PeerStats, len(l.peers))
+	for peerId, b := range l.peers {
+		stats[peerId] = b.stats
+	}
+	return stats
+}
+
+// Dropped returns the number of events dropped from any peer since the limiter was created
+func (l *Limiter) Dropped() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	dropped := l.removedDropped
+	for _, b := range l.peers {
+		dropped += b.stats.Dropped
+	}
+	return dropped
+}
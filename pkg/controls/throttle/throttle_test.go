@@ -0,0 +1,75 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesceMoves(t *testing.T) {
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	c := NewCoalescer(k, m, time.Hour)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, c.SendInputMove(1, -2))
+	}
+	assert.NoError(t, c.SendInputScroll(types.VWheel, 120))
+	assert.NoError(t, c.SendInputScroll(types.VWheel, -40))
+	assert.NoError(t, c.SendInputScroll(types.HWheel, 10))
+	assert.Empty(t, m.Calls())
+	// other input sends what is pending first
+	assert.NoError(t, c.SendInputKeySpecialKey(types.SHIFT, true))
+	assert.Equal(t, []string{"move 100 -200", "scroll vertical 80", "scroll horizontal 10"}, m.Calls())
+	assert.Equal(t, []string{"special SHIFT true"}, k.Calls())
+	assert.NoError(t, c.SendInputKey(types.LMBDown))
+	assert.Equal(t, "mouse LMBDown", m.Calls()[3])
+	assert.Equal(t, CoalescerStats{Received: 103, Sent: 3}, c.Stats())
+}
+
+func TestCoalescerFlushesAfterInterval(t *testing.T) {
+	m := &fake.Mouse{}
+	c := NewCoalescer(&fake.Keyboard{}, m, 5*time.Millisecond)
+	c.SendInputMove(3, 4)
+	c.SendInputMove(3, 4)
+	assert.Eventually(t, func() bool {
+		sent := m.Calls()
+		return len(sent) == 1 && sent[0] == "move 6 8"
+	}, time.Second, time.Millisecond)
+}
+
+func TestCoalescerBatches(t *testing.T) {
+	m := &fake.BatchMouse{}
+	c := NewCoalescer(&fake.Keyboard{}, m, time.Hour)
+	c.SendInputMove(1, 1)
+	c.SendInputScroll(types.VWheel, 120)
+	c.SendInputKey(types.RMBDown)
+	// a single event doesn't need a batch
+	c.SendInputKey(types.RMBUp)
+	assert.Equal(t, []string{"batch 3", "mouse RMBUp"}, m.Calls())
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(10, 3)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.Allow("a") {
+			allowed++
+		}
+	}
+	// only the burst gets through at once
+	assert.Equal(t, 3, allowed)
+	assert.True(t, l.Allow("b"))
+	// a tenth of a second later there is room for one more
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	assert.Equal(t, map[string]PeerStats{"a": {Allowed: 4, Dropped: 8}, "b": {Allowed: 1}}, l.Stats())
+	l.Remove("a")
+	assert.Equal(t, uint64(8), l.Dropped())
+	// a removed peer starts over
+	assert.True(t, l.Allow("a"))
+}
@@ -0,0 +1,11 @@
+package touch
+
+import "github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+
+type Touch interface {
+	// contacts not in the list keep their last position
+	SendInputTouch(contacts []types.TouchContact) error
+	// lift every contact
+	Cancel() error
+	Close() error
+}
@@ -0,0 +1,319 @@
+package tracker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/keyboard"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/mouse"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+)
+
+// the key that releases a pressed mouse button
+var mouseKeyRelease map[types.MouseKey]types.MouseKey = map[types.MouseKey]types.MouseKey{
+	types.LMBDown: types.LMBUp,
+	types.RMBDown: types.RMBUp,
+	types.MMBDown: types.MMBUp,
+	types.XMBDown: types.XMBUp,
+}
+
+// keys and buttons a single peer is holding down
+type peerState struct {
+	chars       map[rune]struct{}
+	specialKeys map[types.SpecialKeyboardKey]struct{}
+	physical    map[types.PhysicalKey]struct{}
+	mouseKeys   map[types.MouseKey]struct{}
+	timer       *time.Timer
+}
+
+// how many peers hold each key down
+type holders[K comparable] map[K]int
+
+// others reports whether peers other than the one holding peerKeys hold key
+func (h holders[K]) others(peerKeys map[K]struct{}, key K) bool {
+	n := h[key]
+	if _, ok := peerKeys[key]; ok {
+		n--
+	}
+	return n > 0
+}
+
+// press records that the peer holding peerKeys holds key, a repeated press isn't counted again
+func (h holders[K]) press(peerKeys map[K]struct{}, key K) {
+	if _, ok := peerKeys[key]; !ok {
+		peerKeys[key] = struct{}{}
+		h[key]++
+	}
+}
+
+// lift records that the peer holding peerKeys let go of key
+func (h holders[K]) lift(peerKeys map[K]struct{}, key K) {
+	if _, ok := peerKeys[key]; !ok {
+		return
+	}
+	delete(peerKeys, key)
+	if h[key]--; h[key] <= 0 {
+		delete(h, key)
+	}
+}
+
+// Tracker wraps a keyboard and a mouse and records which keys and buttons
+// every peer is holding down, so they can be released if the peer goes away.
+// Peers share the keys of the host, so a key is only released when no peer holds it anymore
+type Tracker struct {
+	mutex    sync.Mutex
+	keyboard keyboard.Keyboard
+	mouse    mouse.Mouse
+	// release a peer's keys after this long without input, zero disables it
+	timeout time.Duration
+	peers   map[string]*peerState
+	// held by any peer, mouse buttons by the key that releases them
+	chars       holders[rune]
+	specialKeys holders[types.SpecialKeyboardKey]
+	physical    holders[types.PhysicalKey]
+	mouseKeys   holders[types.MouseKey]
+}
+
+func NewTracker(k keyboard.Keyboard, m mouse.Mouse, timeout time.Duration) *Tracker {
+	return &Tracker{
+		keyboard:    k,
+		mouse:       m,
+		timeout:     timeout,
+		peers:       make(map[string]*peerState),
+		chars:       make(holders[rune]),
+		specialKeys: make(holders[types.SpecialKeyboardKey]),
+		physical:    make(holders[types.PhysicalKey]),
+		mouseKeys:   make(holders[types.MouseKey]),
+	}
+}
+
+// Keyboard returns a keyboard that records input as coming from the given peer
+func (t *Tracker) Keyboard(peerId string) keyboard.Keyboard {
+	return &peerKeyboard{tracker: t, peerId: peerId}
+}
+
+// Mouse returns a mouse that records input as coming from the given peer
+func (t *Tracker) Mouse(peerId string) mouse.Mouse {
+	return &peerMouse{tracker: t, peerId: peerId}
+}
+
+// Pressed reports whether the peer is holding down any key or button
+func (t *Tracker) Pressed(peerId string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	state, ok := t.peers[peerId]
+	if !ok {
+		return false
+	}
+	return len(state.chars) > 0 || len(state.specialKeys) > 0 || len(state.physical) > 0 || len(state.mouseKeys) > 0
+}
+
+// Release releases every key and button held down by the peer and not by another one, and forgets it
+func (t *Tracker) Release(peerId string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.release(peerId)
+}
+
+// ReleaseAll releases every key and button held down by any peer
+func (t *Tracker) ReleaseAll() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var errs []error
+	for peerId := range t.peers {
+		errs = append(errs, t.release(peerId))
+	}
+	return errors.Join(errs...)
+}
+
+// (LOCK MUTEX BEFORE USING THIS)
+func (t *Tracker) release(peerId string) error {
+	state, ok := t.peers[peerId]
+	if !ok {
+		return nil
+	}
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	delete(t.peers, peerId)
+	var errs []error
+	for key := range state.chars {
+		if !t.chars.others(state.chars, key) {
+			errs = append(errs, t.keyboard.SendInputKeyChar(key, false))
+		}
+		t.chars.lift(state.chars, key)
+	}
+	for key := range state.specialKeys {
+		if !t.specialKeys.others(state.specialKeys, key) {
+			errs = append(errs, t.keyboard.SendInputKeySpecialKey(key, false))
+		}
+		t.specialKeys.lift(state.specialKeys, key)
+	}
+	for key := range state.physical {
+		if !t.physical.others(state.physical, key) {
+			errs = append(errs, t.keyboard.SendInputPhysicalKey(key, false))
+		}
+		t.physical.lift(state.physical, key)
+	}
+	for key := range state.mouseKeys {
+		if !t.mouseKeys.others(state.mouseKeys, key) {
+			errs = append(errs, t.mouse.SendInputKey(key))
+		}
+		t.mouseKeys.lift(state.mouseKeys, key)
+	}
+	return errors.Join(errs...)
+}
+
+// release a peer after its inactivity timeout, unless it was already released
+func (t *Tracker) expire(peerId string, state *peerState) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.peers[peerId] == state {
+		t.release(peerId)
+	}
+}
+
+// get the state of a peer and restart its inactivity timer (LOCK MUTEX BEFORE USING THIS)
+func (t *Tracker) touch(peerId string) *peerState {
+	state, ok := t.peers[peerId]
+	if !ok {
+		state = &peerState{
+			chars:       make(map[rune]struct{}),
+			specialKeys: make(map[types.SpecialKeyboardKey]struct{}),
+			physical:    make(map[types.PhysicalKey]struct{}),
+			mouseKeys:   make(map[types.MouseKey]struct{}),
+		}
+		t.peers[peerId] = state
+	}
+	if t.timeout > 0 {
+		if state.timer == nil {
+			state.timer = time.AfterFunc(t.timeout, func() {
+				t.expire(peerId, state)
+			})
+		} else {
+			state.timer.Reset(t.timeout)
+		}
+	}
+	return state
+}
+
+type peerKeyboard struct {
+	tracker *Tracker
+	peerId  string
+}
+
+func (k *peerKeyboard) SendInputKeyChar(key rune, down bool) error {
+	k.tracker.mutex.Lock()
+	defer k.tracker.mutex.Unlock()
+	state := k.tracker.touch(k.peerId)
+	if !down && k.tracker.chars.others(state.chars, key) {
+		// another peer still holds it
+		k.tracker.chars.lift(state.chars, key)
+		return nil
+	}
+	if err := k.tracker.keyboard.SendInputKeyChar(key, down); err != nil {
+		return err
+	}
+	if down {
+		k.tracker.chars.press(state.chars, key)
+	} else {
+		k.tracker.chars.lift(state.chars, key)
+	}
+	return nil
+}
+
+func (k *peerKeyboard) TypeText(text string) error {
+	k.tracker.mutex.Lock()
+	defer k.tracker.mutex.Unlock()
+	k.tracker.touch(k.peerId)
+	return k.tracker.keyboard.TypeText(text)
+}
+
+func (k *peerKeyboard) SendInputKeySpecialKey(key types.SpecialKeyboardKey, down bool) error {
+	k.tracker.mutex.Lock()
+	defer k.tracker.mutex.Unlock()
+	state := k.tracker.touch(k.peerId)
+	if !down && k.tracker.specialKeys.others(state.specialKeys, key) {
+		// another peer still holds it
+		k.tracker.specialKeys.lift(state.specialKeys, key)
+		return nil
+	}
+	if err := k.tracker.keyboard.SendInputKeySpecialKey(key, down); err != nil {
+		return err
+	}
+	if down {
+		k.tracker.specialKeys.press(state.specialKeys, key)
+	} else {
+		k.tracker.specialKeys.lift(state.specialKeys, key)
+	}
+	return nil
+}
+
+func (k *peerKeyboard) SendInputPhysicalKey(key types.PhysicalKey, down bool) error {
+	k.tracker.mutex.Lock()
+	defer k.tracker.mutex.Unlock()
+	state := k.tracker.touch(k.peerId)
+	if !down && k.tracker.physical.others(state.physical, key) {
+		// another peer still holds it
+		k.tracker.physical.lift(state.physical, key)
+		return nil
+	}
+	if err := k.tracker.keyboard.SendInputPhysicalKey(key, down); err != nil {
+		return err
+	}
+	if down {
+		k.tracker.physical.press(state.physical, key)
+	} else {
+		k.tracker.physical.lift(state.physical, key)
+	}
+	return nil
+}
+
+// a chord releases everything it presses, so there is nothing to track
+func (k *peerKeyboard) SendInputChord(chord types.Chord) error {
+	k.tracker.mutex.Lock()
+	defer k.tracker.mutex.Unlock()
+	k.tracker.touch(k.peerId)
+	return keyboard.SendChord(k.tracker.keyboard, chord)
+}
+
+type peerMouse struct {
+	tracker *Tracker
+	peerId  string
+}
+
+func (m *peerMouse) SendInputMove(dx int, dy int) error {
+	m.tracker.mutex.Lock()
+	defer m.tracker.mutex.Unlock()
+	m.tracker.touch(m.peerId)
+	return m.tracker.mouse.SendInputMove(dx, dy)
+}
+
+func (m *peerMouse) SendInputKey(key types.MouseKey) error {
+	m.tracker.mutex.Lock()
+	defer m.tracker.mutex.Unlock()
+	state := m.tracker.touch(m.peerId)
+	release, pressed := mouseKeyRelease[key]
+	if !pressed && m.tracker.mouseKeys.others(state.mouseKeys, key) {
+		// another peer still holds the button
+		m.tracker.mouseKeys.lift(state.mouseKeys, key)
+		return nil
+	}
+	if err := m.tracker.mouse.SendInputKey(key); err != nil {
+		return err
+	}
+	if pressed {
+		m.tracker.mouseKeys.press(state.mouseKeys, release)
+	} else {
+		m.tracker.mouseKeys.lift(state.mouseKeys, key)
+	}
+	return nil
+}
+
+func (m *peerMouse) SendInputScroll(direction types.MouseWheelDir, magnitude int) error {
+	m.tracker.mutex.Lock()
+	defer m.tracker.mutex.Unlock()
+	m.tracker.touch(m.peerId)
+	return m.tracker.mouse.SendInputScroll(direction, magnitude)
+}
@@ -0,0 +1,99 @@
+package tracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/fake"
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type releaseTest struct {
+	press    func(t *Tracker)
+	expected []string
+}
+
+func TestRelease(t *testing.T) {
+	releaseTests := []releaseTest{
+		{func(t *Tracker) {}, []string{}},
+		{func(t *Tracker) {
+			t.Keyboard("p1").SendInputKeySpecialKey(types.SHIFT, true)
+			t.Keyboard("p1").SendInputKeyChar('a', true)
+		}, []string{"char a false", "special SHIFT false"}},
+		{func(t *Tracker) {
+			t.Keyboard("p1").SendInputKeyChar('a', true)
+			t.Keyboard("p1").SendInputKeyChar('a', false)
+			t.Mouse("p1").SendInputKey(types.LMBDown)
+		}, []string{"mouse LMBUp"}},
+		{func(t *Tracker) {
+			t.Mouse("p1").SendInputKey(types.RMBDown)
+			t.Mouse("p1").SendInputKey(types.RMBUp)
+			t.Mouse("p1").SendInputMove(1, 1)
+		}, []string{}},
+		{func(t *Tracker) {
+			t.Keyboard("p2").SendInputKeySpecialKey(types.CONTROL, true)
+		}, []string{}},
+	}
+	for _, test := range releaseTests {
+		k, m := &fake.Keyboard{}, &fake.Mouse{}
+		tracker := NewTracker(k, m, 0)
+		test.press(tracker)
+		k.Reset()
+		m.Reset()
+		assert.Nil(t, tracker.Release("p1"))
+		assert.ElementsMatch(t, append(k.Calls(), m.Calls()...), test.expected)
+		assert.False(t, tracker.Pressed("p1"))
+	}
+}
+
+func TestSharedKeys(t *testing.T) {
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	tracker := NewTracker(k, m, 0)
+	tracker.Keyboard("p1").SendInputKeySpecialKey(types.SHIFT, true)
+	tracker.Keyboard("p2").SendInputKeySpecialKey(types.SHIFT, true)
+	tracker.Keyboard("p1").SendInputPhysicalKey("KeyW", true)
+	tracker.Keyboard("p2").SendInputPhysicalKey("KeyW", true)
+	tracker.Mouse("p1").SendInputKey(types.LMBDown)
+	tracker.Mouse("p2").SendInputKey(types.LMBDown)
+	k.Reset()
+	m.Reset()
+
+	// p2 still holds everything p1 lets go of
+	assert.NoError(t, tracker.Keyboard("p1").SendInputKeySpecialKey(types.SHIFT, false))
+	assert.NoError(t, tracker.Mouse("p1").SendInputKey(types.LMBUp))
+	assert.NoError(t, tracker.Release("p1"))
+	assert.Empty(t, k.Calls())
+	assert.Empty(t, m.Calls())
+	assert.True(t, tracker.Pressed("p2"))
+
+	// the last holder releases them
+	assert.NoError(t, tracker.Keyboard("p2").SendInputKeySpecialKey(types.SHIFT, false))
+	assert.NoError(t, tracker.Release("p2"))
+	assert.ElementsMatch(t, append(k.Calls(), m.Calls()...), []string{"special SHIFT false", "physical KeyW false", "mouse LMBUp"})
+
+	// once nobody holds a key, releasing it goes through again
+	k.Reset()
+	assert.NoError(t, tracker.Keyboard("p1").SendInputKeySpecialKey(types.SHIFT, false))
+	assert.Equal(t, []string{"special SHIFT false"}, k.Calls())
+}
+
+func TestReleaseAll(t *testing.T) {
+	k, m := &fake.Keyboard{}, &fake.Mouse{}
+	tracker := NewTracker(k, m, 0)
+	tracker.Keyboard("p1").SendInputKeySpecialKey(types.LALT, true)
+	tracker.Mouse("p2").SendInputKey(types.MMBDown)
+	k.Reset()
+	m.Reset()
+	assert.Nil(t, tracker.ReleaseAll())
+	assert.ElementsMatch(t, append(k.Calls(), m.Calls()...), []string{"special LALT false", "mouse MMBUp"})
+	assert.False(t, tracker.Pressed("p1"))
+	assert.False(t, tracker.Pressed("p2"))
+}
+
+func TestInactivityTimeout(t *testing.T) {
+	tracker := NewTracker(&fake.Keyboard{}, &fake.Mouse{}, 10*time.Millisecond)
+	tracker.Keyboard("p1").SendInputKeySpecialKey(types.SHIFT, true)
+	assert.True(t, tracker.Pressed("p1"))
+	assert.Eventually(t, func() bool { return !tracker.Pressed("p1") }, time.Second, time.Millisecond)
+}
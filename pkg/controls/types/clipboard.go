@@ -0,0 +1,17 @@
+package types
+
+// what is on a clipboard, either part may be empty
+type ClipboardContent struct {
+	Text string `json:"text,omitempty"`
+	// PNG encoded, base64 in json
+	Image []byte `json:"image,omitempty"`
+}
+
+// Size is the number of bytes of text and image
+func (c ClipboardContent) Size() int {
+	return len(c.Text) + len(c.Image)
+}
+
+func (c ClipboardContent) Empty() bool {
+	return c.Text == "" && len(c.Image) == 0
+}
@@ -0,0 +1,20 @@
+package types
+
+// the image of the host cursor, clients draw it with the hotspot at the cursor position
+type CursorShape struct {
+	// identifies the shape, clients cache shapes by it
+	Hash     string `json:"hash"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	HotspotX int    `json:"hotspotX"`
+	HotspotY int    `json:"hotspotY"`
+	// PNG encoded, base64 in json. Left out when the client was sent the shape before
+	Image []byte `json:"image,omitempty"`
+}
+
+// where the host cursor is, in stream coordinates
+type CursorPosition struct {
+	X       int  `json:"x"`
+	Y       int  `json:"y"`
+	Visible bool `json:"visible"`
+}
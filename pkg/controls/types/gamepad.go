@@ -0,0 +1,44 @@
+package types
+
+import "time"
+
+// types for gamepad input
+type (
+	GamepadButton  string
+	GamepadStick   string
+	GamepadTrigger string
+)
+
+// all types (implementation independent), named after an Xbox controller
+const (
+	GamepadA         GamepadButton = "A"
+	GamepadB         GamepadButton = "B"
+	GamepadX         GamepadButton = "X"
+	GamepadY         GamepadButton = "Y"
+	GamepadLB        GamepadButton = "LB"
+	GamepadRB        GamepadButton = "RB"
+	GamepadBack      GamepadButton = "BACK"
+	GamepadStart     GamepadButton = "START"
+	GamepadGuide     GamepadButton = "GUIDE"
+	GamepadLS        GamepadButton = "LS"
+	GamepadRS        GamepadButton = "RS"
+	GamepadDpadUp    GamepadButton = "DPAD_UP"
+	GamepadDpadDown  GamepadButton = "DPAD_DOWN"
+	GamepadDpadLeft  GamepadButton = "DPAD_LEFT"
+	GamepadDpadRight GamepadButton = "DPAD_RIGHT"
+
+	LeftStick  GamepadStick = "LEFT"
+	RightStick GamepadStick = "RIGHT"
+
+	LeftTrigger  GamepadTrigger = "LT"
+	RightTrigger GamepadTrigger = "RT"
+)
+
+// force feedback sent by the host to a gamepad
+type GamepadRumble struct {
+	// motor magnitudes in range 0-1, both are 0 when the rumble stops
+	Strong float64
+	Weak   float64
+	// zero means until stopped
+	Duration time.Duration
+}
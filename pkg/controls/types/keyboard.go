@@ -3,6 +3,27 @@ package types
 // special keys like Ctrl
 type SpecialKeyboardKey string
 
+// a key by its position on the keyboard, as in the DOM KeyboardEvent.code (e.g. "KeyQ")
+type PhysicalKey string
+
+// a combination like Ctrl+Alt+Del, the modifiers are pressed in order and released in reverse
+type Chord struct {
+	Modifiers []SpecialKeyboardKey
+	// the key pressed last, either a special key or a character
+	Key  SpecialKeyboardKey
+	Char rune
+}
+
+// how physical keys are sent to the host
+type KeyboardMode string
+
+const (
+	// the key at the same position is pressed, whatever the layouts of the client and the host
+	PhysicalMode KeyboardMode = "physical"
+	// the character the key makes in the client's layout is typed
+	CharacterMode KeyboardMode = "character"
+)
+
 // all types (implementation independent)
 const (
 	BACKSPACE SpecialKeyboardKey = "BACKSPACE"
@@ -91,3 +112,93 @@ const (
 	AUDIO_PREV        SpecialKeyboardKey = "AUDIO_PREV"
 	AUDIO_NEXT        SpecialKeyboardKey = "AUDIO_NEXT"
 )
+
+// all special keys, for validation
+var specialKeyboardKeys = map[SpecialKeyboardKey]struct{}{
+	BACKSPACE:         {},
+	DELETE:            {},
+	RETURN:            {},
+	TAB:               {},
+	ESCAPE:            {},
+	UP:                {},
+	DOWN:              {},
+	RIGHT:             {},
+	LEFT:              {},
+	HOME:              {},
+	END:               {},
+	PAGEUP:            {},
+	PAGEDOWN:          {},
+	F1:                {},
+	F2:                {},
+	F3:                {},
+	F4:                {},
+	F5:                {},
+	F6:                {},
+	F7:                {},
+	F8:                {},
+	F9:                {},
+	F10:               {},
+	F11:               {},
+	F12:               {},
+	F13:               {},
+	F14:               {},
+	F15:               {},
+	F16:               {},
+	F17:               {},
+	F18:               {},
+	F19:               {},
+	F20:               {},
+	F21:               {},
+	F22:               {},
+	F23:               {},
+	F24:               {},
+	META:              {},
+	LMETA:             {},
+	RMETA:             {},
+	ALT:               {},
+	LALT:              {},
+	RALT:              {},
+	CONTROL:           {},
+	LCONTROL:          {},
+	RCONTROL:          {},
+	SHIFT:             {},
+	LSHIFT:            {},
+	RSHIFT:            {},
+	CAPSLOCK:          {},
+	SPACE:             {},
+	PRINTSCREEN:       {},
+	INSERT:            {},
+	MENU:              {},
+	NUMPAD_0:          {},
+	NUMPAD_1:          {},
+	NUMPAD_2:          {},
+	NUMPAD_3:          {},
+	NUMPAD_4:          {},
+	NUMPAD_5:          {},
+	NUMPAD_6:          {},
+	NUMPAD_7:          {},
+	NUMPAD_8:          {},
+	NUMPAD_9:          {},
+	NUMPAD_LOCK:       {},
+	NUMPAD_DECIMAL:    {},
+	NUMPAD_PLUS:       {},
+	NUMPAD_MINUS:      {},
+	NUMPAD_MUL:        {},
+	NUMPAD_DIV:        {},
+	NUMPAD_ENTER:      {},
+	NUMPAD_EQUAL:      {},
+	AUDIO_VOLUME_MUTE: {},
+	AUDIO_VOLUME_DOWN: {},
+	AUDIO_VOLUME_UP:   {},
+	AUDIO_PLAY:        {},
+	AUDIO_STOP:        {},
+	AUDIO_PAUSE:       {},
+	AUDIO_PREV:        {},
+	AUDIO_NEXT:        {},
+}
+
+// Valid tells if k is one of the special keys above
+func (k SpecialKeyboardKey) Valid() bool {
+	_, ok := specialKeyboardKeys[k]
+	return ok
+}
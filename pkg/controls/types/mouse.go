@@ -1,5 +1,11 @@
 package types
 
+import (
+	"fmt"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+)
+
 // types for mouse input
 type (
 	MouseKey      string
@@ -20,3 +26,54 @@ const (
 	VWheel MouseWheelDir = true
 	HWheel MouseWheelDir = false
 )
+
+// Valid tells if k is one of the mouse keys above
+func (k MouseKey) Valid() bool {
+	switch k {
+	case LMBUp, LMBDown, RMBUp, RMBDown, MMBUp, MMBDown, XMBUp, XMBDown:
+		return true
+	}
+	return false
+}
+
+func (d MouseWheelDir) MarshalText() ([]byte, error) {
+	if d == VWheel {
+		return []byte("vertical"), nil
+	}
+	return []byte("horizontal"), nil
+}
+
+func (d *MouseWheelDir) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "vertical":
+		*d = VWheel
+	case "horizontal":
+		*d = HWheel
+	default:
+		return pkgerrors.NewUnmarshalError(fmt.Errorf("unknown mouse wheel direction '%s', expected (vertical / horizontal)", text))
+	}
+	return nil
+}
+
+// kind of a mouse event in a batch
+type MouseEventType int
+
+const (
+	MouseMoveEvent MouseEventType = iota
+	MouseKeyEvent
+	MouseScrollEvent
+)
+
+// a single mouse event, for sending several at once.
+// Only the fields of its type are used
+type MouseEvent struct {
+	Type MouseEventType
+	// MouseMoveEvent
+	Dx int
+	Dy int
+	// MouseKeyEvent
+	Key MouseKey
+	// MouseScrollEvent
+	Direction MouseWheelDir
+	Magnitude int
+}
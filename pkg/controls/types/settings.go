@@ -0,0 +1,14 @@
+package types
+
+// StreamSettingsUpdate changes the settings of the running stream, fields left out keep their value
+type StreamSettingsUpdate struct {
+	// in kbit/sec
+	VideoBitrate   *uint `json:"videoBitrate,omitempty"`
+	VideoFramerate *uint `json:"videoFramerate,omitempty"`
+	VideoWidth     *int  `json:"videoWidth,omitempty"`
+	VideoHeight    *int  `json:"videoHeight,omitempty"`
+	VideoCursor    *bool `json:"videoCursor,omitempty"`
+	// in bps
+	AudioBitrate       *uint `json:"audioBitrate,omitempty"`
+	AudioPacketLossPct *uint `json:"audioPacketLossPct,omitempty"`
+}
@@ -0,0 +1,42 @@
+package types
+
+// types for touch and pen input
+type TouchPhase string
+
+// all types (implementation independent)
+const (
+	TouchDown   TouchPhase = "down"
+	TouchMove   TouchPhase = "move"
+	TouchUp     TouchPhase = "up"
+	TouchCancel TouchPhase = "cancel"
+)
+
+// a finger on the screen, positions are in stream coordinates
+type TouchContact struct {
+	// stays the same from down to up
+	ID    int        `json:"id"`
+	Phase TouchPhase `json:"phase"`
+	X     int        `json:"x"`
+	Y     int        `json:"y"`
+	// in range 0-1
+	Pressure float64 `json:"pressure"`
+}
+
+// the state of a stylus, positions are in stream coordinates
+type PenState struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	// in range 0-1
+	Pressure float64 `json:"pressure"`
+	// in degrees, in range -90 to 90
+	TiltX int `json:"tiltX"`
+	TiltY int `json:"tiltY"`
+	// the pen is close enough to the screen to be tracked
+	InRange bool `json:"inRange"`
+	// the pen tip touches the screen
+	InContact bool `json:"inContact"`
+	// the pen is upside down
+	Eraser bool `json:"eraser"`
+	// the barrel button is pressed
+	Barrel bool `json:"barrel"`
+}
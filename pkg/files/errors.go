@@ -0,0 +1,71 @@
+package files
+
+import "fmt"
+
+// SandboxPathError indicates a file name that resolves outside the sandbox
+type SandboxPathError struct {
+	Name string
+}
+
+func (e *SandboxPathError) Error() string {
+	return fmt.Sprintf("SandboxPathError: '%s' is outside the sandbox", e.Name)
+}
+
+func NewSandboxPathError(name string) error {
+	return &SandboxPathError{
+		Name: name,
+	}
+}
+
+// QuotaExceededError indicates an upload that doesn't fit in the sandbox quota
+type QuotaExceededError struct {
+	Size int64
+	Free int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("QuotaExceededError: %d bytes, %d are free", e.Size, e.Free)
+}
+
+func NewQuotaExceededError(size int64, free int64) error {
+	return &QuotaExceededError{
+		Size: size,
+		Free: free,
+	}
+}
+
+// ChecksumError indicates a finished upload that doesn't match its checksum
+type ChecksumError struct {
+	Name     string
+	Expected string
+	Got      string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("ChecksumError: '%s' has sha256 %s, expected %s", e.Name, e.Got, e.Expected)
+}
+
+func NewChecksumError(name string, expected string, got string) error {
+	return &ChecksumError{
+		Name:     name,
+		Expected: expected,
+		Got:      got,
+	}
+}
+
+// TransferError indicates a message that doesn't fit the state of a transfer
+type TransferError struct {
+	ID     uint32
+	Reason string
+}
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("TransferError: transfer %d: %s", e.ID, e.Reason)
+}
+
+func NewTransferError(id uint32, reason string) error {
+	return &TransferError{
+		ID:     id,
+		Reason: reason,
+	}
+}
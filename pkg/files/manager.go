@@ -0,0 +1,490 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+)
+
+// Channel is the files datachannel of each peer
+type Channel interface {
+	SendMessage(peerId string, message string) error
+	SendData(peerId string, data []byte) error
+	// BufferedAmount is the bytes queued on the channel and not sent yet
+	BufferedAmount(peerId string) (uint64, error)
+}
+
+const (
+	// downloads wait while more than this is queued on the channel
+	HighWaterMark = 1024 * 1024
+	// a progress message is sent each time this much more of a file is done
+	ProgressInterval = 1024 * 1024
+	// how often a waiting download checks the buffered amount in case the low signal was missed
+	bufferedAmountPoll = 100 * time.Millisecond
+)
+
+// a cancelled download stops without an error message, the client asked for it
+var errCancelled = errors.New("cancelled")
+
+type upload struct {
+	name    string
+	size    int64
+	sum     string
+	partial string
+	file    *os.File
+	// changed under the manager mutex
+	received int64
+	// received at the last progress message
+	reported int64
+}
+
+type peerTransfers struct {
+	uploads map[uint32]*upload
+	// closed to cancel a download
+	downloads map[uint32]chan struct{}
+	// signaled when the buffered amount falls below the channel's low threshold
+	low chan struct{}
+}
+
+// Manager runs the file transfers of every peer, it handles the events of the files datachannel
+type Manager struct {
+	sandbox *Sandbox
+	channel Channel
+	// nil lets every peer transfer files
+	permissions *permissions.Permissions
+	mutex       sync.Mutex
+	peers       map[string]*peerTransfers
+	// the bytes of the uploads in progress that aren't written yet
+	reserved int64
+}
+
+func NewManager(sandbox *Sandbox, channel Channel) *Manager {
+	return &Manager{
+		sandbox: sandbox,
+		channel: channel,
+		peers:   map[string]*peerTransfers{},
+	}
+}
+
+// SetPermissions only lets the peers allowed to by their role transfer files, this should be done before peers are added
+func (m *Manager) SetPermissions(p *permissions.Permissions) {
+	m.permissions = p
+}
+
+func (m *Manager) OnFilesOpened(peerId string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.peers[peerId] = &peerTransfers{
+		uploads:   map[uint32]*upload{},
+		downloads: map[uint32]chan struct{}{},
+		low:       make(chan struct{}, 1),
+	}
+}
+
+// OnFilesClosed stops the peer's transfers, partial uploads are kept so they can be resumed
+func (m *Manager) OnFilesClosed(peerId string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	transfers, ok := m.peers[peerId]
+	if !ok {
+		return
+	}
+	for _, u := range transfers.uploads {
+		u.file.Close()
+		m.release(u)
+	}
+	for _, cancel := range transfers.downloads {
+		close(cancel)
+	}
+	delete(m.peers, peerId)
+}
+
+func (m *Manager) OnFilesBufferedAmountLow(peerId string) {
+	m.mutex.Lock()
+	transfers, ok := m.peers[peerId]
+	m.mutex.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case transfers.low <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) OnFilesMessage(peerId string, message string) {
+	payload, err := Unmarshal([]byte(message))
+	if err != nil {
+		m.sendError(peerId, 0, err)
+		return
+	}
+	switch p := payload.(type) {
+	case *UploadPayload:
+		err = m.startUpload(peerId, p)
+	case *DownloadPayload:
+		err = m.startDownload(peerId, p)
+	case *CancelPayload:
+		m.cancel(peerId, p.ID)
+	default:
+		err = NewTransferError(0, "unexpected "+string(p.Type())+" message")
+	}
+	if err != nil {
+		var id uint32
+		switch p := payload.(type) {
+		case *UploadPayload:
+			id = p.ID
+		case *DownloadPayload:
+			id = p.ID
+		}
+		m.sendError(peerId, id, err)
+	}
+}
+
+// OnFilesData writes a chunk of an upload
+func (m *Manager) OnFilesData(peerId string, data []byte) {
+	id, offset, data, err := DecodeChunk(data)
+	if err != nil {
+		m.sendError(peerId, 0, err)
+		return
+	}
+	if err := m.writeChunk(peerId, id, offset, data); err != nil {
+		m.abortUpload(peerId, id)
+		m.sendError(peerId, id, err)
+	}
+}
+
+func (m *Manager) startUpload(peerId string, p *UploadPayload) error {
+	if err := m.checkAllowed(peerId); err != nil {
+		return err
+	}
+	if p.Size < 0 {
+		return NewTransferError(p.ID, "negative size")
+	}
+	sum := strings.ToLower(p.SHA256)
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+		return NewTransferError(p.ID, "bad sha256 checksum")
+	}
+	if _, err := m.sandbox.Path(p.Name); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	transfers, ok := m.peers[peerId]
+	if !ok {
+		return NewTransferError(p.ID, "files channel is not open")
+	}
+	if _, ok := transfers.uploads[p.ID]; ok {
+		return NewTransferError(p.ID, "id already in use")
+	}
+	partial := m.sandbox.partialPath(sum, p.Size)
+	// another peer may be sending the same file right now
+	for _, t := range m.peers {
+		for _, u := range t.uploads {
+			if u.partial == partial {
+				return NewTransferError(p.ID, "the same file is being uploaded")
+			}
+		}
+	}
+	var received int64
+	if info, err := os.Stat(partial); err == nil && info.Size() <= p.Size {
+		received = info.Size()
+	}
+	if err := m.sandbox.Reserve(p.Size-received, m.reserved); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	// anything past what is resumed from is thrown away
+	if err := file.Truncate(received); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(received, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	u := &upload{
+		name:     p.Name,
+		size:     p.Size,
+		sum:      sum,
+		partial:  partial,
+		file:     file,
+		received: received,
+		reported: received,
+	}
+	transfers.uploads[p.ID] = u
+	m.reserved += p.Size - received
+	m.send(peerId, &UploadReadyPayload{ID: p.ID, Offset: received})
+	if received == p.Size {
+		go m.completeUpload(peerId, p.ID)
+	}
+	return nil
+}
+
+func (m *Manager) writeChunk(peerId string, id uint32, offset int64, data []byte) error {
+	m.mutex.Lock()
+	transfers, ok := m.peers[peerId]
+	if !ok {
+		m.mutex.Unlock()
+		return NewTransferError(id, "files channel is not open")
+	}
+	u, ok := transfers.uploads[id]
+	m.mutex.Unlock()
+	if !ok {
+		return NewTransferError(id, "no such upload")
+	}
+	// chunks of a transfer arrive in order on the reliable channel, one at a time
+	if offset != u.received {
+		return NewTransferError(id, "chunk out of order")
+	}
+	if u.received+int64(len(data)) > u.size {
+		return NewTransferError(id, "more data than the size given")
+	}
+	if _, err := u.file.Write(data); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	u.received += int64(len(data))
+	m.reserved -= int64(len(data))
+	m.mutex.Unlock()
+	if u.received == u.size {
+		m.completeUpload(peerId, id)
+		return nil
+	}
+	if u.received-u.reported >= ProgressInterval {
+		u.reported = u.received
+		m.send(peerId, &ProgressPayload{ID: id, Done: u.received, Size: u.size})
+	}
+	return nil
+}
+
+func (m *Manager) completeUpload(peerId string, id uint32) {
+	m.mutex.Lock()
+	var u *upload
+	if transfers, ok := m.peers[peerId]; ok {
+		u = transfers.uploads[id]
+		delete(transfers.uploads, id)
+	}
+	m.mutex.Unlock()
+	if u == nil {
+		return
+	}
+	name, err := m.finishUpload(u)
+	if err != nil {
+		m.sendError(peerId, id, err)
+		return
+	}
+	m.send(peerId, &ProgressPayload{ID: id, Done: u.size, Size: u.size})
+	m.send(peerId, &CompletePayload{ID: id, Name: name})
+}
+
+func (m *Manager) finishUpload(u *upload) (string, error) {
+	if err := u.file.Close(); err != nil {
+		return "", err
+	}
+	sum, err := fileChecksum(u.partial)
+	if err != nil {
+		return "", err
+	}
+	if sum != u.sum {
+		// resuming from a corrupt file would never succeed
+		os.Remove(u.partial)
+		return "", NewChecksumError(u.name, u.sum, sum)
+	}
+	return m.sandbox.finish(u.partial, u.name)
+}
+
+// abortUpload stops an upload, keeping what was received so it can be resumed
+func (m *Manager) abortUpload(peerId string, id uint32) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	transfers, ok := m.peers[peerId]
+	if !ok {
+		return
+	}
+	if u, ok := transfers.uploads[id]; ok {
+		u.file.Close()
+		m.release(u)
+		delete(transfers.uploads, id)
+	}
+}
+
+// release gives back the bytes reserved for an upload that won't be written, the mutex must be held
+func (m *Manager) release(u *upload) {
+	m.reserved -= u.size - u.received
+}
+
+// checkAllowed returns a PermissionError if the role of the peer doesn't allow transferring files
+func (m *Manager) checkAllowed(peerId string) error {
+	if m.permissions != nil && !m.permissions.CanTransferFiles(peerId) {
+		return permissions.NewPermissionError(peerId, "transfer files")
+	}
+	return nil
+}
+
+func (m *Manager) cancel(peerId string, id uint32) {
+	m.abortUpload(peerId, id)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	transfers, ok := m.peers[peerId]
+	if !ok {
+		return
+	}
+	if cancel, ok := transfers.downloads[id]; ok {
+		close(cancel)
+		delete(transfers.downloads, id)
+	}
+}
+
+func (m *Manager) startDownload(peerId string, p *DownloadPayload) error {
+	if err := m.checkAllowed(peerId); err != nil {
+		return err
+	}
+	path, err := m.sandbox.Path(p.Name)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return NewTransferError(p.ID, "not a file")
+	}
+	if p.Offset < 0 || p.Offset > info.Size() {
+		return NewTransferError(p.ID, "offset outside the file")
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	transfers, ok := m.peers[peerId]
+	if !ok {
+		return NewTransferError(p.ID, "files channel is not open")
+	}
+	if _, ok := transfers.downloads[p.ID]; ok {
+		return NewTransferError(p.ID, "id already in use")
+	}
+	cancel := make(chan struct{})
+	transfers.downloads[p.ID] = cancel
+	go func() {
+		if err := m.download(peerId, p, path, transfers.low, cancel); err != nil && err != errCancelled {
+			m.sendError(peerId, p.ID, err)
+		}
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if transfers.downloads[p.ID] == cancel {
+			delete(transfers.downloads, p.ID)
+		}
+	}()
+	return nil
+}
+
+// download sends a file from the offset, waiting whenever the channel has too much queued
+func (m *Manager) download(peerId string, p *DownloadPayload, path string, low <-chan struct{}, cancel <-chan struct{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	m.send(peerId, &FilePayload{ID: p.ID, Name: p.Name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	if _, err := file.Seek(p.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	buffer := make([]byte, ChunkSize)
+	offset, reported := p.Offset, p.Offset
+	for offset < size {
+		if err := m.waitForBuffer(peerId, low, cancel); err != nil {
+			return err
+		}
+		n, err := file.Read(buffer)
+		if n > 0 {
+			if err := m.channel.SendData(peerId, EncodeChunk(p.ID, offset, buffer[:n])); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if offset-reported >= ProgressInterval {
+			reported = offset
+			m.send(peerId, &ProgressPayload{ID: p.ID, Done: offset, Size: size})
+		}
+	}
+	m.send(peerId, &ProgressPayload{ID: p.ID, Done: offset, Size: size})
+	m.send(peerId, &CompletePayload{ID: p.ID, Name: p.Name})
+	return nil
+}
+
+// waitForBuffer returns once the channel's buffered amount is below the high water mark
+func (m *Manager) waitForBuffer(peerId string, low <-chan struct{}, cancel <-chan struct{}) error {
+	for {
+		select {
+		case <-cancel:
+			return errCancelled
+		default:
+		}
+		amount, err := m.channel.BufferedAmount(peerId)
+		if err != nil {
+			return err
+		}
+		if amount < HighWaterMark {
+			return nil
+		}
+		select {
+		case <-cancel:
+			return errCancelled
+		case <-low:
+		case <-time.After(bufferedAmountPoll):
+		}
+	}
+}
+
+func (m *Manager) send(peerId string, payload Payload) {
+	bytes, err := Marshal(payload)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := m.channel.SendMessage(peerId, string(bytes)); err != nil {
+		log.Println(err)
+	}
+}
+
+func (m *Manager) sendError(peerId string, id uint32, err error) {
+	m.send(peerId, &ErrorPayload{ID: id, Message: err.Error()})
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
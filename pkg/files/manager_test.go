@@ -0,0 +1,244 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benu-cloud/benu-webrtc/pkg/controls/permissions"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeChannel struct {
+	mutex    sync.Mutex
+	messages []Payload
+	data     [][]byte
+	buffered uint64
+}
+
+func (c *fakeChannel) SendMessage(peerId string, message string) error {
+	payload, err := Unmarshal([]byte(message))
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.messages = append(c.messages, payload)
+	return nil
+}
+
+func (c *fakeChannel) SendData(peerId string, data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.data = append(c.data, data)
+	return nil
+}
+
+func (c *fakeChannel) BufferedAmount(peerId string) (uint64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.buffered, nil
+}
+
+func (c *fakeChannel) setBuffered(amount uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.buffered = amount
+}
+
+// last returns the last message, nil if there is none
+func (c *fakeChannel) last() Payload {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.messages) == 0 {
+		return nil
+	}
+	return c.messages[len(c.messages)-1]
+}
+
+func (c *fakeChannel) received() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var file []byte
+	for _, chunk := range c.data {
+		_, _, data, _ := DecodeChunk(chunk)
+		file = append(file, data...)
+	}
+	return file
+}
+
+func newTestManager(t *testing.T, quota int64) (*Manager, *fakeChannel, string) {
+	dir := t.TempDir()
+	s, err := NewSandbox(dir, quota)
+	assert.NoError(t, err)
+	c := &fakeChannel{}
+	m := NewManager(s, c)
+	m.OnFilesOpened("1")
+	return m, c, dir
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sendMessage(t *testing.T, m *Manager, payload Payload) {
+	bytes, err := Marshal(payload)
+	assert.NoError(t, err)
+	m.OnFilesMessage("1", string(bytes))
+}
+
+func TestUpload(t *testing.T) {
+	m, c, dir := newTestManager(t, 0)
+	file := bytes.Repeat([]byte("0123456789"), 5000)
+	sendMessage(t, m, &UploadPayload{ID: 1, Name: "a.bin", Size: int64(len(file)), SHA256: checksum(file)})
+	assert.Equal(t, &UploadReadyPayload{ID: 1, Offset: 0}, c.last())
+	for offset := 0; offset < len(file); offset += ChunkSize {
+		end := offset + ChunkSize
+		if end > len(file) {
+			end = len(file)
+		}
+		m.OnFilesData("1", EncodeChunk(1, int64(offset), file[offset:end]))
+	}
+	assert.Equal(t, &CompletePayload{ID: 1, Name: "a.bin"}, c.last())
+	saved, err := os.ReadFile(filepath.Join(dir, "a.bin"))
+	assert.NoError(t, err)
+	assert.Equal(t, file, saved)
+}
+
+func TestUploadResume(t *testing.T) {
+	m, c, dir := newTestManager(t, 0)
+	file := []byte("resumable content")
+	upload := &UploadPayload{ID: 1, Name: "a.txt", Size: int64(len(file)), SHA256: checksum(file)}
+	sendMessage(t, m, upload)
+	m.OnFilesData("1", EncodeChunk(1, 0, file[:9]))
+	m.OnFilesClosed("1")
+	m.OnFilesOpened("1")
+	sendMessage(t, m, upload)
+	assert.Equal(t, &UploadReadyPayload{ID: 1, Offset: 9}, c.last())
+	m.OnFilesData("1", EncodeChunk(1, 9, file[9:]))
+	assert.Equal(t, &CompletePayload{ID: 1, Name: "a.txt"}, c.last())
+	saved, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, file, saved)
+}
+
+func TestUploadQuotaReserved(t *testing.T) {
+	m, c, _ := newTestManager(t, 10)
+	file := []byte("content")
+	sendMessage(t, m, &UploadPayload{ID: 1, Name: "a.txt", Size: 7, SHA256: checksum(file)})
+	assert.Equal(t, &UploadReadyPayload{ID: 1, Offset: 0}, c.last())
+	m.OnFilesData("1", EncodeChunk(1, 0, file[:2]))
+	// the 5 bytes still to come are taken, though not written yet
+	sendMessage(t, m, &UploadPayload{ID: 2, Name: "b.txt", Size: 4, SHA256: checksum([]byte("more"))})
+	assert.IsType(t, &ErrorPayload{}, c.last())
+	sendMessage(t, m, &UploadPayload{ID: 3, Name: "c.txt", Size: 3, SHA256: checksum([]byte("abc"))})
+	assert.Equal(t, &UploadReadyPayload{ID: 3, Offset: 0}, c.last())
+
+	// given back when an upload stops
+	sendMessage(t, m, &CancelPayload{ID: 3})
+	sendMessage(t, m, &UploadPayload{ID: 2, Name: "b.txt", Size: 3, SHA256: checksum([]byte("mor"))})
+	assert.Equal(t, &UploadReadyPayload{ID: 2, Offset: 0}, c.last())
+	m.OnFilesClosed("1")
+	m.OnFilesOpened("1")
+	assert.Equal(t, int64(0), m.reserved)
+}
+
+func TestTransferPermissions(t *testing.T) {
+	m, c, dir := newTestManager(t, 0)
+	p := permissions.NewPermissions()
+	p.AddPeer("1", permissions.Viewer)
+	m.SetPermissions(p)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("content"), 0o644))
+	sendMessage(t, m, &DownloadPayload{ID: 1, Name: "a.txt"})
+	assert.Equal(t, &ErrorPayload{ID: 1, Message: "PermissionError: peer '1' is not allowed to transfer files"}, c.last())
+	sendMessage(t, m, &UploadPayload{ID: 2, Name: "b.txt", Size: 7, SHA256: checksum([]byte("content"))})
+	assert.Equal(t, &ErrorPayload{ID: 2, Message: "PermissionError: peer '1' is not allowed to transfer files"}, c.last())
+
+	p.SetRole("1", permissions.Controller)
+	sendMessage(t, m, &UploadPayload{ID: 2, Name: "b.txt", Size: 7, SHA256: checksum([]byte("content"))})
+	assert.Equal(t, &UploadReadyPayload{ID: 2, Offset: 0}, c.last())
+}
+
+type uploadErrorTest struct {
+	upload UploadPayload
+	chunk  []byte
+}
+
+func TestUploadErrors(t *testing.T) {
+	file := []byte("content")
+	uploadErrorTests := []uploadErrorTest{
+		// checksum mismatch
+		{UploadPayload{ID: 1, Name: "a.txt", Size: 7, SHA256: checksum([]byte("other!!"))}, EncodeChunk(1, 0, file)},
+		// over quota
+		{UploadPayload{ID: 1, Name: "a.txt", Size: 11, SHA256: checksum(file)}, nil},
+		// outside the sandbox
+		{UploadPayload{ID: 1, Name: "../a.txt", Size: 7, SHA256: checksum(file)}, nil},
+		// bad checksum
+		{UploadPayload{ID: 1, Name: "a.txt", Size: 7, SHA256: "abc"}, nil},
+		// chunk out of order
+		{UploadPayload{ID: 1, Name: "a.txt", Size: 7, SHA256: checksum(file)}, EncodeChunk(1, 3, file)},
+		// more data than the size
+		{UploadPayload{ID: 1, Name: "a.txt", Size: 3, SHA256: checksum(file)}, EncodeChunk(1, 0, file)},
+		// unknown transfer
+		{UploadPayload{ID: 1, Name: "a.txt", Size: 7, SHA256: checksum(file)}, EncodeChunk(2, 0, file)},
+	}
+	for _, test := range uploadErrorTests {
+		m, c, dir := newTestManager(t, 10)
+		sendMessage(t, m, &test.upload)
+		if test.chunk != nil {
+			m.OnFilesData("1", test.chunk)
+		}
+		assert.IsType(t, &ErrorPayload{}, c.last(), test.upload)
+		assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+	}
+}
+
+func TestDownload(t *testing.T) {
+	m, c, dir := newTestManager(t, 0)
+	file := bytes.Repeat([]byte("x"), 3*ChunkSize+5)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.bin"), file, 0o644))
+	// the channel is full until it signals it's low
+	c.setBuffered(HighWaterMark)
+	sendMessage(t, m, &DownloadPayload{ID: 1, Name: "a.bin"})
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(&FilePayload{ID: 1, Name: "a.bin", Size: int64(len(file)), SHA256: checksum(file)}, c.last())
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, c.received())
+	c.setBuffered(0)
+	m.OnFilesBufferedAmountLow("1")
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(&CompletePayload{ID: 1, Name: "a.bin"}, c.last())
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, file, c.received())
+}
+
+func TestDownloadResume(t *testing.T) {
+	m, c, dir := newTestManager(t, 0)
+	file := []byte("resumable content")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), file, 0o644))
+	sendMessage(t, m, &DownloadPayload{ID: 1, Name: "a.txt", Offset: 9})
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(&CompletePayload{ID: 1, Name: "a.txt"}, c.last())
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, file[9:], c.received())
+	sendMessage(t, m, &DownloadPayload{ID: 2, Name: "a.txt", Offset: 100})
+	assert.IsType(t, &ErrorPayload{}, c.last())
+}
+
+func TestDownloadCancel(t *testing.T) {
+	m, c, dir := newTestManager(t, 0)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("content"), 0o644))
+	c.setBuffered(HighWaterMark)
+	sendMessage(t, m, &DownloadPayload{ID: 1, Name: "a.txt"})
+	sendMessage(t, m, &CancelPayload{ID: 1})
+	c.setBuffered(0)
+	time.Sleep(2 * bufferedAmountPoll)
+	assert.Empty(t, c.received())
+	assert.IsType(t, &FilePayload{}, c.last())
+}
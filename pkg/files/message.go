@@ -0,0 +1,163 @@
+package files
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+)
+
+// type of a message sent over the files datachannel, file contents go as binary chunks
+type MessageType string
+
+const (
+	// client to server
+	UploadMessage   MessageType = "upload"
+	DownloadMessage MessageType = "download"
+	CancelMessage   MessageType = "cancel"
+	// server to client
+	UploadReadyMessage MessageType = "uploadready"
+	FileMessage        MessageType = "file"
+	ProgressMessage    MessageType = "progress"
+	CompleteMessage    MessageType = "complete"
+	ErrorMessage       MessageType = "error"
+)
+
+type GenericMessage struct {
+	Type    MessageType     `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Payload is implemented by every message payload
+type Payload interface {
+	Type() MessageType
+}
+
+// Name is relative to the sandbox, SHA256 is the hex checksum of the whole file
+type UploadPayload struct {
+	ID     uint32 `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Offset is where to resume, 0 for the whole file
+type DownloadPayload struct {
+	ID     uint32 `json:"id"`
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+}
+
+type CancelPayload struct {
+	ID uint32 `json:"id"`
+}
+
+// Offset is what the server already has from an interrupted upload, the client sends from there
+type UploadReadyPayload struct {
+	ID     uint32 `json:"id"`
+	Offset int64  `json:"offset"`
+}
+
+// the file a download sends, followed by its chunks
+type FilePayload struct {
+	ID     uint32 `json:"id"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Done is the number of bytes received for an upload, or sent for a download
+type ProgressPayload struct {
+	ID   uint32 `json:"id"`
+	Done int64  `json:"done"`
+	Size int64  `json:"size"`
+}
+
+// Name is where an upload was saved, which differs from the one asked for if it was taken
+type CompletePayload struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+}
+
+// ID is 0 if the error isn't about a transfer
+type ErrorPayload struct {
+	ID      uint32 `json:"id"`
+	Message string `json:"message"`
+}
+
+func (*UploadPayload) Type() MessageType      { return UploadMessage }
+func (*DownloadPayload) Type() MessageType    { return DownloadMessage }
+func (*CancelPayload) Type() MessageType      { return CancelMessage }
+func (*UploadReadyPayload) Type() MessageType { return UploadReadyMessage }
+func (*FilePayload) Type() MessageType        { return FileMessage }
+func (*ProgressPayload) Type() MessageType    { return ProgressMessage }
+func (*CompletePayload) Type() MessageType    { return CompleteMessage }
+func (*ErrorPayload) Type() MessageType       { return ErrorMessage }
+
+var payloadTypes map[MessageType]func() Payload = map[MessageType]func() Payload{
+	UploadMessage:      func() Payload { return &UploadPayload{} },
+	DownloadMessage:    func() Payload { return &DownloadPayload{} },
+	CancelMessage:      func() Payload { return &CancelPayload{} },
+	UploadReadyMessage: func() Payload { return &UploadReadyPayload{} },
+	FileMessage:        func() Payload { return &FilePayload{} },
+	ProgressMessage:    func() Payload { return &ProgressPayload{} },
+	CompleteMessage:    func() Payload { return &CompletePayload{} },
+	ErrorMessage:       func() Payload { return &ErrorPayload{} },
+}
+
+func Unmarshal(bytes []byte) (Payload, error) {
+	genericMessage := &GenericMessage{}
+	if err := json.Unmarshal(bytes, genericMessage); err != nil {
+		return nil, pkgerrors.NewUnmarshalError(err)
+	}
+	newPayload, ok := payloadTypes[genericMessage.Type]
+	if !ok {
+		return nil, pkgerrors.NewUnsupportedMessageTypeError(string(genericMessage.Type))
+	}
+	payload := newPayload()
+	// payloads without fields may be sent without a payload
+	if len(genericMessage.Payload) == 0 {
+		return payload, nil
+	}
+	if err := json.Unmarshal(genericMessage.Payload, payload); err != nil {
+		return nil, pkgerrors.NewUnmarshalError(err)
+	}
+	return payload, nil
+}
+
+func Marshal(payload Payload) ([]byte, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, pkgerrors.NewMarshalError(err)
+	}
+	bytes, err := json.Marshal(&GenericMessage{Type: payload.Type(), Payload: payloadBytes})
+	if err != nil {
+		return nil, pkgerrors.NewMarshalError(err)
+	}
+	return bytes, nil
+}
+
+// a binary message is a chunk of a file: the transfer id and the offset
+// of the data in the file, both big endian, then the data
+const chunkHeaderSize = 12
+
+// ChunkSize is the most data in a chunk, larger messages don't get through every browser
+const ChunkSize = 16 * 1024
+
+func EncodeChunk(id uint32, offset int64, data []byte) []byte {
+	chunk := make([]byte, chunkHeaderSize+len(data))
+	binary.BigEndian.PutUint32(chunk[0:4], id)
+	binary.BigEndian.PutUint64(chunk[4:12], uint64(offset))
+	copy(chunk[chunkHeaderSize:], data)
+	return chunk
+}
+
+func DecodeChunk(chunk []byte) (id uint32, offset int64, data []byte, err error) {
+	if len(chunk) < chunkHeaderSize {
+		return 0, 0, nil, pkgerrors.NewUnmarshalError(fmt.Errorf("chunk of %d bytes has no header", len(chunk)))
+	}
+	id = binary.BigEndian.Uint32(chunk[0:4])
+	offset = int64(binary.BigEndian.Uint64(chunk[4:12]))
+	return id, offset, chunk[chunkHeaderSize:], nil
+}
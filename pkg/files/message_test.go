@@ -0,0 +1,45 @@
+package files
+
+import (
+	"testing"
+
+	pkgerrors "github.com/benu-cloud/benu-errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type unmarshalTest struct {
+	message  string
+	expected Payload
+	err      error
+}
+
+func TestUnmarshal(t *testing.T) {
+	unmarshalTests := []unmarshalTest{
+		{`{"type":"upload","payload":{"id":1,"name":"a.txt","size":3,"sha256":"ab"}}`, &UploadPayload{ID: 1, Name: "a.txt", Size: 3, SHA256: "ab"}, nil},
+		{`{"type":"download","payload":{"id":2,"name":"a.txt","offset":5}}`, &DownloadPayload{ID: 2, Name: "a.txt", Offset: 5}, nil},
+		{`{"type":"cancel","payload":{"id":3}}`, &CancelPayload{ID: 3}, nil},
+		{`{"type":"nope"}`, nil, &pkgerrors.UnsupportedMessageTypeError{}},
+		{`not json`, nil, &pkgerrors.UnmarshalError{}},
+	}
+	for _, test := range unmarshalTests {
+		payload, err := Unmarshal([]byte(test.message))
+		if test.err != nil {
+			assert.IsType(t, test.err, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, payload)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	chunk := EncodeChunk(7, 1<<33, []byte("data"))
+	assert.Len(t, chunk, chunkHeaderSize+4)
+	id, offset, data, err := DecodeChunk(chunk)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), id)
+	assert.Equal(t, int64(1<<33), offset)
+	assert.Equal(t, []byte("data"), data)
+	_, _, _, err = DecodeChunk(chunk[:chunkHeaderSize-1])
+	assert.Error(t, err)
+}
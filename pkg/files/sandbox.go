@@ -0,0 +1,124 @@
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// uploads in progress are kept here, inside the sandbox, so they count towards the quota
+const partialDir = ".partial"
+
+// Sandbox is the directory uploads land in and downloads are served from
+type Sandbox struct {
+	dir string
+	// zero means no quota
+	quota int64
+}
+
+// NewSandbox creates dir if needed. Files in it may take up at most quota bytes, 0 means no quota
+func NewSandbox(dir string, quota int64) (*Sandbox, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, partialDir), 0o755); err != nil {
+		return nil, err
+	}
+	return &Sandbox{
+		dir:   dir,
+		quota: quota,
+	}, nil
+}
+
+// Path resolves a client supplied name, separated by '/', to a path inside the sandbox
+func (s *Sandbox) Path(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", NewSandboxPathError(name)
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" || strings.HasPrefix(name, "/") {
+		return "", NewSandboxPathError(name)
+	}
+	first := strings.SplitN(filepath.ToSlash(clean), "/", 2)[0]
+	if first == ".." || first == "." || first == partialDir {
+		return "", NewSandboxPathError(name)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// Usage is the bytes taken by the files in the sandbox, partial uploads included
+func (s *Sandbox) Usage() (int64, error) {
+	var usage int64
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usage += info.Size()
+		return nil
+	})
+	return usage, err
+}
+
+// Reserve checks that size more bytes fit in the quota, next to the reserved bytes
+// promised to uploads in progress that aren't written yet
+func (s *Sandbox) Reserve(size int64, reserved int64) error {
+	if s.quota == 0 {
+		return nil
+	}
+	usage, err := s.Usage()
+	if err != nil {
+		return err
+	}
+	free := s.quota - usage - reserved
+	if free < 0 {
+		free = 0
+	}
+	if size > free {
+		return NewQuotaExceededError(size, free)
+	}
+	return nil
+}
+
+// partialPath is where an upload is kept until it's complete, named after its
+// checksum and size so an interrupted upload of the same file picks up from it
+func (s *Sandbox) partialPath(sum string, size int64) string {
+	return filepath.Join(s.dir, partialDir, fmt.Sprintf("%s-%d", sum, size))
+}
+
+// finish moves a complete upload to name, or to "name (n)" if that's taken,
+// and returns the name it was saved under
+func (s *Sandbox) finish(partial string, name string) (string, error) {
+	path, err := s.Path(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; ; n++ {
+		// a hard link fails if the target exists, unlike a rename
+		err := os.Link(partial, path)
+		if err == nil {
+			return name, os.Remove(partial)
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if path, err = s.Path(name); err != nil {
+			return "", err
+		}
+	}
+}
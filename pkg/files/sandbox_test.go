@@ -0,0 +1,77 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pathTest struct {
+	name     string
+	expected string
+	err      bool
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSandbox(dir, 0)
+	assert.NoError(t, err)
+	pathTests := []pathTest{
+		{"a.txt", "a.txt", false},
+		{"dir/a.txt", filepath.Join("dir", "a.txt"), false},
+		{"dir/../a.txt", "a.txt", false},
+		{"../a.txt", "", true},
+		{"dir/../../a.txt", "", true},
+		{"/etc/passwd", "", true},
+		{".", "", true},
+		{"", "", true},
+		{".partial/x", "", true},
+		{"a\x00.txt", "", true},
+	}
+	for _, test := range pathTests {
+		path, err := s.Path(test.name)
+		if test.err {
+			assert.IsType(t, &SandboxPathError{}, err, test.name)
+			continue
+		}
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, filepath.Join(dir, test.expected), path)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSandbox(dir, 100)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Reserve(100, 0))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a"), make([]byte, 60), 0o644))
+	// partial uploads count too
+	assert.NoError(t, os.WriteFile(s.partialPath("00", 30), make([]byte, 30), 0o644))
+	assert.NoError(t, s.Reserve(10, 0))
+	assert.IsType(t, &QuotaExceededError{}, s.Reserve(11, 0))
+	// bytes promised to other uploads
+	assert.NoError(t, s.Reserve(4, 6))
+	assert.IsType(t, &QuotaExceededError{}, s.Reserve(5, 6))
+	unlimited, err := NewSandbox(dir, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, unlimited.Reserve(1<<40, 1<<40))
+}
+
+func TestFinish(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSandbox(dir, 0)
+	assert.NoError(t, err)
+	for _, expected := range []string{"a.txt", "a (1).txt", "a (2).txt"} {
+		partial := s.partialPath("00", 1)
+		assert.NoError(t, os.WriteFile(partial, []byte(expected), 0o644))
+		name, err := s.finish(partial, "a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, name)
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+		assert.NoFileExists(t, partial)
+	}
+}